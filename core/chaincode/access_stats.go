@@ -0,0 +1,159 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// AccessStats describes the state accessed by an execution, including that
+// of the chaincodes it invoked.
+type AccessStats struct {
+	// Reads is the number of keys read, including metadata and hashes.
+	Reads int
+	// ReadBytes is the size of the values read.
+	ReadBytes int
+	// Writes is the number of keys written, deleted or purged, including
+	// metadata.
+	Writes int
+	// WriteBytes is the size of the values written.
+	WriteBytes int
+	// Queries is the number of range and rich queries started.
+	Queries int
+}
+
+// accessCountingSimulator is a TxSimulator which counts the state accessed
+// through it by the chaincode.
+type accessCountingSimulator struct {
+	ledger.TxSimulator
+
+	mutex sync.Mutex
+	stats AccessStats
+}
+
+func (s *accessCountingSimulator) read(value []byte) {
+	s.mutex.Lock()
+	s.stats.Reads++
+	s.stats.ReadBytes += len(value)
+	s.mutex.Unlock()
+}
+
+func (s *accessCountingSimulator) write(value []byte) {
+	s.mutex.Lock()
+	s.stats.Writes++
+	s.stats.WriteBytes += len(value)
+	s.mutex.Unlock()
+}
+
+func (s *accessCountingSimulator) query() {
+	s.mutex.Lock()
+	s.stats.Queries++
+	s.mutex.Unlock()
+}
+
+// Stats returns the state accessed so far.
+func (s *accessCountingSimulator) Stats() AccessStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+func (s *accessCountingSimulator) GetState(namespace, key string) ([]byte, error) {
+	value, err := s.TxSimulator.GetState(namespace, key)
+	s.read(value)
+	return value, err
+}
+
+func (s *accessCountingSimulator) GetStateMetadata(namespace, key string) (map[string][]byte, error) {
+	s.read(nil)
+	return s.TxSimulator.GetStateMetadata(namespace, key)
+}
+
+func (s *accessCountingSimulator) GetPrivateData(namespace, collection, key string) ([]byte, error) {
+	value, err := s.TxSimulator.GetPrivateData(namespace, collection, key)
+	s.read(value)
+	return value, err
+}
+
+func (s *accessCountingSimulator) GetPrivateDataHash(namespace, collection, key string) ([]byte, error) {
+	value, err := s.TxSimulator.GetPrivateDataHash(namespace, collection, key)
+	s.read(value)
+	return value, err
+}
+
+func (s *accessCountingSimulator) GetPrivateDataMetadata(namespace, collection, key string) (map[string][]byte, error) {
+	s.read(nil)
+	return s.TxSimulator.GetPrivateDataMetadata(namespace, collection, key)
+}
+
+func (s *accessCountingSimulator) GetStateRangeScanIterator(namespace, startKey, endKey string) (commonledger.ResultsIterator, error) {
+	s.query()
+	return s.TxSimulator.GetStateRangeScanIterator(namespace, startKey, endKey)
+}
+
+func (s *accessCountingSimulator) GetStateRangeScanIteratorWithPagination(namespace, startKey, endKey string, pageSize int32) (ledger.QueryResultsIterator, error) {
+	s.query()
+	return s.TxSimulator.GetStateRangeScanIteratorWithPagination(namespace, startKey, endKey, pageSize)
+}
+
+func (s *accessCountingSimulator) GetPrivateDataRangeScanIterator(namespace, collection, startKey, endKey string) (commonledger.ResultsIterator, error) {
+	s.query()
+	return s.TxSimulator.GetPrivateDataRangeScanIterator(namespace, collection, startKey, endKey)
+}
+
+func (s *accessCountingSimulator) ExecuteQuery(namespace, query string) (commonledger.ResultsIterator, error) {
+	s.query()
+	return s.TxSimulator.ExecuteQuery(namespace, query)
+}
+
+func (s *accessCountingSimulator) ExecuteQueryWithPagination(namespace, query, bookmark string, pageSize int32) (ledger.QueryResultsIterator, error) {
+	s.query()
+	return s.TxSimulator.ExecuteQueryWithPagination(namespace, query, bookmark, pageSize)
+}
+
+func (s *accessCountingSimulator) ExecuteQueryOnPrivateData(namespace, collection, query string) (commonledger.ResultsIterator, error) {
+	s.query()
+	return s.TxSimulator.ExecuteQueryOnPrivateData(namespace, collection, query)
+}
+
+func (s *accessCountingSimulator) SetState(namespace, key string, value []byte) error {
+	s.write(value)
+	return s.TxSimulator.SetState(namespace, key, value)
+}
+
+func (s *accessCountingSimulator) SetStateMetadata(namespace, key string, metadata map[string][]byte) error {
+	s.write(nil)
+	return s.TxSimulator.SetStateMetadata(namespace, key, metadata)
+}
+
+func (s *accessCountingSimulator) DeleteState(namespace, key string) error {
+	s.write(nil)
+	return s.TxSimulator.DeleteState(namespace, key)
+}
+
+func (s *accessCountingSimulator) SetPrivateData(namespace, collection, key string, value []byte) error {
+	s.write(value)
+	return s.TxSimulator.SetPrivateData(namespace, collection, key, value)
+}
+
+func (s *accessCountingSimulator) SetPrivateDataMetadata(namespace, collection, key string, metadata map[string][]byte) error {
+	s.write(nil)
+	return s.TxSimulator.SetPrivateDataMetadata(namespace, collection, key, metadata)
+}
+
+func (s *accessCountingSimulator) DeletePrivateData(namespace, collection, key string) error {
+	s.write(nil)
+	return s.TxSimulator.DeletePrivateData(namespace, collection, key)
+}
+
+func (s *accessCountingSimulator) PurgePrivateData(namespace, collection, key string) error {
+	s.write(nil)
+	return s.TxSimulator.PurgePrivateData(namespace, collection, key)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+)
+
+// stopRequests tracks the chaincodes which the peer has asked to stop, so that
+// the closing of their streams can be told apart from an unexpected exit. Each
+// request records when it was made, so that a request left behind by a stop
+// which closed no stream is not mistaken for a stop of a later registration.
+type stopRequests struct {
+	mutex sync.Mutex
+	ccids map[string]time.Time
+}
+
+func (s *stopRequests) add(ccid string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ccids == nil {
+		s.ccids = map[string]time.Time{}
+	}
+	s.ccids[ccid] = time.Now()
+}
+
+// remove clears the stop request of the chaincode.
+func (s *stopRequests) remove(ccid string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.ccids, ccid)
+}
+
+// take reports whether a stop of the chaincode was requested no earlier than
+// since and clears any request.
+func (s *stopRequests) take(ccid string, since time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	requested, ok := s.ccids[ccid]
+	delete(s.ccids, ccid)
+	return ok && !requested.Before(since)
+}
+
+// stopRequested reports whether the chaincode was stopped by the peer since
+// the handler registered. Only a RuntimeLauncher tracks stops; chaincodes
+// launched by other launchers are never considered stopped by the peer.
+func (cs *ChaincodeSupport) stopRequested(ccid string, h *Handler) bool {
+	if rl, ok := cs.Launcher.(*RuntimeLauncher); ok {
+		return rl.stops.take(ccid, h.registeredSince())
+	}
+	return false
+}
+
+// restartCounts counts the automatic restarts of each chaincode.
+type restartCounts struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+// reset forgets the restarts of the chaincode.
+func (r *restartCounts) reset(ccid string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.counts, ccid)
+}
+
+// increment counts a restart of the chaincode unless it has already been
+// restarted max times. It reports whether the restart may proceed.
+func (r *restartCounts) increment(ccid string, max int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.counts == nil {
+		r.counts = map[string]int{}
+	}
+	if r.counts[ccid] >= max {
+		return false
+	}
+	r.counts[ccid]++
+	return true
+}
+
+// restartAfterExit relaunches the chaincode served by the handler when
+// AutoRestart is set and its stream closed without the peer stopping it.
+// Chaincodes which never became ready, handlers which were replaced, and
+// system chaincodes are not restarted. A chaincode which ran for at least
+// AutoRestartReset before exiting has its restart count reset, so that
+// MaxAutoRestarts limits restarts in quick succession rather than over the
+// life of the peer.
+func (cs *ChaincodeSupport) restartAfterExit(h *Handler) {
+	ccid := h.chaincodeID
+	if ccid == "" || h.isReplaced() {
+		return
+	}
+	if cs.stopRequested(ccid, h) || h.isStopping() || !cs.AutoRestart || h.State() != Ready || cs.isSysCCID(ccid) {
+		return
+	}
+	if cs.AutoRestartReset > 0 && time.Since(h.registeredSince()) >= cs.AutoRestartReset {
+		cs.restarts.reset(ccid)
+	}
+	if !cs.restarts.increment(ccid, cs.MaxAutoRestarts) {
+		chaincodeLogger.Warningf("chaincode %s exited unexpectedly and has reached the limit of %d automatic restarts", ccid, cs.MaxAutoRestarts)
+		return
+	}
+
+	chaincodeLogger.Warningf("chaincode %s exited unexpectedly, restarting it", ccid)
+	go func() {
+		if _, err := cs.launch(nil, "", ccid, false, chaincodeLogger); err != nil {
+			chaincodeLogger.Warningf("failed to restart chaincode %s: %s", ccid, err)
+		}
+	}()
+}
@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrEmptyChaincodeError is returned when a chaincode fails a transaction
+// without saying why, by returning an ERROR message with an empty payload.
+var ErrEmptyChaincodeError = errors.New("chaincode returned an error with no message")
+
+// ChaincodeError is a structured error returned by a chaincode as the JSON
+// payload of an ERROR message.
+type ChaincodeError struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+func (e *ChaincodeError) Error() string {
+	if e.Details == "" {
+		return fmt.Sprintf("chaincode error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("chaincode error %d: %s (%s)", e.Code, e.Message, e.Details)
+}
+
+// parseChaincodeError attempts to interpret an ERROR payload as a
+// ChaincodeError. It returns nil if the payload is not a structured error.
+func parseChaincodeError(payload []byte) *ChaincodeError {
+	ce := &ChaincodeError{}
+	if err := json.Unmarshal(payload, ce); err != nil {
+		return nil
+	}
+	if ce.Message == "" {
+		return nil
+	}
+	return ce
+}
+
+// ChaincodePanicError is returned when the peer panics while executing a
+// transaction against a chaincode. Only the transaction being executed fails.
+type ChaincodePanicError struct {
+	Chaincode string
+	Value     interface{}
+	Stack     []byte
+}
+
+func (e *ChaincodePanicError) Error() string {
+	return fmt.Sprintf("panic while executing chaincode %s: %v", e.Chaincode, e.Value)
+}
@@ -7,17 +7,42 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-lib-go/common/flogging"
+	floggingmock "github.com/hyperledger/fabric-lib-go/common/flogging/mock"
+	"github.com/hyperledger/fabric-lib-go/common/metrics/metricsfakes"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
 	"github.com/hyperledger/fabric/core/chaincode/mock"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
 	"github.com/hyperledger/fabric/core/common/ccprovider"
+	"github.com/hyperledger/fabric/core/container"
+	"github.com/hyperledger/fabric/core/container/ccintf"
+	containermock "github.com/hyperledger/fabric/core/container/mock"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/scc"
+	"github.com/hyperledger/fabric/protoutil"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+	grpcpeer "google.golang.org/grpc/peer"
 )
 
 var _ = Describe("CheckInvocation", func() {
@@ -153,3 +178,4104 @@ var _ = Describe("CheckInvocation", func() {
 		})
 	})
 })
+
+var _ = Describe("Invocation", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		handler          *chaincode.Handler
+		invokeInfo       *lifecycle.ChaincodeEndorsementInfo
+
+		fakeLifecycle  *mock.Lifecycle
+		fakeSimulator  *mock.TxSimulator
+		fakeChatStream *mock.ChaincodeStream
+
+		fakeResponseSize *metricsfakes.Histogram
+
+		txParams *ccprovider.TransactionParams
+		input    *pb.ChaincodeInput
+		response *pb.ChaincodeMessage
+	)
+
+	BeforeEach(func() {
+		fakeLifecycle = &mock.Lifecycle{}
+		fakeSimulator = &mock.TxSimulator{}
+
+		invokeInfo = &lifecycle.ChaincodeEndorsementInfo{
+			Version:     "definition-version",
+			ChaincodeID: "definition-ccid",
+		}
+		fakeLifecycle.ChaincodeEndorsementInfoReturns(invokeInfo, nil)
+
+		response = &pb.ChaincodeMessage{
+			Type:    pb.ChaincodeMessage_COMPLETED,
+			Payload: []byte("response-payload"),
+		}
+
+		fakeExecuteTimeouts := &metricsfakes.Counter{}
+		fakeExecuteTimeouts.WithReturns(fakeExecuteTimeouts)
+
+		handler = &chaincode.Handler{
+			TXContexts:   chaincode.NewTransactionContexts(),
+			LedgerGetter: &mock.LedgerGetter{},
+			Metrics: &chaincode.HandlerMetrics{
+				ExecuteTimeouts: fakeExecuteTimeouts,
+			},
+		}
+		fakeChatStream = &mock.ChaincodeStream{}
+		fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+			resp := proto.Clone(response).(*pb.ChaincodeMessage)
+			resp.Txid = msg.Txid
+			resp.ChannelId = msg.ChannelId
+			go handler.Notify(resp)
+			return nil
+		}
+		chaincode.SetHandlerChatStream(handler, fakeChatStream)
+		chaincode.SetHandlerChaincodeID(handler, "definition-ccid")
+
+		handlerRegistry := chaincode.NewHandlerRegistry(true)
+		Expect(handlerRegistry.Register(handler)).To(Succeed())
+
+		txParams = &ccprovider.TransactionParams{
+			TxID:        "tx-id",
+			ChannelID:   "channel-id",
+			TXSimulator: fakeSimulator,
+		}
+		input = &pb.ChaincodeInput{Args: [][]byte{[]byte("arg")}}
+
+		fakeResponseSize = &metricsfakes.Histogram{}
+		fakeResponseSize.WithReturns(fakeResponseSize)
+
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			ExecuteTimeout: time.Second,
+			HandlerMetrics: &chaincode.HandlerMetrics{
+				ResponseSize: fakeResponseSize,
+			},
+			HandlerRegistry: handlerRegistry,
+			Lifecycle:       fakeLifecycle,
+		}
+	})
+
+	Describe("Invoke", func() {
+		It("sends a transaction and returns the raw response message", func() {
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp).To(Equal(&pb.ChaincodeMessage{
+				Type:      pb.ChaincodeMessage_COMPLETED,
+				Payload:   []byte("response-payload"),
+				Txid:      "tx-id",
+				ChannelId: "channel-id",
+			}))
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			msg := fakeChatStream.SendArgsForCall(0)
+			Expect(msg.Type).To(Equal(pb.ChaincodeMessage_TRANSACTION))
+			Expect(msg.Txid).To(Equal("tx-id"))
+			Expect(msg.ChannelId).To(Equal("channel-id"))
+		})
+
+		It("executes when the required version matches the resolved version", func() {
+			txParams.RequiredVersion = "definition-version"
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+		})
+
+		It("rejects the invocation when the required version does not match", func() {
+			txParams.RequiredVersion = "previous-version"
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrVersionMismatch))
+			Expect(err).To(MatchError("invocation of chaincode test-chaincode-name requires version previous-version but resolved to definition-version: chaincode version mismatch"))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+
+		Context("when a simulator is supplied", func() {
+			var suppliedSimulator *mock.TxSimulator
+
+			BeforeEach(func() {
+				suppliedSimulator = &mock.TxSimulator{}
+				suppliedSimulator.GetStateReturns([]byte("what-if"), nil)
+
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					txctx := handler.TXContexts.Get(msg.ChannelId, msg.Txid)
+					getState, err := proto.Marshal(&pb.GetState{Key: "read-key"})
+					Expect(err).NotTo(HaveOccurred())
+					resp, err := handler.HandleGetState(&pb.ChaincodeMessage{Payload: getState, Txid: msg.Txid}, txctx)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(resp.Payload).To(Equal([]byte("what-if")))
+
+					resp = proto.Clone(response).(*pb.ChaincodeMessage)
+					resp.Txid = msg.Txid
+					resp.ChannelId = msg.ChannelId
+					go handler.Notify(resp)
+					return nil
+				}
+			})
+
+			It("reads state through the supplied simulator", func() {
+				_, err := chaincodeSupport.InvokeWithSimulator(txParams, suppliedSimulator, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(suppliedSimulator.GetStateCallCount()).To(Equal(1))
+				namespace, key := suppliedSimulator.GetStateArgsForCall(0)
+				Expect(namespace).To(Equal("test-chaincode-name"))
+				Expect(key).To(Equal("read-key"))
+				Expect(fakeSimulator.GetStateCallCount()).To(Equal(0))
+				Expect(txParams.TXSimulator).To(BeIdenticalTo(fakeSimulator))
+			})
+
+			It("rejects a nil simulator", func() {
+				_, err := chaincodeSupport.InvokeWithSimulator(txParams, nil, "test-chaincode-name", input)
+				Expect(err).To(MatchError("invocation of chaincode test-chaincode-name requires a simulator"))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+
+		It("makes the signed proposal available in the transaction context during execution", func() {
+			signedProp := &pb.SignedProposal{ProposalBytes: []byte("proposal-bytes"), Signature: []byte("signature")}
+			txParams.SignedProp = signedProp
+
+			var txContextSignedProp *pb.SignedProposal
+			respond := fakeChatStream.SendStub
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				txContextSignedProp = handler.TXContexts.Get(msg.ChannelId, msg.Txid).SignedProp
+				return respond(msg)
+			}
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(txContextSignedProp).To(BeIdenticalTo(signedProp))
+		})
+
+		It("leaves the signed proposal of the transaction context nil when none is provided", func() {
+			var txContextSignedProp *pb.SignedProposal
+			respond := fakeChatStream.SendStub
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				txContextSignedProp = handler.TXContexts.Get(msg.ChannelId, msg.Txid).SignedProp
+				return respond(msg)
+			}
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(txContextSignedProp).To(BeNil())
+		})
+
+		Context("when the chaincode returns an error", func() {
+			BeforeEach(func() {
+				response = &pb.ChaincodeMessage{
+					Type:    pb.ChaincodeMessage_ERROR,
+					Payload: []byte("chaincode-error"),
+				}
+			})
+
+			It("returns the raw error message without interpreting it", func() {
+				resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Type).To(Equal(pb.ChaincodeMessage_ERROR))
+				Expect(resp.Payload).To(Equal([]byte("chaincode-error")))
+			})
+		})
+
+		Context("when the chaincode does not respond to the first attempt", func() {
+			BeforeEach(func() {
+				chaincodeSupport.ExecuteTimeout = 100 * time.Millisecond
+				chaincodeSupport.RetryOnTimeout = true
+				respond := fakeChatStream.SendStub
+				stream := fakeChatStream
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					if stream.SendCallCount() == 1 {
+						return nil
+					}
+					return respond(msg)
+				}
+			})
+
+			It("retries an idempotent invocation once", func() {
+				txParams.IsIdempotent = true
+				resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+
+				Expect(fakeChatStream.SendCallCount()).To(Equal(2))
+				first, second := fakeChatStream.SendArgsForCall(0), fakeChatStream.SendArgsForCall(1)
+				Expect(first).NotTo(BeIdenticalTo(second))
+				Expect(proto.Equal(first, second)).To(BeTrue())
+			})
+
+			It("does not retry a write transaction", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("timeout expired while executing transaction"))
+				Consistently(fakeChatStream.SendCallCount).Should(Equal(1))
+			})
+
+			Context("when retries are not enabled", func() {
+				BeforeEach(func() {
+					chaincodeSupport.RetryOnTimeout = false
+				})
+
+				It("does not retry an idempotent invocation", func() {
+					txParams.IsIdempotent = true
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).To(MatchError("timeout expired while executing transaction"))
+					Consistently(fakeChatStream.SendCallCount).Should(Equal(1))
+				})
+			})
+		})
+
+		Context("when the chaincode does not respond", func() {
+			BeforeEach(func() {
+				chaincodeSupport.ExecuteTimeout = 50 * time.Millisecond
+				fakeChatStream.SendStub = nil
+			})
+
+			It("reports a terse error by default", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("timeout expired while executing transaction"))
+			})
+
+			Context("when verbose errors are configured", func() {
+				BeforeEach(func() {
+					chaincodeSupport.ErrorFormat = chaincode.VerboseErrors
+				})
+
+				It("identifies the chaincode, transaction, and elapsed time", func() {
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(MatchRegexp(`^chaincode test-chaincode-name timed out executing transaction tx-id after \d+ms: timeout expired while executing transaction$`))
+				})
+			})
+		})
+
+		Context("when the invocation does not specify a channel", func() {
+			BeforeEach(func() {
+				txParams.ChannelID = ""
+			})
+
+			It("returns ErrMissingChannel without invoking the chaincode", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrMissingChannel))
+				Expect(err).To(MatchError("cannot invoke chaincode test-chaincode-name: no channel specified and no default channel configured"))
+				Expect(fakeLifecycle.ChaincodeEndorsementInfoCallCount()).To(Equal(0))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+
+			Context("when a default channel is configured", func() {
+				BeforeEach(func() {
+					chaincodeSupport.DefaultChannel = "default-channel"
+				})
+
+				It("invokes the chaincode on the default channel", func() {
+					resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(resp.ChannelId).To(Equal("default-channel"))
+
+					channelID, _, _ := fakeLifecycle.ChaincodeEndorsementInfoArgsForCall(0)
+					Expect(channelID).To(Equal("default-channel"))
+					Expect(fakeChatStream.SendArgsForCall(0).ChannelId).To(Equal("default-channel"))
+				})
+
+				It("does not modify the caller's transaction parameters", func() {
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(txParams.ChannelID).To(BeEmpty())
+				})
+			})
+
+			Context("when the chaincode is a system chaincode", func() {
+				BeforeEach(func() {
+					chaincodeSupport.BuiltinSCCs = scc.BuiltinSCCs{"test-chaincode-name": struct{}{}}
+					chaincodeSupport.DefaultChannel = "default-channel"
+				})
+
+				It("invokes the chaincode without a channel", func() {
+					resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(resp.ChannelId).To(BeEmpty())
+					Expect(fakeChatStream.SendArgsForCall(0).ChannelId).To(BeEmpty())
+				})
+
+				It("skips the channel specific steps of the execution", func() {
+					fakeInFlight := &metricsfakes.Gauge{}
+					fakeInFlight.WithReturns(fakeInFlight)
+					chaincodeSupport.ChannelLimiter = chaincode.NewChannelLimiter(map[string]int{"channel-id": 1}, chaincode.RejectAtChannelLimit, fakeInFlight, &metricsfakes.Counter{})
+					fakeLedgerGetter := &mock.LedgerGetter{}
+					handler.LedgerGetter = fakeLedgerGetter
+
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeInFlight.WithCallCount()).To(Equal(0))
+					Expect(fakeLedgerGetter.GetLedgerCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the invocation is an init", func() {
+			BeforeEach(func() {
+				invokeInfo.EnforceInit = true
+				input.IsInit = true
+			})
+
+			It("sends an init and returns the raw response message", func() {
+				resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+				Expect(resp.Txid).To(Equal("tx-id"))
+
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+				msg := fakeChatStream.SendArgsForCall(0)
+				Expect(msg.Type).To(Equal(pb.ChaincodeMessage_INIT))
+			})
+		})
+	})
+
+	Describe("lifecycle lookup timeout", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			chaincodeSupport.LifecycleLookupTimeout = 100 * time.Millisecond
+			release = make(chan struct{})
+			blocked, info := release, invokeInfo // shadow to avoid race
+			fakeLifecycle.ChaincodeEndorsementInfoStub = func(string, string, ledger.SimpleQueryExecutor) (*lifecycle.ChaincodeEndorsementInfo, error) {
+				<-blocked
+				return info, nil
+			}
+		})
+
+		AfterEach(func() {
+			close(release)
+		})
+
+		It("fails the invocation when the lookup does not complete in time", func() {
+			start := time.Now()
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrLifecycleTimeout))
+			Expect(err).To(MatchError("invalid invocation: [channel channel-id] failed to get chaincode container info for test-chaincode-name: lookup of chaincode test-chaincode-name on channel channel-id did not complete within 100ms: lifecycle lookup timed out"))
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+
+		It("stops the abandoned lookup from reading through the simulator", func() {
+			lookupErr := make(chan error, 1)
+			blocked, info := release, invokeInfo // shadow to avoid race
+			fakeLifecycle.ChaincodeEndorsementInfoStub = func(_, _ string, qe ledger.SimpleQueryExecutor) (*lifecycle.ChaincodeEndorsementInfo, error) {
+				<-blocked
+				_, err := qe.GetState("namespace", "key")
+				lookupErr <- err
+				return info, nil
+			}
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrLifecycleTimeout))
+
+			release <- struct{}{}
+			Eventually(lookupErr).Should(Receive(Equal(chaincode.ErrLifecycleTimeout)))
+			Expect(fakeSimulator.GetStateCallCount()).To(Equal(0))
+		})
+
+		It("invokes the chaincode when the lookup completes in time", func() {
+			lookupDone := release
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				lookupDone <- struct{}{}
+			}()
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("invocation guard", func() {
+		var errForbidden error
+
+		BeforeEach(func() {
+			errForbidden = errors.New("function forbidden")
+			chaincodeSupport.InvocationGuard = func(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) error {
+				if string(input.Args[0]) == "forbidden" {
+					return errForbidden
+				}
+				return nil
+			}
+		})
+
+		It("allows invocations the guard accepts", func() {
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+		})
+
+		It("rejects invocations the guard rejects before launching the chaincode", func() {
+			input.Args = [][]byte{[]byte("forbidden")}
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(errForbidden))
+			Expect(err).To(MatchError("invocation of chaincode test-chaincode-name rejected: function forbidden"))
+			Expect(fakeLifecycle.ChaincodeEndorsementInfoCallCount()).To(Equal(0))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+
+		It("rejects pre-built messages the guard rejects", func() {
+			payload, err := proto.Marshal(&pb.ChaincodeInput{Args: [][]byte{[]byte("forbidden")}})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", &pb.ChaincodeMessage{
+				Type:      pb.ChaincodeMessage_TRANSACTION,
+				Payload:   payload,
+				Txid:      "tx-id",
+				ChannelId: "channel-id",
+			})
+			Expect(errors.Cause(err)).To(Equal(errForbidden))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("middleware", func() {
+		var calls []string
+
+		BeforeEach(func() {
+			calls = nil
+			respond := fakeChatStream.SendStub
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				calls = append(calls, "invoke")
+				return respond(msg)
+			}
+
+			for _, name := range []string{"first", "second"} {
+				name := name
+				chaincodeSupport.Use(func(next chaincode.InvokeFunc) chaincode.InvokeFunc {
+					return func(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, error) {
+						calls = append(calls, name+"-before")
+						resp, err := next(txParams, chaincodeName, input)
+						calls = append(calls, name+"-after")
+						return resp, err
+					}
+				})
+			}
+		})
+
+		It("runs the middlewares in registration order around the invocation", func() {
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Payload).To(Equal([]byte("response-payload")))
+			Expect(calls).To(Equal([]string{"first-before", "second-before", "invoke", "second-after", "first-after"}))
+		})
+
+		It("runs the middlewares around executions", func() {
+			chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(calls).To(Equal([]string{"first-before", "second-before", "invoke", "second-after", "first-after"}))
+		})
+
+		Context("when a middleware fails the invocation", func() {
+			BeforeEach(func() {
+				chaincodeSupport.Use(func(next chaincode.InvokeFunc) chaincode.InvokeFunc {
+					return func(*ccprovider.TransactionParams, string, *pb.ChaincodeInput) (*pb.ChaincodeMessage, error) {
+						return nil, errors.New("rate-limited")
+					}
+				})
+			})
+
+			It("does not invoke the chaincode", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("rate-limited"))
+				Expect(calls).To(Equal([]string{"first-before", "second-before", "second-after", "first-after"}))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("quiesce", func() {
+		BeforeEach(func() {
+			chaincodeSupport.Quiesce("test-chaincode-name")
+		})
+
+		It("rejects new invocations of the chaincode", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrQuiesced))
+			Expect(err).To(MatchError("cannot execute chaincode test-chaincode-name: chaincode is quiesced"))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+
+		It("does not launch a quiesced chaincode which is not running", func() {
+			fakeRuntime := &mock.Runtime{}
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:  fakeRuntime,
+				Registry: chaincodeSupport.HandlerRegistry,
+			}
+			Expect(chaincodeSupport.HandlerRegistry.Deregister("definition-ccid")).To(Succeed())
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrQuiesced))
+			Expect(fakeLifecycle.ChaincodeEndorsementInfoCallCount()).To(Equal(0))
+			Expect(fakeRuntime.BuildCallCount()).To(Equal(0))
+			Expect(fakeRuntime.StartCallCount()).To(Equal(0))
+		})
+
+		It("does not affect other chaincodes", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "other-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("resumes invocations after unquiesce", func() {
+			chaincodeSupport.Unquiesce("test-chaincode-name")
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+		})
+	})
+
+	Describe("panic recovery", func() {
+		BeforeEach(func() {
+			handler.LedgerGetter.(*mock.LedgerGetter).GetLedgerStub = func(string) ledger.PeerLedger {
+				panic("ledger-panic")
+			}
+		})
+
+		It("fails the transaction with a ChaincodePanicError", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(MatchError("panic while executing chaincode test-chaincode-name: ledger-panic"))
+
+			perr, ok := errors.Cause(err).(*chaincode.ChaincodePanicError)
+			Expect(ok).To(BeTrue())
+			Expect(perr.Chaincode).To(Equal("test-chaincode-name"))
+			Expect(perr.Value).To(Equal("ledger-panic"))
+			Expect(string(perr.Stack)).To(ContainSubstring("getCollectionStore"))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+
+		It("reports the failure of the execution with verbose errors", func() {
+			chaincodeSupport.ErrorFormat = chaincode.VerboseErrors
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(MatchRegexp(`^chaincode test-chaincode-name failed executing transaction tx-id after [0-9.]+m?s: panic while executing chaincode test-chaincode-name: ledger-panic$`))
+		})
+
+		It("leaves the chaincode able to execute later transactions", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(HaveOccurred())
+
+			handler.LedgerGetter.(*mock.LedgerGetter).GetLedgerStub = nil
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+		})
+
+		Context("when the policy is to stop the chaincode", func() {
+			var fakeRuntime *mock.Runtime
+
+			BeforeEach(func() {
+				fakeRuntime = &mock.Runtime{}
+				chaincodeSupport.StopOnPanic = true
+				chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+					Runtime:  fakeRuntime,
+					Registry: chaincodeSupport.HandlerRegistry,
+				}
+			})
+
+			It("stops the chaincode", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(errors.Cause(err)).To(BeAssignableToTypeOf(&chaincode.ChaincodePanicError{}))
+				Eventually(fakeRuntime.StopCallCount).Should(Equal(1))
+				Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("definition-ccid"))
+			})
+		})
+	})
+
+	Describe("input size limits", func() {
+		BeforeEach(func() {
+			chaincodeSupport.MaxInitInputBytes = 64
+			chaincodeSupport.MaxInvokeInputBytes = 16
+			input = &pb.ChaincodeInput{Args: [][]byte{make([]byte, 32)}}
+		})
+
+		It("enforces the invoke limit on invokes", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrInputTooLarge))
+			Expect(err).To(MatchError("invoke input of 34 bytes for chaincode test-chaincode-name exceeds the limit of 16 bytes: chaincode input too large"))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+
+		It("enforces the init limit on inits", func() {
+			invokeInfo.EnforceInit = true
+			input.IsInit = true
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+
+			input.Args = [][]byte{make([]byte, 64)}
+			_, err = chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrInputTooLarge))
+			Expect(err).To(MatchError("init input of 68 bytes for chaincode test-chaincode-name exceeds the limit of 64 bytes: chaincode input too large"))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+		})
+
+		Context("when the chaincode is a system chaincode", func() {
+			BeforeEach(func() {
+				chaincodeSupport.BuiltinSCCs = scc.BuiltinSCCs{"test-chaincode-name": struct{}{}}
+			})
+
+			It("does not limit the input", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ExecuteMessage", func() {
+		var msg *pb.ChaincodeMessage
+
+		BeforeEach(func() {
+			payload, err := proto.Marshal(input)
+			Expect(err).NotTo(HaveOccurred())
+			msg = &pb.ChaincodeMessage{
+				Type:      pb.ChaincodeMessage_TRANSACTION,
+				Payload:   payload,
+				Txid:      "tx-id",
+				ChannelId: "channel-id",
+			}
+		})
+
+		It("sends the transaction message and returns the raw response message", func() {
+			resp, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp).To(Equal(&pb.ChaincodeMessage{
+				Type:      pb.ChaincodeMessage_COMPLETED,
+				Payload:   []byte("response-payload"),
+				Txid:      "tx-id",
+				ChannelId: "channel-id",
+			}))
+
+			Expect(fakeLifecycle.ChaincodeEndorsementInfoCallCount()).To(Equal(1))
+			channelID, chaincodeName, _ := fakeLifecycle.ChaincodeEndorsementInfoArgsForCall(0)
+			Expect(channelID).To(Equal("channel-id"))
+			Expect(chaincodeName).To(Equal("test-chaincode-name"))
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			sent := fakeChatStream.SendArgsForCall(0)
+			Expect(proto.Equal(sent, msg)).To(BeTrue())
+		})
+
+		Context("when the message is not a transaction or init", func() {
+			BeforeEach(func() {
+				msg.Type = pb.ChaincodeMessage_KEEPALIVE
+			})
+
+			It("returns an error without sending the message", func() {
+				_, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+				Expect(err).To(MatchError("cannot execute message of type KEEPALIVE"))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the message is for another transaction", func() {
+			BeforeEach(func() {
+				msg.Txid = "other-tx-id"
+			})
+
+			It("returns an error without sending the message", func() {
+				_, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+				Expect(err).To(MatchError("message for transaction other-tx-id on channel channel-id does not match transaction tx-id on channel channel-id"))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when payload checksums are enabled and the payload has no checksum", func() {
+			BeforeEach(func() {
+				chaincodeSupport.PayloadChecksums = true
+			})
+
+			It("returns an error without sending the message", func() {
+				_, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrPayloadChecksumMismatch))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when payload checksums are enabled and the input carries its checksum", func() {
+			BeforeEach(func() {
+				chaincodeSupport.PayloadChecksums = true
+				input := &pb.ChaincodeInput{}
+				Expect(proto.Unmarshal(msg.Payload, input)).To(Succeed())
+				payload, err := proto.Marshal(input)
+				Expect(err).NotTo(HaveOccurred())
+				sum := sha256.Sum256(payload)
+				input.Decorations = map[string][]byte{chaincode.PayloadChecksumDecoration: sum[:]}
+				msg.Payload, err = proto.Marshal(input)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("sends the message", func() {
+				_, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the payload is not a chaincode input", func() {
+			BeforeEach(func() {
+				msg.Payload = []byte("garbage")
+			})
+
+			It("returns an error without sending the message", func() {
+				_, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+				Expect(err).To(MatchError(ContainSubstring("failed to unmarshal chaincode input")))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when lifecycle returns an error", func() {
+			BeforeEach(func() {
+				fakeLifecycle.ChaincodeEndorsementInfoReturns(nil, errors.New("fake-lifecycle-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := chaincodeSupport.ExecuteMessage(txParams, "test-chaincode-name", msg)
+				Expect(err).To(MatchError("[channel channel-id] failed to get chaincode container info for test-chaincode-name: fake-lifecycle-error"))
+			})
+		})
+	})
+
+	Describe("message recording", func() {
+		var recorded []chaincode.RecordedExchange
+
+		BeforeEach(func() {
+			recorded = nil
+			chaincodeSupport.MessageRecorder = func(exchange chaincode.RecordedExchange) {
+				recorded = append(recorded, exchange)
+			}
+		})
+
+		It("records the transaction request and the completed response", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].ChaincodeName).To(Equal("test-chaincode-name"))
+			Expect(recorded[0].ChannelID).To(Equal("channel-id"))
+			Expect(recorded[0].TxID).To(Equal("tx-id"))
+			Expect(recorded[0].Request.Type).To(Equal(pb.ChaincodeMessage_TRANSACTION))
+			Expect(proto.Equal(recorded[0].Request, fakeChatStream.SendArgsForCall(0))).To(BeTrue())
+			Expect(recorded[0].Response.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+			Expect(recorded[0].Response.Payload).To(Equal([]byte("response-payload")))
+			Expect(recorded[0].Err).NotTo(HaveOccurred())
+		})
+
+		It("records the init request", func() {
+			invokeInfo.EnforceInit = true
+			input.IsInit = true
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].Request.Type).To(Equal(pb.ChaincodeMessage_INIT))
+			Expect(recorded[0].Response.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+		})
+
+		It("records failed executions", func() {
+			chaincodeSupport.ExecuteTimeout = 10 * time.Millisecond
+			fakeChatStream.SendStub = nil
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(HaveOccurred())
+
+			Expect(recorded).To(HaveLen(1))
+			Expect(recorded[0].Request.Type).To(Equal(pb.ChaincodeMessage_TRANSACTION))
+			Expect(recorded[0].Response).To(BeNil())
+			Expect(recorded[0].Err).To(MatchError(ContainSubstring("timeout expired while executing transaction")))
+		})
+
+		It("replays a recorded exchange in another transaction", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			txParams.TxID = "replay-tx-id"
+			resp, err := chaincodeSupport.Replay(txParams, recorded[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+			Expect(resp.Txid).To(Equal("replay-tx-id"))
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(2))
+			replayed := fakeChatStream.SendArgsForCall(1)
+			Expect(replayed.Txid).To(Equal("replay-tx-id"))
+			Expect(replayed.Payload).To(Equal(recorded[0].Request.Payload))
+			Expect(recorded[0].Request.Txid).To(Equal("tx-id"))
+		})
+
+		It("rejects an exchange without a request", func() {
+			_, err := chaincodeSupport.Replay(txParams, chaincode.RecordedExchange{ChaincodeName: "test-chaincode-name"})
+			Expect(err).To(MatchError("recorded exchange of chaincode test-chaincode-name has no request"))
+		})
+	})
+
+	Describe("log level override", func() {
+		var (
+			observer     *floggingmock.Observer
+			prevObserver flogging.Observer
+			prevSpec     string
+		)
+
+		// debugLines returns the chaincode debug lines logged for the
+		// transaction.
+		debugLines := func(txID string) []string {
+			var lines []string
+			for i := 0; i < observer.WriteEntryCallCount(); i++ {
+				entry, _ := observer.WriteEntryArgsForCall(i)
+				if entry.LoggerName == "chaincode" && entry.Level == zapcore.DebugLevel && strings.Contains(entry.Message, "["+txID+"]") {
+					lines = append(lines, entry.Message)
+				}
+			}
+			return lines
+		}
+
+		BeforeEach(func() {
+			prevSpec = flogging.Global.Spec()
+			flogging.ActivateSpec("chaincode=info")
+			observer = &floggingmock.Observer{}
+			prevObserver = flogging.SetObserver(observer)
+		})
+
+		AfterEach(func() {
+			flogging.SetObserver(prevObserver)
+			flogging.ActivateSpec(prevSpec)
+		})
+
+		It("logs debug lines for the invocation which requests them", func() {
+			txParams.ProposalDecorations = map[string][]byte{chaincode.LogLevelDecoration: []byte("debug")}
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(debugLines("tx-id")).To(ContainElement(ContainSubstring("executing TRANSACTION for chaincode test-chaincode-name on channel channel-id")))
+
+			otherTxParams := *txParams
+			otherTxParams.TxID = "other-id"
+			otherTxParams.ProposalDecorations = nil
+			_, err = chaincodeSupport.Invoke(&otherTxParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(debugLines("other-id")).To(BeEmpty())
+		})
+
+		Context("when the requested level is invalid", func() {
+			BeforeEach(func() {
+				txParams.ProposalDecorations = map[string][]byte{chaincode.LogLevelDecoration: []byte("chatty")}
+			})
+
+			It("uses the chaincode log level", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(debugLines("tx-id")).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("developer mode registration wait", func() {
+		var lateRegistry *chaincode.HandlerRegistry
+
+		BeforeEach(func() {
+			lateRegistry = chaincode.NewHandlerRegistry(true)
+			chaincodeSupport.HandlerRegistry = lateRegistry
+			chaincodeSupport.UserRunsCC = true
+			chaincodeSupport.DevModeWait = 100 * time.Millisecond
+		})
+
+		It("invokes a chaincode which registers within the wait", func() {
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(20 * time.Millisecond)
+				Expect(lateRegistry.Register(handler)).To(Succeed())
+				lateRegistry.Ready("definition-ccid")
+			}()
+
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Payload).To(Equal([]byte("response-payload")))
+		})
+
+		It("fails when the chaincode does not register within the wait", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(MatchError("chaincode definition-ccid did not register within 100ms in developer mode"))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("message type mapping", func() {
+		BeforeEach(func() {
+			handler.MessageTypes = map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type{
+				pb.ChaincodeMessage_TRANSACTION: 102,
+			}
+		})
+
+		It("sends the mapped message type to the chaincode", func() {
+			resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Type).To(Equal(pb.ChaincodeMessage_COMPLETED))
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			Expect(fakeChatStream.SendArgsForCall(0).Type).To(Equal(pb.ChaincodeMessage_Type(102)))
+		})
+
+		It("sends unmapped message types unchanged", func() {
+			handler.MessageTypes = map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type{
+				pb.ChaincodeMessage_INIT: 101,
+			}
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			Expect(fakeChatStream.SendArgsForCall(0).Type).To(Equal(pb.ChaincodeMessage_TRANSACTION))
+		})
+	})
+
+	Describe("decoration codec", func() {
+		var fakeDecorationCodec *mock.DecorationCodec
+
+		BeforeEach(func() {
+			txParams.ProposalDecorations = map[string][]byte{"key": []byte("value")}
+
+			fakeDecorationCodec = &mock.DecorationCodec{}
+			fakeDecorationCodec.EncodeReturns(map[string][]byte{"key": []byte("encoded-value")}, nil)
+			chaincodeSupport.DecorationCodec = fakeDecorationCodec
+		})
+
+		It("passes the encoded decorations to the chaincode", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeDecorationCodec.EncodeCallCount()).To(Equal(1))
+			Expect(fakeDecorationCodec.EncodeArgsForCall(0)).To(Equal(map[string][]byte{"key": []byte("value")}))
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			sent := &pb.ChaincodeInput{}
+			Expect(proto.Unmarshal(fakeChatStream.SendArgsForCall(0).Payload, sent)).To(Succeed())
+			Expect(sent.Decorations).To(Equal(map[string][]byte{"key": []byte("encoded-value")}))
+		})
+
+		Context("when encoding fails", func() {
+			BeforeEach(func() {
+				fakeDecorationCodec.EncodeReturns(nil, errors.New("bad-decoration"))
+			})
+
+			It("fails the invocation", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("failed to encode proposal decorations: bad-decoration"))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("correlation ID", func() {
+		var (
+			logOutput  *gbytes.Buffer
+			prevWriter io.Writer
+			prevSpec   string
+		)
+
+		// sentDecorations returns the decorations of the input sent to the
+		// chaincode.
+		sentDecorations := func() map[string][]byte {
+			Expect(fakeChatStream.SendCallCount()).To(BeNumerically(">", 0))
+			msg := fakeChatStream.SendArgsForCall(0)
+			sent := &pb.ChaincodeInput{}
+			Expect(proto.Unmarshal(msg.Payload, sent)).To(Succeed())
+			return sent.Decorations
+		}
+
+		BeforeEach(func() {
+			prevSpec = flogging.Global.Spec()
+			flogging.ActivateSpec("chaincode=debug")
+			logOutput = gbytes.NewBuffer()
+			prevWriter = flogging.SetWriter(logOutput)
+		})
+
+		AfterEach(func() {
+			flogging.SetWriter(prevWriter)
+			flogging.ActivateSpec(prevSpec)
+		})
+
+		It("tags the log lines and the message with the correlation ID", func() {
+			txParams.CorrelationID = "correlation-id"
+			txParams.ProposalDecorations = map[string][]byte{"key": []byte("value")}
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logOutput).To(gbytes.Say(`executing TRANSACTION for chaincode test-chaincode-name.*correlationID=correlation-id`))
+
+			Expect(sentDecorations()).To(Equal(map[string][]byte{
+				"key":                             []byte("value"),
+				chaincode.CorrelationIDDecoration: []byte("correlation-id"),
+			}))
+			Expect(txParams.ProposalDecorations).To(HaveLen(1))
+		})
+
+		It("takes the correlation ID from the proposal decoration", func() {
+			txParams.ProposalDecorations = map[string][]byte{chaincode.CorrelationIDDecoration: []byte("decorated-id")}
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logOutput).To(gbytes.Say(`executing TRANSACTION.*correlationID=decorated-id`))
+			Expect(sentDecorations()).To(HaveKeyWithValue(chaincode.CorrelationIDDecoration, []byte("decorated-id")))
+		})
+
+		It("does not tag invocations without a correlation ID", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logOutput.Contents()).To(ContainSubstring("executing TRANSACTION"))
+			Expect(logOutput.Contents()).NotTo(ContainSubstring("correlationID"))
+			Expect(sentDecorations()).NotTo(HaveKey(chaincode.CorrelationIDDecoration))
+		})
+	})
+
+	Describe("execute concurrency limit", func() {
+		var fakeQueueDepth *metricsfakes.Gauge
+
+		BeforeEach(func() {
+			fakeQueueDepth = &metricsfakes.Gauge{}
+			chaincodeSupport.ExecuteLimiter = chaincode.NewExecuteLimiter(1, fakeQueueDepth)
+		})
+
+		It("releases the slot once the execution completes", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			txParams.TxID = "other-tx-id"
+			_, err = chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chaincodeSupport.ExecuteLimiter.Waiting()).To(Equal(0))
+		})
+
+		It("queues executions beyond the limit", func() {
+			chaincodeSupport.ExecuteLimiter.Acquire("busy-tx-id")
+
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				errCh <- err
+			}()
+			Eventually(chaincodeSupport.ExecuteLimiter.Waiting).Should(Equal(1))
+			Consistently(errCh).ShouldNot(Receive())
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+
+			chaincodeSupport.ExecuteLimiter.Release("busy-tx-id")
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(fakeQueueDepth.SetArgsForCall(fakeQueueDepth.SetCallCount() - 1)).To(Equal(0.0))
+		})
+
+		Context("when a soft limit is configured", func() {
+			var (
+				fakeSoftLimitExceeded *metricsfakes.Counter
+				logOutput             *gbytes.Buffer
+				prevWriter            io.Writer
+			)
+
+			BeforeEach(func() {
+				fakeSoftLimitExceeded = &metricsfakes.Counter{}
+				chaincodeSupport.HandlerMetrics.ExecuteSoftLimitExceeded = fakeSoftLimitExceeded
+				chaincodeSupport.ExecuteLimiter = chaincode.NewExecuteLimiter(2, fakeQueueDepth)
+				chaincodeSupport.SoftConcurrencyLimit = 1
+
+				logOutput = gbytes.NewBuffer()
+				prevWriter = flogging.SetWriter(logOutput)
+			})
+
+			AfterEach(func() {
+				flogging.SetWriter(prevWriter)
+			})
+
+			It("does not warn within the soft limit", func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeSoftLimitExceeded.AddCallCount()).To(Equal(0))
+				Expect(logOutput.Contents()).NotTo(ContainSubstring("soft concurrency limit"))
+			})
+
+			It("warns without making the execution wait once the soft limit is crossed", func() {
+				chaincodeSupport.ExecuteLimiter.Acquire("busy-tx-id")
+				defer chaincodeSupport.ExecuteLimiter.Release("busy-tx-id")
+
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+				Expect(fakeSoftLimitExceeded.AddCallCount()).To(Equal(1))
+				Expect(fakeSoftLimitExceeded.AddArgsForCall(0)).To(Equal(1.0))
+				Expect(logOutput).To(gbytes.Say("2 chaincode executions are in progress, above the soft concurrency limit of 1; executions wait once 2 are in progress"))
+			})
+
+			It("still makes executions wait at the limit", func() {
+				chaincodeSupport.ExecuteLimiter.Acquire("busy-tx-id")
+				chaincodeSupport.ExecuteLimiter.Acquire("other-busy-tx-id")
+				defer chaincodeSupport.ExecuteLimiter.Release("other-busy-tx-id")
+
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					errCh <- err
+				}()
+				Eventually(chaincodeSupport.ExecuteLimiter.Waiting).Should(Equal(1))
+				Consistently(errCh).ShouldNot(Receive())
+
+				chaincodeSupport.ExecuteLimiter.Release("busy-tx-id")
+				Eventually(errCh).Should(Receive(BeNil()))
+			})
+		})
+
+		Context("when capacity is reserved for system chaincodes", func() {
+			BeforeEach(func() {
+				chaincodeSupport.SystemExecuteLimiter = chaincode.NewExecuteLimiter(1, &metricsfakes.Gauge{})
+				chaincodeSupport.BuiltinSCCs = scc.BuiltinSCCs{"test-chaincode-name": struct{}{}}
+			})
+
+			It("executes system chaincodes while user chaincode executions are saturated", func() {
+				chaincodeSupport.ExecuteLimiter.Acquire("busy-tx-id")
+				defer chaincodeSupport.ExecuteLimiter.Release("busy-tx-id")
+
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+				Expect(chaincodeSupport.ExecuteLimiter.Waiting()).To(Equal(0))
+			})
+
+			It("executes system chaincodes in the shared capacity while it is available", func() {
+				chaincodeSupport.SystemExecuteLimiter.Acquire("busy-tx-id")
+				defer chaincodeSupport.SystemExecuteLimiter.Release("busy-tx-id")
+
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+				Expect(chaincodeSupport.SystemExecuteLimiter.Waiting()).To(Equal(0))
+			})
+
+			It("does not let user chaincodes consume the reserved capacity", func() {
+				chaincodeSupport.BuiltinSCCs = scc.BuiltinSCCs{"lscc": struct{}{}}
+				chaincodeSupport.ExecuteLimiter.Acquire("busy-tx-id")
+
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					errCh <- err
+				}()
+				Eventually(chaincodeSupport.ExecuteLimiter.Waiting).Should(Equal(1))
+				Expect(chaincodeSupport.SystemExecuteLimiter.Waiting()).To(Equal(0))
+				Consistently(errCh).ShouldNot(Receive())
+
+				chaincodeSupport.ExecuteLimiter.Release("busy-tx-id")
+				Eventually(errCh).Should(Receive(BeNil()))
+			})
+		})
+	})
+
+	Describe("EvictIdle", func() {
+		var fakeRuntime *mock.Runtime
+
+		BeforeEach(func() {
+			fakeRuntime = &mock.Runtime{}
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:  fakeRuntime,
+				Registry: chaincodeSupport.HandlerRegistry,
+			}
+
+			idleHandler := &chaincode.Handler{TXContexts: chaincode.NewTransactionContexts()}
+			chaincode.SetHandlerChaincodeID(idleHandler, "idle-ccid")
+			Expect(chaincodeSupport.HandlerRegistry.Register(idleHandler)).To(Succeed())
+		})
+
+		It("evicts idle chaincodes and keeps active ones", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			evicted := chaincodeSupport.EvictIdle(time.Minute)
+			Expect(evicted).To(Equal([]string{"idle-ccid"}))
+			Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("idle-ccid"))
+			Expect(chaincodeSupport.HandlerRegistry.Handler("idle-ccid")).To(BeNil())
+			Expect(chaincodeSupport.HandlerRegistry.Handler("definition-ccid")).To(Equal(handler))
+		})
+
+		It("does not evict chaincodes with an execution in progress", func() {
+			sent := make(chan *pb.ChaincodeMessage, 1)
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				sent <- msg
+				return nil
+			}
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				errCh <- err
+			}()
+
+			var msg *pb.ChaincodeMessage
+			Eventually(sent).Should(Receive(&msg))
+			Expect(chaincodeSupport.EvictIdle(0)).To(Equal([]string{"idle-ccid"}))
+			Expect(chaincodeSupport.HandlerRegistry.Handler("definition-ccid")).To(Equal(handler))
+
+			handler.Notify(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Txid: msg.Txid, ChannelId: msg.ChannelId})
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+
+		Context("when a minimum container lifetime is configured", func() {
+			BeforeEach(func() {
+				chaincodeSupport.MinContainerLifetime = time.Hour
+
+				youngHandler := &chaincode.Handler{TXContexts: chaincode.NewTransactionContexts()}
+				chaincode.SetHandlerChaincodeID(youngHandler, "young-ccid")
+				chaincode.SetHandlerRegisteredAt(youngHandler, time.Now().Add(-time.Minute))
+				Expect(chaincodeSupport.HandlerRegistry.Register(youngHandler)).To(Succeed())
+			})
+
+			It("does not evict chaincodes younger than the minimum lifetime", func() {
+				Expect(chaincodeSupport.EvictIdle(0)).To(ConsistOf("idle-ccid", "definition-ccid"))
+				Expect(chaincodeSupport.HandlerRegistry.Handler("young-ccid")).NotTo(BeNil())
+			})
+
+			It("still stops them when asked explicitly", func() {
+				Expect(chaincodeSupport.Launcher.Stop("young-ccid")).To(Succeed())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+				Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("young-ccid"))
+			})
+		})
+
+		Context("when the chaincode cannot be stopped", func() {
+			BeforeEach(func() {
+				fakeRuntime.StopReturns(errors.New("stuck"))
+			})
+
+			It("keeps the chaincode registered", func() {
+				Expect(chaincodeSupport.EvictIdle(time.Minute)).To(BeEmpty())
+				Expect(chaincodeSupport.HandlerRegistry.Handler("idle-ccid")).NotTo(BeNil())
+			})
+		})
+	})
+
+	Describe("Stop", func() {
+		var fakeRuntime *mock.Runtime
+
+		BeforeEach(func() {
+			fakeRuntime = &mock.Runtime{}
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:  fakeRuntime,
+				Registry: chaincodeSupport.HandlerRegistry,
+			}
+			chaincodeSupport.GracefulStopTimeout = time.Minute
+		})
+
+		Context("when the chaincode acknowledges the shutdown", func() {
+			BeforeEach(func() {
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					if msg.Type == chaincode.ShutdownMessageType {
+						go chaincode.HandleMessage(handler, &pb.ChaincodeMessage{Type: chaincode.ShutdownMessageType})
+					}
+					return nil
+				}
+			})
+
+			It("stops the chaincode once it has acknowledged", func() {
+				fakeRuntime.StopStub = func(string) error {
+					Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+					return nil
+				}
+
+				start := time.Now()
+				Expect(chaincodeSupport.Stop("definition-ccid")).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically("<", time.Minute))
+
+				Expect(fakeChatStream.SendArgsForCall(0).Type).To(Equal(chaincode.ShutdownMessageType))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+				Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("definition-ccid"))
+			})
+		})
+
+		Context("when the chaincode does not acknowledge the shutdown", func() {
+			BeforeEach(func() {
+				chaincodeSupport.GracefulStopTimeout = 50 * time.Millisecond
+				fakeChatStream.SendStub = nil
+			})
+
+			It("stops the chaincode once the timeout expires", func() {
+				start := time.Now()
+				Expect(chaincodeSupport.Stop("definition-ccid")).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when graceful stops are disabled", func() {
+			BeforeEach(func() {
+				chaincodeSupport.GracefulStopTimeout = 0
+			})
+
+			It("stops the chaincode without asking it", func() {
+				Expect(chaincodeSupport.Stop("definition-ccid")).To(Succeed())
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when an execution is in progress", func() {
+			var (
+				started chan struct{}
+				release chan struct{}
+			)
+
+			BeforeEach(func() {
+				chaincodeSupport.GracefulStopTimeout = 0
+				started = make(chan struct{}, 1)
+				release = make(chan struct{})
+				started, release := started, release
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					resp := proto.Clone(response).(*pb.ChaincodeMessage)
+					resp.Txid = msg.Txid
+					resp.ChannelId = msg.ChannelId
+					started <- struct{}{}
+					go func() {
+						<-release
+						handler.Notify(resp)
+					}()
+					return nil
+				}
+			})
+
+			invokeAsync := func() <-chan error {
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					errCh <- err
+				}()
+				Eventually(started).Should(Receive())
+				return errCh
+			}
+
+			It("waits for the execution to complete within the grace period", func() {
+				chaincodeSupport.StopGracePeriod = time.Minute
+				errCh := invokeAsync()
+
+				stopCh := make(chan error, 1)
+				go func() { stopCh <- chaincodeSupport.Stop("definition-ccid") }()
+				Consistently(stopCh).ShouldNot(Receive())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError(ContainSubstring(chaincode.ErrorHandlerStopping)))
+
+				close(release)
+				Eventually(errCh).Should(Receive(BeNil()))
+				Eventually(stopCh).Should(Receive(BeNil()))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			})
+
+			It("cancels the execution when the grace period expires", func() {
+				chaincodeSupport.StopGracePeriod = 50 * time.Millisecond
+				errCh := invokeAsync()
+
+				start := time.Now()
+				Expect(chaincodeSupport.Stop("definition-ccid")).To(Succeed())
+				Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+				Eventually(errCh).Should(Receive(MatchError(ContainSubstring(chaincode.ErrorExecutionCancelled))))
+			})
+
+			It("cancels the execution immediately without a grace period", func() {
+				errCh := invokeAsync()
+
+				Expect(chaincodeSupport.Stop("definition-ccid")).To(Succeed())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+				Eventually(errCh).Should(Receive(MatchError(ContainSubstring(chaincode.ErrorExecutionCancelled))))
+			})
+
+			It("accepts executions again when the chaincode cannot be stopped", func() {
+				fakeRuntime.StopReturns(errors.New("stop-failed"))
+				close(release)
+
+				Expect(chaincodeSupport.Stop("definition-ccid")).NotTo(Succeed())
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("fallback invoker", func() {
+		var (
+			fakeRuntime         *mock.Runtime
+			fakeFallbackInvoker *mock.Invoker
+		)
+
+		BeforeEach(func() {
+			invokeInfo.ChaincodeID = "unlaunched-ccid"
+
+			fakeRuntime = &mock.Runtime{}
+			fakeRuntime.BuildReturns(nil, errors.New("image-missing"))
+			fakeLaunchFailures := &metricsfakes.Counter{}
+			fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+			fakeLaunchDuration := &metricsfakes.Histogram{}
+			fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       chaincodeSupport.HandlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			}
+
+			fakeFallbackInvoker = &mock.Invoker{}
+			fakeFallbackInvoker.InvokeReturns(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Payload: []byte("remote-payload")}, nil)
+		})
+
+		It("fails the invocation when no fallback is configured", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(MatchError("could not launch chaincode unlaunched-ccid: error building chaincode: image-missing"))
+			Expect(fakeFallbackInvoker.InvokeCallCount()).To(Equal(0))
+		})
+
+		Context("when a fallback is configured", func() {
+			BeforeEach(func() {
+				chaincodeSupport.FallbackInvoker = fakeFallbackInvoker
+			})
+
+			It("delegates the invocation when the chaincode cannot be launched", func() {
+				resp, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Payload).To(Equal([]byte("remote-payload")))
+
+				Expect(fakeFallbackInvoker.InvokeCallCount()).To(Equal(1))
+				params, name, in := fakeFallbackInvoker.InvokeArgsForCall(0)
+				Expect(params).To(Equal(txParams))
+				Expect(name).To(Equal("test-chaincode-name"))
+				Expect(in).To(Equal(input))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+			})
+
+			It("does not consult the fallback when the chaincode is running", func() {
+				invokeInfo.ChaincodeID = "definition-ccid"
+
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeFallbackInvoker.InvokeCallCount()).To(Equal(0))
+			})
+
+			Context("when the fallback fails", func() {
+				BeforeEach(func() {
+					fakeFallbackInvoker.InvokeReturns(nil, errors.New("remote-unavailable"))
+				})
+
+				It("returns the error", func() {
+					_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+					Expect(err).To(MatchError("fallback invocation of chaincode test-chaincode-name failed: remote-unavailable"))
+				})
+			})
+		})
+	})
+
+	Describe("launch policy", func() {
+		var (
+			fakeRuntime *mock.Runtime
+			release     chan struct{}
+		)
+
+		BeforeEach(func() {
+			invokeInfo.ChaincodeID = "cold-ccid"
+
+			release = make(chan struct{})
+			fakeRuntime = &mock.Runtime{}
+			fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+				<-release
+				return nil, errors.New("image-missing")
+			}
+			fakeLaunchFailures := &metricsfakes.Counter{}
+			fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+			fakeLaunchDuration := &metricsfakes.Histogram{}
+			fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       chaincodeSupport.HandlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			}
+		})
+
+		invokeAsync := func() <-chan error {
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				errCh <- err
+			}()
+			return errCh
+		}
+
+		It("makes concurrent cold invocations wait for the launch by default", func() {
+			first := invokeAsync()
+			Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+			second := invokeAsync()
+			Consistently(second).ShouldNot(Receive())
+
+			close(release)
+			Eventually(first).Should(Receive(MatchError("could not launch chaincode cold-ccid: error building chaincode: image-missing")))
+			Eventually(second).Should(Receive(MatchError("could not launch chaincode cold-ccid: chaincode registration failed: error building chaincode: image-missing")))
+			Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+		})
+
+		Context("when the invocation's context is cancelled", func() {
+			var cancel context.CancelFunc
+
+			BeforeEach(func() {
+				chaincodeSupport.LaunchFailureTTL = time.Minute
+				txParams.Context, cancel = context.WithCancel(context.Background())
+			})
+
+			AfterEach(func() {
+				cancel()
+				close(release)
+			})
+
+			It("stops waiting on the launch without recording it as failed", func() {
+				errCh := invokeAsync()
+				Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+				Consistently(errCh).ShouldNot(Receive())
+
+				cancel()
+				var err error
+				Eventually(errCh).Should(Receive(&err))
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+				Expect(chaincode.LaunchFailureBackoff(chaincodeSupport, "cold-ccid")).To(BeZero())
+			})
+		})
+
+		Context("when invocations are rejected during launch", func() {
+			BeforeEach(func() {
+				chaincodeSupport.LaunchPolicy = chaincode.RejectDuringLaunch
+			})
+
+			It("rejects concurrent cold invocations while the launch is in progress", func() {
+				first := invokeAsync()
+				Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchInProgress))
+				Expect(err).To(MatchError("cannot invoke chaincode cold-ccid: chaincode launch in progress"))
+
+				close(release)
+				Eventually(first).Should(Receive(MatchError("could not launch chaincode cold-ccid: error building chaincode: image-missing")))
+				Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+			})
+
+			It("launches again once the launch in progress completes", func() {
+				close(release)
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("could not launch chaincode cold-ccid: error building chaincode: image-missing"))
+
+				_, err = chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("could not launch chaincode cold-ccid: error building chaincode: image-missing"))
+				Expect(fakeRuntime.BuildCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("LaunchHistory", func() {
+		var fakeRuntime *mock.Runtime
+
+		BeforeEach(func() {
+			invokeInfo.ChaincodeID = "cold-ccid"
+
+			fakeRuntime = &mock.Runtime{}
+			fakeRuntime.BuildReturns(nil, errors.New("image-missing"))
+			fakeLaunchFailures := &metricsfakes.Counter{}
+			fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+			fakeLaunchDuration := &metricsfakes.Histogram{}
+			fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       chaincodeSupport.HandlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			}
+		})
+
+		It("is empty for chaincodes which were never launched", func() {
+			Expect(chaincodeSupport.LaunchHistory("cold-ccid")).To(BeEmpty())
+		})
+
+		It("records launch attempts in order", func() {
+			for i := 0; i < 2; i++ {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				Expect(err).To(HaveOccurred())
+			}
+
+			fakeRuntime.BuildReturns(nil, nil)
+			fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+				launched := &chaincode.Handler{
+					TXContexts:   chaincode.NewTransactionContexts(),
+					LedgerGetter: &mock.LedgerGetter{},
+					Metrics:      handler.Metrics,
+				}
+				chaincode.SetHandlerChatStream(launched, fakeChatStream)
+				chaincode.SetHandlerChaincodeID(launched, ccid)
+				// responses are notified on the launched handler
+				handler = launched
+				Expect(chaincodeSupport.HandlerRegistry.Register(launched)).To(Succeed())
+				chaincodeSupport.HandlerRegistry.Ready(ccid)
+				return nil
+			}
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			history := chaincodeSupport.LaunchHistory("cold-ccid")
+			Expect(history).To(HaveLen(3))
+			for _, record := range history[:2] {
+				Expect(record.ChannelID).To(Equal("channel-id"))
+				Expect(record.Success).To(BeFalse())
+				Expect(record.Err).To(MatchError("error building chaincode: image-missing"))
+			}
+			Expect(history[2].Success).To(BeTrue())
+			Expect(history[2].Err).NotTo(HaveOccurred())
+			Expect(history[1].StartTime).NotTo(BeTemporally("<", history[0].StartTime))
+			Expect(history[2].StartTime).NotTo(BeTemporally("<", history[1].StartTime.Add(history[1].Duration)))
+		})
+
+		It("remembers a bounded number of launch attempts", func() {
+			for i := 0; i < 20; i++ {
+				txParams.ChannelID = fmt.Sprintf("channel-%d", i)
+				chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			}
+
+			history := chaincodeSupport.LaunchHistory("cold-ccid")
+			Expect(history).To(HaveLen(16))
+			Expect(history[0].ChannelID).To(Equal("channel-4"))
+			Expect(history[15].ChannelID).To(Equal("channel-19"))
+		})
+
+		It("records a launch joined by concurrent invocations once", func() {
+			release := make(chan struct{})
+			fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+				<-release
+				return nil, errors.New("image-missing")
+			}
+
+			errCh := make(chan error, 2)
+			invokeAsync := func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				errCh <- err
+			}
+			go invokeAsync()
+			Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+			go invokeAsync()
+			Consistently(errCh).ShouldNot(Receive())
+
+			close(release)
+			Eventually(errCh).Should(Receive())
+			Eventually(errCh).Should(Receive())
+			Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+
+			Expect(chaincodeSupport.LaunchHistory("cold-ccid")).To(HaveLen(1))
+		})
+	})
+
+	Describe("PreloadPackage", func() {
+		var (
+			fakeLedgerGetter    *mock.LedgerGetter
+			fakePeerLedger      *mock.PeerLedger
+			fakePackageProvider *containermock.PackageProvider
+			fakeInstance        *containermock.Instance
+		)
+
+		BeforeEach(func() {
+			invokeInfo.ChaincodeID = "cold-ccid"
+
+			fakePeerLedger = &mock.PeerLedger{}
+			fakePeerLedger.NewQueryExecutorReturns(fakeSimulator, nil)
+			fakeLedgerGetter = &mock.LedgerGetter{}
+			fakeLedgerGetter.GetLedgerReturns(fakePeerLedger)
+			chaincodeSupport.LedgerGetter = fakeLedgerGetter
+
+			fakePackageProvider = &containermock.PackageProvider{}
+			fakePackageProvider.GetChaincodePackageStub = func(string) (*persistence.ChaincodePackageMetadata, []byte, io.ReadCloser, error) {
+				return &persistence.ChaincodePackageMetadata{}, nil, io.NopCloser(&bytes.Buffer{}), nil
+			}
+			fakeInstance = &containermock.Instance{}
+			fakeInstance.StartReturns(errors.New("start-failed"))
+			fakeDockerBuilder := &containermock.DockerBuilder{}
+			fakeDockerBuilder.BuildReturns(fakeInstance, nil)
+
+			containerRuntime := &chaincode.ContainerRuntime{
+				ContainerRouter: &container.Router{
+					DockerBuilder:   fakeDockerBuilder,
+					PackageProvider: fakePackageProvider,
+				},
+				BuildRegistry: &container.BuildRegistry{},
+			}
+			fakeLaunchFailures := &metricsfakes.Counter{}
+			fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+			fakeLaunchDuration := &metricsfakes.Histogram{}
+			fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+			chaincodeSupport.Runtime = containerRuntime
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:        containerRuntime,
+				Registry:       chaincodeSupport.HandlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			}
+		})
+
+		It("fetches the package without starting the chaincode", func() {
+			Expect(chaincodeSupport.PreloadPackage("channel-id", "test-chaincode-name")).To(Succeed())
+
+			Expect(fakePackageProvider.GetChaincodePackageCallCount()).To(Equal(1))
+			Expect(fakePackageProvider.GetChaincodePackageArgsForCall(0)).To(Equal("cold-ccid"))
+			Expect(fakeInstance.StartCallCount()).To(Equal(0))
+
+			Expect(fakeLedgerGetter.GetLedgerArgsForCall(0)).To(Equal("channel-id"))
+			channelID, name, qe := fakeLifecycle.ChaincodeEndorsementInfoArgsForCall(0)
+			Expect(channelID).To(Equal("channel-id"))
+			Expect(name).To(Equal("test-chaincode-name"))
+			Expect(qe).To(Equal(fakeSimulator))
+			Expect(fakeSimulator.DoneCallCount()).To(Equal(1))
+		})
+
+		It("does not fetch the package again when the chaincode is launched", func() {
+			Expect(chaincodeSupport.PreloadPackage("channel-id", "test-chaincode-name")).To(Succeed())
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).To(MatchError(ContainSubstring("start-failed")))
+			Expect(fakeInstance.StartCallCount()).To(Equal(1))
+			Expect(fakePackageProvider.GetChaincodePackageCallCount()).To(Equal(1))
+		})
+
+		It("does nothing when the chaincode is running", func() {
+			invokeInfo.ChaincodeID = "definition-ccid"
+
+			Expect(chaincodeSupport.PreloadPackage("channel-id", "test-chaincode-name")).To(Succeed())
+			Expect(fakePackageProvider.GetChaincodePackageCallCount()).To(Equal(0))
+		})
+
+		It("returns an error when the channel does not exist", func() {
+			fakeLedgerGetter.GetLedgerReturns(nil)
+
+			err := chaincodeSupport.PreloadPackage("missing-channel", "test-chaincode-name")
+			Expect(err).To(MatchError("channel missing-channel not found"))
+		})
+
+		It("returns an error when the chaincode definition cannot be found", func() {
+			fakeLifecycle.ChaincodeEndorsementInfoReturns(nil, errors.New("fake-lifecycle-error"))
+
+			err := chaincodeSupport.PreloadPackage("channel-id", "test-chaincode-name")
+			Expect(err).To(MatchError("[channel channel-id] failed to get chaincode container info for test-chaincode-name: fake-lifecycle-error"))
+			Expect(fakePackageProvider.GetChaincodePackageCallCount()).To(Equal(0))
+		})
+
+		It("returns an error when the package cannot be fetched", func() {
+			fakePackageProvider.GetChaincodePackageStub = nil
+			fakePackageProvider.GetChaincodePackageReturns(nil, nil, nil, errors.New("package-missing"))
+
+			err := chaincodeSupport.PreloadPackage("channel-id", "test-chaincode-name")
+			Expect(err).To(MatchError("failed to preload package of chaincode cold-ccid: error building image: failed to get chaincode package for docker build: package-missing"))
+		})
+	})
+
+	Describe("DiagnosticsDump", func() {
+		It("reports a chaincode with an active transaction", func() {
+			sent := make(chan *pb.ChaincodeMessage, 1)
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				sent <- msg
+				return nil
+			}
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				errCh <- err
+			}()
+
+			var msg *pb.ChaincodeMessage
+			Eventually(sent).Should(Receive(&msg))
+
+			// dumps are safe while the chaincode executes
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 10; i++ {
+					chaincodeSupport.DiagnosticsDump()
+				}
+			}()
+
+			report := chaincodeSupport.DiagnosticsDump()
+			Expect(report.Time).NotTo(BeZero())
+			Expect(report.Chaincodes).To(Equal([]chaincode.ChaincodeDiagnostics{{
+				ChaincodeID: "definition-ccid",
+				InFlight:    1,
+			}}))
+			Expect(report.PendingLaunches).To(BeEmpty())
+			Expect(report.LaunchErrors).To(BeEmpty())
+			Expect(report.Config).To(Equal(chaincodeSupport.EffectiveConfig()))
+			Eventually(done).Should(BeClosed())
+
+			handler.Notify(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Txid: msg.Txid, ChannelId: msg.ChannelId})
+			Eventually(errCh).Should(Receive(BeNil()))
+			Expect(chaincodeSupport.DiagnosticsDump().Chaincodes[0].InFlight).To(Equal(0))
+		})
+	})
+
+	Describe("channel concurrency limit", func() {
+		var fakeRejections *metricsfakes.Counter
+
+		BeforeEach(func() {
+			fakeInFlight := &metricsfakes.Gauge{}
+			fakeInFlight.WithReturns(fakeInFlight)
+			fakeRejections = &metricsfakes.Counter{}
+			fakeRejections.WithReturns(fakeRejections)
+			chaincodeSupport.ChannelLimiter = chaincode.NewChannelLimiter(map[string]int{"channel-id": 1}, chaincode.RejectAtChannelLimit, fakeInFlight, fakeRejections)
+		})
+
+		It("releases the slot once the execution completes", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(chaincodeSupport.ChannelLimiter.InFlight("channel-id")).To(Equal(0))
+		})
+
+		It("rejects executions beyond the limit of the channel", func() {
+			Expect(chaincodeSupport.ChannelLimiter.Acquire("channel-id", "busy-tx-id")).To(Succeed())
+
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrChannelLimitReached))
+			Expect(fakeRejections.AddCallCount()).To(Equal(1))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("execute rate limit", func() {
+		BeforeEach(func() {
+			chaincodeSupport.RateLimiter = chaincode.NewRateLimiter(map[string]chaincode.RateLimit{
+				"test-chaincode-name": {Rate: 1, Burst: 1},
+			}, chaincode.RejectAtRateLimit)
+		})
+
+		It("rejects invocations beyond the rate of the chaincode", func() {
+			_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			txParams.TxID = "tx-id-2"
+			_, err = chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrRateLimited))
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("serial execution", func() {
+		var (
+			sent          chan *pb.ChaincodeMessage
+			otherTxParams *ccprovider.TransactionParams
+		)
+
+		BeforeEach(func() {
+			chaincodeSupport.SerialExecution = map[string]bool{"serial-chaincode-name": true}
+
+			sent = make(chan *pb.ChaincodeMessage, 2)
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				sent <- msg
+				return nil
+			}
+			otherTxParams = &ccprovider.TransactionParams{
+				TxID:        "other-tx-id",
+				ChannelID:   "channel-id",
+				TXSimulator: fakeSimulator,
+			}
+		})
+
+		invokeConcurrently := func(name string) chan error {
+			errCh := make(chan error, 2)
+			for _, tp := range []*ccprovider.TransactionParams{txParams, otherTxParams} {
+				go func(tp *ccprovider.TransactionParams) {
+					_, err := chaincodeSupport.Invoke(tp, name, input)
+					errCh <- err
+				}(tp)
+			}
+			return errCh
+		}
+
+		respond := func(msg *pb.ChaincodeMessage) {
+			handler.Notify(&pb.ChaincodeMessage{
+				Type:      pb.ChaincodeMessage_COMPLETED,
+				Txid:      msg.Txid,
+				ChannelId: msg.ChannelId,
+			})
+		}
+
+		It("executes the invocations of a serial chaincode one at a time", func() {
+			errCh := invokeConcurrently("serial-chaincode-name")
+
+			var first *pb.ChaincodeMessage
+			Eventually(sent).Should(Receive(&first))
+			Consistently(sent).ShouldNot(Receive())
+
+			respond(first)
+			Eventually(errCh).Should(Receive(BeNil()))
+
+			var second *pb.ChaincodeMessage
+			Eventually(sent).Should(Receive(&second))
+			Expect(second.Txid).NotTo(Equal(first.Txid))
+
+			respond(second)
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+
+		It("executes the invocations of other chaincodes concurrently", func() {
+			errCh := invokeConcurrently("test-chaincode-name")
+
+			var first, second *pb.ChaincodeMessage
+			Eventually(sent).Should(Receive(&first))
+			Eventually(sent).Should(Receive(&second))
+
+			respond(first)
+			respond(second)
+			Eventually(errCh).Should(Receive(BeNil()))
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+	})
+
+	Describe("init ordering", func() {
+		var (
+			invokeTxParams   *ccprovider.TransactionParams
+			invokeInput      *pb.ChaincodeInput
+			initResponseType pb.ChaincodeMessage_Type
+			releaseInit      chan struct{}
+		)
+
+		BeforeEach(func() {
+			invokeInfo.EnforceInit = true
+			input.IsInit = true
+
+			invokeSimulator := &mock.TxSimulator{}
+			invokeSimulator.GetStateReturns([]byte("definition-version"), nil)
+			invokeTxParams = &ccprovider.TransactionParams{
+				TxID:        "invoke-tx-id",
+				ChannelID:   "channel-id",
+				TXSimulator: invokeSimulator,
+			}
+			invokeInput = &pb.ChaincodeInput{Args: [][]byte{[]byte("arg")}}
+
+			initResponseType = pb.ChaincodeMessage_COMPLETED
+			releaseInit = make(chan struct{})
+			respond := fakeChatStream.SendStub
+			initHandler := handler
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				if msg.Type != pb.ChaincodeMessage_INIT {
+					return respond(msg)
+				}
+				responseType := initResponseType
+				go func() {
+					<-releaseInit
+					initHandler.Notify(&pb.ChaincodeMessage{
+						Type:      responseType,
+						Payload:   []byte("init-failed"),
+						Txid:      msg.Txid,
+						ChannelId: msg.ChannelId,
+					})
+				}()
+				return nil
+			}
+		})
+
+		// startInitAndInvoke starts an init, then an invoke once the init has
+		// been sent to the chaincode.
+		startInitAndInvoke := func() (initErrCh, invokeErrCh chan error) {
+			initErrCh = make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Invoke(txParams, "test-chaincode-name", input)
+				initErrCh <- err
+			}()
+			Eventually(fakeChatStream.SendCallCount).Should(Equal(1))
+
+			invokeErrCh = make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Invoke(invokeTxParams, "test-chaincode-name", invokeInput)
+				invokeErrCh <- err
+			}()
+			return initErrCh, invokeErrCh
+		}
+
+		It("holds an invoke until the in-progress init completes", func() {
+			initErrCh, invokeErrCh := startInitAndInvoke()
+			Consistently(invokeErrCh).ShouldNot(Receive())
+			Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+
+			close(releaseInit)
+			Eventually(initErrCh).Should(Receive(BeNil()))
+			Eventually(invokeErrCh).Should(Receive(BeNil()))
+
+			Expect(fakeChatStream.SendCallCount()).To(Equal(2))
+			Expect(fakeChatStream.SendArgsForCall(1).Type).To(Equal(pb.ChaincodeMessage_TRANSACTION))
+			Expect(chaincode.InitOrderLocks(chaincodeSupport)).To(Equal(0))
+		})
+
+		It("fails an init which completes without a response", func() {
+			Expect(chaincode.InitResult(nil, nil)).To(MatchError("init returned no response"))
+		})
+
+		Context("when the init fails", func() {
+			BeforeEach(func() {
+				initResponseType = pb.ChaincodeMessage_ERROR
+			})
+
+			It("fails the held invoke", func() {
+				initErrCh, invokeErrCh := startInitAndInvoke()
+				Consistently(invokeErrCh).ShouldNot(Receive())
+
+				close(releaseInit)
+				Eventually(initErrCh).Should(Receive(BeNil()))
+				Eventually(invokeErrCh).Should(Receive(MatchError("init of chaincode test-chaincode-name failed: transaction returned with failure: init-failed")))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+			})
+		})
+
+		It("does not hold invokes of the chaincode on other channels", func() {
+			invokeTxParams.ChannelID = "other-channel-id"
+			initErrCh, invokeErrCh := startInitAndInvoke()
+			Eventually(invokeErrCh).Should(Receive(BeNil()))
+
+			close(releaseInit)
+			Eventually(initErrCh).Should(Receive(BeNil()))
+		})
+	})
+
+	Describe("Execute", func() {
+		BeforeEach(func() {
+			payload, err := proto.Marshal(&pb.Response{
+				Status:  200,
+				Message: "secret-message",
+				Payload: []byte("secret-payload"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			response.Payload = payload
+		})
+
+		It("returns the unmarshaled chaincode response", func() {
+			resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(proto.Equal(resp, &pb.Response{
+				Status:  200,
+				Message: "secret-message",
+				Payload: []byte("secret-payload"),
+			})).To(BeTrue())
+		})
+
+		It("records the size of the response payload", func() {
+			_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeResponseSize.WithCallCount()).To(Equal(1))
+			Expect(fakeResponseSize.WithArgsForCall(0)).To(Equal([]string{"chaincode", "test-chaincode-name"}))
+			Expect(fakeResponseSize.ObserveCallCount()).To(Equal(1))
+			Expect(fakeResponseSize.ObserveArgsForCall(0)).To(Equal(float64(len(response.Payload))))
+		})
+
+		Context("when the response has fields unknown to the peer", func() {
+			var payload []byte
+
+			BeforeEach(func() {
+				known, err := proto.Marshal(&pb.Response{Status: 200, Payload: []byte("secret-payload")})
+				Expect(err).NotTo(HaveOccurred())
+				// field 99, a varint set by a newer shim
+				payload = append(known, 0x98, 0x06, 0x01)
+				response.Payload = payload
+			})
+
+			It("preserves the unknown fields through a round-trip", func() {
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				marshaled, err := proto.Marshal(resp)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(marshaled).To(Equal(payload))
+			})
+
+			Context("when unknown fields are discarded", func() {
+				BeforeEach(func() {
+					chaincodeSupport.DiscardUnknownFields = true
+				})
+
+				It("returns only the known fields", func() {
+					resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					marshaled, err := proto.Marshal(resp)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(marshaled).To(Equal(payload[:len(payload)-3]))
+				})
+			})
+		})
+
+		Context("when payload checksums are enabled", func() {
+			BeforeEach(func() {
+				chaincodeSupport.PayloadChecksums = true
+			})
+
+			It("decorates the input with its checksum", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+
+				expectedPayload, err := proto.Marshal(input)
+				Expect(err).NotTo(HaveOccurred())
+				sum := sha256.Sum256(expectedPayload)
+
+				sent := &pb.ChaincodeInput{}
+				Expect(proto.Unmarshal(fakeChatStream.SendArgsForCall(0).Payload, sent)).To(Succeed())
+				Expect(sent.Args).To(Equal(input.Args))
+				Expect(sent.Decorations).To(HaveKeyWithValue(chaincode.PayloadChecksumDecoration, sum[:]))
+				Expect(input.Decorations).NotTo(HaveKey(chaincode.PayloadChecksumDecoration))
+			})
+
+			It("does not require a checksum on the response", func() {
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Payload).To(Equal([]byte("secret-payload")))
+			})
+		})
+
+		Context("when response checksums are enabled", func() {
+			withChecksum := func(payload []byte) []byte {
+				sum := sha256.Sum256(payload)
+				return append(append([]byte{}, payload...), sum[:]...)
+			}
+
+			BeforeEach(func() {
+				chaincodeSupport.ResponseChecksums = true
+				response.Payload = withChecksum(response.Payload)
+			})
+
+			It("verifies the response checksum", func() {
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(proto.Equal(resp, &pb.Response{
+					Status:  200,
+					Message: "secret-message",
+					Payload: []byte("secret-payload"),
+				})).To(BeTrue())
+			})
+
+			Context("when the response payload is corrupted", func() {
+				BeforeEach(func() {
+					response.Payload[0] ^= 0xff
+				})
+
+				It("returns ErrPayloadChecksumMismatch", func() {
+					_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(errors.Cause(err)).To(Equal(chaincode.ErrPayloadChecksumMismatch))
+					Expect(err).To(MatchError("invalid response for transaction tx-id: payload checksum mismatch"))
+				})
+			})
+
+			Context("when the response payload is too short to carry a checksum", func() {
+				BeforeEach(func() {
+					response.Payload = []byte("short")
+				})
+
+				It("returns ErrPayloadChecksumMismatch", func() {
+					_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(errors.Cause(err)).To(Equal(chaincode.ErrPayloadChecksumMismatch))
+				})
+			})
+		})
+
+		Context("when the chaincode input cannot be marshaled", func() {
+			var fakeMarshalFailures *metricsfakes.Counter
+
+			BeforeEach(func() {
+				fakeMarshalFailures = &metricsfakes.Counter{}
+				fakeMarshalFailures.WithReturns(fakeMarshalFailures)
+				chaincodeSupport.HandlerMetrics.MessageMarshalFailures = fakeMarshalFailures
+				txParams.ProposalDecorations = map[string][]byte{"\xff": nil}
+			})
+
+			It("identifies the invocation in the error and counts the failure", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError(ContainSubstring("failed to create chaincode message: failed to marshal TRANSACTION input for chaincode test-chaincode-name in transaction tx-id")))
+				Expect(fakeChatStream.SendCallCount()).To(Equal(0))
+
+				Expect(fakeMarshalFailures.WithCallCount()).To(Equal(1))
+				Expect(fakeMarshalFailures.WithArgsForCall(0)).To(Equal([]string{"chaincode", "test-chaincode-name"}))
+				Expect(fakeMarshalFailures.AddCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the response payload cannot be unmarshaled", func() {
+			BeforeEach(func() {
+				response.Payload = []byte("unmarshalable-payload")
+			})
+
+			It("still records the size of the response payload", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError(ContainSubstring("failed to unmarshal response for transaction tx-id")))
+				Expect(fakeResponseSize.ObserveCallCount()).To(Equal(1))
+				Expect(fakeResponseSize.ObserveArgsForCall(0)).To(Equal(float64(len("unmarshalable-payload"))))
+			})
+		})
+
+		Context("when a result validator is configured", func() {
+			BeforeEach(func() {
+				chaincodeSupport.ResultValidator = func(resp *pb.Response) error {
+					if resp.Status == 299 {
+						return errors.Errorf("status %d is reserved", resp.Status)
+					}
+					return nil
+				}
+			})
+
+			It("returns responses which pass validation", func() {
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Status).To(Equal(int32(200)))
+			})
+
+			Context("when the response fails validation", func() {
+				BeforeEach(func() {
+					payload, err := proto.Marshal(&pb.Response{Status: 299, Payload: []byte("secret-payload")})
+					Expect(err).NotTo(HaveOccurred())
+					response.Payload = payload
+				})
+
+				It("fails the invocation", func() {
+					_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(err).To(MatchError("response for transaction tx-id failed validation: status 299 is reserved"))
+				})
+			})
+		})
+
+		Context("when status code handlers are configured", func() {
+			var handled []int32
+
+			BeforeEach(func() {
+				handled = nil
+				chaincodeSupport.StatusCodeHandlers = map[int32]chaincode.StatusCodeHandler{
+					200: func(resp *pb.Response) (*pb.Response, error) {
+						handled = append(handled, resp.Status)
+						return &pb.Response{Status: 202, Message: "pending"}, nil
+					},
+					404: func(resp *pb.Response) (*pb.Response, error) {
+						return nil, errors.Errorf("not found: %s", resp.Message)
+					},
+					410: func(resp *pb.Response) (*pb.Response, error) {
+						return nil, nil
+					},
+				}
+			})
+
+			It("fails the invocation when the handler returns no response", func() {
+				validated := 0
+				chaincodeSupport.ResultValidator = func(resp *pb.Response) error {
+					validated++
+					return nil
+				}
+				payload, err := proto.Marshal(&pb.Response{Status: 410, Message: "gone"})
+				Expect(err).NotTo(HaveOccurred())
+				response.Payload = payload
+
+				_, _, err = chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("handler for status 410 returned no response for transaction tx-id"))
+				Expect(validated).To(Equal(0))
+			})
+
+			It("returns the response of the handler for the status", func() {
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(proto.Equal(resp, &pb.Response{Status: 202, Message: "pending"})).To(BeTrue())
+				Expect(handled).To(Equal([]int32{200}))
+			})
+
+			It("fails the invocation when the handler fails", func() {
+				payload, err := proto.Marshal(&pb.Response{Status: 404, Message: "missing-asset"})
+				Expect(err).NotTo(HaveOccurred())
+				response.Payload = payload
+
+				_, _, err = chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("failed to handle status 404 of response for transaction tx-id: not found: missing-asset"))
+			})
+
+			It("passes through responses with a status without a handler", func() {
+				payload, err := proto.Marshal(&pb.Response{Status: 201, Message: "created"})
+				Expect(err).NotTo(HaveOccurred())
+				response.Payload = payload
+
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(proto.Equal(resp, &pb.Response{Status: 201, Message: "created"})).To(BeTrue())
+				Expect(handled).To(BeEmpty())
+			})
+		})
+
+		Context("when an event sink is configured", func() {
+			var fakeEventSink *mock.EventSink
+
+			BeforeEach(func() {
+				fakeEventSink = &mock.EventSink{}
+				chaincodeSupport.EventSink = fakeEventSink
+			})
+
+			It("publishes the event of the execution", func() {
+				response.ChaincodeEvent = &pb.ChaincodeEvent{EventName: "event-name", Payload: []byte("event-payload")}
+
+				_, event, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeEventSink.PublishCallCount()).To(Equal(1))
+				published := fakeEventSink.PublishArgsForCall(0)
+				Expect(published).To(Equal(event))
+				Expect(proto.Equal(published, &pb.ChaincodeEvent{
+					ChaincodeId: "test-chaincode-name",
+					TxId:        "tx-id",
+					EventName:   "event-name",
+					Payload:     []byte("event-payload"),
+				})).To(BeTrue())
+			})
+
+			It("publishes nothing when the execution sets no event", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeEventSink.PublishCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a response transformer is configured", func() {
+			var transformerTxParams *ccprovider.TransactionParams
+			var transformerChaincodeName string
+
+			BeforeEach(func() {
+				chaincodeSupport.ResponseTransformer = func(txParams *ccprovider.TransactionParams, chaincodeName string, resp *pb.Response) (*pb.Response, error) {
+					transformerTxParams = txParams
+					transformerChaincodeName = chaincodeName
+					resp.Payload = []byte("redacted")
+					return resp, nil
+				}
+			})
+
+			It("returns the transformed response", func() {
+				resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.Status).To(Equal(int32(200)))
+				Expect(resp.Message).To(Equal("secret-message"))
+				Expect(resp.Payload).To(Equal([]byte("redacted")))
+
+				Expect(transformerTxParams).To(Equal(txParams))
+				Expect(transformerChaincodeName).To(Equal("test-chaincode-name"))
+			})
+
+			Context("when the transformer fails", func() {
+				BeforeEach(func() {
+					chaincodeSupport.ResponseTransformer = func(*ccprovider.TransactionParams, string, *pb.Response) (*pb.Response, error) {
+						return nil, errors.New("masking-failed")
+					}
+				})
+
+				It("fails the invocation", func() {
+					_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(err).To(MatchError("failed to transform response for transaction tx-id: masking-failed"))
+				})
+			})
+		})
+
+		Context("when the chaincode returns an error", func() {
+			BeforeEach(func() {
+				response.Type = pb.ChaincodeMessage_ERROR
+				response.Payload = []byte("chaincode-error")
+				chaincodeSupport.ResponseTransformer = func(*ccprovider.TransactionParams, string, *pb.Response) (*pb.Response, error) {
+					Fail("transformer should not be called")
+					return nil, nil
+				}
+			})
+
+			It("does not apply the transformer", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("transaction returned with failure: chaincode-error"))
+			})
+		})
+
+		Context("when structured errors are enabled", func() {
+			BeforeEach(func() {
+				chaincodeSupport.StructuredErrors = true
+				response.Type = pb.ChaincodeMessage_ERROR
+				response.Payload = []byte(`{"code":404,"message":"asset not found","details":"asset-1"}`)
+			})
+
+			It("returns the structured chaincode error", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("transaction returned with failure: chaincode error 404: asset not found (asset-1)"))
+
+				ce, ok := errors.Cause(err).(*chaincode.ChaincodeError)
+				Expect(ok).To(BeTrue())
+				Expect(ce).To(Equal(&chaincode.ChaincodeError{
+					Code:    404,
+					Message: "asset not found",
+					Details: "asset-1",
+				}))
+			})
+
+			Context("when the payload is not a structured error", func() {
+				BeforeEach(func() {
+					response.Payload = []byte("chaincode-error")
+				})
+
+				It("falls back to the payload string", func() {
+					_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(err).To(MatchError("transaction returned with failure: chaincode-error"))
+					_, ok := errors.Cause(err).(*chaincode.ChaincodeError)
+					Expect(ok).To(BeFalse())
+				})
+			})
+		})
+
+		Context("when structured errors are disabled", func() {
+			BeforeEach(func() {
+				response.Type = pb.ChaincodeMessage_ERROR
+				response.Payload = []byte(`{"code":404,"message":"asset not found"}`)
+			})
+
+			It("returns the payload string", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError(`transaction returned with failure: {"code":404,"message":"asset not found"}`))
+			})
+		})
+
+		Context("when the chaincode returns an error with an empty payload", func() {
+			BeforeEach(func() {
+				response.Type = pb.ChaincodeMessage_ERROR
+				response.Payload = nil
+			})
+
+			It("returns ErrEmptyChaincodeError", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("transaction returned with failure: chaincode returned an error with no message"))
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrEmptyChaincodeError))
+			})
+		})
+
+		Context("when the chaincode responds with a message type other than completed or error", func() {
+			BeforeEach(func() {
+				response.Type = pb.ChaincodeMessage_TRANSACTION
+				response.Payload = []byte("raw-payload")
+			})
+
+			It("returns an error", func() {
+				_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("unexpected response type 5 for transaction tx-id"))
+			})
+
+			Context("when the type is allowed", func() {
+				BeforeEach(func() {
+					// a type introduced by a newer shim, delivered through
+					// the handler as it is read from the stream
+					response.Type = 101
+					chaincodeSupport.AllowedResponseTypes = map[pb.ChaincodeMessage_Type]bool{101: true}
+					handler.AllowedResponseTypes = chaincodeSupport.AllowedResponseTypes
+					chaincode.SetHandlerState(handler, chaincode.Ready)
+					fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+						resp := proto.Clone(response).(*pb.ChaincodeMessage)
+						resp.Txid = msg.Txid
+						resp.ChannelId = msg.ChannelId
+						go func() {
+							defer GinkgoRecover()
+							Expect(chaincode.HandleMessage(handler, resp)).To(Succeed())
+						}()
+						return nil
+					}
+				})
+
+				It("returns the raw payload with a success status", func() {
+					resp, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(proto.Equal(resp, &pb.Response{Status: 200, Payload: []byte("raw-payload")})).To(BeTrue())
+				})
+			})
+		})
+	})
+
+	Describe("ExecuteToWriter", func() {
+		var (
+			largePayload []byte
+			writer       *chunkRecorder
+		)
+
+		BeforeEach(func() {
+			largePayload = make([]byte, 200*1024)
+			for i := range largePayload {
+				largePayload[i] = byte(i % 251)
+			}
+			payload, err := proto.Marshal(&pb.Response{
+				Status:  200,
+				Message: "large-message",
+				Payload: largePayload,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			response.Payload = payload
+
+			writer = &chunkRecorder{}
+		})
+
+		It("writes the payload in chunks and matches the buffered result", func() {
+			resp, _, err := chaincodeSupport.ExecuteToWriter(txParams, "test-chaincode-name", input, writer)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Status).To(Equal(int32(200)))
+			Expect(resp.Message).To(Equal("large-message"))
+			Expect(resp.Payload).To(BeEmpty())
+
+			Expect(len(writer.chunks)).To(BeNumerically(">", 1))
+			for _, chunk := range writer.chunks {
+				Expect(len(chunk)).To(BeNumerically("<=", 64*1024))
+			}
+
+			txParams.TxID = "buffered-tx-id"
+			buffered, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bytes.Join(writer.chunks, nil)).To(Equal(buffered.Payload))
+			Expect(buffered.Payload).To(Equal(largePayload))
+		})
+
+		Context("when the writer fails", func() {
+			BeforeEach(func() {
+				writer.err = errors.New("disk-full")
+			})
+
+			It("returns the error", func() {
+				_, _, err := chaincodeSupport.ExecuteToWriter(txParams, "test-chaincode-name", input, writer)
+				Expect(err).To(MatchError("failed to write response payload for transaction tx-id: disk-full"))
+			})
+		})
+
+		Context("when the chaincode returns an error", func() {
+			BeforeEach(func() {
+				response = &pb.ChaincodeMessage{
+					Type:    pb.ChaincodeMessage_ERROR,
+					Payload: []byte("chaincode-error"),
+				}
+			})
+
+			It("writes nothing", func() {
+				_, _, err := chaincodeSupport.ExecuteToWriter(txParams, "test-chaincode-name", input, writer)
+				Expect(err).To(MatchError("transaction returned with failure: chaincode-error"))
+				Expect(writer.chunks).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("ExecuteWithMetadata", func() {
+		BeforeEach(func() {
+			payload, err := proto.Marshal(&pb.Response{Status: 200})
+			Expect(err).NotTo(HaveOccurred())
+			response.Payload = payload
+		})
+
+		It("reports the chaincode and version which served the request", func() {
+			resp, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Status).To(Equal(int32(200)))
+			Expect(metadata).To(Equal(&chaincode.ExecuteMetadata{
+				ChaincodeID: "definition-ccid",
+				Version:     "definition-version",
+			}))
+
+			_, name, _ := fakeLifecycle.ChaincodeEndorsementInfoArgsForCall(0)
+			Expect(name).To(Equal("test-chaincode-name"))
+		})
+
+		Context("when the chaincode returns an error", func() {
+			BeforeEach(func() {
+				response = &pb.ChaincodeMessage{
+					Type:    pb.ChaincodeMessage_ERROR,
+					Payload: []byte("chaincode-error"),
+				}
+			})
+
+			It("returns no metadata", func() {
+				_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+				Expect(err).To(MatchError("transaction returned with failure: chaincode-error"))
+				Expect(metadata).To(BeNil())
+			})
+		})
+
+		Context("when memory accounting is enabled", func() {
+			var fakeRuntime *mock.Runtime
+
+			BeforeEach(func() {
+				fakeRuntime = &mock.Runtime{}
+				fakeRuntime.MemoryUsageReturnsOnCall(0, 1024, true, nil)
+				fakeRuntime.MemoryUsageReturnsOnCall(1, 4096, true, nil)
+				chaincodeSupport.Runtime = fakeRuntime
+				chaincodeSupport.MemoryAccounting = true
+			})
+
+			JustBeforeEach(func() {
+				chaincodeSupport.SampleMemory()
+
+				// the memory is sampled again while the chaincode executes
+				respond := fakeChatStream.SendStub
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					chaincodeSupport.SampleMemory()
+					return respond(msg)
+				}
+			})
+
+			It("reports the memory consumed by the execution", func() {
+				_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metadata.MemoryAccounted).To(BeTrue())
+				Expect(metadata.MemoryBytes).To(Equal(uint64(3072)))
+
+				Expect(fakeRuntime.MemoryUsageCallCount()).To(Equal(2))
+				Expect(fakeRuntime.MemoryUsageArgsForCall(0)).To(Equal("definition-ccid"))
+			})
+
+			It("does not measure the memory on the execution path", func() {
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					resp := proto.Clone(response).(*pb.ChaincodeMessage)
+					resp.Txid = msg.Txid
+					resp.ChannelId = msg.ChannelId
+					go handler.Notify(resp)
+					return nil
+				}
+
+				_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metadata.MemoryAccounted).To(BeTrue())
+				Expect(metadata.MemoryBytes).To(BeZero())
+				Expect(fakeRuntime.MemoryUsageCallCount()).To(Equal(1))
+			})
+
+			Context("when the resident memory shrinks during the execution", func() {
+				BeforeEach(func() {
+					fakeRuntime.MemoryUsageReturnsOnCall(1, 512, true, nil)
+				})
+
+				It("reports no memory consumed", func() {
+					_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(metadata.MemoryAccounted).To(BeTrue())
+					Expect(metadata.MemoryBytes).To(BeZero())
+				})
+			})
+
+			Context("when the runtime cannot report memory usage", func() {
+				BeforeEach(func() {
+					fakeRuntime.MemoryUsageReturnsOnCall(0, 0, false, nil)
+				})
+
+				It("skips memory accounting", func() {
+					_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(metadata.MemoryAccounted).To(BeFalse())
+				})
+			})
+		})
+
+		Context("when the chaincode is not running", func() {
+			BeforeEach(func() {
+				invokeInfo.ChaincodeID = "cold-ccid"
+
+				fakeRuntime := &mock.Runtime{}
+				fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+					launched := &chaincode.Handler{
+						TXContexts:   chaincode.NewTransactionContexts(),
+						LedgerGetter: &mock.LedgerGetter{},
+						Metrics:      handler.Metrics,
+					}
+					chaincode.SetHandlerChatStream(launched, fakeChatStream)
+					chaincode.SetHandlerChaincodeID(launched, ccid)
+					// responses are notified on the launched handler
+					handler = launched
+					Expect(chaincodeSupport.HandlerRegistry.Register(launched)).To(Succeed())
+					chaincodeSupport.HandlerRegistry.Ready(ccid)
+					return nil
+				}
+				fakeLaunchDuration := &metricsfakes.Histogram{}
+				fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+				chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+					Runtime:        fakeRuntime,
+					Registry:       chaincodeSupport.HandlerRegistry,
+					StartupTimeout: time.Minute,
+					Metrics:        &chaincode.LaunchMetrics{LaunchDuration: fakeLaunchDuration},
+				}
+			})
+
+			It("reports a cold start only for the execution which launched it", func() {
+				_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metadata.ChaincodeID).To(Equal("cold-ccid"))
+				Expect(metadata.ColdStart).To(BeTrue())
+
+				_, _, metadata, err = chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metadata.ColdStart).To(BeFalse())
+			})
+		})
+
+		Context("when the chaincode accesses state", func() {
+			BeforeEach(func() {
+				fakeSimulator.GetStateReturns([]byte("value"), nil)
+				fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+					Expect(txParams.TXSimulator).To(BeIdenticalTo(fakeSimulator))
+					txctx := handler.TXContexts.Get("channel-id", msg.Txid)
+					getState, err := proto.Marshal(&pb.GetState{Key: "read-key"})
+					Expect(err).NotTo(HaveOccurred())
+					_, err = handler.HandleGetState(&pb.ChaincodeMessage{Payload: getState, Txid: msg.Txid}, txctx)
+					Expect(err).NotTo(HaveOccurred())
+					putState, err := proto.Marshal(&pb.PutState{Key: "write-key", Value: []byte("written")})
+					Expect(err).NotTo(HaveOccurred())
+					_, err = handler.HandlePutState(&pb.ChaincodeMessage{Payload: putState, Txid: msg.Txid}, txctx)
+					Expect(err).NotTo(HaveOccurred())
+
+					resp := proto.Clone(response).(*pb.ChaincodeMessage)
+					resp.Txid = msg.Txid
+					resp.ChannelId = msg.ChannelId
+					go handler.Notify(resp)
+					return nil
+				}
+			})
+
+			It("reports the state accessed by the execution", func() {
+				_, _, metadata, err := chaincodeSupport.ExecuteWithMetadata(txParams, "test-chaincode-name", input)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metadata.Access).To(Equal(chaincode.AccessStats{
+					Reads:      1,
+					ReadBytes:  5,
+					Writes:     1,
+					WriteBytes: 7,
+				}))
+
+				Expect(txParams.TXSimulator).To(BeIdenticalTo(fakeSimulator))
+				Expect(fakeSimulator.GetStateCallCount()).To(Equal(1))
+				Expect(fakeSimulator.SetStateCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("Stats", func() {
+		BeforeEach(func() {
+			payload, err := proto.Marshal(&pb.Response{Status: 200})
+			Expect(err).NotTo(HaveOccurred())
+			response.Payload = payload
+		})
+
+		It("reports chaincodes that have not been executed", func() {
+			_, ok := chaincodeSupport.Stats("test-chaincode-name")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("counts successful and failed executions", func() {
+			_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).NotTo(HaveOccurred())
+
+			response.Type = pb.ChaincodeMessage_ERROR
+			_, _, err = chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).To(HaveOccurred())
+
+			stats, ok := chaincodeSupport.Stats("test-chaincode-name")
+			Expect(ok).To(BeTrue())
+			Expect(stats).To(Equal(chaincode.ExecStats{
+				Executions: 3,
+				Successes:  2,
+				Errors:     1,
+			}))
+		})
+
+		It("counts executions that time out", func() {
+			chaincodeSupport.ExecuteTimeout = 100 * time.Millisecond
+			fakeChatStream.SendStub = nil
+
+			_, _, err := chaincodeSupport.Execute(txParams, "test-chaincode-name", input)
+			Expect(err).To(MatchError(ContainSubstring(chaincode.ErrorExecutionTimeout)))
+
+			stats, ok := chaincodeSupport.Stats("test-chaincode-name")
+			Expect(ok).To(BeTrue())
+			Expect(stats).To(Equal(chaincode.ExecStats{
+				Executions: 1,
+				Errors:     1,
+				Timeouts:   1,
+			}))
+		})
+	})
+})
+
+var _ = Describe("Shutdown", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		handlerRegistry  *chaincode.HandlerRegistry
+		fakeRuntime      *mock.Runtime
+		fakeDependencies *mock.DependencyProvider
+		sysccid          string
+		stopped          []string
+	)
+
+	register := func(ccid string) {
+		handler := &chaincode.Handler{TXContexts: chaincode.NewTransactionContexts()}
+		chaincode.SetHandlerChaincodeID(handler, ccid)
+		Expect(handlerRegistry.Register(handler)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		handlerRegistry = chaincode.NewHandlerRegistry(true)
+		sysccid = scc.ChaincodeID("lscc")
+		stopped = nil
+
+		fakeRuntime = &mock.Runtime{}
+		fakeRuntime.StopStub = func(ccid string) error {
+			Expect(handlerRegistry.Handler(sysccid)).NotTo(BeNil())
+			stopped = append(stopped, ccid)
+			return nil
+		}
+		fakeDependencies = &mock.DependencyProvider{}
+
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			BuiltinSCCs:     scc.BuiltinSCCs{"lscc": struct{}{}},
+			HandlerRegistry: handlerRegistry,
+			Launcher: &chaincode.RuntimeLauncher{
+				Runtime:  fakeRuntime,
+				Registry: handlerRegistry,
+			},
+		}
+
+		register(sysccid)
+		register("cc-a:hash-a")
+		register("cc-b:hash-b")
+	})
+
+	It("stops system chaincodes after user chaincodes", func() {
+		chaincodeSupport.Shutdown(time.Second)
+		Expect(stopped).To(Equal([]string{"cc-a:hash-a", "cc-b:hash-b"}))
+		Expect(handlerRegistry.Handler(sysccid)).To(BeNil())
+	})
+
+	Context("when dependencies are declared", func() {
+		BeforeEach(func() {
+			fakeDependencies.DependenciesStub = func(ccid string) []string {
+				if ccid == "cc-b:hash-b" {
+					return []string{"cc-a:hash-a"}
+				}
+				return nil
+			}
+			chaincodeSupport.ShutdownDependencies = fakeDependencies
+		})
+
+		It("stops chaincodes before their dependencies", func() {
+			chaincodeSupport.Shutdown(time.Second)
+			Expect(stopped).To(Equal([]string{"cc-b:hash-b", "cc-a:hash-a"}))
+			Expect(handlerRegistry.Handler(sysccid)).To(BeNil())
+		})
+
+		Context("when the dependencies form a cycle", func() {
+			BeforeEach(func() {
+				fakeDependencies.DependenciesReturns([]string{"cc-a:hash-a", "cc-b:hash-b"})
+				fakeDependencies.DependenciesStub = nil
+			})
+
+			It("still stops system chaincodes last", func() {
+				chaincodeSupport.Shutdown(time.Second)
+				Expect(stopped).To(Equal([]string{"cc-a:hash-a", "cc-b:hash-b"}))
+				Expect(handlerRegistry.Handler(sysccid)).To(BeNil())
+			})
+		})
+	})
+})
+
+var _ = Describe("Uptime", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		handlerRegistry  *chaincode.HandlerRegistry
+	)
+
+	BeforeEach(func() {
+		handlerRegistry = chaincode.NewHandlerRegistry(true)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry: handlerRegistry,
+		}
+	})
+
+	register := func() {
+		payload, err := proto.Marshal(&pb.ChaincodeID{Name: "chaincode-id"})
+		Expect(err).NotTo(HaveOccurred())
+
+		handler := &chaincode.Handler{
+			Registry:   handlerRegistry,
+			TXContexts: chaincode.NewTransactionContexts(),
+		}
+		chaincode.SetHandlerChatStream(handler, &mock.ChaincodeStream{})
+		handler.HandleRegister(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload})
+		Expect(handlerRegistry.Handler("chaincode-id")).To(Equal(handler))
+	}
+
+	uptime := func() time.Duration {
+		uptime, ok := chaincodeSupport.Uptime("chaincode-id")
+		Expect(ok).To(BeTrue())
+		return uptime
+	}
+
+	It("increases while the chaincode is running", func() {
+		register()
+		initial := uptime()
+		Eventually(uptime).Should(BeNumerically(">", initial+10*time.Millisecond))
+	})
+
+	It("starts again when the chaincode is relaunched", func() {
+		register()
+		time.Sleep(50 * time.Millisecond)
+		beforeRestart := uptime()
+		Expect(beforeRestart).To(BeNumerically(">=", 50*time.Millisecond))
+
+		Expect(handlerRegistry.Deregister("chaincode-id")).To(Succeed())
+		_, ok := chaincodeSupport.Uptime("chaincode-id")
+		Expect(ok).To(BeFalse())
+
+		register()
+		Expect(uptime()).To(BeNumerically("<", beforeRestart))
+	})
+
+	It("reports chaincodes that are not registered", func() {
+		_, ok := chaincodeSupport.Uptime("missing-chaincode")
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("RegisteredAddress", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeChatStream   *mock.ChaincodeStream
+	)
+
+	BeforeEach(func() {
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry: chaincode.NewHandlerRegistry(true),
+		}
+
+		fakeChatStream = &mock.ChaincodeStream{}
+	})
+
+	// registeredAddress streams a registration and returns the address
+	// reported while the chaincode is registered.
+	registeredAddress := func(stream ccintf.ChaincodeStream) (string, bool) {
+		payload, err := proto.Marshal(&pb.ChaincodeID{Name: "chaincode-id"})
+		Expect(err).NotTo(HaveOccurred())
+
+		var address string
+		var ok bool
+		registered := false
+		fakeChatStream.RecvStub = func() (*pb.ChaincodeMessage, error) {
+			if !registered {
+				registered = true
+				return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload}, nil
+			}
+			// the registration has been handled before the next receive
+			address, ok = chaincodeSupport.RegisteredAddress("chaincode-id")
+			return nil, io.EOF
+		}
+		chaincodeSupport.HandleChaincodeStream(stream)
+		return address, ok
+	}
+
+	It("returns the address of the connecting client", func() {
+		clientAddr := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 40123}
+		stream := &peerContextStream{
+			ChaincodeStream: fakeChatStream,
+			ctx:             grpcpeer.NewContext(context.Background(), &grpcpeer.Peer{Addr: clientAddr}),
+		}
+
+		address, ok := registeredAddress(stream)
+		Expect(ok).To(BeTrue())
+		Expect(address).To(Equal("192.0.2.10:40123"))
+	})
+
+	It("reports streams without a gRPC peer", func() {
+		_, ok := registeredAddress(fakeChatStream)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("reports chaincodes that are not registered", func() {
+		_, ok := chaincodeSupport.RegisteredAddress("missing-chaincode")
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("HandleChaincodeStream", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeChatStream   *mock.ChaincodeStream
+	)
+
+	BeforeEach(func() {
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry:   chaincode.NewHandlerRegistry(true),
+			SendBufferSize:    16,
+			ReceiveBufferSize: 8,
+		}
+
+		fakeChatStream = &mock.ChaincodeStream{}
+	})
+
+	It("applies the configured buffer sizes to the handler", func() {
+		payload, err := proto.Marshal(&pb.ChaincodeID{Name: "chaincode-id"})
+		Expect(err).NotTo(HaveOccurred())
+
+		release := make(chan struct{})
+		registered := false
+		fakeChatStream.RecvStub = func() (*pb.ChaincodeMessage, error) {
+			if !registered {
+				registered = true
+				return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload}, nil
+			}
+			<-release
+			return nil, io.EOF
+		}
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- chaincodeSupport.HandleChaincodeStream(fakeChatStream) }()
+
+		Eventually(func() *chaincode.Handler {
+			return chaincodeSupport.HandlerRegistry.Handler("chaincode-id")
+		}).ShouldNot(BeNil())
+		handler := chaincodeSupport.HandlerRegistry.Handler("chaincode-id")
+		Expect(handler.SendBufferSize).To(Equal(16))
+		Expect(handler.ReceiveBufferSize).To(Equal(8))
+
+		close(release)
+		Eventually(errCh).Should(Receive(Equal(io.EOF)))
+	})
+})
+
+var _ = Describe("Ready", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		handlerRegistry  *chaincode.HandlerRegistry
+		fakeChatStream   *mock.ChaincodeStream
+	)
+
+	BeforeEach(func() {
+		handlerRegistry = chaincode.NewHandlerRegistry(false)
+		fakeChatStream = &mock.ChaincodeStream{}
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry: handlerRegistry,
+		}
+	})
+
+	It("closes when the launched chaincode becomes ready", func() {
+		readyCh := chaincodeSupport.Ready("chaincode-id")
+		handlerRegistry.Launching("chaincode-id")
+		Consistently(readyCh).ShouldNot(BeClosed())
+
+		payload, err := proto.Marshal(&pb.ChaincodeID{Name: "chaincode-id"})
+		Expect(err).NotTo(HaveOccurred())
+		handler := &chaincode.Handler{Registry: handlerRegistry}
+		chaincode.SetHandlerChatStream(handler, fakeChatStream)
+		fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+			if msg.Type == pb.ChaincodeMessage_READY {
+				Expect(readyCh).NotTo(BeClosed())
+			}
+			return nil
+		}
+
+		handler.HandleRegister(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload})
+		Expect(handler.State()).To(Equal(chaincode.Ready))
+		Expect(readyCh).To(BeClosed())
+	})
+
+	It("is already closed for a ready chaincode", func() {
+		handlerRegistry.Launching("chaincode-id")
+		handlerRegistry.Ready("chaincode-id")
+		Expect(chaincodeSupport.Ready("chaincode-id")).To(BeClosed())
+	})
+})
+
+var _ = Describe("OnStreamClosed", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeChatStream   *mock.ChaincodeStream
+		closedCh         chan error
+		closedCCIDs      chan string
+	)
+
+	BeforeEach(func() {
+		closedCh = make(chan error, 1)
+		closedCCIDs = make(chan string, 1)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry: chaincode.NewHandlerRegistry(true),
+			OnStreamClosed: func(ccid string, err error) {
+				closedCCIDs <- ccid
+				closedCh <- err
+			},
+		}
+
+		payload, err := proto.Marshal(&pb.ChaincodeID{Name: "chaincode-id"})
+		Expect(err).NotTo(HaveOccurred())
+		fakeChatStream = &mock.ChaincodeStream{}
+		fakeChatStream.RecvReturnsOnCall(0, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload}, nil)
+	})
+
+	It("is called with the error which terminated the stream", func() {
+		fakeChatStream.RecvReturnsOnCall(1, nil, errors.New("connection reset"))
+
+		err := chaincodeSupport.HandleChaincodeStream(fakeChatStream)
+		Expect(err).To(MatchError(ContainSubstring("connection reset")))
+		Expect(closedCCIDs).To(Receive(Equal("chaincode-id")))
+		Expect(closedCh).To(Receive(MatchError(ContainSubstring("connection reset"))))
+	})
+
+	It("is called with a nil error when the chaincode closes the stream", func() {
+		fakeChatStream.RecvReturnsOnCall(1, nil, io.EOF)
+
+		err := chaincodeSupport.HandleChaincodeStream(fakeChatStream)
+		Expect(err).To(Equal(io.EOF))
+		Expect(closedCCIDs).To(Receive(Equal("chaincode-id")))
+		Expect(closedCh).To(Receive(BeNil()))
+	})
+})
+
+var _ = Describe("TryLaunch", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		handlerRegistry  *chaincode.HandlerRegistry
+		limiter          *chaincode.LaunchLimiter
+	)
+
+	BeforeEach(func() {
+		handlerRegistry = chaincode.NewHandlerRegistry(true)
+		limiter = chaincode.NewLaunchLimiter(1)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry: handlerRegistry,
+			Launcher: &chaincode.RuntimeLauncher{
+				Registry: handlerRegistry,
+				Limiter:  limiter,
+			},
+		}
+	})
+
+	It("succeeds for a running chaincode", func() {
+		handler := &chaincode.Handler{}
+		chaincode.SetHandlerChaincodeID(handler, "chaincode-id")
+		Expect(handlerRegistry.Register(handler)).To(Succeed())
+		limiter.Acquire("channel-id")
+
+		launched, err := chaincodeSupport.TryLaunch("chaincode-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(launched).To(BeTrue())
+	})
+
+	Context("when the launch limit is saturated", func() {
+		BeforeEach(func() {
+			limiter.Acquire("channel-id")
+		})
+
+		It("returns false without launching", func() {
+			launched, err := chaincodeSupport.TryLaunch("chaincode-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(launched).To(BeFalse())
+			Expect(handlerRegistry.Running("chaincode-id")).To(BeFalse())
+		})
+	})
+
+	Context("when invocations are rejected during launch", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			fakeRuntime := &mock.Runtime{}
+			fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+				<-release
+				return nil, errors.New("image-missing")
+			}
+			fakeLaunchFailures := &metricsfakes.Counter{}
+			fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+			fakeLaunchDuration := &metricsfakes.Histogram{}
+			fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+			chaincodeSupport.LaunchPolicy = chaincode.RejectDuringLaunch
+			chaincodeSupport.Launcher = &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       handlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			}
+		})
+
+		It("rejects the try while the chaincode is being launched", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				_, err := chaincodeSupport.Launch("chaincode-id")
+				errCh <- err
+			}()
+			Eventually(func() int { return chaincode.LaunchesInProgress(chaincodeSupport, "chaincode-id") }).Should(Equal(1))
+
+			launched, err := chaincodeSupport.TryLaunch("chaincode-id")
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchInProgress))
+			Expect(launched).To(BeFalse())
+
+			close(release)
+			Eventually(errCh).Should(Receive(HaveOccurred()))
+		})
+	})
+})
+
+var _ = Describe("PendingLaunches", func() {
+	It("is empty when no launches are in progress", func() {
+		chaincodeSupport := &chaincode.ChaincodeSupport{
+			Launcher: &chaincode.RuntimeLauncher{},
+		}
+		Expect(chaincodeSupport.PendingLaunches()).To(BeEmpty())
+	})
+
+	It("is empty when the launcher is not a RuntimeLauncher", func() {
+		chaincodeSupport := &chaincode.ChaincodeSupport{}
+		Expect(chaincodeSupport.PendingLaunches()).To(BeEmpty())
+	})
+
+	It("is settled when no launches are in progress", func() {
+		chaincodeSupport := &chaincode.ChaincodeSupport{
+			Launcher: &chaincode.RuntimeLauncher{},
+		}
+		Expect(chaincodeSupport.WaitLaunchesSettled(context.Background())).To(Succeed())
+	})
+})
+
+var _ = Describe("automatic restart", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeRuntime      *mock.Runtime
+		closeOnShutdown  bool
+	)
+
+	// connect registers the chaincode over a stream which closes once the
+	// returned function is called, or when the chaincode is asked to shut
+	// down if closeOnShutdown is set. The stream is closed by the time the
+	// function returns.
+	connect := func(ccid string) func() {
+		recvCh := make(chan *pb.ChaincodeMessage, 1)
+		payload, err := proto.Marshal(&pb.ChaincodeID{Name: ccid})
+		Expect(err).NotTo(HaveOccurred())
+		recvCh <- &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload}
+
+		var closeOnce sync.Once
+		closeStream := func() { closeOnce.Do(func() { close(recvCh) }) }
+
+		stream := &mock.ChaincodeStream{}
+		stream.SendStub = func(msg *pb.ChaincodeMessage) error {
+			if closeOnShutdown && msg.Type == chaincode.ShutdownMessageType {
+				closeStream()
+			}
+			return nil
+		}
+		stream.RecvStub = func() (*pb.ChaincodeMessage, error) {
+			msg, ok := <-recvCh
+			if !ok {
+				return nil, io.EOF
+			}
+			return msg, nil
+		}
+
+		doneCh := make(chan struct{})
+		go func() {
+			defer close(doneCh)
+			chaincodeSupport.HandleChaincodeStream(stream)
+		}()
+		Eventually(chaincodeSupport.Ready(ccid)).Should(BeClosed())
+
+		return func() {
+			closeStream()
+			Eventually(doneCh).Should(BeClosed())
+		}
+	}
+
+	BeforeEach(func() {
+		closeOnShutdown = false
+		fakeRuntime = &mock.Runtime{}
+		fakeRuntime.BuildReturns(nil, errors.New("image-missing"))
+
+		fakeLaunchFailures := &metricsfakes.Counter{}
+		fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+		fakeLaunchDuration := &metricsfakes.Histogram{}
+		fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+
+		handlerRegistry := chaincode.NewHandlerRegistry(true)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			AutoRestart:     true,
+			MaxAutoRestarts: 1,
+			HandlerRegistry: handlerRegistry,
+			Launcher: &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       handlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			},
+		}
+	})
+
+	It("relaunches a chaincode which exits unexpectedly", func() {
+		connect("restart-ccid")()
+		Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+		Expect(fakeRuntime.BuildArgsForCall(0)).To(Equal("restart-ccid"))
+	})
+
+	It("stops relaunching once the restart limit is reached", func() {
+		connect("restart-ccid")()
+		Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+
+		connect("restart-ccid")()
+		Consistently(fakeRuntime.BuildCallCount).Should(Equal(1))
+	})
+
+	It("restarts chaincodes again once they have run for the reset interval", func() {
+		chaincodeSupport.AutoRestartReset = 50 * time.Millisecond
+
+		release := connect("restart-ccid")
+		time.Sleep(60 * time.Millisecond)
+		release()
+		Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+
+		release = connect("restart-ccid")
+		time.Sleep(60 * time.Millisecond)
+		release()
+		Eventually(fakeRuntime.BuildCallCount).Should(Equal(2))
+	})
+
+	It("relaunches a chaincode which exits after an earlier stop of it while it was not running", func() {
+		Expect(chaincodeSupport.Stop("restart-ccid")).To(Succeed())
+		Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+
+		connect("restart-ccid")()
+		Eventually(fakeRuntime.BuildCallCount).Should(Equal(1))
+	})
+
+	It("does not relaunch a chaincode stopped by the peer", func() {
+		release := connect("restart-ccid")
+		Expect(chaincodeSupport.Stop("restart-ccid")).To(Succeed())
+		Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+		release()
+
+		Consistently(fakeRuntime.BuildCallCount).Should(Equal(0))
+	})
+
+	It("does not relaunch a chaincode which closes its stream when asked to shut down", func() {
+		closeOnShutdown = true
+		chaincodeSupport.GracefulStopTimeout = time.Minute
+		release := connect("restart-ccid")
+		Expect(chaincodeSupport.Stop("restart-ccid")).To(Succeed())
+		release()
+
+		Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+		Consistently(fakeRuntime.BuildCallCount).Should(Equal(0))
+	})
+
+	Context("when automatic restart is disabled", func() {
+		BeforeEach(func() {
+			chaincodeSupport.AutoRestart = false
+		})
+
+		It("does not relaunch the chaincode", func() {
+			connect("restart-ccid")()
+			Consistently(fakeRuntime.BuildCallCount).Should(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("launch failure caching", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeRuntime      *mock.Runtime
+	)
+
+	BeforeEach(func() {
+		fakeRuntime = &mock.Runtime{}
+		fakeRuntime.BuildReturns(nil, errors.New("image-missing"))
+
+		fakeLaunchFailures := &metricsfakes.Counter{}
+		fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+		fakeLaunchDuration := &metricsfakes.Histogram{}
+		fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+
+		handlerRegistry := chaincode.NewHandlerRegistry(true)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry:  handlerRegistry,
+			LaunchFailureTTL: time.Hour,
+			Launcher: &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       handlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			},
+		}
+	})
+
+	It("fails fast with the cached error within the TTL", func() {
+		_, err := chaincodeSupport.Launch("chaincode-id")
+		Expect(err).To(MatchError("could not launch chaincode chaincode-id: error building chaincode: image-missing"))
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+
+		_, err = chaincodeSupport.Launch("chaincode-id")
+		Expect(err).To(MatchError("chaincode chaincode-id recently failed to launch: could not launch chaincode chaincode-id: error building chaincode: image-missing"))
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+
+		_, err = chaincodeSupport.TryLaunch("chaincode-id")
+		Expect(err).To(MatchError(ContainSubstring("recently failed to launch")))
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+	})
+
+	It("does not affect other chaincodes", func() {
+		chaincodeSupport.Launch("chaincode-id")
+		chaincodeSupport.Launch("other-chaincode-id")
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(2))
+	})
+
+	It("reports the cached error in diagnostics", func() {
+		chaincodeSupport.Launch("chaincode-id")
+		Expect(chaincodeSupport.DiagnosticsDump().LaunchErrors).To(Equal(map[string]string{
+			"chaincode-id": "could not launch chaincode chaincode-id: error building chaincode: image-missing",
+		}))
+	})
+
+	Context("when the TTL has expired", func() {
+		BeforeEach(func() {
+			chaincodeSupport.LaunchFailureTTL = time.Millisecond
+		})
+
+		It("attempts the launch again", func() {
+			chaincodeSupport.Launch("chaincode-id")
+			time.Sleep(5 * time.Millisecond)
+			_, err := chaincodeSupport.Launch("chaincode-id")
+			Expect(err).To(MatchError("could not launch chaincode chaincode-id: error building chaincode: image-missing"))
+			Expect(fakeRuntime.BuildCallCount()).To(Equal(2))
+		})
+	})
+
+	Context("when the TTL backs off", func() {
+		// relaunch launches the chaincode once its cached failure expires.
+		relaunch := func() {
+			builds := fakeRuntime.BuildCallCount()
+			Eventually(func() int {
+				chaincodeSupport.Launch("chaincode-id")
+				return fakeRuntime.BuildCallCount()
+			}).Should(Equal(builds + 1))
+		}
+
+		BeforeEach(func() {
+			chaincodeSupport.LaunchFailureTTL = 5 * time.Millisecond
+			chaincodeSupport.LaunchFailureMaxTTL = 20 * time.Millisecond
+		})
+
+		It("doubles the TTL with each consecutive failure up to the maximum", func() {
+			var backoffs []time.Duration
+			for i := 0; i < 4; i++ {
+				relaunch()
+				backoffs = append(backoffs, chaincode.LaunchFailureBackoff(chaincodeSupport, "chaincode-id"))
+			}
+			Expect(backoffs).To(Equal([]time.Duration{
+				5 * time.Millisecond,
+				10 * time.Millisecond,
+				20 * time.Millisecond,
+				20 * time.Millisecond,
+			}))
+		})
+
+		It("counts a failed launch joined by concurrent callers once", func() {
+			build := make(chan struct{})
+			fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+				<-build
+				return nil, errors.New("image-missing")
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					chaincodeSupport.Launch("chaincode-id")
+				}()
+			}
+			Eventually(func() int { return chaincode.LaunchesInProgress(chaincodeSupport, "chaincode-id") }).Should(Equal(5))
+			close(build)
+			wg.Wait()
+
+			Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+			Expect(chaincode.LaunchFailureBackoff(chaincodeSupport, "chaincode-id")).To(Equal(5 * time.Millisecond))
+		})
+
+		It("resets the TTL once the chaincode launches successfully", func() {
+			for i := 0; i < 3; i++ {
+				relaunch()
+			}
+			Expect(chaincode.LaunchFailureBackoff(chaincodeSupport, "chaincode-id")).To(Equal(20 * time.Millisecond))
+
+			fakeRuntime.BuildReturns(nil, nil)
+			fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+				launched := &chaincode.Handler{TXContexts: chaincode.NewTransactionContexts()}
+				chaincode.SetHandlerChaincodeID(launched, ccid)
+				Expect(chaincodeSupport.HandlerRegistry.Register(launched)).To(Succeed())
+				chaincodeSupport.HandlerRegistry.Ready(ccid)
+				return nil
+			}
+			relaunch()
+			Expect(chaincodeSupport.HandlerRegistry.Handler("chaincode-id")).NotTo(BeNil())
+			Expect(chaincode.LaunchFailureBackoff(chaincodeSupport, "chaincode-id")).To(BeZero())
+
+			Expect(chaincodeSupport.HandlerRegistry.Deregister("chaincode-id")).To(Succeed())
+			fakeRuntime.BuildReturns(nil, errors.New("image-missing"))
+			relaunch()
+			Expect(chaincode.LaunchFailureBackoff(chaincodeSupport, "chaincode-id")).To(Equal(5 * time.Millisecond))
+		})
+	})
+
+	Context("when caching is disabled", func() {
+		BeforeEach(func() {
+			chaincodeSupport.LaunchFailureTTL = 0
+		})
+
+		It("attempts every launch", func() {
+			chaincodeSupport.Launch("chaincode-id")
+			chaincodeSupport.Launch("chaincode-id")
+			Expect(fakeRuntime.BuildCallCount()).To(Equal(2))
+		})
+	})
+})
+
+var _ = Describe("launch intents", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeRuntime      *mock.Runtime
+		intentsDir       string
+	)
+
+	newChaincodeSupport := func() *chaincode.ChaincodeSupport {
+		fakeLaunchFailures := &metricsfakes.Counter{}
+		fakeLaunchFailures.WithReturns(fakeLaunchFailures)
+		fakeLaunchDuration := &metricsfakes.Histogram{}
+		fakeLaunchDuration.WithReturns(fakeLaunchDuration)
+
+		handlerRegistry := chaincode.NewHandlerRegistry(true)
+		return &chaincode.ChaincodeSupport{
+			HandlerRegistry: handlerRegistry,
+			LaunchIntents:   &chaincode.FileLaunchIntentStore{Path: intentsDir},
+			Launcher: &chaincode.RuntimeLauncher{
+				Runtime:        fakeRuntime,
+				Registry:       handlerRegistry,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchFailures: fakeLaunchFailures,
+					LaunchDuration: fakeLaunchDuration,
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		intentsDir, err = os.MkdirTemp("", "launch-intents")
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeRuntime = &mock.Runtime{}
+		fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+			launched := &chaincode.Handler{TXContexts: chaincode.NewTransactionContexts()}
+			chaincode.SetHandlerChaincodeID(launched, ccid)
+			Expect(chaincodeSupport.HandlerRegistry.Register(launched)).To(Succeed())
+			chaincodeSupport.HandlerRegistry.Ready(ccid)
+			return nil
+		}
+		chaincodeSupport = newChaincodeSupport()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(intentsDir)
+	})
+
+	It("records a launch while it is in progress", func() {
+		var inProgress []chaincode.LaunchIntent
+		fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+			var err error
+			inProgress, err = chaincodeSupport.LaunchIntents.List()
+			return nil, err
+		}
+
+		_, err := chaincodeSupport.Launch("chaincode-id")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inProgress).To(ConsistOf(chaincode.LaunchIntent{ChaincodeID: "chaincode-id"}))
+		Expect(chaincodeSupport.LaunchIntents.List()).To(BeEmpty())
+	})
+
+	It("forgets a failed launch", func() {
+		fakeRuntime.BuildReturns(nil, errors.New("image-missing"))
+
+		_, err := chaincodeSupport.Launch("chaincode-id")
+		Expect(err).To(MatchError(ContainSubstring("image-missing")))
+		Expect(chaincodeSupport.LaunchIntents.List()).To(BeEmpty())
+	})
+
+	It("resumes a launch interrupted by a restart", func() {
+		interrupted := &chaincode.FileLaunchIntentStore{Path: intentsDir}
+		Expect(interrupted.Put(chaincode.LaunchIntent{ChaincodeID: "chaincode-id", ChannelID: "channel-id"})).To(Succeed())
+
+		// the restarted peer starts from a fresh chaincode support
+		chaincodeSupport = newChaincodeSupport()
+		Expect(chaincodeSupport.ResumeLaunches()).To(Succeed())
+
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+		Expect(fakeRuntime.BuildArgsForCall(0)).To(Equal("chaincode-id"))
+		Expect(chaincodeSupport.HandlerRegistry.Handler("chaincode-id")).NotTo(BeNil())
+		Expect(chaincodeSupport.LaunchIntents.List()).To(BeEmpty())
+	})
+
+	It("does nothing when no launch was interrupted", func() {
+		Expect(chaincodeSupport.ResumeLaunches()).To(Succeed())
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(0))
+	})
+
+	It("fails when the recorded launches cannot be read", func() {
+		Expect(os.WriteFile(filepath.Join(intentsDir, "corrupt.json"), []byte("{"), 0o600)).To(Succeed())
+
+		err := chaincodeSupport.ResumeLaunches()
+		Expect(err).To(MatchError(ContainSubstring("failed to list interrupted launches: failed to unmarshal launch intent corrupt.json")))
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(0))
+	})
+})
+
+var _ = Describe("ImageDigest", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		handlerRegistry  *chaincode.HandlerRegistry
+		fakeRuntime      *mock.Runtime
+		exitCh           chan int
+	)
+
+	BeforeEach(func() {
+		handlerRegistry = chaincode.NewHandlerRegistry(true)
+
+		fakeRuntime = &mock.Runtime{}
+		fakeRuntime.ImageDigestReturns("sha256:digest", nil)
+		fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+			handler := &chaincode.Handler{}
+			chaincode.SetHandlerChaincodeID(handler, ccid)
+			Expect(handlerRegistry.Register(handler)).To(Succeed())
+			handlerRegistry.Ready(ccid)
+			return nil
+		}
+		exitCh = make(chan int)
+		waitExitCh := exitCh // shadow to avoid race
+		fakeRuntime.WaitStub = func(string) (int, error) {
+			return <-waitExitCh, nil
+		}
+
+		launchDuration := &metricsfakes.Histogram{}
+		launchDuration.WithReturns(launchDuration)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			HandlerRegistry: handlerRegistry,
+			Launcher: &chaincode.RuntimeLauncher{
+				Registry:       handlerRegistry,
+				Runtime:        fakeRuntime,
+				StartupTimeout: time.Minute,
+				Metrics: &chaincode.LaunchMetrics{
+					LaunchDuration: launchDuration,
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		close(exitCh)
+	})
+
+	It("is recorded after launch", func() {
+		_, ok := chaincodeSupport.ImageDigest("chaincode-id")
+		Expect(ok).To(BeFalse())
+
+		_, err := chaincodeSupport.Launch("chaincode-id")
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() string {
+			digest, _ := chaincodeSupport.ImageDigest("chaincode-id")
+			return digest
+		}).Should(Equal("sha256:digest"))
+	})
+})
+
+var _ = Describe("concurrent launches", func() {
+	var (
+		chaincodeSupport *chaincode.ChaincodeSupport
+		fakeRuntime      *mock.Runtime
+		fakeLifecycle    *mock.Lifecycle
+		startCh          chan struct{}
+		exitCh           chan int
+		txParams         *ccprovider.TransactionParams
+	)
+
+	BeforeEach(func() {
+		handlerRegistry := chaincode.NewHandlerRegistry(false)
+
+		startCh = make(chan struct{})
+		start := startCh // shadow to avoid race
+		fakeRuntime = &mock.Runtime{}
+		fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+			<-start
+
+			fakeExecuteTimeouts := &metricsfakes.Counter{}
+			fakeExecuteTimeouts.WithReturns(fakeExecuteTimeouts)
+			handler := &chaincode.Handler{
+				TXContexts:   chaincode.NewTransactionContexts(),
+				LedgerGetter: &mock.LedgerGetter{},
+				Metrics:      &chaincode.HandlerMetrics{ExecuteTimeouts: fakeExecuteTimeouts},
+			}
+			fakeChatStream := &mock.ChaincodeStream{}
+			fakeChatStream.SendStub = func(msg *pb.ChaincodeMessage) error {
+				go handler.Notify(&pb.ChaincodeMessage{
+					Type:      pb.ChaincodeMessage_COMPLETED,
+					Payload:   protoutil.MarshalOrPanic(&pb.Response{Status: 200}),
+					Txid:      msg.Txid,
+					ChannelId: msg.ChannelId,
+				})
+				return nil
+			}
+			chaincode.SetHandlerChatStream(handler, fakeChatStream)
+			chaincode.SetHandlerChaincodeID(handler, ccid)
+			Expect(handlerRegistry.Register(handler)).To(Succeed())
+			handlerRegistry.Ready(ccid)
+			return nil
+		}
+		exitCh = make(chan int)
+		waitExitCh := exitCh // shadow to avoid race
+		fakeRuntime.WaitStub = func(string) (int, error) {
+			return <-waitExitCh, nil
+		}
+
+		fakeLifecycle = &mock.Lifecycle{}
+		fakeLifecycle.ChaincodeEndorsementInfoReturns(&lifecycle.ChaincodeEndorsementInfo{
+			Version:     "1.0",
+			ChaincodeID: "test-chaincode-name:1.0",
+		}, nil)
+
+		launchDuration := &metricsfakes.Histogram{}
+		launchDuration.WithReturns(launchDuration)
+		responseSize := &metricsfakes.Histogram{}
+		responseSize.WithReturns(responseSize)
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			ExecuteTimeout:  time.Minute,
+			HandlerMetrics:  &chaincode.HandlerMetrics{ResponseSize: responseSize},
+			HandlerRegistry: handlerRegistry,
+			Lifecycle:       fakeLifecycle,
+			Launcher: &chaincode.RuntimeLauncher{
+				Registry:       handlerRegistry,
+				Runtime:        fakeRuntime,
+				StartupTimeout: time.Minute,
+				Metrics:        &chaincode.LaunchMetrics{LaunchDuration: launchDuration},
+			},
+		}
+
+		txParams = &ccprovider.TransactionParams{
+			TxID:        "init-tx-id",
+			ChannelID:   "channel-id",
+			TXSimulator: &mock.TxSimulator{},
+		}
+	})
+
+	AfterEach(func() {
+		close(exitCh)
+	})
+
+	It("starts a cold chaincode once for a concurrent init and invoke", func() {
+		initErrCh := make(chan error, 1)
+		go func() {
+			_, _, err := chaincodeSupport.ExecuteLegacyInit(txParams, "test-chaincode-name", "1.0", &pb.ChaincodeInput{})
+			initErrCh <- err
+		}()
+		invokeErrCh := make(chan error, 1)
+		go func() {
+			invokeParams := *txParams
+			invokeParams.TxID = "invoke-tx-id"
+			_, err := chaincodeSupport.Invoke(&invokeParams, "test-chaincode-name", &pb.ChaincodeInput{})
+			invokeErrCh <- err
+		}()
+
+		Eventually(fakeRuntime.StartCallCount).Should(Equal(1))
+		Eventually(fakeLifecycle.ChaincodeEndorsementInfoCallCount).Should(Equal(1))
+		close(startCh)
+
+		Eventually(initErrCh).Should(Receive(BeNil()))
+		Eventually(invokeErrCh).Should(Receive(BeNil()))
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+		Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+	})
+
+	It("rejects a name containing the chaincode ID separator", func() {
+		_, _, err := chaincodeSupport.ExecuteLegacyInit(txParams, "test-chaincode-name:1.0", "2.0", &pb.ChaincodeInput{})
+		Expect(err).To(MatchError("chaincode name 'test-chaincode-name:1.0' must not contain ':'"))
+		Expect(fakeRuntime.BuildCallCount()).To(Equal(0))
+	})
+})
+
+var _ = Describe("EffectiveConfig", func() {
+	var chaincodeSupport *chaincode.ChaincodeSupport
+
+	BeforeEach(func() {
+		chaincodeSupport = &chaincode.ChaincodeSupport{
+			ExecuteTimeout:  30 * time.Second,
+			InstallTimeout:  5 * time.Minute,
+			Keepalive:       time.Minute,
+			TotalQueryLimit: 100,
+			Launcher: &chaincode.RuntimeLauncher{
+				StartupTimeout:      5 * time.Minute,
+				StallTimeout:        time.Minute,
+				RegistrationTimeout: 30 * time.Second,
+				Jitter:              time.Second,
+				Limiter:             chaincode.NewLaunchLimiter(4),
+			},
+		}
+	})
+
+	It("returns the settings in effect", func() {
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.ExecuteTimeout).To(Equal(30 * time.Second))
+		Expect(config.InstallTimeout).To(Equal(5 * time.Minute))
+		Expect(config.Keepalive).To(Equal(time.Minute))
+		Expect(config.TotalQueryLimit).To(Equal(100))
+		Expect(config.StartupTimeout).To(Equal(5 * time.Minute))
+		Expect(config.LaunchStallTimeout).To(Equal(time.Minute))
+		Expect(config.RegistrationTimeout).To(Equal(30 * time.Second))
+		Expect(config.LaunchJitter).To(Equal(time.Second))
+		Expect(config.StartupProbe).To(BeNil())
+		Expect(config.MaxConcurrentLaunches).To(Equal(4))
+	})
+
+	It("includes the init launch priority", func() {
+		chaincodeSupport.Launcher.(*chaincode.RuntimeLauncher).PrioritizeInit = true
+		Expect(chaincodeSupport.EffectiveConfig().PrioritizeInitLaunches).To(BeTrue())
+	})
+
+	It("includes the chaincode memory budget", func() {
+		chaincodeSupport.Launcher.(*chaincode.RuntimeLauncher).MemoryBudget = chaincode.NewMemoryBudget(8192<<20, 512<<20)
+		Expect(chaincodeSupport.EffectiveConfig().TotalChaincodeMemoryLimitMB).To(Equal(8192))
+	})
+
+	It("includes the launch failure webhook", func() {
+		chaincodeSupport.Launcher.(*chaincode.RuntimeLauncher).FailureNotifier = &chaincode.WebhookNotifier{URL: "https://alerts.example.com/fabric"}
+		Expect(chaincodeSupport.EffectiveConfig().LaunchFailureWebhook).To(Equal("https://alerts.example.com/fabric"))
+	})
+
+	It("includes the container network", func() {
+		chaincodeSupport.Launcher.(*chaincode.RuntimeLauncher).ContainerNetwork = "sidecar-net"
+		Expect(chaincodeSupport.EffectiveConfig().ContainerNetwork).To(Equal("sidecar-net"))
+	})
+
+	It("includes the default channel", func() {
+		chaincodeSupport.DefaultChannel = "default-channel"
+		Expect(chaincodeSupport.EffectiveConfig().DefaultChannel).To(Equal("default-channel"))
+	})
+
+	It("includes the container start backoff", func() {
+		chaincodeSupport.Runtime = &chaincode.ContainerRuntime{
+			StartBackoff: &chaincode.ExponentialStartBackoff{Retries: 3, Initial: time.Second},
+		}
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.StartRetries).To(Equal(3))
+		Expect(config.StartRetryBackoff).To(Equal(time.Second))
+	})
+
+	It("includes the launch failure TTL", func() {
+		chaincodeSupport.LaunchFailureTTL = time.Minute
+		Expect(chaincodeSupport.EffectiveConfig().LaunchFailureTTL).To(Equal(time.Minute))
+	})
+
+	It("includes the maximum launch failure TTL", func() {
+		chaincodeSupport.LaunchFailureMaxTTL = time.Hour
+		Expect(chaincodeSupport.EffectiveConfig().LaunchFailureMaxTTL).To(Equal(time.Hour))
+	})
+
+	It("includes whether interrupted launches are resumed", func() {
+		Expect(chaincodeSupport.EffectiveConfig().ResumeLaunches).To(BeFalse())
+		chaincodeSupport.LaunchIntents = &chaincode.FileLaunchIntentStore{Path: "launches"}
+		Expect(chaincodeSupport.EffectiveConfig().ResumeLaunches).To(BeTrue())
+	})
+
+	It("includes the launch policy", func() {
+		chaincodeSupport.LaunchPolicy = chaincode.RejectDuringLaunch
+		Expect(chaincodeSupport.EffectiveConfig().LaunchPolicy).To(Equal(chaincode.RejectDuringLaunch))
+	})
+
+	It("includes whether keepalives are sent only to idle chaincodes", func() {
+		chaincodeSupport.KeepaliveIdleOnly = true
+		Expect(chaincodeSupport.EffectiveConfig().KeepaliveIdleOnly).To(BeTrue())
+	})
+
+	It("includes the context keys", func() {
+		chaincodeSupport.ContextKeys = []chaincode.ContextKey{"tenant"}
+		Expect(chaincodeSupport.EffectiveConfig().ContextKeys).To(Equal([]chaincode.ContextKey{"tenant"}))
+	})
+
+	It("includes the lifecycle lookup timeout", func() {
+		chaincodeSupport.LifecycleLookupTimeout = 3 * time.Second
+		Expect(chaincodeSupport.EffectiveConfig().LifecycleLookupTimeout).To(Equal(3 * time.Second))
+	})
+
+	It("includes the error format", func() {
+		chaincodeSupport.ErrorFormat = chaincode.VerboseErrors
+		Expect(chaincodeSupport.EffectiveConfig().ErrorFormat).To(Equal(chaincode.VerboseErrors))
+	})
+
+	It("includes the channel concurrency limits", func() {
+		chaincodeSupport.ChannelLimiter = chaincode.NewChannelLimiter(map[string]int{"busy-channel": 2}, chaincode.RejectAtChannelLimit, nil, nil)
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.ChannelLimits).To(Equal(map[string]int{"busy-channel": 2}))
+		Expect(config.ChannelLimitPolicy).To(Equal(chaincode.RejectAtChannelLimit))
+	})
+
+	It("includes the chaincode rate limits", func() {
+		chaincodeSupport.RateLimiter = chaincode.NewRateLimiter(map[string]chaincode.RateLimit{"busy-cc": {Rate: 5, Burst: 1}}, chaincode.RejectAtRateLimit)
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.RateLimits).To(Equal(map[string]chaincode.RateLimit{"busy-cc": {Rate: 5, Burst: 1}}))
+		Expect(config.RateLimitPolicy).To(Equal(chaincode.RejectAtRateLimit))
+	})
+
+	It("includes the serially executed chaincodes", func() {
+		chaincodeSupport.SerialExecution = map[string]bool{"unsafe-cc": true}
+		Expect(chaincodeSupport.EffectiveConfig().SerialExecution).To(Equal(map[string]bool{"unsafe-cc": true}))
+	})
+
+	It("includes the allowed response types", func() {
+		chaincodeSupport.AllowedResponseTypes = map[pb.ChaincodeMessage_Type]bool{pb.ChaincodeMessage_TRANSACTION: true}
+		Expect(chaincodeSupport.EffectiveConfig().AllowedResponseTypes).To(Equal(map[pb.ChaincodeMessage_Type]bool{pb.ChaincodeMessage_TRANSACTION: true}))
+	})
+
+	It("includes the message type mapping", func() {
+		chaincodeSupport.MessageTypes = map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type{pb.ChaincodeMessage_INIT: 101}
+		Expect(chaincodeSupport.EffectiveConfig().MessageTypes).To(Equal(map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type{pb.ChaincodeMessage_INIT: 101}))
+	})
+
+	It("includes the minimum container lifetime", func() {
+		chaincodeSupport.MinContainerLifetime = time.Minute
+		Expect(chaincodeSupport.EffectiveConfig().MinContainerLifetime).To(Equal(time.Minute))
+	})
+
+	It("includes whether unknown response fields are discarded", func() {
+		chaincodeSupport.DiscardUnknownFields = true
+		Expect(chaincodeSupport.EffectiveConfig().DiscardUnknownFields).To(BeTrue())
+	})
+
+	It("includes the graceful stop timeout", func() {
+		chaincodeSupport.GracefulStopTimeout = 10 * time.Second
+		Expect(chaincodeSupport.EffectiveConfig().GracefulStopTimeout).To(Equal(10 * time.Second))
+	})
+
+	It("includes the stop grace period", func() {
+		chaincodeSupport.StopGracePeriod = 20 * time.Second
+		Expect(chaincodeSupport.EffectiveConfig().StopGracePeriod).To(Equal(20 * time.Second))
+	})
+
+	It("includes the developer mode registration wait", func() {
+		chaincodeSupport.DevModeWait = 5 * time.Second
+		Expect(chaincodeSupport.EffectiveConfig().DevModeWait).To(Equal(5 * time.Second))
+	})
+
+	It("includes the automatic restart policy", func() {
+		chaincodeSupport.AutoRestart = true
+		chaincodeSupport.MaxAutoRestarts = 2
+		chaincodeSupport.AutoRestartReset = time.Minute
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.AutoRestart).To(BeTrue())
+		Expect(config.MaxAutoRestarts).To(Equal(2))
+		Expect(config.AutoRestartReset).To(Equal(time.Minute))
+	})
+
+	It("includes the panic policy", func() {
+		chaincodeSupport.StopOnPanic = true
+		Expect(chaincodeSupport.EffectiveConfig().StopOnPanic).To(BeTrue())
+	})
+
+	It("includes the send timeout", func() {
+		chaincodeSupport.SendTimeout = 5 * time.Second
+		Expect(chaincodeSupport.EffectiveConfig().SendTimeout).To(Equal(5 * time.Second))
+	})
+
+	It("includes the handler buffer sizes", func() {
+		chaincodeSupport.SendBufferSize = 16
+		chaincodeSupport.ReceiveBufferSize = 8
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.SendBufferSize).To(Equal(16))
+		Expect(config.ReceiveBufferSize).To(Equal(8))
+	})
+
+	It("includes the input size limits", func() {
+		chaincodeSupport.MaxInitInputBytes = 1024
+		chaincodeSupport.MaxInvokeInputBytes = 512
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.MaxInitInputBytes).To(Equal(1024))
+		Expect(config.MaxInvokeInputBytes).To(Equal(512))
+	})
+
+	It("includes the execute concurrency limit", func() {
+		chaincodeSupport.ExecuteLimiter = chaincode.NewExecuteLimiter(8, &metricsfakes.Gauge{})
+		chaincodeSupport.ExecuteLimiter.AlertThreshold = 2
+		chaincodeSupport.SoftConcurrencyLimit = 6
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.MaxConcurrentExecutions).To(Equal(8))
+		Expect(config.ExecuteQueueAlertThreshold).To(Equal(2))
+		Expect(config.SoftConcurrencyLimit).To(Equal(6))
+	})
+
+	It("includes the capacity reserved for system chaincodes", func() {
+		chaincodeSupport.SystemExecuteLimiter = chaincode.NewExecuteLimiter(2, &metricsfakes.Gauge{})
+		Expect(chaincodeSupport.EffectiveConfig().SystemExecutionReserve).To(Equal(2))
+	})
+
+	It("reflects settings changed at runtime", func() {
+		chaincodeSupport.ExecuteTimeout = time.Minute
+		chaincodeSupport.RetryOnTimeout = true
+		chaincodeSupport.KeepaliveRetries = 3
+		chaincodeSupport.Launcher.(*chaincode.RuntimeLauncher).StartupTimeout = 10 * time.Minute
+
+		config := chaincodeSupport.EffectiveConfig()
+		Expect(config.ExecuteTimeout).To(Equal(time.Minute))
+		Expect(config.RetryOnTimeout).To(BeTrue())
+		Expect(config.KeepaliveRetries).To(Equal(3))
+		Expect(config.StartupTimeout).To(Equal(10 * time.Minute))
+	})
+
+	Context("when the launcher is not a RuntimeLauncher", func() {
+		BeforeEach(func() {
+			chaincodeSupport.Launcher = nil
+		})
+
+		It("omits the launch settings", func() {
+			config := chaincodeSupport.EffectiveConfig()
+			Expect(config.ExecuteTimeout).To(Equal(30 * time.Second))
+			Expect(config.StartupTimeout).To(BeZero())
+			Expect(config.MaxConcurrentLaunches).To(BeZero())
+		})
+	})
+})
+
+// chunkRecorder is an io.Writer which records each write.
+type chunkRecorder struct {
+	chunks [][]byte
+	err    error
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.chunks = append(c.chunks, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// peerContextStream is a ChaincodeStream which carries a stream context, as
+// gRPC server streams do.
+type peerContextStream struct {
+	*mock.ChaincodeStream
+	ctx context.Context
+}
+
+func (p *peerContextStream) Context() context.Context { return p.ctx }
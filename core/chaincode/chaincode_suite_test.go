@@ -122,6 +122,11 @@ type applicationConfigRetriever interface {
 	chaincode.ApplicationConfigRetriever
 }
 
+//go:generate counterfeiter -o fake/launch_failure_notifier.go --fake-name LaunchFailureNotifier . launchFailureNotifier
+type launchFailureNotifier interface {
+	chaincode.LaunchFailureNotifier
+}
+
 //go:generate counterfeiter -o mock/collection_store.go --fake-name CollectionStore . collectionStore
 type collectionStore interface {
 	privdata.CollectionStore
@@ -152,7 +157,22 @@ type policy interface {
 	policies.Policy
 }
 
+//go:generate counterfeiter -o mock/dependency_provider.go --fake-name DependencyProvider . dependencyProvider
+type dependencyProvider interface {
+	chaincode.DependencyProvider
+}
+
+//go:generate counterfeiter -o mock/decoration_codec.go --fake-name DecorationCodec . decorationCodec
+type decorationCodec interface {
+	chaincode.DecorationCodec
+}
+
 //go:generate counterfeiter -o mock/connectionhandler.go --fake-name ConnectionHandler . connectionHandler
 type connectionHandler interface {
 	chaincode.ConnectionHandler
 }
+
+//go:generate counterfeiter -o mock/event_sink.go --fake-name EventSink . eventSink
+type eventSink interface {
+	chaincode.EventSink
+}
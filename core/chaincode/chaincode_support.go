@@ -8,10 +8,15 @@ package chaincode
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"runtime/debug"
 	"time"
 	"unicode/utf8"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-lib-go/common/flogging"
+	"github.com/hyperledger/fabric-protos-go/common"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/chaincode/extcc"
@@ -24,6 +29,7 @@ import (
 	"github.com/hyperledger/fabric/core/scc"
 	"github.com/hyperledger/fabric/msp"
 	"github.com/pkg/errors"
+	grpcpeer "google.golang.org/grpc/peer"
 )
 
 const (
@@ -33,6 +39,9 @@ const (
 	// the backing chaincode bytes change (but not be required to re-initialize
 	// the chaincode say, when endorsement policy changes).
 	InitializedKeyName = "\x00" + string(utf8.MaxRune) + "initialized"
+
+	// writeChunkSize is the largest write ExecuteToWriter makes to its writer.
+	writeChunkSize = 64 * 1024
 )
 
 // Runtime is used to manage chaincode runtime instances.
@@ -41,11 +50,16 @@ type Runtime interface {
 	Start(ccid string, ccinfo *ccintf.PeerConnection) error
 	Stop(ccid string) error
 	Wait(ccid string) (int, error)
+	ImageDigest(ccid string) (string, error)
+	MemoryUsage(ccid string) (uint64, bool, error)
+	Probe(ccid string, command []string, timeout time.Duration) error
 }
 
 // Launcher is used to launch chaincode runtimes.
 type Launcher interface {
 	Launch(ccid string, streamHandler extcc.StreamHandler) error
+	LaunchOnChannel(channelID, ccid string, streamHandler extcc.StreamHandler) error
+	TryLaunch(channelID, ccid string, streamHandler extcc.StreamHandler) (bool, error)
 	Stop(ccid string) error
 }
 
@@ -56,36 +70,238 @@ type Lifecycle interface {
 	ChaincodeEndorsementInfo(channelID, chaincodeName string, qe ledger.SimpleQueryExecutor) (*lifecycle.ChaincodeEndorsementInfo, error)
 }
 
+// ErrMissingChannel is returned when a chaincode is invoked without a channel
+// and no default channel is configured.
+var ErrMissingChannel = errors.New("no channel specified and no default channel configured")
+
+// A ResponseTransformer is applied to the response of every successfully
+// completed invocation before it is returned to the caller. An error returned
+// by the transformer fails the invocation.
+type ResponseTransformer func(txParams *ccprovider.TransactionParams, chaincodeName string, resp *pb.Response) (*pb.Response, error)
+
+// A StatusCodeHandler post-processes the response of a successfully completed
+// invocation which has a particular status, for example to turn a status into
+// a typed error. An error returned by the handler fails the invocation, as
+// does a nil response.
+type StatusCodeHandler func(resp *pb.Response) (*pb.Response, error)
+
+// A ResultValidator enforces invariants on the response of every successfully
+// completed invocation, such as the range of its status. An error returned by
+// the validator fails the invocation.
+type ResultValidator func(resp *pb.Response) error
+
+// ErrInputTooLarge is returned when the input of an invocation exceeds the
+// configured limit for its type.
+var ErrInputTooLarge = errors.New("chaincode input too large")
+
+// ErrVersionMismatch is returned for invocations which require a version of
+// the chaincode other than the one they resolved to.
+var ErrVersionMismatch = errors.New("chaincode version mismatch")
+
+// ExecuteMetadata describes the chaincode which served an execution.
+type ExecuteMetadata struct {
+	// ChaincodeID is the ID of the chaincode handler which executed the
+	// request.
+	ChaincodeID string
+	// Version is the version of the chaincode definition the handler was
+	// resolved from.
+	Version string
+	// MemoryBytes estimates the memory consumed by the execution as the
+	// growth of the memory used by the chaincode between the latest samples
+	// taken by SampleMemory at the start and the end of the execution. It is
+	// zero when no sample was taken during the execution, it is not the peak
+	// usage, and it includes the growth caused by concurrent executions.
+	MemoryBytes uint64
+	// MemoryAccounted indicates whether MemoryBytes was measured. It is
+	// false when memory accounting is disabled or the runtime cannot report
+	// the memory usage of the chaincode.
+	MemoryAccounted bool
+	// ColdStart indicates whether the chaincode was launched to serve the
+	// execution rather than found running. An execution which waited for a
+	// launch started by another caller also paid the cold start penalty.
+	ColdStart bool
+	// Access describes the state accessed through the transaction simulator
+	// while the chaincode executed.
+	Access AccessStats
+}
+
+// A StreamClosedHook is called when the stream of a chaincode with the peer
+// ends. The error is nil when the chaincode closed the stream cleanly and
+// otherwise the error which terminated the stream. The ccid is empty if the
+// chaincode did not register before the stream ended.
+type StreamClosedHook func(ccid string, err error)
+
+// An InvocationGuard is consulted before a chaincode is launched or executed
+// for an invocation. A non-nil error rejects the invocation, allowing custom
+// authorization beyond the ACLProvider.
+type InvocationGuard func(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) error
+
 // ChaincodeSupport responsible for providing interfacing with chaincodes from the Peer.
 type ChaincodeSupport struct {
 	ACLProvider            ACLProvider
+	AllowedResponseTypes   map[pb.ChaincodeMessage_Type]bool
 	AppConfig              ApplicationConfigRetriever
+	AutoRestart            bool
+	AutoRestartReset       time.Duration
 	BuiltinSCCs            scc.BuiltinSCCs
+	ChannelLimiter         *ChannelLimiter
+	ContextKeys            []ContextKey
+	DecorationCodec        DecorationCodec
+	DefaultChannel         string
 	DeployedCCInfoProvider ledger.DeployedChaincodeInfoProvider
+	DevModeWait            time.Duration
+	DiscardUnknownFields   bool
+	ErrorFormat            ErrorFormat
+	EventSink              EventSink
+	ExecuteLimiter         *ExecuteLimiter
 	ExecuteTimeout         time.Duration
+	FallbackInvoker        Invoker
+	GracefulStopTimeout    time.Duration
 	InstallTimeout         time.Duration
+	InvocationGuard        InvocationGuard
 	HandlerMetrics         *HandlerMetrics
 	HandlerRegistry        *HandlerRegistry
 	Keepalive              time.Duration
+	KeepaliveDisabled      map[string]bool
+	KeepaliveIdleOnly      bool
+	KeepaliveMissThreshold int
+	KeepaliveRetries       int
+	KeepaliveRetryBackoff  time.Duration
 	Launcher               Launcher
+	LaunchFailureMaxTTL    time.Duration
+	LaunchFailureTTL       time.Duration
+	LaunchIntents          LaunchIntentStore
+	LaunchPolicy           LaunchPolicy
+	LedgerGetter           LedgerGetter
 	Lifecycle              Lifecycle
+	LifecycleLookupTimeout time.Duration
+	MaxInitInputBytes      int
+	MaxAutoRestarts        int
+	MaxInvokeInputBytes    int
+	MemoryAccounting       bool
+	MessageRecorder        MessageRecorder
+	MessageTypes           map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type
+	MinContainerLifetime   time.Duration
+	OnStreamClosed         StreamClosedHook
+	PayloadChecksums       bool
 	Peer                   *peer.Peer
+	RateLimiter            *RateLimiter
+	ReceiveBufferSize      int
+	ResponseChecksums      bool
+	ResponseTransformer    ResponseTransformer
+	ResultValidator        ResultValidator
+	RetryOnTimeout         bool
 	Runtime                Runtime
+	SendBufferSize         int
+	SendTimeout            time.Duration
+	SerialExecution        map[string]bool
+	SoftConcurrencyLimit   int
+	ShutdownDependencies   DependencyProvider
+	StatusCodeHandlers     map[int32]StatusCodeHandler
+	StopGracePeriod        time.Duration
+	StopOnPanic            bool
+	StructuredErrors       bool
+	SystemExecuteLimiter   *ExecuteLimiter
 	TotalQueryLimit        int
 	UserRunsCC             bool
+
+	execStats      execStatsTracker
+	middlewares    []InvokeMiddleware
+	initOrder      initOrder
+	launchFailures launchFailureCache
+	launchHistory  launchHistory
+	launching      launchesInProgress
+	memorySamples  memorySamples
+	quiesced       quiescedSet
+	restarts       restartCounts
+	serial         serialLocks
 }
 
 // Launch starts executing chaincode if it is not already running. This method
 // blocks until the peer side handler gets into ready state or encounters a fatal
 // error. If the chaincode is already running, it simply returns.
 func (cs *ChaincodeSupport) Launch(ccid string) (*Handler, error) {
-	if h := cs.HandlerRegistry.Handler(ccid); h != nil {
+	return cs.launch(nil, "", ccid, false, chaincodeLogger)
+}
+
+// TryLaunch is Launch without waiting for the launch concurrency limit. If
+// the chaincode is not running and the limit is saturated, it returns false
+// immediately so that the caller may shed load or route elsewhere.
+func (cs *ChaincodeSupport) TryLaunch(ccid string) (bool, error) {
+	h, err := cs.launchWith("", ccid, chaincodeLogger, func() (bool, error) {
+		return cs.Launcher.TryLaunch("", ccid, cs)
+	})
+	return h != nil, err
+}
+
+// launch is Launch on behalf of an invocation, or an init if init is true, on
+// channelID, so that the launcher may schedule the launch fairly with those of
+// other channels and, with a RuntimeLauncher, ahead of ordinary invocations.
+// With a RuntimeLauncher, a caller whose ctx is cancelled stops waiting on the
+// launch, which is abandoned once every caller waiting on it has gone; a nil
+// ctx waits for the launch to complete.
+func (cs *ChaincodeSupport) launch(ctx context.Context, channelID, ccid string, init bool, logger *flogging.FabricLogger) (*Handler, error) {
+	return cs.launchWith(channelID, ccid, logger, func() (bool, error) {
+		rl, isRuntime := cs.Launcher.(*RuntimeLauncher)
+		switch {
+		case isRuntime && ctx != nil:
+			return true, rl.LaunchContextOnChannel(ctx, channelID, ccid, cs, init)
+		case isRuntime && init:
+			return true, rl.LaunchInitOnChannel(channelID, ccid, cs)
+		default:
+			return true, cs.Launcher.LaunchOnChannel(channelID, ccid, cs)
+		}
+	})
+}
+
+// launchWith is the path through which invocations, inits and TryLaunch alike
+// launch the chaincode, starting its runtime with start unless it is already
+// running. Concurrent launches of a chaincode are coalesced by the
+// HandlerRegistry, so its runtime is started once whether an init or an
+// invocation triggered it, and under the RejectDuringLaunch policy callers
+// arriving while it is being launched fail with ErrLaunchInProgress instead
+// of waiting. When start reports that it did not launch the chaincode,
+// launchWith returns a nil handler and no error.
+func (cs *ChaincodeSupport) launchWith(channelID, ccid string, logger *flogging.FabricLogger, start func() (bool, error)) (*Handler, error) {
+	if h := cs.HandlerRegistry.ReadyHandler(ccid); h != nil {
 		return h, nil
 	}
+	if cs.UserRunsCC && cs.DevModeWait > 0 {
+		return cs.awaitRegistration(ccid, logger)
+	}
+	if err := cs.recentLaunchFailure(ccid); err != nil {
+		logger.Debugf("not launching chaincode %s which recently failed to launch", ccid)
+		return nil, err
+	}
+	admitted, first := cs.launching.begin(ccid, cs.LaunchPolicy == RejectDuringLaunch)
+	if !admitted {
+		logger.Debugf("rejecting invocation of chaincode %s which is being launched", ccid)
+		return nil, errors.WithMessagef(ErrLaunchInProgress, "cannot invoke chaincode %s", ccid)
+	}
+	defer cs.launching.end(ccid)
+	if first && cs.LaunchIntents != nil {
+		cs.recordLaunchIntent(channelID, ccid)
+		defer cs.clearLaunchIntent(ccid)
+	}
 
-	if err := cs.Launcher.Launch(ccid, cs); err != nil {
-		return nil, errors.Wrapf(err, "could not launch chaincode %s", ccid)
+	logger.Debugf("launching chaincode %s on channel %s", ccid, channelID)
+	startTime := time.Now()
+	launched, err := start()
+	if !launched && err == nil {
+		logger.Debugf("did not launch chaincode %s", ccid)
+		return nil, nil
+	}
+	if first {
+		// callers joining the launch in progress do not record it again
+		cs.launchHistory.add(ccid, newLaunchRecord(channelID, startTime, err))
 	}
+	if err != nil {
+		logger.Debugf("launch of chaincode %s failed: %s", ccid, err)
+		// a launch abandoned by its callers did not fail and is not recorded
+		return nil, cs.launchFailed(ccid, err, first && errors.Cause(err) != ErrLaunchAborted)
+	}
+	logger.Debugf("launched chaincode %s", ccid)
+	cs.launchFailures.reset(ccid)
 
 	h := cs.HandlerRegistry.Handler(ccid)
 	if h == nil {
@@ -95,6 +311,192 @@ func (cs *ChaincodeSupport) Launch(ccid string) (*Handler, error) {
 	return h, nil
 }
 
+// awaitRegistration waits up to DevModeWait for a chaincode started by the
+// developer to register with the peer, rather than launching it.
+func (cs *ChaincodeSupport) awaitRegistration(ccid string, logger *flogging.FabricLogger) (*Handler, error) {
+	logger.Debugf("waiting up to %s for chaincode %s to register in developer mode", cs.DevModeWait, ccid)
+	timer := time.NewTimer(cs.DevModeWait)
+	defer timer.Stop()
+
+	select {
+	case <-cs.HandlerRegistry.ReadyCh(ccid):
+	case <-timer.C:
+		logDevModeError(cs.UserRunsCC)
+		return nil, errors.Errorf("chaincode %s did not register within %s in developer mode", ccid, cs.DevModeWait)
+	}
+
+	h := cs.HandlerRegistry.Handler(ccid)
+	if h == nil {
+		return nil, errors.Errorf("chaincode %s registered but could not find handler", ccid)
+	}
+	return h, nil
+}
+
+// launchFailed wraps the error of a failed launch and, when LaunchFailureTTL
+// is set, remembers it so that launches of the chaincode fail fast until the
+// TTL expires. When LaunchFailureMaxTTL exceeds LaunchFailureTTL, the TTL
+// doubles with each consecutive failure of the chaincode up to
+// LaunchFailureMaxTTL; a successful launch starts it again from
+// LaunchFailureTTL. Only the caller which started the launch records it, so
+// that callers joining a failed launch do not count as further failures.
+func (cs *ChaincodeSupport) launchFailed(ccid string, err error, record bool) error {
+	err = errors.Wrapf(err, "could not launch chaincode %s", ccid)
+	if record && cs.LaunchFailureTTL > 0 {
+		cs.launchFailures.add(ccid, err, cs.LaunchFailureTTL, cs.LaunchFailureMaxTTL)
+	}
+	return err
+}
+
+// recentLaunchFailure returns the error of a launch of the chaincode which
+// failed within the LaunchFailureTTL, or nil.
+func (cs *ChaincodeSupport) recentLaunchFailure(ccid string) error {
+	if err := cs.launchFailures.get(ccid); err != nil {
+		return errors.WithMessagef(err, "chaincode %s recently failed to launch", ccid)
+	}
+	return nil
+}
+
+// RegisteredAddress returns the network address from which a running
+// chaincode registered with the peer. The bool is false if the chaincode is
+// not registered or registered over a stream with no known address.
+func (cs *ChaincodeSupport) RegisteredAddress(ccid string) (string, bool) {
+	h := cs.HandlerRegistry.Handler(ccid)
+	if h == nil || h.RemoteAddress() == "" {
+		return "", false
+	}
+	return h.RemoteAddress(), true
+}
+
+// Uptime returns how long a running chaincode has been registered with the
+// peer. It is measured from the most recent registration, so it starts again
+// from zero when the chaincode is relaunched. The bool is false if the
+// chaincode is not registered.
+func (cs *ChaincodeSupport) Uptime(ccid string) (time.Duration, bool) {
+	h := cs.HandlerRegistry.Handler(ccid)
+	if h == nil {
+		return 0, false
+	}
+	registeredAt := h.registeredSince()
+	if registeredAt.IsZero() {
+		return 0, false
+	}
+	return time.Since(registeredAt), true
+}
+
+// Stats returns the cumulative execution counts for the named chaincode. The
+// bool is false if the chaincode has not been executed.
+func (cs *ChaincodeSupport) Stats(ccName string) (ExecStats, bool) {
+	return cs.execStats.get(ccName)
+}
+
+// Ready returns a channel which is closed when the chaincode has launched and
+// completed registration with the peer. If the chaincode is already running,
+// the returned channel is already closed. Ready does not launch the chaincode.
+func (cs *ChaincodeSupport) Ready(ccid string) <-chan struct{} {
+	return cs.HandlerRegistry.ReadyCh(ccid)
+}
+
+// ImageDigest returns the digest of the image the launched chaincode runs
+// from, so that operators can verify the exact image in use. The bool is
+// false if the chaincode is not running or does not run from an image.
+func (cs *ChaincodeSupport) ImageDigest(ccid string) (string, bool) {
+	return cs.HandlerRegistry.ImageDigest(ccid)
+}
+
+// EffectiveConfig returns a snapshot of the settings currently in effect for
+// chaincode execution, reflecting any changes made after construction. Launch
+// settings are included when the launcher is a RuntimeLauncher.
+func (cs *ChaincodeSupport) EffectiveConfig() Config {
+	config := Config{
+		TotalQueryLimit:        cs.TotalQueryLimit,
+		Keepalive:              cs.Keepalive,
+		KeepaliveRetries:       cs.KeepaliveRetries,
+		KeepaliveRetryBackoff:  cs.KeepaliveRetryBackoff,
+		KeepaliveMissThreshold: cs.KeepaliveMissThreshold,
+		KeepaliveDisabled:      cs.KeepaliveDisabled,
+		KeepaliveIdleOnly:      cs.KeepaliveIdleOnly,
+		ContextKeys:            cs.ContextKeys,
+		DefaultChannel:         cs.DefaultChannel,
+		DevModeWait:            cs.DevModeWait,
+		ExecuteTimeout:         cs.ExecuteTimeout,
+		InstallTimeout:         cs.InstallTimeout,
+		RetryOnTimeout:         cs.RetryOnTimeout,
+		StructuredErrors:       cs.StructuredErrors,
+		ErrorFormat:            cs.ErrorFormat,
+		DiscardUnknownFields:   cs.DiscardUnknownFields,
+		PayloadChecksums:       cs.PayloadChecksums,
+		ResponseChecksums:      cs.ResponseChecksums,
+		MaxInitInputBytes:      cs.MaxInitInputBytes,
+		MaxInvokeInputBytes:    cs.MaxInvokeInputBytes,
+		MemoryAccounting:       cs.MemoryAccounting,
+		SendTimeout:            cs.SendTimeout,
+		SendBufferSize:         cs.SendBufferSize,
+		ReceiveBufferSize:      cs.ReceiveBufferSize,
+		GracefulStopTimeout:    cs.GracefulStopTimeout,
+		StopGracePeriod:        cs.StopGracePeriod,
+		LaunchFailureTTL:       cs.LaunchFailureTTL,
+		LaunchFailureMaxTTL:    cs.LaunchFailureMaxTTL,
+		ResumeLaunches:         cs.LaunchIntents != nil,
+		LaunchPolicy:           cs.LaunchPolicy,
+		LifecycleLookupTimeout: cs.LifecycleLookupTimeout,
+		MinContainerLifetime:   cs.MinContainerLifetime,
+		StopOnPanic:            cs.StopOnPanic,
+		AutoRestart:            cs.AutoRestart,
+		AutoRestartReset:       cs.AutoRestartReset,
+		MaxAutoRestarts:        cs.MaxAutoRestarts,
+		SerialExecution:        cs.SerialExecution,
+		AllowedResponseTypes:   cs.AllowedResponseTypes,
+		MessageTypes:           cs.MessageTypes,
+	}
+
+	if cs.ChannelLimiter != nil {
+		config.ChannelLimits = cs.ChannelLimiter.Limits()
+		config.ChannelLimitPolicy = cs.ChannelLimiter.Policy()
+	}
+
+	if cs.RateLimiter != nil {
+		config.RateLimits = cs.RateLimiter.Limits()
+		config.RateLimitPolicy = cs.RateLimiter.Policy()
+	}
+
+	if cs.ExecuteLimiter != nil {
+		config.MaxConcurrentExecutions = cs.ExecuteLimiter.Limit()
+		config.ExecuteQueueAlertThreshold = cs.ExecuteLimiter.AlertThreshold
+		config.SoftConcurrencyLimit = cs.SoftConcurrencyLimit
+	}
+	if cs.SystemExecuteLimiter != nil {
+		config.SystemExecutionReserve = cs.SystemExecuteLimiter.Limit()
+	}
+
+	if cr, ok := cs.Runtime.(*ContainerRuntime); ok {
+		if b, ok := cr.StartBackoff.(*ExponentialStartBackoff); ok {
+			config.StartRetries = b.Retries
+			config.StartRetryBackoff = b.Initial
+		}
+	}
+
+	if rl, ok := cs.Launcher.(*RuntimeLauncher); ok {
+		config.StartupTimeout = rl.StartupTimeout
+		config.LaunchStallTimeout = rl.StallTimeout
+		config.RegistrationTimeout = rl.RegistrationTimeout
+		config.LaunchJitter = rl.Jitter
+		config.ContainerNetwork = rl.ContainerNetwork
+		config.StartupProbe = rl.StartupProbe
+		config.PrioritizeInitLaunches = rl.PrioritizeInit
+		if wn, ok := rl.FailureNotifier.(*WebhookNotifier); ok {
+			config.LaunchFailureWebhook = wn.URL
+		}
+		if rl.Limiter != nil {
+			config.MaxConcurrentLaunches = rl.Limiter.Limit()
+		}
+		if rl.MemoryBudget != nil {
+			config.TotalChaincodeMemoryLimitMB = int(rl.MemoryBudget.total / bytesPerMB)
+		}
+	}
+
+	return config
+}
+
 // LaunchInProc is a stopgap solution to be called by the inproccontroller to allow system chaincodes to register
 func (cs *ChaincodeSupport) LaunchInProc(ccid string) <-chan struct{} {
 	launchStatus, ok := cs.HandlerRegistry.Launching(ccid)
@@ -113,6 +515,18 @@ func (cs *ChaincodeSupport) HandleChaincodeStream(stream ccintf.ChaincodeStream)
 	handler := &Handler{
 		Invoker:                cs,
 		Keepalive:              cs.Keepalive,
+		KeepaliveRetries:       cs.KeepaliveRetries,
+		KeepaliveRetryBackoff:  cs.KeepaliveRetryBackoff,
+		KeepaliveMissThreshold: cs.KeepaliveMissThreshold,
+		KeepaliveDisabled:      cs.KeepaliveDisabled,
+		KeepaliveIdleOnly:      cs.KeepaliveIdleOnly,
+		ContextKeys:            cs.ContextKeys,
+		AllowedResponseTypes:   cs.AllowedResponseTypes,
+		OnPanic:                cs.handlerPanicked,
+		SendTimeout:            cs.SendTimeout,
+		SendBufferSize:         cs.SendBufferSize,
+		ReceiveBufferSize:      cs.ReceiveBufferSize,
+		MessageTypes:           cs.MessageTypes,
 		Registry:               cs.HandlerRegistry,
 		ACLProvider:            cs.ACLProvider,
 		TXContexts:             NewTransactionContexts(),
@@ -126,9 +540,33 @@ func (cs *ChaincodeSupport) HandleChaincodeStream(stream ccintf.ChaincodeStream)
 		AppConfig:              cs.AppConfig,
 		Metrics:                cs.HandlerMetrics,
 		TotalQueryLimit:        cs.TotalQueryLimit,
+		remoteAddress:          streamRemoteAddress(stream),
+	}
+
+	err := handler.ProcessStream(stream)
+	cs.restartAfterExit(handler)
+	if cs.OnStreamClosed != nil {
+		closeErr := err
+		if closeErr == io.EOF {
+			closeErr = nil
+		}
+		cs.OnStreamClosed(handler.chaincodeID, closeErr)
 	}
+	return err
+}
 
-	return handler.ProcessStream(stream)
+// streamRemoteAddress returns the address of the remote end of a gRPC stream,
+// or an empty string for streams which do not carry a gRPC peer.
+func streamRemoteAddress(stream ccintf.ChaincodeStream) string {
+	s, ok := stream.(interface{ Context() context.Context })
+	if !ok {
+		return ""
+	}
+	p, ok := grpcpeer.FromContext(s.Context())
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	return p.Addr.String()
 }
 
 // Register the bidi stream entry point called by chaincode to register with the Peer.
@@ -145,24 +583,96 @@ func (cs *ChaincodeSupport) ExecuteLegacyInit(txParams *ccprovider.TransactionPa
 	// ccid manually but rather let lifecycle construct it
 	// for us. However this is legacy code that will disappear
 	// so it is acceptable for now (FAB-14627)
-	ccid := ccName + ":" + ccVersion
+	ccid, err := ccprovider.LegacyChaincodeID(ccName, ccVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txParams, err = cs.resolveChannel(txParams, ccName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cs.checkQuiesced(ccName); err != nil {
+		return nil, nil, err
+	}
+	if err := cs.guard(txParams, ccName, input); err != nil {
+		return nil, nil, err
+	}
+	if err := cs.checkInputSize(pb.ChaincodeMessage_INIT, ccName, proto.Size(input)); err != nil {
+		return nil, nil, err
+	}
 
-	h, err := cs.Launch(ccid)
+	h, err := cs.launch(txParams.Context, txParams.ChannelID, ccid, true, invocationLogger(txParams))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	resp, err := cs.execute(pb.ChaincodeMessage_INIT, txParams, ccName, input, h)
-	return processChaincodeExecutionResult(txParams.TxID, ccName, resp, err)
+	resp, err := cs.ordered(pb.ChaincodeMessage_INIT, txParams, ccName, func() (*pb.ChaincodeMessage, error) {
+		return cs.execute(pb.ChaincodeMessage_INIT, txParams, ccName, input, h, nil)
+	})
+	return cs.processChaincodeExecutionResult(txParams, ccName, resp, err)
 }
 
 // Execute invokes chaincode and returns the original response.
 func (cs *ChaincodeSupport) Execute(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.Response, *pb.ChaincodeEvent, error) {
-	resp, err := cs.Invoke(txParams, chaincodeName, input)
-	return processChaincodeExecutionResult(txParams.TxID, chaincodeName, resp, err)
+	resp, _, err := cs.invoke(txParams, chaincodeName, input)
+	return cs.processChaincodeExecutionResult(txParams, chaincodeName, resp, err)
+}
+
+// ExecuteWithMetadata is Execute which also returns metadata describing the
+// chaincode which served the request, for example to identify the version
+// which endorsed during a rolling upgrade. The metadata is nil when the
+// execution fails.
+func (cs *ChaincodeSupport) ExecuteWithMetadata(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.Response, *pb.ChaincodeEvent, *ExecuteMetadata, error) {
+	resp, metadata, err := cs.invoke(txParams, chaincodeName, input)
+	res, event, err := cs.processChaincodeExecutionResult(txParams, chaincodeName, resp, err)
+	if err != nil {
+		return res, event, nil, err
+	}
+	return res, event, metadata, nil
+}
+
+// ExecuteToWriter is Execute which writes the payload of a successful
+// response to w in chunks of at most writeChunkSize bytes rather than
+// returning it in the response, whose Payload is left empty. The chaincode
+// delivers its response in a single message, so the payload is received and
+// unmarshaled in full before anything is written; only the writes to w are
+// bounded. Nothing is written when the execution fails.
+func (cs *ChaincodeSupport) ExecuteToWriter(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput, w io.Writer) (*pb.Response, *pb.ChaincodeEvent, error) {
+	res, event, err := cs.Execute(txParams, chaincodeName, input)
+	if err != nil {
+		return res, event, err
+	}
+
+	payload := res.Payload
+	res.Payload = nil
+	for len(payload) > 0 {
+		n := len(payload)
+		if n > writeChunkSize {
+			n = writeChunkSize
+		}
+		if _, err := w.Write(payload[:n]); err != nil {
+			return nil, event, errors.Wrapf(err, "failed to write response payload for transaction %s", txParams.TxID)
+		}
+		payload = payload[n:]
+	}
+
+	return res, event, nil
+}
+
+func (cs *ChaincodeSupport) processChaincodeExecutionResult(txParams *ccprovider.TransactionParams, ccName string, resp *pb.ChaincodeMessage, err error) (*pb.Response, *pb.ChaincodeEvent, error) {
+	res, event, resErr := cs.chaincodeResponse(txParams, ccName, resp, err)
+	timedOut := err != nil && errors.Cause(err).Error() == ErrorExecutionTimeout
+	cs.execStats.record(ccName, resErr == nil, timedOut)
+	if event != nil && cs.EventSink != nil {
+		cs.EventSink.Publish(event)
+	}
+	return res, event, resErr
 }
 
-func processChaincodeExecutionResult(txid, ccName string, resp *pb.ChaincodeMessage, err error) (*pb.Response, *pb.ChaincodeEvent, error) {
+// chaincodeResponse interprets the message returned by an execution.
+func (cs *ChaincodeSupport) chaincodeResponse(txParams *ccprovider.TransactionParams, ccName string, resp *pb.ChaincodeMessage, err error) (*pb.Response, *pb.ChaincodeEvent, error) {
+	txid := txParams.TxID
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "failed to execute transaction %s", txid)
 	}
@@ -175,37 +685,280 @@ func processChaincodeExecutionResult(txid, ccName string, resp *pb.ChaincodeMess
 		resp.ChaincodeEvent.TxId = txid
 	}
 
+	if cs.ResponseChecksums && (resp.Type == pb.ChaincodeMessage_COMPLETED || resp.Type == pb.ChaincodeMessage_ERROR) {
+		payload, err := verifyPayloadChecksum(resp.Payload)
+		if err != nil {
+			return nil, nil, errors.WithMessagef(err, "invalid response for transaction %s", txid)
+		}
+		resp.Payload = payload
+	}
+
 	switch resp.Type {
 	case pb.ChaincodeMessage_COMPLETED:
+		cs.HandlerMetrics.ResponseSize.With("chaincode", ccName).Observe(float64(len(resp.Payload)))
 		res := &pb.Response{}
 		err := proto.Unmarshal(resp.Payload, res)
 		if err != nil {
 			return nil, nil, errors.Wrapf(err, "failed to unmarshal response for transaction %s", txid)
 		}
+		if cs.DiscardUnknownFields {
+			proto.DiscardUnknown(res)
+		}
+		if handle, ok := cs.StatusCodeHandlers[res.Status]; ok {
+			status := res.Status
+			res, err = handle(res)
+			if err != nil {
+				return nil, nil, errors.WithMessagef(err, "failed to handle status %d of response for transaction %s", status, txid)
+			}
+			if res == nil {
+				return nil, nil, errors.Errorf("handler for status %d returned no response for transaction %s", status, txid)
+			}
+		}
+		if cs.ResultValidator != nil {
+			if err := cs.ResultValidator(res); err != nil {
+				return nil, nil, errors.WithMessagef(err, "response for transaction %s failed validation", txid)
+			}
+		}
+		if cs.ResponseTransformer != nil {
+			res, err = cs.ResponseTransformer(txParams, ccName, res)
+			if err != nil {
+				return nil, nil, errors.WithMessagef(err, "failed to transform response for transaction %s", txid)
+			}
+		}
 		return res, resp.ChaincodeEvent, nil
 
 	case pb.ChaincodeMessage_ERROR:
+		if len(resp.Payload) == 0 {
+			return nil, resp.ChaincodeEvent, errors.WithMessage(ErrEmptyChaincodeError, "transaction returned with failure")
+		}
+		if cs.StructuredErrors {
+			if ce := parseChaincodeError(resp.Payload); ce != nil {
+				return nil, resp.ChaincodeEvent, errors.WithMessage(ce, "transaction returned with failure")
+			}
+		}
 		return nil, resp.ChaincodeEvent, errors.Errorf("transaction returned with failure: %s", resp.Payload)
 
 	default:
+		// types introduced by newer shims are only accepted once allowed
+		if cs.AllowedResponseTypes[resp.Type] {
+			return &pb.Response{Status: int32(common.Status_SUCCESS), Payload: resp.Payload}, resp.ChaincodeEvent, nil
+		}
 		return nil, nil, errors.Errorf("unexpected response type %d for transaction %s", resp.Type, txid)
 	}
 }
 
 // Invoke will invoke chaincode and return the message containing the response.
 // The chaincode will be launched if it is not already running.
+//
+// Invoke is the raw invocation path. Whether the invocation is an init or a
+// transaction is determined by CheckInvocation, and the returned message is
+// exactly what the chaincode sent back (typically COMPLETED or ERROR) without
+// any interpretation of its type or payload. Callers that only need the
+// chaincode response should use Execute instead.
 func (cs *ChaincodeSupport) Invoke(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, error) {
-	ccid, cctype, err := cs.CheckInvocation(txParams, chaincodeName, input)
+	resp, _, err := cs.invoke(txParams, chaincodeName, input)
+	return resp, err
+}
+
+// InvokeWithSimulator is Invoke using simulator rather than the simulator of
+// txParams for the transaction, for example to speculatively execute against
+// a what-if view of the state. Every state access during the invocation,
+// including the resolution of the chaincode definition, goes through
+// simulator. txParams is not modified.
+func (cs *ChaincodeSupport) InvokeWithSimulator(txParams *ccprovider.TransactionParams, simulator ledger.TxSimulator, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, error) {
+	if simulator == nil {
+		return nil, errors.Errorf("invocation of chaincode %s requires a simulator", chaincodeName)
+	}
+
+	params := *txParams
+	params.TXSimulator = simulator
+	return cs.Invoke(&params, chaincodeName, input)
+}
+
+// invoke is Invoke which also returns the metadata of the chaincode handler
+// which served the invocation. The invocation runs through the middlewares.
+func (cs *ChaincodeSupport) invoke(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, *ExecuteMetadata, error) {
+	var metadata *ExecuteMetadata
+	core := func(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, error) {
+		var resp *pb.ChaincodeMessage
+		var err error
+		resp, metadata, err = cs.invokeChaincode(txParams, chaincodeName, input)
+		return resp, err
+	}
+
+	resp, err := cs.chain(core)(txParams, chaincodeName, input)
+	return resp, metadata, err
+}
+
+// invokeChaincode launches and invokes the chaincode.
+func (cs *ChaincodeSupport) invokeChaincode(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, *ExecuteMetadata, error) {
+	txParams, err := cs.resolveChannel(txParams, chaincodeName)
 	if err != nil {
-		return nil, errors.WithMessage(err, "invalid invocation")
+		return nil, nil, err
+	}
+	if err := cs.checkQuiesced(chaincodeName); err != nil {
+		return nil, nil, err
+	}
+	if err := cs.guard(txParams, chaincodeName, input); err != nil {
+		return nil, nil, err
 	}
 
-	h, err := cs.Launch(ccid)
+	cii, cctype, err := cs.checkInvocation(txParams, chaincodeName, input)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "invalid invocation")
+	}
+	if err := cs.checkInputSize(cctype, chaincodeName, proto.Size(input)); err != nil {
+		return nil, nil, err
+	}
+	if txParams.RequiredVersion != "" && txParams.RequiredVersion != cii.Version {
+		return nil, nil, errors.WithMessagef(ErrVersionMismatch, "invocation of chaincode %s requires version %s but resolved to %s", chaincodeName, txParams.RequiredVersion, cii.Version)
+	}
+
+	coldStart := cs.HandlerRegistry.Handler(cii.ChaincodeID) == nil
+	h, err := cs.launch(txParams.Context, txParams.ChannelID, cii.ChaincodeID, cctype == pb.ChaincodeMessage_INIT, invocationLogger(txParams))
+	if err != nil {
+		if cs.FallbackInvoker == nil {
+			return nil, nil, err
+		}
+		return cs.invokeFallback(txParams, chaincodeName, input, err)
+	}
+
+	metadata := &ExecuteMetadata{ChaincodeID: h.chaincodeID, Version: cii.Version, ColdStart: coldStart}
+	resp, err := cs.ordered(cctype, txParams, chaincodeName, func() (*pb.ChaincodeMessage, error) {
+		return cs.execute(cctype, txParams, chaincodeName, input, h, metadata)
+	})
+	return resp, metadata, err
+}
+
+// invokeFallback delegates an invocation of a chaincode which could not be
+// launched to the FallbackInvoker. There is no metadata for the invocation as
+// no local handler served it.
+func (cs *ChaincodeSupport) invokeFallback(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput, launchErr error) (*pb.ChaincodeMessage, *ExecuteMetadata, error) {
+	invocationLogger(txParams).Warningf("[%s] delegating invocation of chaincode %s to the fallback invoker: %s", shorttxid(txParams.TxID), chaincodeName, launchErr)
+	resp, err := cs.FallbackInvoker.Invoke(txParams, chaincodeName, input)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "fallback invocation of chaincode %s failed", chaincodeName)
+	}
+	return resp, nil, nil
+}
+
+// resolveChannel applies the default channel to an invocation of a user
+// chaincode which does not specify a channel. System chaincodes may be invoked
+// without a channel for peer level operations. Such invocations skip the
+// channel specific steps of an execution: the lifecycle resolves system
+// chaincodes without consulting the channel, no channel concurrency limit
+// applies, and no private data collections are available. The default channel
+// is applied to a copy of txParams, which is not modified.
+func (cs *ChaincodeSupport) resolveChannel(txParams *ccprovider.TransactionParams, chaincodeName string) (*ccprovider.TransactionParams, error) {
+	if txParams.ChannelID != "" || cs.BuiltinSCCs.IsSysCC(chaincodeName) {
+		return txParams, nil
+	}
+	if cs.DefaultChannel == "" {
+		return nil, errors.WithMessagef(ErrMissingChannel, "cannot invoke chaincode %s", chaincodeName)
+	}
+
+	chaincodeLogger.Debugf("[%s] invoking chaincode %s on default channel %s", shorttxid(txParams.TxID), chaincodeName, cs.DefaultChannel)
+	params := *txParams
+	params.ChannelID = cs.DefaultChannel
+	return &params, nil
+}
+
+// guard consults the InvocationGuard, if any, about an invocation.
+func (cs *ChaincodeSupport) guard(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) error {
+	if cs.InvocationGuard == nil {
+		return nil
+	}
+	if err := cs.InvocationGuard(txParams, chaincodeName, input); err != nil {
+		return errors.WithMessagef(err, "invocation of chaincode %s rejected", chaincodeName)
+	}
+	return nil
+}
+
+// checkInputSize enforces the input size limit for inits or invokes of user
+// chaincodes. System chaincodes, such as _lifecycle which receives entire
+// chaincode packages on install, are not limited.
+func (cs *ChaincodeSupport) checkInputSize(cctyp pb.ChaincodeMessage_Type, chaincodeName string, size int) error {
+	if cs.BuiltinSCCs.IsSysCC(chaincodeName) {
+		return nil
+	}
+
+	kind, limit := "invoke", cs.MaxInvokeInputBytes
+	if cctyp == pb.ChaincodeMessage_INIT {
+		kind, limit = "init", cs.MaxInitInputBytes
+	}
+	if limit > 0 && size > limit {
+		return errors.WithMessagef(ErrInputTooLarge, "%s input of %d bytes for chaincode %s exceeds the limit of %d bytes", kind, size, chaincodeName, limit)
+	}
+	return nil
+}
+
+// ExecuteMessage executes a pre-built TRANSACTION or INIT message against the
+// chaincode, launching it if it is not already running. The message must be
+// for the channel and transaction of txParams and its payload must be a
+// ChaincodeInput, decorated with its checksum when payload checksums are
+// enabled. Unlike Invoke, the message is sent as is and no
+// 'init exactly once' checks are made. The returned message is exactly what
+// the chaincode sent back.
+func (cs *ChaincodeSupport) ExecuteMessage(txParams *ccprovider.TransactionParams, chaincodeName string, msg *pb.ChaincodeMessage) (*pb.ChaincodeMessage, error) {
+	switch msg.Type {
+	case pb.ChaincodeMessage_TRANSACTION, pb.ChaincodeMessage_INIT:
+	default:
+		return nil, errors.Errorf("cannot execute message of type %s", msg.Type)
+	}
+	if msg.ChannelId != txParams.ChannelID || msg.Txid != txParams.TxID {
+		return nil, errors.Errorf("message for transaction %s on channel %s does not match transaction %s on channel %s", msg.Txid, msg.ChannelId, txParams.TxID, txParams.ChannelID)
+	}
+
+	if err := cs.checkQuiesced(chaincodeName); err != nil {
+		return nil, err
+	}
+	if err := cs.checkInputSize(msg.Type, chaincodeName, len(msg.Payload)); err != nil {
+		return nil, err
+	}
+	input := &pb.ChaincodeInput{}
+	if err := proto.Unmarshal(msg.Payload, input); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal chaincode input")
+	}
+	if cs.PayloadChecksums {
+		if err := verifyInputChecksum(input); err != nil {
+			return nil, errors.WithMessage(err, "invalid chaincode input")
+		}
+	}
+	if err := cs.guard(txParams, chaincodeName, input); err != nil {
+		return nil, err
+	}
+
+	cii, err := cs.endorsementInfo(txParams.ChannelID, chaincodeName, txParams.TXSimulator)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[channel %s] failed to get chaincode container info for %s", txParams.ChannelID, chaincodeName)
+	}
+
+	h, err := cs.launch(txParams.Context, txParams.ChannelID, cii.ChaincodeID, msg.Type == pb.ChaincodeMessage_INIT, invocationLogger(txParams))
 	if err != nil {
 		return nil, err
 	}
 
-	return cs.execute(cctype, txParams, chaincodeName, input, h)
+	return cs.ordered(msg.Type, txParams, chaincodeName, func() (*pb.ChaincodeMessage, error) {
+		return cs.executeMessage(txParams, chaincodeName, input, msg, h, nil)
+	})
+}
+
+// ordered runs an execution of the chaincode in order with inits of the
+// chaincode on the channel. An init holds back other executions until it
+// completes and other executions observe the outcome of an init in progress.
+func (cs *ChaincodeSupport) ordered(cctyp pb.ChaincodeMessage_Type, txParams *ccprovider.TransactionParams, chaincodeName string, exec func() (*pb.ChaincodeMessage, error)) (*pb.ChaincodeMessage, error) {
+	if cctyp == pb.ChaincodeMessage_INIT {
+		done := cs.initOrder.begin(txParams.ChannelID, chaincodeName, txParams.TxID)
+		resp, err := exec()
+		done(initResult(resp, err))
+		return resp, err
+	}
+
+	if err := cs.initOrder.wait(txParams.ChannelID, chaincodeName, txParams.TxID); err != nil {
+		return nil, errors.WithMessagef(err, "init of chaincode %s failed", chaincodeName)
+	}
+
+	return exec()
 }
 
 // CheckInvocation inspects the parameters of an invocation and determines if, how, and to where a that invocation should be routed.
@@ -213,11 +966,21 @@ func (cs *ChaincodeSupport) Invoke(txParams *ccprovider.TransactionParams, chain
 // Then, if the chaincode definition requires it, this function enforces 'init exactly once' semantics.
 // Finally, it returns the chaincode ID to route to and the message type of the request (normal transaction, or init).
 func (cs *ChaincodeSupport) CheckInvocation(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (ccid string, cctype pb.ChaincodeMessage_Type, err error) {
+	cii, cctype, err := cs.checkInvocation(txParams, chaincodeName, input)
+	if err != nil {
+		return "", 0, err
+	}
+	return cii.ChaincodeID, cctype, nil
+}
+
+// checkInvocation is CheckInvocation which returns the endorsement info of
+// the chaincode rather than only its ID.
+func (cs *ChaincodeSupport) checkInvocation(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*lifecycle.ChaincodeEndorsementInfo, pb.ChaincodeMessage_Type, error) {
 	chaincodeLogger.Debugf("[%s] getting chaincode data for %s on channel %s", shorttxid(txParams.TxID), chaincodeName, txParams.ChannelID)
-	cii, err := cs.Lifecycle.ChaincodeEndorsementInfo(txParams.ChannelID, chaincodeName, txParams.TXSimulator)
+	cii, err := cs.endorsementInfo(txParams.ChannelID, chaincodeName, txParams.TXSimulator)
 	if err != nil {
 		logDevModeError(cs.UserRunsCC)
-		return "", 0, errors.Wrapf(err, "[channel %s] failed to get chaincode container info for %s", txParams.ChannelID, chaincodeName)
+		return nil, 0, errors.Wrapf(err, "[channel %s] failed to get chaincode container info for %s", txParams.ChannelID, chaincodeName)
 	}
 
 	needsInitialization := false
@@ -225,7 +988,7 @@ func (cs *ChaincodeSupport) CheckInvocation(txParams *ccprovider.TransactionPara
 
 		value, err := txParams.TXSimulator.GetState(chaincodeName, InitializedKeyName)
 		if err != nil {
-			return "", 0, errors.WithMessage(err, "could not get 'initialized' key")
+			return nil, 0, errors.WithMessage(err, "could not get 'initialized' key")
 		}
 
 		needsInitialization = !bytes.Equal(value, []byte(cii.Version))
@@ -236,53 +999,237 @@ func (cs *ChaincodeSupport) CheckInvocation(txParams *ccprovider.TransactionPara
 	// InstantiationPolicy contract enforces which users may call init.
 	if input.IsInit {
 		if !cii.EnforceInit {
-			return "", 0, errors.Errorf("chaincode '%s' does not require initialization but called as init", chaincodeName)
+			return nil, 0, errors.Errorf("chaincode '%s' does not require initialization but called as init", chaincodeName)
 		}
 
 		if !needsInitialization {
-			return "", 0, errors.Errorf("chaincode '%s' is already initialized but called as init", chaincodeName)
+			return nil, 0, errors.Errorf("chaincode '%s' is already initialized but called as init", chaincodeName)
 		}
 
 		err = txParams.TXSimulator.SetState(chaincodeName, InitializedKeyName, []byte(cii.Version))
 		if err != nil {
-			return "", 0, errors.WithMessage(err, "could not set 'initialized' key")
+			return nil, 0, errors.WithMessage(err, "could not set 'initialized' key")
 		}
 
-		return cii.ChaincodeID, pb.ChaincodeMessage_INIT, nil
+		return cii, pb.ChaincodeMessage_INIT, nil
 	}
 
 	if needsInitialization {
-		return "", 0, errors.Errorf("chaincode '%s' has not been initialized for this version, must call as init first", chaincodeName)
+		return nil, 0, errors.Errorf("chaincode '%s' has not been initialized for this version, must call as init first", chaincodeName)
 	}
 
-	return cii.ChaincodeID, pb.ChaincodeMessage_TRANSACTION, nil
+	return cii, pb.ChaincodeMessage_TRANSACTION, nil
 }
 
 // execute executes a transaction and waits for it to complete until a timeout value.
-func (cs *ChaincodeSupport) execute(cctyp pb.ChaincodeMessage_Type, txParams *ccprovider.TransactionParams, namespace string, input *pb.ChaincodeInput, h *Handler) (*pb.ChaincodeMessage, error) {
-	input.Decorations = txParams.ProposalDecorations
+func (cs *ChaincodeSupport) execute(cctyp pb.ChaincodeMessage_Type, txParams *ccprovider.TransactionParams, namespace string, input *pb.ChaincodeInput, h *Handler, metadata *ExecuteMetadata) (*pb.ChaincodeMessage, error) {
+	decorations := invocationDecorations(txParams)
+	if cs.DecorationCodec != nil {
+		encoded, err := cs.DecorationCodec.Encode(decorations)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to encode proposal decorations")
+		}
+		decorations = encoded
+	}
+	input.Decorations = decorations
 
-	payload, err := proto.Marshal(input)
+	ccMsg, err := createCCMessage(cctyp, txParams.ChannelID, txParams.TxID, namespace, input, cs.PayloadChecksums)
 	if err != nil {
+		cs.HandlerMetrics.MessageMarshalFailures.With("chaincode", namespace).Add(1)
 		return nil, errors.WithMessage(err, "failed to create chaincode message")
 	}
 
-	ccMsg := &pb.ChaincodeMessage{
-		Type:      cctyp,
-		Payload:   payload,
-		Txid:      txParams.TxID,
-		ChannelId: txParams.ChannelID,
+	return cs.executeMessage(txParams, namespace, input, ccMsg, h, metadata)
+}
+
+// checkSoftConcurrencyLimit reports an execution admitted by the
+// ExecuteLimiter while active transactions, including its own, hold execution
+// slots. Executions above the SoftConcurrencyLimit are counted and a warning
+// is logged when the limit is crossed, but unlike the limit of the
+// ExecuteLimiter the soft limit never makes an execution wait.
+func (cs *ChaincodeSupport) checkSoftConcurrencyLimit(active int) {
+	if cs.SoftConcurrencyLimit <= 0 || active <= cs.SoftConcurrencyLimit {
+		return
+	}
+	cs.HandlerMetrics.ExecuteSoftLimitExceeded.Add(1)
+	if active == cs.SoftConcurrencyLimit+1 {
+		chaincodeLogger.Warningf("%d chaincode executions are in progress, above the soft concurrency limit of %d; executions wait once %d are in progress", active, cs.SoftConcurrencyLimit, cs.ExecuteLimiter.Limit())
+	}
+}
+
+// executeMessage sends the message built from input to the chaincode and
+// waits for the response. When metadata is not nil, the state accessed by
+// the execution is recorded in it, as is the memory consumed by the execution
+// when memory accounting is enabled.
+func (cs *ChaincodeSupport) executeMessage(txParams *ccprovider.TransactionParams, namespace string, input *pb.ChaincodeInput, ccMsg *pb.ChaincodeMessage, h *Handler, metadata *ExecuteMetadata) (*pb.ChaincodeMessage, error) {
+	if err := cs.checkQuiesced(namespace); err != nil {
+		return nil, err
+	}
+
+	// executions wait for their rate before holding any slot, but no longer
+	// than they would be allowed to execute
+	if cs.RateLimiter != nil {
+		if err := cs.RateLimiter.WaitAtMost(namespace, cs.executeTimeout(namespace, input)); err != nil {
+			return nil, err
+		}
+	}
+
+	// serial executions wait their turn before taking an execution slot
+	if cs.SerialExecution[namespace] {
+		defer cs.serial.lock(namespace)()
+	}
+
+	// channel-less system chaincode invocations are not bound by any channel
+	if cs.ChannelLimiter != nil && txParams.ChannelID != "" {
+		if err := cs.ChannelLimiter.Acquire(txParams.ChannelID, txParams.TxID); err != nil {
+			return nil, err
+		}
+		defer cs.ChannelLimiter.Release(txParams.ChannelID, txParams.TxID)
+	}
+
+	// system chaincodes share the capacity of user chaincodes and fall
+	// back to their reserved capacity, which user chaincode executions
+	// cannot consume, only when the shared capacity is saturated
+	if limiter := cs.ExecuteLimiter; limiter != nil {
+		var active int
+		if cs.SystemExecuteLimiter != nil && cs.BuiltinSCCs.IsSysCC(namespace) {
+			var ok bool
+			if active, ok = limiter.tryAcquire(txParams.TxID); !ok {
+				limiter = cs.SystemExecuteLimiter
+				limiter.acquire(txParams.TxID)
+			}
+		} else {
+			active = limiter.acquire(txParams.TxID)
+		}
+		defer limiter.Release(txParams.TxID)
+		if limiter == cs.ExecuteLimiter {
+			cs.checkSoftConcurrencyLimit(active)
+		}
 	}
 
+	logger := invocationLogger(txParams)
 	timeout := cs.executeTimeout(namespace, input)
-	ccresp, err := h.Execute(txParams, namespace, ccMsg, timeout)
+	logger.Debugf("[%s] executing %s for chaincode %s on channel %s with timeout %s", shorttxid(txParams.TxID), ccMsg.Type, namespace, txParams.ChannelID, timeout)
+
+	var memoryBefore uint64
+	accounting := metadata != nil && cs.MemoryAccounting
+	if accounting {
+		memoryBefore, accounting = cs.memoryUsage(h.chaincodeID)
+	}
+
+	// the execution counts its state accesses through its own copy of
+	// the parameters, leaving the caller's simulator in place
+	execParams := txParams
+	var counter *accessCountingSimulator
+	if metadata != nil && txParams.TXSimulator != nil {
+		counter = &accessCountingSimulator{TxSimulator: txParams.TXSimulator}
+		counted := *txParams
+		counted.TXSimulator = counter
+		execParams = &counted
+	}
+
+	startTime := time.Now()
+	ccresp, err := cs.executeHandler(h, execParams, namespace, ccMsg, timeout)
+	if err != nil && cs.retryable(ccMsg.Type, txParams, err) {
+		logger.Warningf("[%s] retrying idempotent invocation of %s after execute timeout", shorttxid(txParams.TxID), namespace)
+		ccresp, err = cs.executeHandler(h, execParams, namespace, proto.Clone(ccMsg).(*pb.ChaincodeMessage), timeout)
+	}
+	if cs.MessageRecorder != nil {
+		cs.record(txParams, namespace, ccMsg, ccresp, err)
+	}
+
+	if counter != nil {
+		metadata.Access = counter.Stats()
+	}
+
+	if accounting {
+		if memoryAfter, ok := cs.memoryUsage(h.chaincodeID); ok {
+			metadata.MemoryAccounted = true
+			if memoryAfter > memoryBefore {
+				metadata.MemoryBytes = memoryAfter - memoryBefore
+			}
+		}
+	}
 	if err != nil {
-		return nil, errors.WithMessage(err, "error sending")
+		logger.Debugf("[%s] execution of chaincode %s failed: %s", shorttxid(txParams.TxID), namespace, err)
+		return nil, cs.executeError(err, namespace, txParams.TxID, startTime)
 	}
 
+	logger.Debugf("[%s] execution of chaincode %s completed with %s", shorttxid(txParams.TxID), namespace, ccresp.Type)
 	return ccresp, nil
 }
 
+// executeHandler executes the message on the handler, converting a panic into
+// a ChaincodePanicError which fails only this transaction. Panics while the
+// handler serves the chaincode's own requests are recovered by the handler.
+func (cs *ChaincodeSupport) executeHandler(h *Handler, txParams *ccprovider.TransactionParams, namespace string, msg *pb.ChaincodeMessage, timeout time.Duration) (resp *pb.ChaincodeMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr := &ChaincodePanicError{Chaincode: namespace, Value: r, Stack: debug.Stack()}
+			invocationLogger(txParams).Errorf("[%s] recovered from %s\n%s", shorttxid(txParams.TxID), perr, perr.Stack)
+			cs.handlerPanicked(h.chaincodeID)
+			resp, err = nil, perr
+		}
+	}()
+
+	return h.Execute(txParams, namespace, msg, timeout)
+}
+
+// handlerPanicked stops a user chaincode after a panic while executing it or
+// serving its requests, when StopOnPanic is set.
+func (cs *ChaincodeSupport) handlerPanicked(ccid string) {
+	if cs.StopOnPanic && !cs.isSysCCID(ccid) {
+		go cs.stopAfterPanic(ccid)
+	}
+}
+
+// stopAfterPanic stops a chaincode whose execution panicked.
+func (cs *ChaincodeSupport) stopAfterPanic(ccid string) {
+	chaincodeLogger.Warningf("stopping chaincode %s after panic during execution", ccid)
+	if err := cs.Stop(ccid); err != nil {
+		chaincodeLogger.Warningf("failed to stop chaincode %s after panic: %s", ccid, err)
+	}
+}
+
+// memoryUsage returns the latest memory usage sampled for the chaincode by
+// SampleMemory. The bool is false if no usage has been sampled. Executions
+// never wait on the runtime to measure the usage.
+func (cs *ChaincodeSupport) memoryUsage(ccid string) (uint64, bool) {
+	return cs.memorySamples.get(ccid)
+}
+
+// retryable determines whether a failed execution may be attempted again.
+// Only transactions that are flagged as idempotent are retried, and only
+// when they timed out.
+func (cs *ChaincodeSupport) retryable(cctyp pb.ChaincodeMessage_Type, txParams *ccprovider.TransactionParams, err error) bool {
+	return cs.RetryOnTimeout &&
+		txParams.IsIdempotent &&
+		cctyp == pb.ChaincodeMessage_TRANSACTION &&
+		err.Error() == ErrorExecutionTimeout
+}
+
+// createCCMessage creates a message carrying the chaincode input. When
+// checksum is set, the input is decorated with its SHA-256 checksum.
+func createCCMessage(messageType pb.ChaincodeMessage_Type, cid string, txid string, namespace string, cMsg *pb.ChaincodeInput, checksum bool) (*pb.ChaincodeMessage, error) {
+	if checksum {
+		var err error
+		if cMsg, err = addInputChecksum(cMsg); err != nil {
+			return nil, errors.Wrapf(err, "failed to checksum %s input for chaincode %s in transaction %s", messageType, namespace, txid)
+		}
+	}
+	payload, err := proto.Marshal(cMsg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal %s input for chaincode %s in transaction %s", messageType, namespace, txid)
+	}
+
+	return &pb.ChaincodeMessage{
+		Type:      messageType,
+		Payload:   payload,
+		Txid:      txid,
+		ChannelId: cid,
+	}, nil
+}
+
 func (cs *ChaincodeSupport) executeTimeout(namespace string, input *pb.ChaincodeInput) time.Duration {
 	operation := chaincodeOperation(input.Args)
 	switch {
@@ -0,0 +1,140 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-lib-go/common/metrics"
+	"github.com/pkg/errors"
+)
+
+// ErrChannelLimitReached is returned for executions rejected because their
+// channel is at its concurrency limit.
+var ErrChannelLimitReached = errors.New("channel concurrency limit reached")
+
+// ChannelLimitPolicy determines what happens to an execution on a channel
+// which is at its concurrency limit.
+type ChannelLimitPolicy string
+
+const (
+	// BlockAtChannelLimit makes the execution wait for an execution on the
+	// channel to complete. This is the default.
+	BlockAtChannelLimit ChannelLimitPolicy = "block"
+	// RejectAtChannelLimit fails the execution with ErrChannelLimitReached.
+	RejectAtChannelLimit ChannelLimitPolicy = "reject"
+)
+
+type channelTx struct {
+	channelID string
+	txID      string
+}
+
+// ChannelLimiter tracks the chaincode executions in progress on each channel
+// and bounds them on channels which have a limit, so that one busy channel
+// cannot monopolize the chaincodes of a peer shared by several tenants. As
+// with the ExecuteLimiter, executions for the same transaction share a single
+// slot so that nested invocations cannot deadlock.
+type ChannelLimiter struct {
+	limits     map[string]int
+	policy     ChannelLimitPolicy
+	inFlight   metrics.Gauge
+	rejections metrics.Counter
+
+	mutex    sync.Mutex
+	released *sync.Cond
+	active   map[string]int
+	holders  map[channelTx]int
+}
+
+// NewChannelLimiter creates a ChannelLimiter which admits at most the limit
+// of concurrent executions on each channel in limits, applying the policy to
+// executions beyond it. Channels without a positive limit are not bounded.
+// The number of executions in progress on each channel is reported through
+// the inFlight gauge, and the number of executions rejected at a limit
+// through the rejections counter.
+func NewChannelLimiter(limits map[string]int, policy ChannelLimitPolicy, inFlight metrics.Gauge, rejections metrics.Counter) *ChannelLimiter {
+	l := &ChannelLimiter{
+		limits:     limits,
+		policy:     policy,
+		inFlight:   inFlight,
+		rejections: rejections,
+		active:     map[string]int{},
+		holders:    map[channelTx]int{},
+	}
+	l.released = sync.NewCond(&l.mutex)
+	return l
+}
+
+// Limits returns the concurrency limit of each bounded channel.
+func (l *ChannelLimiter) Limits() map[string]int {
+	return l.limits
+}
+
+// Policy returns the policy applied to executions beyond a channel's limit.
+func (l *ChannelLimiter) Policy() ChannelLimitPolicy {
+	return l.policy
+}
+
+// Acquire takes an execution slot on the channel for the transaction. When
+// the channel is at its limit, Acquire waits for a slot or, under
+// RejectAtChannelLimit, returns ErrChannelLimitReached. Every successful call
+// to Acquire must be paired with a call to Release.
+func (l *ChannelLimiter) Acquire(channelID, txID string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := channelTx{channelID: channelID, txID: txID}
+	if l.holders[key] > 0 {
+		l.holders[key]++
+		return nil
+	}
+
+	if limit := l.limits[channelID]; limit > 0 && l.active[channelID] >= limit {
+		if l.policy == RejectAtChannelLimit {
+			l.rejections.With("channel", channelID).Add(1)
+			return errors.WithMessagef(ErrChannelLimitReached, "cannot execute transaction %s on channel %s with %d executions in progress", txID, channelID, l.active[channelID])
+		}
+		for l.active[channelID] >= limit && l.holders[key] == 0 {
+			l.released.Wait()
+		}
+		if l.holders[key] > 0 {
+			// another execution of the transaction acquired a slot meanwhile
+			l.holders[key]++
+			return nil
+		}
+	}
+
+	l.holders[key]++
+	l.active[channelID]++
+	l.inFlight.With("channel", channelID).Set(float64(l.active[channelID]))
+	return nil
+}
+
+// Release returns the slot held on the channel for the transaction once all
+// of its executions have completed.
+func (l *ChannelLimiter) Release(channelID, txID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := channelTx{channelID: channelID, txID: txID}
+	l.holders[key]--
+	if l.holders[key] > 0 {
+		return
+	}
+	delete(l.holders, key)
+	l.active[channelID]--
+	l.inFlight.With("channel", channelID).Set(float64(l.active[channelID]))
+	l.released.Broadcast()
+}
+
+// InFlight returns the number of transactions executing on the channel.
+func (l *ChannelLimiter) InFlight(channelID string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.active[channelID]
+}
@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode_test
+
+import (
+	"github.com/hyperledger/fabric-lib-go/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/core/chaincode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("ChannelLimiter", func() {
+	var (
+		fakeInFlight   *metricsfakes.Gauge
+		fakeRejections *metricsfakes.Counter
+		limiter        *chaincode.ChannelLimiter
+		admitted       chan string
+	)
+
+	// acquire takes a slot for txID on the busy channel in the background.
+	acquire := func(txID string) {
+		go func() {
+			if err := limiter.Acquire("busy-channel", txID); err == nil {
+				admitted <- txID
+			}
+		}()
+	}
+
+	BeforeEach(func() {
+		fakeInFlight = &metricsfakes.Gauge{}
+		fakeInFlight.WithReturns(fakeInFlight)
+		fakeRejections = &metricsfakes.Counter{}
+		fakeRejections.WithReturns(fakeRejections)
+		limiter = chaincode.NewChannelLimiter(map[string]int{"busy-channel": 1}, chaincode.BlockAtChannelLimit, fakeInFlight, fakeRejections)
+		admitted = make(chan string, 10)
+	})
+
+	It("reports the executions in flight on each channel", func() {
+		Expect(limiter.Acquire("busy-channel", "tx-1")).To(Succeed())
+		Expect(limiter.Acquire("other-channel", "tx-2")).To(Succeed())
+		Expect(limiter.InFlight("busy-channel")).To(Equal(1))
+		Expect(limiter.InFlight("other-channel")).To(Equal(1))
+
+		Expect(fakeInFlight.WithArgsForCall(0)).To(Equal([]string{"channel", "busy-channel"}))
+		Expect(fakeInFlight.SetArgsForCall(0)).To(Equal(1.0))
+
+		limiter.Release("busy-channel", "tx-1")
+		Expect(limiter.InFlight("busy-channel")).To(Equal(0))
+		Expect(fakeInFlight.SetArgsForCall(fakeInFlight.SetCallCount() - 1)).To(Equal(0.0))
+	})
+
+	It("does not limit channels without a limit", func() {
+		for _, txID := range []string{"tx-1", "tx-2", "tx-3"} {
+			Expect(limiter.Acquire("other-channel", txID)).To(Succeed())
+		}
+		Expect(fakeRejections.AddCallCount()).To(Equal(0))
+	})
+
+	It("admits nested executions of a transaction in the slot of their caller", func() {
+		Expect(limiter.Acquire("busy-channel", "tx-1")).To(Succeed())
+		Expect(limiter.Acquire("busy-channel", "tx-1")).To(Succeed())
+		Expect(limiter.InFlight("busy-channel")).To(Equal(1))
+		Expect(fakeRejections.AddCallCount()).To(Equal(0))
+	})
+
+	It("blocks executions beyond the limit until a slot is released", func() {
+		Expect(limiter.Acquire("busy-channel", "tx-1")).To(Succeed())
+		acquire("tx-2")
+		Consistently(admitted).ShouldNot(Receive())
+
+		limiter.Release("busy-channel", "tx-1")
+		Eventually(admitted).Should(Receive(Equal("tx-2")))
+		Expect(fakeRejections.AddCallCount()).To(Equal(0))
+	})
+
+	Context("when the policy is to reject", func() {
+		BeforeEach(func() {
+			limiter = chaincode.NewChannelLimiter(map[string]int{"busy-channel": 1}, chaincode.RejectAtChannelLimit, fakeInFlight, fakeRejections)
+		})
+
+		It("rejects executions beyond the limit", func() {
+			Expect(limiter.Acquire("busy-channel", "tx-1")).To(Succeed())
+
+			err := limiter.Acquire("busy-channel", "tx-2")
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrChannelLimitReached))
+			Expect(err).To(MatchError("cannot execute transaction tx-2 on channel busy-channel with 1 executions in progress: channel concurrency limit reached"))
+			Expect(fakeRejections.AddCallCount()).To(Equal(1))
+			Expect(fakeRejections.WithArgsForCall(0)).To(Equal([]string{"channel", "busy-channel"}))
+			Expect(limiter.InFlight("busy-channel")).To(Equal(1))
+		})
+	})
+})
@@ -12,25 +12,90 @@ import (
 	"time"
 
 	"github.com/hyperledger/fabric-lib-go/common/flogging"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/spf13/viper"
 )
 
 const (
-	defaultExecutionTimeout = 30 * time.Second
-	minimumStartupTimeout   = 5 * time.Second
+	defaultExecutionTimeout      = 30 * time.Second
+	minimumStartupTimeout        = 5 * time.Second
+	defaultKeepaliveRetryBackoff = 100 * time.Millisecond
+	defaultStartRetryBackoff     = 500 * time.Millisecond
+	defaultStartupProbeTimeout   = 10 * time.Second
+	defaultMemorySampleInterval  = 5 * time.Second
 )
 
 type Config struct {
-	TotalQueryLimit int
-	TLSEnabled      bool
-	Keepalive       time.Duration
-	ExecuteTimeout  time.Duration
-	InstallTimeout  time.Duration
-	StartupTimeout  time.Duration
-	LogFormat       string
-	LogLevel        string
-	ShimLogLevel    string
-	SCCAllowlist    map[string]bool
+	TotalQueryLimit             int
+	TLSEnabled                  bool
+	TLSClientAuth               TLSClientAuth
+	Keepalive                   time.Duration
+	KeepaliveRetries            int
+	KeepaliveRetryBackoff       time.Duration
+	KeepaliveMissThreshold      int
+	KeepaliveDisabled           map[string]bool
+	KeepaliveIdleOnly           bool
+	ContextKeys                 []ContextKey
+	SerialExecution             map[string]bool
+	ReplaceStaleHandlers        bool
+	DefaultChannel              string
+	ExecuteTimeout              time.Duration
+	SendTimeout                 time.Duration
+	SendBufferSize              int
+	ReceiveBufferSize           int
+	GracefulStopTimeout         time.Duration
+	StopGracePeriod             time.Duration
+	NotRunningPolicy            NotRunningPolicy
+	InstallTimeout              time.Duration
+	RetryOnTimeout              bool
+	StructuredErrors            bool
+	ErrorFormat                 ErrorFormat
+	DiscardUnknownFields        bool
+	AllowedResponseTypes        map[pb.ChaincodeMessage_Type]bool
+	MessageTypes                map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type
+	PayloadChecksums            bool
+	ResponseChecksums           bool
+	ChannelMetricLabel          bool
+	EnforceProposalVersion      bool
+	MaxInitInputBytes           int
+	MaxInvokeInputBytes         int
+	MemoryAccounting            bool
+	MemorySampleInterval        time.Duration
+	StopOnPanic                 bool
+	AutoRestart                 bool
+	AutoRestartReset            time.Duration
+	MaxAutoRestarts             int
+	StartupTimeout              time.Duration
+	StartupProbe                *StartupProbe
+	StartRetries                int
+	StartRetryBackoff           time.Duration
+	LaunchStallTimeout          time.Duration
+	RegistrationTimeout         time.Duration
+	LaunchFailureTTL            time.Duration
+	LaunchFailureMaxTTL         time.Duration
+	LaunchFailureWebhook        string
+	ResumeLaunches              bool
+	LaunchPolicy                LaunchPolicy
+	LifecycleLookupTimeout      time.Duration
+	DevModeWait                 time.Duration
+	MinContainerLifetime        time.Duration
+	LaunchJitter                time.Duration
+	ContainerNetwork            string
+	MaxConcurrentLaunches       int
+	TotalChaincodeMemoryLimitMB int
+	PrioritizeInitLaunches      bool
+	MaxConcurrentExecutions     int
+	ExecuteQueueAlertThreshold  int
+	SoftConcurrencyLimit        int
+	SystemExecutionReserve      int
+	ChannelLimits               map[string]int
+	ChannelLimitPolicy          ChannelLimitPolicy
+	RateLimits                  map[string]RateLimit
+	RateLimitPolicy             RateLimitPolicy
+	LogFormat                   string
+	LogLevel                    string
+	ShimLogLevel                string
+	SCCAllowlist                map[string]bool
 }
 
 func GlobalConfig() *Config {
@@ -46,17 +111,125 @@ func (c *Config) load() {
 	viper.SetEnvKeyReplacer(replacer)
 
 	c.TLSEnabled = viper.GetBool("peer.tls.enabled")
+	c.TLSClientAuth = getTLSClientAuthFromViper("chaincode.tlsClientAuth")
 
 	c.Keepalive = toSeconds(viper.GetString("chaincode.keepalive"), 0)
+	c.KeepaliveRetries = viper.GetInt("chaincode.keepaliveRetries")
+	c.KeepaliveRetryBackoff = viper.GetDuration("chaincode.keepaliveRetryBackoff")
+	if c.KeepaliveRetryBackoff <= 0 {
+		c.KeepaliveRetryBackoff = defaultKeepaliveRetryBackoff
+	}
+	c.KeepaliveMissThreshold = viper.GetInt("chaincode.keepaliveMissThreshold")
+	c.KeepaliveDisabled = map[string]bool{}
+	for _, ccid := range viper.GetStringSlice("chaincode.keepaliveDisabled") {
+		c.KeepaliveDisabled[ccid] = true
+	}
+	c.KeepaliveIdleOnly = viper.GetBool("chaincode.keepaliveIdleOnly")
+	for _, key := range viper.GetStringSlice("chaincode.contextKeys") {
+		c.ContextKeys = append(c.ContextKeys, ContextKey(key))
+	}
+	c.SerialExecution = map[string]bool{}
+	for _, name := range viper.GetStringSlice("chaincode.serialExecution") {
+		c.SerialExecution[name] = true
+	}
+	c.ReplaceStaleHandlers = viper.GetBool("chaincode.replaceStaleHandlers")
+	c.DefaultChannel = viper.GetString("chaincode.defaultChannel")
 	c.ExecuteTimeout = viper.GetDuration("chaincode.executetimeout")
 	if c.ExecuteTimeout < time.Second {
 		c.ExecuteTimeout = defaultExecutionTimeout
 	}
+	c.SendTimeout = viper.GetDuration("chaincode.sendTimeout")
+	c.SendBufferSize = viper.GetInt("chaincode.sendBufferSize")
+	c.ReceiveBufferSize = viper.GetInt("chaincode.receiveBufferSize")
+	c.GracefulStopTimeout = viper.GetDuration("chaincode.gracefulStopTimeout")
+	c.StopGracePeriod = viper.GetDuration("chaincode.stopGracePeriod")
+	c.NotRunningPolicy = getNotRunningPolicyFromViper("chaincode.notRunningPolicy")
 	c.InstallTimeout = viper.GetDuration("chaincode.installTimeout")
+	c.RetryOnTimeout = viper.GetBool("chaincode.retryOnTimeout")
+	c.MaxConcurrentExecutions = viper.GetInt("chaincode.maxConcurrentExecutions")
+	c.ExecuteQueueAlertThreshold = viper.GetInt("chaincode.executeQueueAlertThreshold")
+	c.SoftConcurrencyLimit = viper.GetInt("chaincode.softConcurrencyLimit")
+	if c.SoftConcurrencyLimit > 0 && c.MaxConcurrentExecutions <= 0 {
+		chaincodeLogger.Warningf("chaincode.softConcurrencyLimit is set without chaincode.maxConcurrentExecutions. the soft limit is disabled")
+		c.SoftConcurrencyLimit = 0
+	}
+	c.SystemExecutionReserve = viper.GetInt("chaincode.systemExecutionReserve")
+	c.ChannelLimits = map[string]int{}
+	for channelID, limit := range viper.GetStringMapString("chaincode.channelLimits") {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			chaincodeLogger.Warningf("chaincode.channelLimits has invalid limit %s for channel %s. the channel is not limited", limit, channelID)
+			continue
+		}
+		c.ChannelLimits[channelID] = n
+	}
+	c.ChannelLimitPolicy = getChannelLimitPolicyFromViper("chaincode.channelLimitPolicy")
+	c.RateLimits = map[string]RateLimit{}
+	for name := range viper.GetStringMap("chaincode.rateLimits") {
+		limit := RateLimit{
+			Rate:  viper.GetFloat64("chaincode.rateLimits." + name + ".rate"),
+			Burst: viper.GetInt("chaincode.rateLimits." + name + ".burst"),
+		}
+		if limit.Rate <= 0 {
+			chaincodeLogger.Warningf("chaincode.rateLimits has invalid rate for chaincode %s. the chaincode is not limited", name)
+			continue
+		}
+		c.RateLimits[name] = limit
+	}
+	c.RateLimitPolicy = getRateLimitPolicyFromViper("chaincode.rateLimitPolicy")
+	c.StructuredErrors = viper.GetBool("chaincode.structuredErrors")
+	c.ErrorFormat = getErrorFormatFromViper("chaincode.errorFormat")
+	c.DiscardUnknownFields = viper.GetBool("chaincode.discardUnknownFields")
+	c.AllowedResponseTypes = getAllowedResponseTypesFromViper("chaincode.allowedResponseTypes")
+	c.MessageTypes = getMessageTypesFromViper("chaincode.messageTypes")
+	c.PayloadChecksums = viper.GetBool("chaincode.payloadChecksums")
+	c.ResponseChecksums = viper.GetBool("chaincode.responseChecksums")
+	c.ChannelMetricLabel = viper.GetBool("chaincode.channelMetricLabel")
+	c.EnforceProposalVersion = viper.GetBool("chaincode.enforceProposalVersion")
+	c.MaxInitInputBytes = viper.GetInt("chaincode.maxInitInputBytes")
+	c.MaxInvokeInputBytes = viper.GetInt("chaincode.maxInvokeInputBytes")
+	c.MemoryAccounting = viper.GetBool("chaincode.memoryAccounting")
+	c.MemorySampleInterval = viper.GetDuration("chaincode.memorySampleInterval")
+	if c.MemorySampleInterval <= 0 {
+		c.MemorySampleInterval = defaultMemorySampleInterval
+	}
+	c.StopOnPanic = viper.GetBool("chaincode.stopOnPanic")
+	c.AutoRestart = viper.GetBool("chaincode.autoRestart")
+	c.MaxAutoRestarts = viper.GetInt("chaincode.maxAutoRestarts")
+	c.AutoRestartReset = viper.GetDuration("chaincode.autoRestartReset")
 	c.StartupTimeout = viper.GetDuration("chaincode.startuptimeout")
 	if c.StartupTimeout < minimumStartupTimeout {
 		c.StartupTimeout = minimumStartupTimeout
 	}
+	if command := viper.GetStringSlice("chaincode.startupProbe.command"); len(command) > 0 {
+		c.StartupProbe = &StartupProbe{
+			Command: command,
+			Timeout: viper.GetDuration("chaincode.startupProbe.timeout"),
+		}
+		if c.StartupProbe.Timeout <= 0 {
+			c.StartupProbe.Timeout = defaultStartupProbeTimeout
+		}
+	}
+	c.StartRetries = viper.GetInt("chaincode.startRetries")
+	c.StartRetryBackoff = viper.GetDuration("chaincode.startRetryBackoff")
+	if c.StartRetryBackoff <= 0 {
+		c.StartRetryBackoff = defaultStartRetryBackoff
+	}
+	c.LaunchStallTimeout = viper.GetDuration("chaincode.launchStallTimeout")
+	c.RegistrationTimeout = viper.GetDuration("chaincode.registrationTimeout")
+	c.LaunchFailureTTL = viper.GetDuration("chaincode.launchFailureTTL")
+	c.LaunchFailureMaxTTL = viper.GetDuration("chaincode.launchFailureMaxTTL")
+	c.LaunchFailureWebhook = viper.GetString("chaincode.launchFailureWebhook")
+	c.ResumeLaunches = viper.GetBool("chaincode.resumeLaunches")
+	c.LaunchPolicy = getLaunchPolicyFromViper("chaincode.launchPolicy")
+	c.LifecycleLookupTimeout = viper.GetDuration("chaincode.lifecycleLookupTimeout")
+	c.DevModeWait = viper.GetDuration("chaincode.devModeWait")
+	c.MinContainerLifetime = viper.GetDuration("chaincode.minContainerLifetime")
+	c.LaunchJitter = viper.GetDuration("chaincode.launchJitter")
+	c.ContainerNetwork = viper.GetString("chaincode.containerNetwork")
+	c.MaxConcurrentLaunches = viper.GetInt("chaincode.maxConcurrentLaunches")
+	c.TotalChaincodeMemoryLimitMB = viper.GetInt("chaincode.totalMemoryLimitMB")
+	c.PrioritizeInitLaunches = viper.GetBool("chaincode.prioritizeInitLaunches")
 
 	c.SCCAllowlist = map[string]bool{}
 	for k, v := range viper.GetStringMapString("chaincode.system") {
@@ -91,6 +264,50 @@ func toSeconds(s string, def int) time.Duration {
 	return time.Duration(seconds) * time.Second
 }
 
+// getMessageTypesFromViper gets the mapping of the INIT and TRANSACTION
+// message types onto the types used by custom shims. A type may be given by
+// name or by number.
+func getMessageTypesFromViper(key string) map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type {
+	types := map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type{}
+	for from, to := range viper.GetStringMapString(key) {
+		t := pb.ChaincodeMessage_Type(pb.ChaincodeMessage_Type_value[strings.ToUpper(from)])
+		if t != pb.ChaincodeMessage_INIT && t != pb.ChaincodeMessage_TRANSACTION {
+			chaincodeLogger.Warningf("%s maps message type %s. only INIT and TRANSACTION may be mapped", key, from)
+			continue
+		}
+		mapped, ok := pb.ChaincodeMessage_Type_value[strings.ToUpper(to)]
+		if !ok {
+			n, err := strconv.ParseInt(to, 10, 32)
+			if err != nil {
+				chaincodeLogger.Warningf("%s maps %s to unknown message type %s. the type is not mapped", key, from, to)
+				continue
+			}
+			mapped = int32(n)
+		}
+		types[t] = pb.ChaincodeMessage_Type(mapped)
+	}
+	return types
+}
+
+// getAllowedResponseTypesFromViper gets the additional response message types
+// from viper. Types unknown to this peer are given by number.
+func getAllowedResponseTypesFromViper(key string) map[pb.ChaincodeMessage_Type]bool {
+	types := map[pb.ChaincodeMessage_Type]bool{}
+	for _, name := range viper.GetStringSlice(key) {
+		t, ok := pb.ChaincodeMessage_Type_value[strings.ToUpper(name)]
+		if !ok {
+			n, err := strconv.ParseInt(name, 10, 32)
+			if err != nil {
+				chaincodeLogger.Warningf("%s has unknown message type %s. the type is not allowed", key, name)
+				continue
+			}
+			t = int32(n)
+		}
+		types[pb.ChaincodeMessage_Type(t)] = true
+	}
+	return types
+}
+
 // getLogLevelFromViper gets the chaincode container log levels from viper
 func getLogLevelFromViper(key string) string {
 	levelString := viper.GetString(key)
@@ -102,6 +319,121 @@ func getLogLevelFromViper(key string) string {
 	return flogging.NameToLevel(levelString).String()
 }
 
+// TLSClientAuth determines whether chaincode must authenticate with a TLS
+// client certificate issued by the peer when connecting to the peer.
+type TLSClientAuth string
+
+const (
+	// RequireAndVerifyClientCert requires chaincode to present a valid
+	// client certificate. This is the default.
+	RequireAndVerifyClientCert TLSClientAuth = "RequireAndVerifyClientCert"
+	// NoClientCert does not require chaincode to present a client
+	// certificate. It is only permitted in development mode.
+	NoClientCert TLSClientAuth = "NoClientCert"
+)
+
+// RequireClientCert returns true if chaincode must present a client
+// certificate.
+func (a TLSClientAuth) RequireClientCert() bool {
+	return a != NoClientCert
+}
+
+// getTLSClientAuthFromViper gets the chaincode TLS client auth mode from viper
+func getTLSClientAuthFromViper(key string) TLSClientAuth {
+	mode := TLSClientAuth(viper.GetString(key))
+	switch mode {
+	case RequireAndVerifyClientCert:
+		return mode
+	case NoClientCert:
+		// unauthenticated chaincode connections are only for development
+		if !IsDevMode() {
+			chaincodeLogger.Warningf("%s %s is only permitted in development mode. defaulting to %s", key, mode, RequireAndVerifyClientCert)
+			return RequireAndVerifyClientCert
+		}
+		return mode
+	case "":
+		return RequireAndVerifyClientCert
+	default:
+		chaincodeLogger.Warningf("%s has invalid TLS client auth mode %s. defaulting to %s", key, mode, RequireAndVerifyClientCert)
+		return RequireAndVerifyClientCert
+	}
+}
+
+// getChannelLimitPolicyFromViper gets the policy applied at channel
+// concurrency limits from viper
+func getChannelLimitPolicyFromViper(key string) ChannelLimitPolicy {
+	policy := ChannelLimitPolicy(viper.GetString(key))
+	switch policy {
+	case BlockAtChannelLimit, RejectAtChannelLimit:
+		return policy
+	case "":
+		return BlockAtChannelLimit
+	default:
+		chaincodeLogger.Warningf("%s has invalid channel limit policy %s. defaulting to %s", key, policy, BlockAtChannelLimit)
+		return BlockAtChannelLimit
+	}
+}
+
+// getErrorFormatFromViper gets the format of chaincode execution errors from
+// viper.
+func getErrorFormatFromViper(key string) ErrorFormat {
+	format := ErrorFormat(viper.GetString(key))
+	switch format {
+	case TerseErrors, VerboseErrors:
+		return format
+	case "":
+		return TerseErrors
+	default:
+		chaincodeLogger.Warningf("%s has invalid error format %s. defaulting to %s", key, format, TerseErrors)
+		return TerseErrors
+	}
+}
+
+// getLaunchPolicyFromViper gets the policy applied to invocations of a
+// chaincode which is being launched from viper.
+func getLaunchPolicyFromViper(key string) LaunchPolicy {
+	policy := LaunchPolicy(viper.GetString(key))
+	switch policy {
+	case WaitDuringLaunch, RejectDuringLaunch:
+		return policy
+	case "":
+		return WaitDuringLaunch
+	default:
+		chaincodeLogger.Warningf("%s has invalid launch policy %s. defaulting to %s", key, policy, WaitDuringLaunch)
+		return WaitDuringLaunch
+	}
+}
+
+// getNotRunningPolicyFromViper gets the policy applied to stopping a
+// chaincode which is not running from viper.
+func getNotRunningPolicyFromViper(key string) NotRunningPolicy {
+	policy := NotRunningPolicy(viper.GetString(key))
+	switch policy {
+	case StopNotRunning, RejectNotRunning, IgnoreNotRunning:
+		return policy
+	case "":
+		return StopNotRunning
+	default:
+		chaincodeLogger.Warningf("%s has invalid not running policy %s. defaulting to %s", key, policy, StopNotRunning)
+		return StopNotRunning
+	}
+}
+
+// getRateLimitPolicyFromViper gets the policy applied at chaincode rate
+// limits from viper.
+func getRateLimitPolicyFromViper(key string) RateLimitPolicy {
+	policy := RateLimitPolicy(viper.GetString(key))
+	switch policy {
+	case BlockAtRateLimit, RejectAtRateLimit:
+		return policy
+	case "":
+		return BlockAtRateLimit
+	default:
+		chaincodeLogger.Warningf("%s has invalid rate limit policy %s. defaulting to %s", key, policy, BlockAtRateLimit)
+		return BlockAtRateLimit
+	}
+}
+
 // DevModeUserRunsChaincode enables chaincode execution in a development
 // environment
 const DevModeUserRunsChaincode string = "dev"
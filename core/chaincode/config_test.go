@@ -9,6 +9,7 @@ package chaincode_test
 import (
 	"time"
 
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/chaincode"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -29,10 +30,75 @@ var _ = Describe("Config", func() {
 	Describe("GlobalConfig", func() {
 		It("captures the configuration from viper", func() {
 			viper.Set("peer.tls.enabled", "true")
+			viper.Set("chaincode.tlsClientAuth", "NoClientCert")
+			viper.Set("chaincode.mode", chaincode.DevModeUserRunsChaincode)
 			viper.Set("chaincode.keepalive", "50")
+			viper.Set("chaincode.keepaliveRetries", "3")
+			viper.Set("chaincode.keepaliveRetryBackoff", "2s")
+			viper.Set("chaincode.keepaliveMissThreshold", "2")
+			viper.Set("chaincode.keepaliveDisabled", []string{"external-cc:abc123"})
+			viper.Set("chaincode.keepaliveIdleOnly", true)
+			viper.Set("chaincode.contextKeys", []string{"tenant", "request-id"})
+			viper.Set("chaincode.serialExecution", []string{"unsafe-cc"})
+			viper.Set("chaincode.replaceStaleHandlers", true)
+			viper.Set("chaincode.defaultChannel", "default-channel")
 			viper.Set("chaincode.executetimeout", "20h")
 			viper.Set("chaincode.installTimeout", "30m")
+			viper.Set("chaincode.retryOnTimeout", true)
+			viper.Set("chaincode.maxConcurrentExecutions", 100)
+			viper.Set("chaincode.systemExecutionReserve", 4)
+			viper.Set("chaincode.executeQueueAlertThreshold", 50)
+			viper.Set("chaincode.softConcurrencyLimit", 80)
+			viper.Set("chaincode.channelLimits", map[string]interface{}{"busy-channel": 4, "bad-channel": "many"})
+			viper.Set("chaincode.channelLimitPolicy", "reject")
+			viper.Set("chaincode.rateLimits", map[string]interface{}{
+				"busy-cc": map[string]interface{}{"rate": 2.5, "burst": 5},
+				"bad-cc":  map[string]interface{}{"burst": 5},
+			})
+			viper.Set("chaincode.rateLimitPolicy", "reject")
+			viper.Set("chaincode.structuredErrors", true)
+			viper.Set("chaincode.errorFormat", "verbose")
+			viper.Set("chaincode.discardUnknownFields", true)
+			viper.Set("chaincode.allowedResponseTypes", []string{"transaction", "101", "not-a-type"})
+			viper.Set("chaincode.payloadChecksums", true)
+			viper.Set("chaincode.responseChecksums", true)
+			viper.Set("chaincode.channelMetricLabel", true)
+			viper.Set("chaincode.enforceProposalVersion", true)
+			viper.Set("chaincode.maxInitInputBytes", 1048576)
+			viper.Set("chaincode.maxInvokeInputBytes", 65536)
+			viper.Set("chaincode.memoryAccounting", true)
+			viper.Set("chaincode.memorySampleInterval", "2s")
+			viper.Set("chaincode.stopOnPanic", true)
+			viper.Set("chaincode.messageTypes", map[string]string{"init": "101", "transaction": "102"})
+			viper.Set("chaincode.autoRestart", true)
+			viper.Set("chaincode.maxAutoRestarts", 5)
+			viper.Set("chaincode.autoRestartReset", "10m")
+			viper.Set("chaincode.sendTimeout", "5s")
+			viper.Set("chaincode.sendBufferSize", 16)
+			viper.Set("chaincode.receiveBufferSize", 8)
+			viper.Set("chaincode.gracefulStopTimeout", "10s")
+			viper.Set("chaincode.stopGracePeriod", "20s")
+			viper.Set("chaincode.notRunningPolicy", "reject")
 			viper.Set("chaincode.startuptimeout", "30h")
+			viper.Set("chaincode.launchStallTimeout", "2m")
+			viper.Set("chaincode.registrationTimeout", "30s")
+			viper.Set("chaincode.launchFailureTTL", "30s")
+			viper.Set("chaincode.launchFailureMaxTTL", "5m")
+			viper.Set("chaincode.launchFailureWebhook", "https://alerts.example.com/fabric")
+			viper.Set("chaincode.resumeLaunches", true)
+			viper.Set("chaincode.launchPolicy", "reject")
+			viper.Set("chaincode.lifecycleLookupTimeout", "5s")
+			viper.Set("chaincode.devModeWait", "15s")
+			viper.Set("chaincode.minContainerLifetime", "5m")
+			viper.Set("chaincode.launchJitter", "500ms")
+			viper.Set("chaincode.containerNetwork", "sidecar-net")
+			viper.Set("chaincode.startRetries", 2)
+			viper.Set("chaincode.startupProbe.command", []string{"/bin/ready", "--check"})
+			viper.Set("chaincode.startupProbe.timeout", "3s")
+			viper.Set("chaincode.startRetryBackoff", "1s")
+			viper.Set("chaincode.maxConcurrentLaunches", 4)
+			viper.Set("chaincode.totalMemoryLimitMB", 8192)
+			viper.Set("chaincode.prioritizeInitLaunches", true)
 			viper.Set("chaincode.logging.format", "test-chaincode-logging-format")
 			viper.Set("chaincode.logging.level", "warning")
 			viper.Set("chaincode.logging.shim", "warning")
@@ -40,10 +106,73 @@ var _ = Describe("Config", func() {
 
 			config := chaincode.GlobalConfig()
 			Expect(config.TLSEnabled).To(BeTrue())
+			Expect(config.TLSClientAuth).To(Equal(chaincode.NoClientCert))
 			Expect(config.Keepalive).To(Equal(50 * time.Second))
+			Expect(config.KeepaliveRetries).To(Equal(3))
+			Expect(config.KeepaliveRetryBackoff).To(Equal(2 * time.Second))
+			Expect(config.KeepaliveMissThreshold).To(Equal(2))
+			Expect(config.KeepaliveDisabled).To(Equal(map[string]bool{"external-cc:abc123": true}))
+			Expect(config.KeepaliveIdleOnly).To(BeTrue())
+			Expect(config.ContextKeys).To(Equal([]chaincode.ContextKey{"tenant", "request-id"}))
+			Expect(config.SerialExecution).To(Equal(map[string]bool{"unsafe-cc": true}))
+			Expect(config.ReplaceStaleHandlers).To(BeTrue())
+			Expect(config.DefaultChannel).To(Equal("default-channel"))
 			Expect(config.ExecuteTimeout).To(Equal(20 * time.Hour))
 			Expect(config.InstallTimeout).To(Equal(30 * time.Minute))
+			Expect(config.RetryOnTimeout).To(BeTrue())
+			Expect(config.MaxConcurrentExecutions).To(Equal(100))
+			Expect(config.ExecuteQueueAlertThreshold).To(Equal(50))
+			Expect(config.SoftConcurrencyLimit).To(Equal(80))
+			Expect(config.SystemExecutionReserve).To(Equal(4))
+			Expect(config.ChannelLimits).To(Equal(map[string]int{"busy-channel": 4}))
+			Expect(config.ChannelLimitPolicy).To(Equal(chaincode.RejectAtChannelLimit))
+			Expect(config.RateLimits).To(Equal(map[string]chaincode.RateLimit{"busy-cc": {Rate: 2.5, Burst: 5}}))
+			Expect(config.RateLimitPolicy).To(Equal(chaincode.RejectAtRateLimit))
+			Expect(config.StructuredErrors).To(BeTrue())
+			Expect(config.ErrorFormat).To(Equal(chaincode.VerboseErrors))
+			Expect(config.DiscardUnknownFields).To(BeTrue())
+			Expect(config.AllowedResponseTypes).To(Equal(map[pb.ChaincodeMessage_Type]bool{pb.ChaincodeMessage_TRANSACTION: true, 101: true}))
+			Expect(config.PayloadChecksums).To(BeTrue())
+			Expect(config.ResponseChecksums).To(BeTrue())
+			Expect(config.ChannelMetricLabel).To(BeTrue())
+			Expect(config.EnforceProposalVersion).To(BeTrue())
+			Expect(config.MaxInitInputBytes).To(Equal(1048576))
+			Expect(config.MaxInvokeInputBytes).To(Equal(65536))
+			Expect(config.MemoryAccounting).To(BeTrue())
+			Expect(config.MemorySampleInterval).To(Equal(2 * time.Second))
+			Expect(config.StopOnPanic).To(BeTrue())
+			Expect(config.MessageTypes).To(Equal(map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type{
+				pb.ChaincodeMessage_INIT:        101,
+				pb.ChaincodeMessage_TRANSACTION: 102,
+			}))
+			Expect(config.AutoRestart).To(BeTrue())
+			Expect(config.MaxAutoRestarts).To(Equal(5))
+			Expect(config.AutoRestartReset).To(Equal(10 * time.Minute))
+			Expect(config.SendTimeout).To(Equal(5 * time.Second))
+			Expect(config.SendBufferSize).To(Equal(16))
+			Expect(config.ReceiveBufferSize).To(Equal(8))
+			Expect(config.GracefulStopTimeout).To(Equal(10 * time.Second))
+			Expect(config.StopGracePeriod).To(Equal(20 * time.Second))
+			Expect(config.NotRunningPolicy).To(Equal(chaincode.RejectNotRunning))
 			Expect(config.StartupTimeout).To(Equal(30 * time.Hour))
+			Expect(config.LaunchStallTimeout).To(Equal(2 * time.Minute))
+			Expect(config.RegistrationTimeout).To(Equal(30 * time.Second))
+			Expect(config.LaunchFailureTTL).To(Equal(30 * time.Second))
+			Expect(config.LaunchFailureMaxTTL).To(Equal(5 * time.Minute))
+			Expect(config.LaunchFailureWebhook).To(Equal("https://alerts.example.com/fabric"))
+			Expect(config.ResumeLaunches).To(BeTrue())
+			Expect(config.LaunchPolicy).To(Equal(chaincode.RejectDuringLaunch))
+			Expect(config.LifecycleLookupTimeout).To(Equal(5 * time.Second))
+			Expect(config.DevModeWait).To(Equal(15 * time.Second))
+			Expect(config.MinContainerLifetime).To(Equal(5 * time.Minute))
+			Expect(config.LaunchJitter).To(Equal(500 * time.Millisecond))
+			Expect(config.ContainerNetwork).To(Equal("sidecar-net"))
+			Expect(config.StartupProbe).To(Equal(&chaincode.StartupProbe{Command: []string{"/bin/ready", "--check"}, Timeout: 3 * time.Second}))
+			Expect(config.StartRetries).To(Equal(2))
+			Expect(config.StartRetryBackoff).To(Equal(time.Second))
+			Expect(config.MaxConcurrentLaunches).To(Equal(4))
+			Expect(config.TotalChaincodeMemoryLimitMB).To(Equal(8192))
+			Expect(config.PrioritizeInitLaunches).To(BeTrue())
 			Expect(config.LogFormat).To(Equal("test-chaincode-logging-format"))
 			Expect(config.LogLevel).To(Equal("warn"))
 			Expect(config.ShimLogLevel).To(Equal("warn"))
@@ -61,6 +190,28 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when the keepalive retry backoff is not set", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.keepaliveRetryBackoff", "")
+			})
+
+			It("falls back to the default backoff", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.KeepaliveRetryBackoff).To(Equal(100 * time.Millisecond))
+			})
+		})
+
+		Context("when the memory sample interval is not set", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.memorySampleInterval", "")
+			})
+
+			It("falls back to the default interval", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.MemorySampleInterval).To(Equal(5 * time.Second))
+			})
+		})
+
 		Context("when the execute timeout is less than the minimum", func() {
 			BeforeEach(func() {
 				viper.Set("chaincode.executetimeout", "15")
@@ -83,6 +234,108 @@ var _ = Describe("Config", func() {
 			})
 		})
 
+		Context("when the TLS client auth mode is not set", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.tlsClientAuth", "")
+			})
+
+			It("requires and verifies client certificates", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.TLSClientAuth).To(Equal(chaincode.RequireAndVerifyClientCert))
+				Expect(config.TLSClientAuth.RequireClientCert()).To(BeTrue())
+			})
+		})
+
+		Context("when client certificates are not required outside development mode", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.tlsClientAuth", "NoClientCert")
+				viper.Set("chaincode.mode", "net")
+			})
+
+			It("falls back to requiring and verifying client certificates", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.TLSClientAuth).To(Equal(chaincode.RequireAndVerifyClientCert))
+			})
+		})
+
+		Context("when an invalid TLS client auth mode is configured", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.tlsClientAuth", "Sometimes")
+			})
+
+			It("falls back to requiring and verifying client certificates", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.TLSClientAuth).To(Equal(chaincode.RequireAndVerifyClientCert))
+			})
+		})
+
+		Context("when a soft concurrency limit is set without a hard limit", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.softConcurrencyLimit", 80)
+				viper.Set("chaincode.maxConcurrentExecutions", 0)
+			})
+
+			It("disables the soft limit", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.SoftConcurrencyLimit).To(Equal(0))
+			})
+		})
+
+		Context("when an invalid channel limit policy is configured", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.channelLimitPolicy", "sometimes")
+			})
+
+			It("falls back to blocking", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.ChannelLimitPolicy).To(Equal(chaincode.BlockAtChannelLimit))
+			})
+		})
+
+		Context("when an invalid error format is configured", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.errorFormat", "chatty")
+			})
+
+			It("falls back to terse errors", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.ErrorFormat).To(Equal(chaincode.TerseErrors))
+			})
+		})
+
+		Context("when an invalid launch policy is configured", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.launchPolicy", "sometimes")
+			})
+
+			It("falls back to waiting", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.LaunchPolicy).To(Equal(chaincode.WaitDuringLaunch))
+			})
+		})
+
+		Context("when an invalid not running policy is configured", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.notRunningPolicy", "sometimes")
+			})
+
+			It("falls back to stopping", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.NotRunningPolicy).To(Equal(chaincode.StopNotRunning))
+			})
+		})
+
+		Context("when an invalid rate limit policy is configured", func() {
+			BeforeEach(func() {
+				viper.Set("chaincode.rateLimitPolicy", "sometimes")
+			})
+
+			It("falls back to blocking", func() {
+				config := chaincode.GlobalConfig()
+				Expect(config.RateLimitPolicy).To(Equal(chaincode.BlockAtRateLimit))
+			})
+		})
+
 		Context("when an invalid log level is configured", func() {
 			BeforeEach(func() {
 				viper.Set("chaincode.logging.level", "foo")
@@ -116,13 +369,18 @@ func capture() (restore func()) {
 	viper.SetEnvPrefix("CORE")
 	viper.AutomaticEnv()
 	config := map[string]string{
-		"peer.tls.enabled":         viper.GetString("peer.tls.enabled"),
-		"chaincode.keepalive":      viper.GetString("chaincode.keepalive"),
-		"chaincode.executetimeout": viper.GetString("chaincode.executetimeout"),
-		"chaincode.startuptimeout": viper.GetString("chaincode.startuptimeout"),
-		"chaincode.logging.format": viper.GetString("chaincode.logging.format"),
-		"chaincode.logging.level":  viper.GetString("chaincode.logging.level"),
-		"chaincode.logging.shim":   viper.GetString("chaincode.logging.shim"),
+		"peer.tls.enabled":                 viper.GetString("peer.tls.enabled"),
+		"chaincode.tlsClientAuth":          viper.GetString("chaincode.tlsClientAuth"),
+		"chaincode.mode":                   viper.GetString("chaincode.mode"),
+		"chaincode.keepalive":              viper.GetString("chaincode.keepalive"),
+		"chaincode.keepaliveRetries":       viper.GetString("chaincode.keepaliveRetries"),
+		"chaincode.keepaliveRetryBackoff":  viper.GetString("chaincode.keepaliveRetryBackoff"),
+		"chaincode.keepaliveMissThreshold": viper.GetString("chaincode.keepaliveMissThreshold"),
+		"chaincode.executetimeout":         viper.GetString("chaincode.executetimeout"),
+		"chaincode.startuptimeout":         viper.GetString("chaincode.startuptimeout"),
+		"chaincode.logging.format":         viper.GetString("chaincode.logging.format"),
+		"chaincode.logging.level":          viper.GetString("chaincode.logging.level"),
+		"chaincode.logging.shim":           viper.GetString("chaincode.logging.shim"),
 	}
 
 	return func() {
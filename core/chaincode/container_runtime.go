@@ -7,6 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"math"
+	"time"
+
 	"github.com/hyperledger/fabric/core/container"
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/pkg/errors"
@@ -23,12 +26,56 @@ type ContainerRouter interface {
 	Start(ccid string, peerConnection *ccintf.PeerConnection) error
 	Stop(ccid string) error
 	Wait(ccid string) (int, error)
+	ImageDigest(ccid string) (string, error)
+	MemoryUsage(ccid string) (uint64, bool, error)
+	Probe(ccid string, command []string, timeout time.Duration) error
+}
+
+// A StartBackoff decides whether a failed container start is retried by the
+// runtime before the failure is reported to the launcher. Given the number of
+// attempts which failed so far and the last error, it returns the delay before
+// the next attempt, or false to give up. When the container is started with
+// StartUntil, as the RuntimeLauncher does, retries end with the launch.
+type StartBackoff interface {
+	Backoff(attempt int, err error) (delay time.Duration, retry bool)
+}
+
+// ExponentialStartBackoff retries a failed container start up to Retries
+// times, waiting Initial before the first retry and doubling the delay before
+// each subsequent one, up to Max when it is set.
+type ExponentialStartBackoff struct {
+	Retries int
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Backoff implements StartBackoff.
+func (e *ExponentialStartBackoff) Backoff(attempt int, err error) (time.Duration, bool) {
+	if attempt > e.Retries {
+		return 0, false
+	}
+
+	limit := e.Max
+	if limit <= 0 {
+		limit = math.MaxInt64 / 2
+	}
+	delay := e.Initial
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if e.Max > 0 && delay > e.Max {
+		delay = e.Max
+	}
+	return delay, true
 }
 
 // ContainerRuntime is responsible for managing containerized chaincode.
 type ContainerRuntime struct {
 	ContainerRouter ContainerRouter
 	BuildRegistry   *container.BuildRegistry
+	// StartBackoff, if set, retries transient container start failures.
+	// Without it a failed start is reported to the launcher immediately.
+	StartBackoff StartBackoff
 }
 
 // Build builds the chaincode if necessary and returns ChaincodeServerInfo if
@@ -50,9 +97,32 @@ func (c *ContainerRuntime) Build(ccid string) (*ccintf.ChaincodeServerInfo, erro
 
 // Start launches chaincode in a runtime environment.
 func (c *ContainerRuntime) Start(ccid string, ccinfo *ccintf.PeerConnection) error {
+	return c.StartUntil(nil, ccid, ccinfo)
+}
+
+// StartUntil is Start, giving up retrying a failed start once done is closed
+// so that no container is started after its launch has ended. A nil done
+// channel never gives up.
+func (c *ContainerRuntime) StartUntil(done <-chan struct{}, ccid string, ccinfo *ccintf.PeerConnection) error {
 	chaincodeLogger.Debugf("start container: %s", ccid)
 
-	if err := c.ContainerRouter.Start(ccid, ccinfo); err != nil {
+	err := c.ContainerRouter.Start(ccid, ccinfo)
+	for attempt := 1; err != nil && c.StartBackoff != nil; attempt++ {
+		delay, retry := c.StartBackoff.Backoff(attempt, err)
+		if !retry {
+			break
+		}
+		chaincodeLogger.Warningf("retrying start of container %s in %s after attempt %d failed: %s", ccid, delay, attempt, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-done:
+			timer.Stop()
+			return errors.WithMessagef(err, "error starting container, gave up retrying after attempt %d as the launch ended", attempt)
+		}
+		err = c.ContainerRouter.Start(ccid, ccinfo)
+	}
+	if err != nil {
 		return errors.WithMessage(err, "error starting container")
 	}
 
@@ -72,3 +142,25 @@ func (c *ContainerRuntime) Stop(ccid string) error {
 func (c *ContainerRuntime) Wait(ccid string) (int, error) {
 	return c.ContainerRouter.Wait(ccid)
 }
+
+// ImageDigest returns the digest of the image the chaincode runs from, or an
+// empty digest if the chaincode does not run from an image.
+func (c *ContainerRuntime) ImageDigest(ccid string) (string, error) {
+	return c.ContainerRouter.ImageDigest(ccid)
+}
+
+// MemoryUsage returns the resident memory in bytes of the chaincode. The bool
+// is false if the chaincode runtime cannot report its memory usage.
+func (c *ContainerRuntime) MemoryUsage(ccid string) (uint64, bool, error) {
+	return c.ContainerRouter.MemoryUsage(ccid)
+}
+
+// Probe runs the command in the chaincode container and returns an error
+// unless it succeeds within the timeout.
+func (c *ContainerRuntime) Probe(ccid string, command []string, timeout time.Duration) error {
+	if err := c.ContainerRouter.Probe(ccid, command, timeout); err != nil {
+		return errors.WithMessage(err, "error probing container")
+	}
+
+	return nil
+}
@@ -8,6 +8,7 @@ package chaincode_test
 
 import (
 	"testing"
+	"time"
 
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/chaincode"
@@ -83,6 +84,120 @@ func TestContainerRuntimeStartErrors(t *testing.T) {
 	}
 }
 
+func TestContainerRuntimeStartRetries(t *testing.T) {
+	fakeRouter := &mock.ContainerRouter{}
+	fakeRouter.StartReturnsOnCall(0, errors.New("transient-failure"))
+	fakeRouter.StartReturnsOnCall(1, errors.New("transient-failure"))
+	fakeRouter.StartReturnsOnCall(2, nil)
+
+	cr := &chaincode.ContainerRuntime{
+		ContainerRouter: fakeRouter,
+		StartBackoff:    &chaincode.ExponentialStartBackoff{Retries: 2, Initial: time.Millisecond},
+	}
+
+	err := cr.Start("chaincode-name:chaincode-version", &ccintf.PeerConnection{Address: "peer-address"})
+	require.NoError(t, err)
+	require.Equal(t, 3, fakeRouter.StartCallCount())
+}
+
+func TestContainerRuntimeStartRetriesExhausted(t *testing.T) {
+	fakeRouter := &mock.ContainerRouter{}
+	fakeRouter.StartReturns(errors.New("persistent-failure"))
+
+	cr := &chaincode.ContainerRuntime{
+		ContainerRouter: fakeRouter,
+		StartBackoff:    &chaincode.ExponentialStartBackoff{Retries: 2, Initial: time.Millisecond},
+	}
+
+	err := cr.Start("chaincode-name:chaincode-version", &ccintf.PeerConnection{Address: "peer-address"})
+	require.EqualError(t, err, "error starting container: persistent-failure")
+	require.Equal(t, 3, fakeRouter.StartCallCount())
+}
+
+func TestContainerRuntimeStartWithoutBackoff(t *testing.T) {
+	fakeRouter := &mock.ContainerRouter{}
+	fakeRouter.StartReturns(errors.New("transient-failure"))
+
+	cr := &chaincode.ContainerRuntime{
+		ContainerRouter: fakeRouter,
+	}
+
+	err := cr.Start("chaincode-name:chaincode-version", &ccintf.PeerConnection{Address: "peer-address"})
+	require.EqualError(t, err, "error starting container: transient-failure")
+	require.Equal(t, 1, fakeRouter.StartCallCount())
+}
+
+func TestExponentialStartBackoff(t *testing.T) {
+	backoff := &chaincode.ExponentialStartBackoff{Retries: 3, Initial: 100 * time.Millisecond}
+
+	for attempt, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		delay, retry := backoff.Backoff(attempt+1, errors.New("failed"))
+		require.True(t, retry)
+		require.Equal(t, expected, delay)
+	}
+
+	_, retry := backoff.Backoff(4, errors.New("failed"))
+	require.False(t, retry)
+}
+
+func TestExponentialStartBackoffMax(t *testing.T) {
+	backoff := &chaincode.ExponentialStartBackoff{Retries: 100, Initial: 100 * time.Millisecond, Max: 300 * time.Millisecond}
+
+	for attempt, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		delay, retry := backoff.Backoff(attempt+1, errors.New("failed"))
+		require.True(t, retry)
+		require.Equal(t, expected, delay)
+	}
+
+	delay, retry := backoff.Backoff(100, errors.New("failed"))
+	require.True(t, retry)
+	require.Equal(t, 300*time.Millisecond, delay)
+}
+
+func TestExponentialStartBackoffDoesNotOverflow(t *testing.T) {
+	backoff := &chaincode.ExponentialStartBackoff{Retries: 100, Initial: time.Second}
+
+	delay, retry := backoff.Backoff(100, errors.New("failed"))
+	require.True(t, retry)
+	require.True(t, delay > 0)
+}
+
+func TestContainerRuntimeStartUntilDone(t *testing.T) {
+	fakeRouter := &mock.ContainerRouter{}
+	fakeRouter.StartReturns(errors.New("transient-failure"))
+
+	cr := &chaincode.ContainerRuntime{
+		ContainerRouter: fakeRouter,
+		StartBackoff:    &chaincode.ExponentialStartBackoff{Retries: 2, Initial: time.Hour},
+	}
+
+	done := make(chan struct{})
+	close(done)
+	err := cr.StartUntil(done, "chaincode-name:chaincode-version", &ccintf.PeerConnection{Address: "peer-address"})
+	require.EqualError(t, err, "error starting container, gave up retrying after attempt 1 as the launch ended: transient-failure")
+	require.Equal(t, 1, fakeRouter.StartCallCount())
+}
+
+func TestContainerRuntimeProbe(t *testing.T) {
+	fakeRouter := &mock.ContainerRouter{}
+
+	cr := &chaincode.ContainerRuntime{
+		ContainerRouter: fakeRouter,
+	}
+
+	err := cr.Probe("chaincode-name:chaincode-version", []string{"/bin/ready"}, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 1, fakeRouter.ProbeCallCount())
+	ccid, command, timeout := fakeRouter.ProbeArgsForCall(0)
+	require.Equal(t, "chaincode-name:chaincode-version", ccid)
+	require.Equal(t, []string{"/bin/ready"}, command)
+	require.Equal(t, time.Second, timeout)
+
+	fakeRouter.ProbeReturns(errors.New("not-ready"))
+	err = cr.Probe("chaincode-name:chaincode-version", []string{"/bin/ready"}, time.Second)
+	require.EqualError(t, err, "error probing container: not-ready")
+}
+
 func TestContainerRuntimeStop(t *testing.T) {
 	fakeRouter := &mock.ContainerRouter{}
 
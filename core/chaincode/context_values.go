@@ -0,0 +1,35 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "context"
+
+// A ContextKey is the key of a request-scoped value in the context of an
+// invocation. Values stored under a key listed in the ContextKeys of the
+// handler are copied into the transaction context of the invocation.
+type ContextKey string
+
+// contextValues returns the values of ctx for the given keys. Keys without a
+// value are omitted. It returns nil when no value is copied.
+func contextValues(ctx context.Context, keys []ContextKey) map[ContextKey]interface{} {
+	if ctx == nil {
+		return nil
+	}
+
+	var values map[ContextKey]interface{}
+	for _, key := range keys {
+		value := ctx.Value(key)
+		if value == nil {
+			continue
+		}
+		if values == nil {
+			values = map[ContextKey]interface{}{}
+		}
+		values[key] = value
+	}
+	return values
+}
@@ -0,0 +1,54 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-lib-go/common/flogging"
+	"github.com/hyperledger/fabric/core/common/ccprovider"
+)
+
+// CorrelationIDDecoration is the proposal decoration which carries the
+// correlation ID of an invocation to the chaincode.
+const CorrelationIDDecoration = "correlation_id"
+
+// correlationID returns the correlation ID of the invocation. An ID set on
+// the transaction parameters takes precedence over one supplied as a
+// proposal decoration.
+func correlationID(txParams *ccprovider.TransactionParams) string {
+	if txParams.CorrelationID != "" {
+		return txParams.CorrelationID
+	}
+	return string(txParams.ProposalDecorations[CorrelationIDDecoration])
+}
+
+// invocationDecorations returns the decorations passed to the chaincode for
+// the invocation, including its correlation ID. The proposal decorations are
+// not modified.
+func invocationDecorations(txParams *ccprovider.TransactionParams) map[string][]byte {
+	id := correlationID(txParams)
+	if id == "" || string(txParams.ProposalDecorations[CorrelationIDDecoration]) == id {
+		return txParams.ProposalDecorations
+	}
+
+	decorations := make(map[string][]byte, len(txParams.ProposalDecorations)+1)
+	for k, v := range txParams.ProposalDecorations {
+		decorations[k] = v
+	}
+	decorations[CorrelationIDDecoration] = []byte(id)
+	return decorations
+}
+
+// invocationLogger returns the logger for the log lines of an invocation,
+// honoring its requested log level and tagging the lines with its
+// correlation ID.
+func invocationLogger(txParams *ccprovider.TransactionParams) *flogging.FabricLogger {
+	logger := txLogger(string(txParams.ProposalDecorations[LogLevelDecoration]))
+	if id := correlationID(txParams); id != "" {
+		return logger.With("correlationID", id)
+	}
+	return logger
+}
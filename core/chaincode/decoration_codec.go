@@ -0,0 +1,17 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+// A DecorationCodec serializes the proposal decorations of an invocation
+// before they are passed to the chaincode, so that typed decorations are
+// encoded consistently for every chaincode.
+type DecorationCodec interface {
+	// Encode returns the decorations to pass to the chaincode. The
+	// decorations provided must not be modified. An error fails the
+	// invocation.
+	Encode(decorations map[string][]byte) (map[string][]byte, error)
+}
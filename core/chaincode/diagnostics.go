@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "time"
+
+// DiagnosticsReport is a snapshot of the state of chaincode support, collected
+// for inclusion in bug reports.
+type DiagnosticsReport struct {
+	// Time is when the report was collected.
+	Time time.Time
+	// Chaincodes describes the chaincodes registered with the peer, sorted by
+	// chaincode ID.
+	Chaincodes []ChaincodeDiagnostics
+	// PendingLaunches holds the IDs of the chaincodes whose launch is queued
+	// or in progress.
+	PendingLaunches []string
+	// LaunchErrors holds the errors of recent launch failures which are
+	// still remembered, by chaincode ID.
+	LaunchErrors map[string]string
+	// Config holds the settings in effect.
+	Config Config
+}
+
+// ChaincodeDiagnostics describes a chaincode registered with the peer.
+type ChaincodeDiagnostics struct {
+	ChaincodeID   string
+	RemoteAddress string
+	ImageDigest   string
+	// InFlight is the number of executions in progress.
+	InFlight int
+}
+
+// DiagnosticsDump collects a DiagnosticsReport. It may be called while
+// chaincodes are launching and executing; each part of the report is
+// consistent on its own, but the parts are not collected atomically.
+func (cs *ChaincodeSupport) DiagnosticsDump() DiagnosticsReport {
+	report := DiagnosticsReport{
+		Time:            time.Now(),
+		PendingLaunches: cs.PendingLaunches(),
+		LaunchErrors:    map[string]string{},
+		Config:          cs.EffectiveConfig(),
+	}
+
+	for _, ccid := range cs.HandlerRegistry.Registered() {
+		h := cs.HandlerRegistry.Handler(ccid)
+		if h == nil {
+			// deregistered since it was listed
+			continue
+		}
+		digest, _ := cs.ImageDigest(ccid)
+		report.Chaincodes = append(report.Chaincodes, ChaincodeDiagnostics{
+			ChaincodeID:   ccid,
+			RemoteAddress: h.RemoteAddress(),
+			ImageDigest:   digest,
+			InFlight:      h.inFlight(),
+		})
+	}
+
+	for ccid, err := range cs.launchFailures.list() {
+		report.LaunchErrors[ccid] = err.Error()
+	}
+
+	return report
+}
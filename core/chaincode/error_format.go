@@ -0,0 +1,44 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorFormat determines how much context is added to the error of a failed
+// chaincode execution.
+type ErrorFormat string
+
+const (
+	// TerseErrors returns the failure of the execution as it is. This is the
+	// default.
+	TerseErrors ErrorFormat = "terse"
+	// VerboseErrors also identifies the chaincode and transaction, whether
+	// the execution failed or timed out, and how long it ran before then.
+	VerboseErrors ErrorFormat = "verbose"
+)
+
+// executeError wraps the failure of an execution of the chaincode namespace
+// for the transaction txID which started at startTime.
+func (cs *ChaincodeSupport) executeError(err error, namespace, txID string, startTime time.Time) error {
+	if cs.ErrorFormat != VerboseErrors {
+		return err
+	}
+
+	elapsed := time.Since(startTime).Round(time.Millisecond)
+	switch errors.Cause(err).Error() {
+	case ErrorExecutionTimeout:
+		return errors.WithMessagef(err, "chaincode %s timed out executing transaction %s after %s", namespace, txID, elapsed)
+	case ErrorSendTimeout:
+		return errors.WithMessagef(err, "chaincode %s timed out receiving transaction %s after %s", namespace, txID, elapsed)
+	default:
+		return errors.WithMessagef(err, "chaincode %s failed executing transaction %s after %s", namespace, txID, elapsed)
+	}
+}
@@ -0,0 +1,21 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// An EventSink receives the chaincode events of all executions, allowing peer
+// subsystems to observe chaincode events centrally rather than through each
+// caller.
+type EventSink interface {
+	// Publish is called with each event set by a chaincode execution. Events
+	// of failed executions are published as well. Publish is called on the
+	// execution path and must not block.
+	Publish(event *pb.ChaincodeEvent)
+}
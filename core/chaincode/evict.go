@@ -0,0 +1,48 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "time"
+
+// EvictIdle stops and deregisters the user chaincodes which have not executed
+// a transaction for longer than maxIdle, and returns the IDs of the evicted
+// chaincodes. Chaincodes with an execution in progress and system chaincodes
+// are not evicted, nor are chaincodes which registered less than
+// MinContainerLifetime ago. An evicted chaincode is launched again when it is
+// next invoked.
+func (cs *ChaincodeSupport) EvictIdle(maxIdle time.Duration) []string {
+	var evicted []string
+	for _, ccid := range cs.HandlerRegistry.Registered() {
+		if cs.isSysCCID(ccid) {
+			continue
+		}
+		h := cs.HandlerRegistry.Handler(ccid)
+		if h == nil {
+			continue
+		}
+		lastActivity, idle := h.idleSince()
+		if !idle || time.Since(lastActivity) <= maxIdle {
+			continue
+		}
+		if time.Since(h.registeredSince()) < cs.MinContainerLifetime {
+			chaincodeLogger.Debugf("not evicting chaincode %s which has not reached its minimum lifetime", ccid)
+			continue
+		}
+
+		chaincodeLogger.Infof("evicting chaincode %s which has been idle since %s", ccid, lastActivity)
+		if err := cs.Stop(ccid); err != nil {
+			chaincodeLogger.Warningf("failed to evict idle chaincode %s: %s", ccid, err)
+			continue
+		}
+		// the stream of the stopped chaincode may already have deregistered it
+		if err := cs.HandlerRegistry.Deregister(ccid); err != nil {
+			chaincodeLogger.Debugf("idle chaincode %s was already deregistered: %s", ccid, err)
+		}
+		evicted = append(evicted, ccid)
+	}
+	return evicted
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "sync"
+
+// ExecStats holds cumulative execution counts for a chaincode.
+type ExecStats struct {
+	// Executions is the number of completed Init and Invoke executions.
+	Executions uint64
+	// Successes is the number of executions which returned a response.
+	Successes uint64
+	// Errors is the number of executions which failed, including those
+	// that timed out.
+	Errors uint64
+	// Timeouts is the number of executions which exceeded the execute
+	// timeout.
+	Timeouts uint64
+}
+
+// execStatsTracker maintains ExecStats by chaincode name. The zero value is
+// ready to use.
+type execStatsTracker struct {
+	mutex sync.Mutex
+	stats map[string]*ExecStats
+}
+
+func (t *execStatsTracker) record(ccName string, success, timeout bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.stats == nil {
+		t.stats = map[string]*ExecStats{}
+	}
+	s, ok := t.stats[ccName]
+	if !ok {
+		s = &ExecStats{}
+		t.stats[ccName] = s
+	}
+
+	s.Executions++
+	switch {
+	case success:
+		s.Successes++
+	case timeout:
+		s.Errors++
+		s.Timeouts++
+	default:
+		s.Errors++
+	}
+}
+
+func (t *execStatsTracker) get(ccName string) (ExecStats, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.stats[ccName]
+	if !ok {
+		return ExecStats{}, false
+	}
+	return *s, true
+}
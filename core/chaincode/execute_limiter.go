@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-lib-go/common/metrics"
+)
+
+// ExecuteLimiter bounds the number of chaincode executions which may be in
+// progress at once. Executions beyond the limit wait in a queue whose depth
+// is reported through a gauge. Executions for the same transaction, such as a
+// chaincode invoking another chaincode, share a single slot so that nested
+// invocations cannot deadlock waiting on the slot held by their caller.
+type ExecuteLimiter struct {
+	// AlertThreshold is the queue depth above which Alert is called. A value
+	// <= 0 disables the alert.
+	AlertThreshold int
+	// Alert is called with the queue depth each time the depth rises above
+	// AlertThreshold.
+	Alert func(depth int)
+
+	limit      int
+	slots      chan struct{}
+	queueDepth metrics.Gauge
+
+	mutex   sync.Mutex
+	waiting int
+	holders map[string]int
+}
+
+// NewExecuteLimiter creates an ExecuteLimiter which admits at most limit
+// concurrent executions and reports the depth of the waiting queue to the
+// gauge.
+func NewExecuteLimiter(limit int, queueDepth metrics.Gauge) *ExecuteLimiter {
+	return &ExecuteLimiter{
+		limit:      limit,
+		slots:      make(chan struct{}, limit),
+		queueDepth: queueDepth,
+		holders:    map[string]int{},
+	}
+}
+
+// Limit returns the maximum number of concurrent executions.
+func (l *ExecuteLimiter) Limit() int {
+	return l.limit
+}
+
+// Acquire blocks until an execution slot is available for the transaction.
+// Every call to Acquire must be paired with a call to Release.
+func (l *ExecuteLimiter) Acquire(txID string) {
+	l.acquire(txID)
+}
+
+// acquire is Acquire, returning the number of transactions holding a slot
+// once the transaction was admitted. It returns zero for a transaction which
+// already held a slot.
+func (l *ExecuteLimiter) acquire(txID string) int {
+	l.mutex.Lock()
+	if l.holders[txID] > 0 {
+		l.holders[txID]++
+		l.mutex.Unlock()
+		return 0
+	}
+	l.mutex.Unlock()
+
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		l.enqueued()
+		l.slots <- struct{}{}
+		l.dequeued()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.holders[txID] > 0 {
+		// another execution of the transaction acquired a slot meanwhile
+		<-l.slots
+		l.holders[txID]++
+		return 0
+	}
+	l.holders[txID]++
+	return len(l.holders)
+}
+
+// tryAcquire is acquire without waiting. The bool is false, and no slot is
+// held, if no slot is available for the transaction.
+func (l *ExecuteLimiter) tryAcquire(txID string) (int, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.holders[txID] > 0 {
+		l.holders[txID]++
+		return 0, true
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		return 0, false
+	}
+	l.holders[txID]++
+	return len(l.holders), true
+}
+
+// Release returns the execution slot held for the transaction once all of
+// its executions have completed.
+func (l *ExecuteLimiter) Release(txID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.holders[txID]--
+	if l.holders[txID] > 0 {
+		return
+	}
+	delete(l.holders, txID)
+	<-l.slots
+}
+
+// Waiting returns the number of executions waiting for a slot.
+func (l *ExecuteLimiter) Waiting() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.waiting
+}
+
+func (l *ExecuteLimiter) enqueued() {
+	l.mutex.Lock()
+	l.waiting++
+	depth := l.waiting
+	l.queueDepth.Set(float64(depth))
+	l.mutex.Unlock()
+
+	if l.AlertThreshold > 0 && depth == l.AlertThreshold+1 && l.Alert != nil {
+		l.Alert(depth)
+	}
+}
+
+func (l *ExecuteLimiter) dequeued() {
+	l.mutex.Lock()
+	l.waiting--
+	l.queueDepth.Set(float64(l.waiting))
+	l.mutex.Unlock()
+}
@@ -0,0 +1,95 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode_test
+
+import (
+	"github.com/hyperledger/fabric-lib-go/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/core/chaincode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ExecuteLimiter", func() {
+	var (
+		fakeQueueDepth *metricsfakes.Gauge
+		limiter        *chaincode.ExecuteLimiter
+		admitted       chan string
+	)
+
+	// enqueue acquires a slot for txID in the background and waits until it
+	// is queued.
+	enqueue := func(txID string) {
+		queued := limiter.Waiting()
+		go func() {
+			limiter.Acquire(txID)
+			admitted <- txID
+		}()
+		Eventually(limiter.Waiting).Should(Equal(queued + 1))
+	}
+
+	BeforeEach(func() {
+		fakeQueueDepth = &metricsfakes.Gauge{}
+		limiter = chaincode.NewExecuteLimiter(1, fakeQueueDepth)
+		admitted = make(chan string, 10)
+	})
+
+	It("admits executions up to the limit without waiting", func() {
+		limiter.Acquire("tx-1")
+		Expect(limiter.Waiting()).To(Equal(0))
+		Expect(fakeQueueDepth.SetCallCount()).To(Equal(0))
+	})
+
+	It("admits nested executions of a transaction in the slot of their caller", func() {
+		limiter.Acquire("tx-1")
+		limiter.Acquire("tx-1")
+		Expect(limiter.Waiting()).To(Equal(0))
+
+		limiter.Release("tx-1")
+		enqueue("tx-2")
+		Consistently(admitted).ShouldNot(Receive())
+
+		limiter.Release("tx-1")
+		Eventually(admitted).Should(Receive(Equal("tx-2")))
+	})
+
+	It("tracks executions entering and leaving the queue", func() {
+		limiter.Acquire("tx-1")
+		enqueue("tx-2")
+		enqueue("tx-3")
+		Expect(fakeQueueDepth.SetCallCount()).To(Equal(2))
+		Expect(fakeQueueDepth.SetArgsForCall(0)).To(Equal(1.0))
+		Expect(fakeQueueDepth.SetArgsForCall(1)).To(Equal(2.0))
+
+		limiter.Release("tx-1")
+		Eventually(admitted).Should(Receive())
+		Eventually(fakeQueueDepth.SetCallCount).Should(Equal(3))
+		Expect(fakeQueueDepth.SetArgsForCall(2)).To(Equal(1.0))
+		Expect(limiter.Waiting()).To(Equal(1))
+	})
+
+	Context("when an alert threshold is set", func() {
+		var alerts chan int
+
+		BeforeEach(func() {
+			alerts = make(chan int, 10)
+			limiter.AlertThreshold = 1
+			limiter.Alert = func(depth int) { alerts <- depth }
+			limiter.Acquire("tx-1")
+		})
+
+		It("alerts when the queue depth rises above the threshold", func() {
+			enqueue("tx-2")
+			Consistently(alerts).ShouldNot(Receive())
+
+			enqueue("tx-3")
+			Eventually(alerts).Should(Receive(Equal(2)))
+
+			enqueue("tx-4")
+			Consistently(alerts).ShouldNot(Receive())
+		})
+	})
+})
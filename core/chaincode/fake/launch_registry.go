@@ -32,6 +32,23 @@ type LaunchRegistry struct {
 		result1 *chaincode.LaunchState
 		result2 bool
 	}
+	RunningStub        func(string) bool
+	runningMutex       sync.RWMutex
+	runningArgsForCall []struct {
+		arg1 string
+	}
+	runningReturns struct {
+		result1 bool
+	}
+	runningReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	SetImageDigestStub        func(string, string)
+	setImageDigestMutex       sync.RWMutex
+	setImageDigestArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -42,15 +59,16 @@ func (fake *LaunchRegistry) Deregister(arg1 string) error {
 	fake.deregisterArgsForCall = append(fake.deregisterArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.DeregisterStub
+	fakeReturns := fake.deregisterReturns
 	fake.recordInvocation("Deregister", []interface{}{arg1})
 	fake.deregisterMutex.Unlock()
-	if fake.DeregisterStub != nil {
-		return fake.DeregisterStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.deregisterReturns
 	return fakeReturns.result1
 }
 
@@ -102,15 +120,16 @@ func (fake *LaunchRegistry) Launching(arg1 string) (*chaincode.LaunchState, bool
 	fake.launchingArgsForCall = append(fake.launchingArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.LaunchingStub
+	fakeReturns := fake.launchingReturns
 	fake.recordInvocation("Launching", []interface{}{arg1})
 	fake.launchingMutex.Unlock()
-	if fake.LaunchingStub != nil {
-		return fake.LaunchingStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.launchingReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -159,6 +178,100 @@ func (fake *LaunchRegistry) LaunchingReturnsOnCall(i int, result1 *chaincode.Lau
 	}{result1, result2}
 }
 
+func (fake *LaunchRegistry) Running(arg1 string) bool {
+	fake.runningMutex.Lock()
+	ret, specificReturn := fake.runningReturnsOnCall[len(fake.runningArgsForCall)]
+	fake.runningArgsForCall = append(fake.runningArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.RunningStub
+	fakeReturns := fake.runningReturns
+	fake.recordInvocation("Running", []interface{}{arg1})
+	fake.runningMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *LaunchRegistry) RunningCallCount() int {
+	fake.runningMutex.RLock()
+	defer fake.runningMutex.RUnlock()
+	return len(fake.runningArgsForCall)
+}
+
+func (fake *LaunchRegistry) RunningCalls(stub func(string) bool) {
+	fake.runningMutex.Lock()
+	defer fake.runningMutex.Unlock()
+	fake.RunningStub = stub
+}
+
+func (fake *LaunchRegistry) RunningArgsForCall(i int) string {
+	fake.runningMutex.RLock()
+	defer fake.runningMutex.RUnlock()
+	argsForCall := fake.runningArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *LaunchRegistry) RunningReturns(result1 bool) {
+	fake.runningMutex.Lock()
+	defer fake.runningMutex.Unlock()
+	fake.RunningStub = nil
+	fake.runningReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *LaunchRegistry) RunningReturnsOnCall(i int, result1 bool) {
+	fake.runningMutex.Lock()
+	defer fake.runningMutex.Unlock()
+	fake.RunningStub = nil
+	if fake.runningReturnsOnCall == nil {
+		fake.runningReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.runningReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *LaunchRegistry) SetImageDigest(arg1 string, arg2 string) {
+	fake.setImageDigestMutex.Lock()
+	fake.setImageDigestArgsForCall = append(fake.setImageDigestArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	stub := fake.SetImageDigestStub
+	fake.recordInvocation("SetImageDigest", []interface{}{arg1, arg2})
+	fake.setImageDigestMutex.Unlock()
+	if stub != nil {
+		fake.SetImageDigestStub(arg1, arg2)
+	}
+}
+
+func (fake *LaunchRegistry) SetImageDigestCallCount() int {
+	fake.setImageDigestMutex.RLock()
+	defer fake.setImageDigestMutex.RUnlock()
+	return len(fake.setImageDigestArgsForCall)
+}
+
+func (fake *LaunchRegistry) SetImageDigestCalls(stub func(string, string)) {
+	fake.setImageDigestMutex.Lock()
+	defer fake.setImageDigestMutex.Unlock()
+	fake.SetImageDigestStub = stub
+}
+
+func (fake *LaunchRegistry) SetImageDigestArgsForCall(i int) (string, string) {
+	fake.setImageDigestMutex.RLock()
+	defer fake.setImageDigestMutex.RUnlock()
+	argsForCall := fake.setImageDigestArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *LaunchRegistry) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -166,6 +279,10 @@ func (fake *LaunchRegistry) Invocations() map[string][][]interface{} {
 	defer fake.deregisterMutex.RUnlock()
 	fake.launchingMutex.RLock()
 	defer fake.launchingMutex.RUnlock()
+	fake.runningMutex.RLock()
+	defer fake.runningMutex.RUnlock()
+	fake.setImageDigestMutex.RLock()
+	defer fake.setImageDigestMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
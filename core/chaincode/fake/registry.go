@@ -19,6 +19,11 @@ type Registry struct {
 	deregisterReturnsOnCall map[int]struct {
 		result1 error
 	}
+	EstablishedStub        func(string)
+	establishedMutex       sync.RWMutex
+	establishedArgsForCall []struct {
+		arg1 string
+	}
 	FailedStub        func(string, error)
 	failedMutex       sync.RWMutex
 	failedArgsForCall []struct {
@@ -51,15 +56,16 @@ func (fake *Registry) Deregister(arg1 string) error {
 	fake.deregisterArgsForCall = append(fake.deregisterArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.DeregisterStub
+	fakeReturns := fake.deregisterReturns
 	fake.recordInvocation("Deregister", []interface{}{arg1})
 	fake.deregisterMutex.Unlock()
-	if fake.DeregisterStub != nil {
-		return fake.DeregisterStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.deregisterReturns
 	return fakeReturns.result1
 }
 
@@ -105,15 +111,48 @@ func (fake *Registry) DeregisterReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Registry) Established(arg1 string) {
+	fake.establishedMutex.Lock()
+	fake.establishedArgsForCall = append(fake.establishedArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.EstablishedStub
+	fake.recordInvocation("Established", []interface{}{arg1})
+	fake.establishedMutex.Unlock()
+	if stub != nil {
+		fake.EstablishedStub(arg1)
+	}
+}
+
+func (fake *Registry) EstablishedCallCount() int {
+	fake.establishedMutex.RLock()
+	defer fake.establishedMutex.RUnlock()
+	return len(fake.establishedArgsForCall)
+}
+
+func (fake *Registry) EstablishedCalls(stub func(string)) {
+	fake.establishedMutex.Lock()
+	defer fake.establishedMutex.Unlock()
+	fake.EstablishedStub = stub
+}
+
+func (fake *Registry) EstablishedArgsForCall(i int) string {
+	fake.establishedMutex.RLock()
+	defer fake.establishedMutex.RUnlock()
+	argsForCall := fake.establishedArgsForCall[i]
+	return argsForCall.arg1
+}
+
 func (fake *Registry) Failed(arg1 string, arg2 error) {
 	fake.failedMutex.Lock()
 	fake.failedArgsForCall = append(fake.failedArgsForCall, struct {
 		arg1 string
 		arg2 error
 	}{arg1, arg2})
+	stub := fake.FailedStub
 	fake.recordInvocation("Failed", []interface{}{arg1, arg2})
 	fake.failedMutex.Unlock()
-	if fake.FailedStub != nil {
+	if stub != nil {
 		fake.FailedStub(arg1, arg2)
 	}
 }
@@ -142,9 +181,10 @@ func (fake *Registry) Ready(arg1 string) {
 	fake.readyArgsForCall = append(fake.readyArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.ReadyStub
 	fake.recordInvocation("Ready", []interface{}{arg1})
 	fake.readyMutex.Unlock()
-	if fake.ReadyStub != nil {
+	if stub != nil {
 		fake.ReadyStub(arg1)
 	}
 }
@@ -174,15 +214,16 @@ func (fake *Registry) Register(arg1 *chaincode.Handler) error {
 	fake.registerArgsForCall = append(fake.registerArgsForCall, struct {
 		arg1 *chaincode.Handler
 	}{arg1})
+	stub := fake.RegisterStub
+	fakeReturns := fake.registerReturns
 	fake.recordInvocation("Register", []interface{}{arg1})
 	fake.registerMutex.Unlock()
-	if fake.RegisterStub != nil {
-		return fake.RegisterStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.registerReturns
 	return fakeReturns.result1
 }
 
@@ -233,6 +274,8 @@ func (fake *Registry) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.deregisterMutex.RLock()
 	defer fake.deregisterMutex.RUnlock()
+	fake.establishedMutex.RLock()
+	defer fake.establishedMutex.RUnlock()
 	fake.failedMutex.RLock()
 	defer fake.failedMutex.RUnlock()
 	fake.readyMutex.RLock()
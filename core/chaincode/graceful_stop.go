@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ShutdownMessageType is the type of the message which asks a chaincode to
+// prepare to stop. It is not defined by fabric-protos-go. A shim which
+// supports graceful stops replies with a message of the same type once it has
+// flushed its state; chaincodes with other shims are stopped when the
+// GracefulStopTimeout expires.
+const ShutdownMessageType pb.ChaincodeMessage_Type = 100
+
+// Stop stops the chaincode with the given ID. No executions are started on
+// the chaincode once Stop is called, and those in progress are given up to the
+// StopGracePeriod to complete before they are cancelled, so that none outlives
+// the chaincode. When GracefulStopTimeout is set, the chaincode is then asked
+// to prepare to stop and given up to that long to acknowledge. It is stopped
+// whether or not it acknowledges. The handler is marked as stopping before the
+// chaincode is asked, so a chaincode which closes its stream in response is
+// not restarted. If the chaincode cannot be stopped, it accepts executions
+// again.
+func (cs *ChaincodeSupport) Stop(ccid string) error {
+	h := cs.HandlerRegistry.Handler(ccid)
+	if h == nil {
+		return cs.Launcher.Stop(ccid)
+	}
+
+	cs.drainExecutions(ccid, h)
+	if cs.GracefulStopTimeout > 0 {
+		if !h.requestShutdown(cs.GracefulStopTimeout) {
+			chaincodeLogger.Warningf("chaincode %s did not acknowledge shutdown within %s, stopping it", ccid, cs.GracefulStopTimeout)
+		}
+	}
+	if err := cs.Launcher.Stop(ccid); err != nil {
+		h.resumeExecutions()
+		return err
+	}
+	return nil
+}
+
+// drainExecutions stops the handler starting executions and waits up to the
+// StopGracePeriod for those in progress to complete. Any still in progress are
+// then cancelled, and drainExecutions returns once they have all returned.
+func (cs *ChaincodeSupport) drainExecutions(ccid string, h *Handler) {
+	drained := h.stopExecutions()
+	if cs.StopGracePeriod > 0 {
+		timer := time.NewTimer(cs.StopGracePeriod)
+		defer timer.Stop()
+		select {
+		case <-drained:
+			return
+		case <-timer.C:
+		}
+	}
+
+	select {
+	case <-drained:
+		return
+	default:
+	}
+	chaincodeLogger.Warningf("cancelling %d executions in progress on chaincode %s which is stopping", h.inFlight(), ccid)
+	h.cancelExecutions()
+	<-drained
+}
+
+// requestShutdown asks the chaincode to prepare to stop and waits up to
+// timeout for it to acknowledge or close its stream. It returns false if the
+// chaincode did neither.
+func (h *Handler) requestShutdown(timeout time.Duration) bool {
+	h.mutex.Lock()
+	if h.shutdownAck == nil {
+		h.shutdownAck = make(chan struct{})
+	}
+	ack := h.shutdownAck
+	h.mutex.Unlock()
+
+	if err := h.serialSend(&pb.ChaincodeMessage{Type: ShutdownMessageType}); err != nil {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ack:
+		return true
+	case <-h.streamDone():
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// ackShutdown handles the chaincode's acknowledgement of a shutdown request.
+// Unsolicited acknowledgements are ignored.
+func (h *Handler) ackShutdown() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.shutdownAck == nil {
+		return
+	}
+	select {
+	case <-h.shutdownAck:
+	default:
+		close(h.shutdownAck)
+	}
+}
+
+// stopExecutions stops the handler starting executions. The returned channel
+// is closed once no executions are in progress.
+func (h *Handler) stopExecutions() <-chan struct{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.stopping {
+		h.stopping = true
+		h.drainedChan = make(chan struct{})
+		if h.executions == 0 {
+			close(h.drainedChan)
+		}
+	}
+	return h.drainedChan
+}
+
+// isStopping reports whether the chaincode served by the handler is being
+// stopped by the peer.
+func (h *Handler) isStopping() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.stopping
+}
+
+// cancelExecutions fails the executions in progress on the handler.
+func (h *Handler) cancelExecutions() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.cancelledChan == nil {
+		h.cancelledChan = make(chan struct{})
+	}
+	select {
+	case <-h.cancelledChan:
+	default:
+		close(h.cancelledChan)
+	}
+}
+
+// resumeExecutions lets the handler start executions again after a failed
+// stop.
+func (h *Handler) resumeExecutions() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.stopping = false
+	h.drainedChan = nil
+	h.cancelledChan = nil
+}
+
+func (h *Handler) cancelledDone() <-chan struct{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.cancelledChan == nil {
+		h.cancelledChan = make(chan struct{})
+	}
+	return h.cancelledChan
+}
@@ -9,6 +9,7 @@ package chaincode
 import (
 	"fmt"
 	"io"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,6 +41,7 @@ type ACLProvider interface {
 // A Registry is responsible for tracking handlers.
 type Registry interface {
 	Register(*Handler) error
+	Established(string)
 	Ready(string)
 	Failed(string, error)
 	Deregister(string) error
@@ -92,17 +94,68 @@ type ApplicationConfigRetriever interface {
 }
 
 const (
-	ErrorExecutionTimeout = "timeout expired while executing transaction"
-	ErrorStreamTerminated = "chaincode stream terminated"
+	ErrorExecutionTimeout   = "timeout expired while executing transaction"
+	ErrorSendTimeout        = "timeout expired while sending transaction to chaincode"
+	ErrorStreamTerminated   = "chaincode stream terminated"
+	ErrorHandlerReplaced    = "chaincode handler replaced by a new registration"
+	ErrorHandlerStopping    = "chaincode is stopping"
+	ErrorExecutionCancelled = "execution cancelled because the chaincode is stopping"
 )
 
 // Handler implements the peer side of the chaincode stream.
 type Handler struct {
 	// Keepalive specifies the interval at which keep-alive messages are sent.
 	Keepalive time.Duration
+	// KeepaliveRetries specifies the number of times a failed keep-alive send
+	// is retried before the keep-alive is counted as missed.
+	KeepaliveRetries int
+	// KeepaliveRetryBackoff specifies the delay before the first keep-alive
+	// retry. The delay doubles with each subsequent retry.
+	KeepaliveRetryBackoff time.Duration
+	// KeepaliveMissThreshold specifies the number of consecutive missed
+	// keep-alives that are tolerated before the stream is terminated.
+	KeepaliveMissThreshold int
+	// KeepaliveDisabled holds the IDs of chaincodes which manage their own
+	// liveness and are not sent keep-alive messages.
+	KeepaliveDisabled map[string]bool
+	// KeepaliveIdleOnly suppresses keep-alive messages while executions are
+	// in progress, as the traffic of the executions already shows the stream
+	// is alive. Keep-alives resume once the chaincode is idle.
+	KeepaliveIdleOnly bool
+	// ContextKeys lists the keys of the request-scoped values which are
+	// copied from the context of an invocation into its transaction context.
+	ContextKeys []ContextKey
+	// AllowedResponseTypes holds the additional message types, such as those
+	// introduced by newer shims, which are accepted in the ready state as the
+	// response to an execution.
+	AllowedResponseTypes map[pb.ChaincodeMessage_Type]bool
+	// OnPanic, if set, is called with the ID of the chaincode once a panic
+	// while handling one of its requests has been recovered.
+	OnPanic func(ccid string)
 	// TotalQueryLimit specifies the maximum number of results to return for
 	// chaincode queries.
 	TotalQueryLimit int
+	// SendTimeout bounds sending an execute message onto the chaincode
+	// stream. The execute timeout then bounds only awaiting the response.
+	// When zero, the execute timeout covers both. A message still waiting to
+	// be sent when the execution fails is never sent, but one which is
+	// already being written to the stream cannot be withdrawn and may reach
+	// the chaincode after the execution has failed; the requests the
+	// chaincode then makes for the transaction fail, as its context is gone.
+	SendTimeout time.Duration
+	// SendBufferSize, when positive, is the number of messages which may be
+	// queued for a single sender to write to the chaincode stream in order.
+	// Messages sent while the queue is full are each sent by a goroutine of
+	// their own, as they are when SendBufferSize is zero.
+	SendBufferSize int
+	// ReceiveBufferSize, when positive, is the number of messages read ahead
+	// from the chaincode stream while a message is handled. When zero, the
+	// next message is read once the previous one has been handled.
+	ReceiveBufferSize int
+	// MessageTypes maps the types of the execute messages sent to the
+	// chaincode onto the types used by its shim. Types without a mapping are
+	// sent unchanged.
+	MessageTypes map[pb.ChaincodeMessage_Type]pb.ChaincodeMessage_Type
 	// Invoker is used to invoke chaincode.
 	Invoker Invoker
 	// Registry is used to track active handlers.
@@ -136,6 +189,9 @@ type Handler struct {
 	state State
 	// chaincodeID holds the ID of the chaincode that registered with the peer.
 	chaincodeID string
+	// remoteAddress holds the network address of the chaincode end of the
+	// stream, when known.
+	remoteAddress string
 
 	// serialLock is used to serialize sends across the grpc chat stream.
 	serialLock sync.Mutex
@@ -144,10 +200,38 @@ type Handler struct {
 	chatStream ccintf.ChaincodeStream
 	// errChan is used to communicate errors from the async send to the receive loop
 	errChan chan error
-	// mutex is used to serialze the stream closed chan.
+	// sendQueue holds the messages awaiting the sender when SendBufferSize is
+	// positive.
+	sendQueue chan *pendingSend
+	// mutex is used to serialze the stream closed chan, the replaced chan, the
+	// keep-alive miss count, and the activity of the handler.
 	mutex sync.Mutex
 	// streamDoneChan is closed when the chaincode stream terminates.
 	streamDoneChan chan struct{}
+	// replacedChan is closed when a new registration of the chaincode
+	// replaces this handler.
+	replacedChan chan struct{}
+	// keepaliveMisses counts consecutive keep-alives that could not be sent.
+	keepaliveMisses int
+	// executions counts the executions in progress on the handler.
+	executions int
+	// lastActivity holds when the handler registered or last completed an
+	// execution.
+	lastActivity time.Time
+	// registeredAt holds when the chaincode registered with the handler.
+	registeredAt time.Time
+	// shutdownAck is closed when the chaincode acknowledges a request to
+	// prepare to stop.
+	shutdownAck chan struct{}
+	// stopping is set while the chaincode is being stopped. No executions are
+	// started on the handler while it is set.
+	stopping bool
+	// drainedChan is closed once the handler is stopping and no executions
+	// are in progress.
+	drainedChan chan struct{}
+	// cancelledChan is closed to cancel the executions in progress on a
+	// stopping handler.
+	cancelledChan chan struct{}
 }
 
 // handleMessage is called by ProcessStream to dispatch messages.
@@ -157,6 +241,10 @@ func (h *Handler) handleMessage(msg *pb.ChaincodeMessage) error {
 	if msg.Type == pb.ChaincodeMessage_KEEPALIVE {
 		return nil
 	}
+	if msg.Type == ShutdownMessageType {
+		h.ackShutdown()
+		return nil
+	}
 
 	switch h.state {
 	case Created:
@@ -210,6 +298,10 @@ func (h *Handler) handleMessageReadyState(msg *pb.ChaincodeMessage) error {
 	case pb.ChaincodeMessage_PURGE_PRIVATE_DATA:
 		go h.HandleTransaction(msg, h.HandlePurgePrivateData)
 	default:
+		if h.AllowedResponseTypes[msg.Type] {
+			h.Notify(msg)
+			return nil
+		}
 		return fmt.Errorf("[%s] Fabric side handler cannot handle message (%s) while in ready state", msg.Txid, msg.Type)
 	}
 
@@ -233,14 +325,6 @@ func (h *Handler) HandleTransaction(msg *pb.ChaincodeMessage, delegate handleFun
 	}
 
 	startTime := time.Now()
-	var txContext *TransactionContext
-	var err error
-	if msg.Type == pb.ChaincodeMessage_INVOKE_CHAINCODE {
-		txContext, err = h.getTxContextForInvoke(msg.ChannelId, msg.Txid, msg.Payload, "")
-	} else {
-		txContext, err = h.isValidTxSim(msg.ChannelId, msg.Txid, "no ledger context")
-	}
-
 	meterLabels := []string{
 		"type", msg.Type.String(),
 		"channel", msg.ChannelId,
@@ -248,11 +332,7 @@ func (h *Handler) HandleTransaction(msg *pb.ChaincodeMessage, delegate handleFun
 	}
 	h.Metrics.ShimRequestsReceived.With(meterLabels...).Add(1)
 
-	var resp *pb.ChaincodeMessage
-	if err == nil {
-		resp, err = delegate(msg, txContext)
-	}
-
+	resp, err := h.handleRequest(msg, delegate)
 	if err != nil {
 		err = errors.Wrapf(err, "%s failed: transaction ID: %s", msg.Type, msg.Txid)
 		chaincodeLogger.Errorf("[%s] Failed to handle %s. error: %+v", shorttxid(msg.Txid), msg.Type, err)
@@ -268,6 +348,34 @@ func (h *Handler) HandleTransaction(msg *pb.ChaincodeMessage, delegate handleFun
 	h.Metrics.ShimRequestsCompleted.With(meterLabels...).Add(1)
 }
 
+// handleRequest looks up the transaction context of a request from the
+// chaincode and hands the request to the delegate. As requests are handled on
+// their own goroutines, a panic is recovered here and converted into a
+// ChaincodePanicError which fails only the request's transaction.
+func (h *Handler) handleRequest(msg *pb.ChaincodeMessage, delegate handleFunc) (resp *pb.ChaincodeMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			perr := &ChaincodePanicError{Chaincode: h.chaincodeID, Value: r, Stack: debug.Stack()}
+			chaincodeLogger.Errorf("[%s] recovered from %s while handling %s\n%s", shorttxid(msg.Txid), perr, msg.Type, perr.Stack)
+			if h.OnPanic != nil {
+				h.OnPanic(h.chaincodeID)
+			}
+			resp, err = nil, perr
+		}
+	}()
+
+	var txContext *TransactionContext
+	if msg.Type == pb.ChaincodeMessage_INVOKE_CHAINCODE {
+		txContext, err = h.getTxContextForInvoke(msg.ChannelId, msg.Txid, msg.Payload, "")
+	} else {
+		txContext, err = h.isValidTxSim(msg.ChannelId, msg.Txid, "no ledger context")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return delegate(msg, txContext)
+}
+
 func shorttxid(txid string) string {
 	if len(txid) < 8 {
 		return txid
@@ -298,6 +406,24 @@ func (h *Handler) serialSend(msg *pb.ChaincodeMessage) error {
 	h.serialLock.Lock()
 	defer h.serialLock.Unlock()
 
+	return h.send(msg)
+}
+
+// serialSendPending sends the pending message unless it was abandoned before
+// its send started.
+func (h *Handler) serialSendPending(ps *pendingSend) error {
+	h.serialLock.Lock()
+	defer h.serialLock.Unlock()
+
+	if !ps.start() {
+		return nil
+	}
+	return h.send(ps.msg)
+}
+
+// send writes the message to the chaincode stream. The caller must hold the
+// serialLock.
+func (h *Handler) send(msg *pb.ChaincodeMessage) error {
 	if err := h.chatStream.Send(msg); err != nil {
 		err = errors.WithMessagef(err, "[%s] error sending %s", shorttxid(msg.Txid), msg.Type)
 		chaincodeLogger.Errorf("%+v", err)
@@ -311,23 +437,96 @@ func (h *Handler) serialSend(msg *pb.ChaincodeMessage) error {
 // be happy). In addition, it is also asynchronous so send-remoterecv--localrecv loop
 // can be nonblocking. Only errors need to be handled and these are handled by
 // communication on supplied error channel. A typical use will be a non-blocking or
-// nil channel
-func (h *Handler) serialSendAsync(msg *pb.ChaincodeMessage) {
-	go func() {
-		if err := h.serialSend(msg); err != nil {
-			// provide an error response to the caller
-			resp := &pb.ChaincodeMessage{
-				Type:      pb.ChaincodeMessage_ERROR,
-				Payload:   []byte(err.Error()),
-				Txid:      msg.Txid,
-				ChannelId: msg.ChannelId,
-			}
-			h.Notify(resp)
+// nil channel. The returned pendingSend completes once the send has completed.
+func (h *Handler) serialSendAsync(msg *pb.ChaincodeMessage) *pendingSend {
+	ps := &pendingSend{msg: msg, sent: make(chan struct{})}
 
-			// surface send error to stream processing
-			h.errChan <- err
+	h.mutex.Lock()
+	queue := h.sendQueue
+	h.mutex.Unlock()
+	if queue != nil {
+		select {
+		case queue <- ps:
+			return ps
+		default:
 		}
-	}()
+	}
+
+	go h.sendPending(ps)
+	return ps
+}
+
+// sendPending sends the pending message and completes it.
+func (h *Handler) sendPending(ps *pendingSend) {
+	defer close(ps.sent)
+	if err := h.serialSendPending(ps); err != nil {
+		h.sendFailed(ps.msg, err)
+	}
+}
+
+// pendingSend is a message awaiting its send. The sent channel is closed once
+// the message has been sent, or skipped because it was abandoned.
+type pendingSend struct {
+	msg  *pb.ChaincodeMessage
+	sent chan struct{}
+
+	mutex     sync.Mutex
+	started   bool
+	abandoned bool
+}
+
+// start marks the send as started, unless it has been abandoned, in which
+// case false is returned and the message must not be sent.
+func (ps *pendingSend) start() bool {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if ps.abandoned {
+		return false
+	}
+	ps.started = true
+	return true
+}
+
+// abandon prevents the message from being sent if its send has not started.
+// It returns false when the send has already started, in which case the
+// message may still be delivered.
+func (ps *pendingSend) abandon() bool {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if ps.started {
+		return false
+	}
+	ps.abandoned = true
+	return true
+}
+
+// processSends sends the queued messages in order until done is closed.
+func (h *Handler) processSends(queue <-chan *pendingSend, done <-chan struct{}) {
+	for {
+		select {
+		case ps := <-queue:
+			h.sendPending(ps)
+		case <-done:
+			return
+		}
+	}
+}
+
+// sendFailed provides an error response to the caller waiting on the message
+// and surfaces the send error to stream processing.
+func (h *Handler) sendFailed(msg *pb.ChaincodeMessage, err error) {
+	resp := &pb.ChaincodeMessage{
+		Type:      pb.ChaincodeMessage_ERROR,
+		Payload:   []byte(err.Error()),
+		Txid:      msg.Txid,
+		ChannelId: msg.ChannelId,
+	}
+	h.Notify(resp)
+
+	select {
+	case h.errChan <- err:
+	case <-h.streamDone():
+	}
 }
 
 // Check if the transactor is allow to call this chaincode on this channel
@@ -352,9 +551,50 @@ func (h *Handler) checkACL(signedProp *pb.SignedProposal, proposal *pb.Proposal,
 }
 
 func (h *Handler) deregister() {
+	// a replaced handler is no longer registered and must not deregister
+	// the handler that replaced it
+	if h.isReplaced() {
+		return
+	}
 	h.Registry.Deregister(h.chaincodeID)
 }
 
+func (h *Handler) replacedDone() <-chan struct{} {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.replacedChan == nil {
+		h.replacedChan = make(chan struct{})
+	}
+	return h.replacedChan
+}
+
+func (h *Handler) isReplaced() bool {
+	select {
+	case <-h.replacedDone():
+		return true
+	default:
+		return false
+	}
+}
+
+// replace marks the handler as replaced by a new registration of the
+// chaincode. Transactions in flight on the handler fail and its transaction
+// contexts are closed.
+func (h *Handler) replace() {
+	h.mutex.Lock()
+	if h.replacedChan == nil {
+		h.replacedChan = make(chan struct{})
+	}
+	select {
+	case <-h.replacedChan:
+	default:
+		close(h.replacedChan)
+	}
+	h.mutex.Unlock()
+
+	h.Close()
+}
+
 func (h *Handler) streamDone() <-chan struct{} {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
@@ -364,14 +604,26 @@ func (h *Handler) streamDone() <-chan struct{} {
 func (h *Handler) ProcessStream(stream ccintf.ChaincodeStream) error {
 	defer h.deregister()
 
+	done := make(chan struct{})
 	h.mutex.Lock()
-	h.streamDoneChan = make(chan struct{})
+	h.streamDoneChan = done
 	h.mutex.Unlock()
-	defer close(h.streamDoneChan)
+	defer close(done)
 
 	h.chatStream = stream
 	h.errChan = make(chan error, 1)
 
+	if h.SendBufferSize > 0 {
+		queue := make(chan *pendingSend, h.SendBufferSize)
+		h.mutex.Lock()
+		h.sendQueue = queue
+		h.mutex.Unlock()
+		go h.processSends(queue, done)
+	}
+
+	h.trackState(h.state, 1)
+	defer func() { h.trackState(h.state, -1) }()
+
 	var keepaliveCh <-chan time.Time
 	if h.Keepalive != 0 {
 		ticker := time.NewTicker(h.Keepalive)
@@ -384,14 +636,39 @@ func (h *Handler) ProcessStream(stream ccintf.ChaincodeStream) error {
 		msg *pb.ChaincodeMessage
 		err error
 	}
-	msgAvail := make(chan *recvMsg, 1)
+	readAhead := h.ReceiveBufferSize > 0
+	bufferSize := 1
+	if readAhead {
+		bufferSize = h.ReceiveBufferSize
+	}
+	msgAvail := make(chan *recvMsg, bufferSize)
 
 	receiveMessage := func() {
 		in, err := h.chatStream.Recv()
 		msgAvail <- &recvMsg{in, err}
 	}
 
-	go receiveMessage()
+	// when reading ahead, messages are received until the buffer is full
+	// rather than once each message has been handled
+	receiveMessages := func() {
+		for {
+			in, err := h.chatStream.Recv()
+			select {
+			case msgAvail <- &recvMsg{in, err}:
+			case <-done:
+				return
+			}
+			if err != nil || in == nil {
+				return
+			}
+		}
+	}
+
+	if readAhead {
+		go receiveMessages()
+	} else {
+		go receiveMessage()
+	}
 	for {
 		select {
 		case rmsg := <-msgAvail:
@@ -416,7 +693,9 @@ func (h *Handler) ProcessStream(stream ccintf.ChaincodeStream) error {
 					return err
 				}
 
-				go receiveMessage()
+				if !readAhead {
+					go receiveMessage()
+				}
 			}
 
 		case sendErr := <-h.errChan:
@@ -424,14 +703,58 @@ func (h *Handler) ProcessStream(stream ccintf.ChaincodeStream) error {
 			chaincodeLogger.Errorf("%s", err)
 			return err
 		case <-keepaliveCh:
-			// if no error message from serialSend, KEEPALIVE happy, and don't care about error
-			// (maybe it'll work later)
-			h.serialSendAsync(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_KEEPALIVE})
+			if h.KeepaliveDisabled[h.chaincodeID] {
+				continue
+			}
+			if h.KeepaliveIdleOnly && h.inFlight() > 0 {
+				continue
+			}
+			// transient send failures are retried with backoff; only keep-alives
+			// that could not be sent at all count towards the miss threshold
+			go h.sendKeepalive()
 			continue
 		}
 	}
 }
 
+// sendKeepalive sends a KEEPALIVE to the chaincode. A failed send is retried
+// up to KeepaliveRetries times with exponential backoff before the keep-alive
+// is counted as missed. Once more than KeepaliveMissThreshold consecutive
+// keep-alives have been missed, the error is surfaced to stream processing.
+func (h *Handler) sendKeepalive() {
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_KEEPALIVE}
+	backoff := h.KeepaliveRetryBackoff
+
+	err := h.serialSend(msg)
+	for retry := 0; err != nil && retry < h.KeepaliveRetries; retry++ {
+		select {
+		case <-time.After(backoff):
+		case <-h.streamDone():
+			return
+		}
+		backoff *= 2
+		err = h.serialSend(msg)
+	}
+
+	h.mutex.Lock()
+	if err == nil {
+		h.keepaliveMisses = 0
+	} else {
+		h.keepaliveMisses++
+	}
+	misses := h.keepaliveMisses
+	h.mutex.Unlock()
+
+	if err == nil || misses <= h.KeepaliveMissThreshold {
+		return
+	}
+
+	select {
+	case h.errChan <- errors.WithMessagef(err, "missed %d consecutive keepalives", misses):
+	case <-h.streamDone():
+	}
+}
+
 // sendReady sends READY to chaincode serially (just like REGISTER)
 func (h *Handler) sendReady() error {
 	chaincodeLogger.Debugf("sending READY for chaincode %s", h.chaincodeID)
@@ -443,7 +766,7 @@ func (h *Handler) sendReady() error {
 		return err
 	}
 
-	h.state = Ready
+	h.setState(Ready)
 
 	chaincodeLogger.Debugf("Changed to state ready for chaincode %s", h.chaincodeID)
 
@@ -490,6 +813,7 @@ func (h *Handler) HandleRegister(msg *pb.ChaincodeMessage) {
 		h.notifyRegistry(err)
 		return
 	}
+	h.recordRegistration()
 
 	chaincodeLogger.Debugf("Got %s for chaincodeID = %s, sending back %s", pb.ChaincodeMessage_REGISTER, h.chaincodeID, pb.ChaincodeMessage_REGISTERED)
 	if err := h.serialSend(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTERED}); err != nil {
@@ -498,7 +822,8 @@ func (h *Handler) HandleRegister(msg *pb.ChaincodeMessage) {
 		return
 	}
 
-	h.state = Established
+	h.setState(Established)
+	h.Registry.Established(h.chaincodeID)
 
 	chaincodeLogger.Debugf("Changed state to established for %s", h.chaincodeID)
 
@@ -595,6 +920,10 @@ func getReadWritePermission(chaincodeName, collection string, txContext *Transac
 		return rwPermission, nil
 	}
 
+	if txContext.CollectionStore == nil {
+		return nil, errors.Errorf("private data collection %s of chaincode %s is not available without a channel", collection, chaincodeName)
+	}
+
 	cc := privdata.CollectionCriteria{
 		Channel:    txContext.ChannelID,
 		Namespace:  chaincodeName,
@@ -1187,6 +1516,9 @@ func (h *Handler) HandleInvokeChaincode(msg *pb.ChaincodeMessage, txContext *Tra
 
 		txParams.TXSimulator = sim
 		txParams.HistoryQueryExecutor = hqe
+		// the results on the other channel are discarded, so the
+		// invocation is a query
+		txParams.IsIdempotent = true
 	}
 
 	// Execute the chaincode... this CANNOT be an init at least for now
@@ -1209,7 +1541,26 @@ func (h *Handler) Execute(txParams *ccprovider.TransactionParams, namespace stri
 	chaincodeLogger.Debugf("Entry")
 	defer chaincodeLogger.Debugf("Exit")
 
-	txParams.CollectionStore = h.getCollectionStore(msg.ChannelId)
+	h.mutex.Lock()
+	if h.stopping {
+		h.mutex.Unlock()
+		return nil, errors.New(ErrorHandlerStopping)
+	}
+	h.executions++
+	h.mutex.Unlock()
+	defer func() {
+		h.mutex.Lock()
+		h.executions--
+		if h.stopping && h.executions == 0 {
+			close(h.drainedChan)
+		}
+		h.mutex.Unlock()
+		h.recordActivity()
+	}()
+
+	if msg.ChannelId != "" {
+		txParams.CollectionStore = h.getCollectionStore(msg.ChannelId)
+	}
 	txParams.IsInitTransaction = msg.Type == pb.ChaincodeMessage_INIT
 	txParams.NamespaceID = namespace
 
@@ -1218,12 +1569,36 @@ func (h *Handler) Execute(txParams *ccprovider.TransactionParams, namespace stri
 		return nil, err
 	}
 	defer h.TXContexts.Delete(msg.ChannelId, msg.Txid)
+	txctx.ContextValues = contextValues(txParams.Context, h.ContextKeys)
 
 	if err := h.setChaincodeProposal(txParams.SignedProp, txParams.Proposal, msg); err != nil {
 		return nil, err
 	}
 
-	h.serialSendAsync(msg)
+	// the transaction context is deleted when the execution fails, so a
+	// message which has not been sent by then is abandoned rather than
+	// delivered late
+	ps := h.serialSendAsync(h.shimMessage(msg))
+	if h.SendTimeout > 0 {
+		select {
+		case <-ps.sent:
+		case <-time.After(h.SendTimeout):
+			err = errors.New(ErrorSendTimeout)
+			if !ps.abandon() {
+				chaincodeLogger.Warningf("[%s] send to chaincode %s timed out while being written to the stream; the chaincode may still receive it", shorttxid(msg.Txid), h.chaincodeID)
+			}
+		case <-h.streamDone():
+			err = errors.New(ErrorStreamTerminated)
+		case <-h.replacedDone():
+			err = errors.New(ErrorHandlerReplaced)
+		case <-h.cancelledDone():
+			err = errors.New(ErrorExecutionCancelled)
+		}
+		if err != nil {
+			ps.abandon()
+			return nil, err
+		}
+	}
 
 	var ccresp *pb.ChaincodeMessage
 	select {
@@ -1232,14 +1607,33 @@ func (h *Handler) Execute(txParams *ccprovider.TransactionParams, namespace stri
 		// are typically treated as error
 	case <-time.After(timeout):
 		err = errors.New(ErrorExecutionTimeout)
-		h.Metrics.ExecuteTimeouts.With("chaincode", h.chaincodeID).Add(1)
+		h.Metrics.ExecuteTimeouts.With(metricLabels(h.Metrics.Labeler, msg.ChannelId, h.chaincodeID, "chaincode", h.chaincodeID)...).Add(1)
 	case <-h.streamDone():
 		err = errors.New(ErrorStreamTerminated)
+	case <-h.replacedDone():
+		err = errors.New(ErrorHandlerReplaced)
+	case <-h.cancelledDone():
+		err = errors.New(ErrorExecutionCancelled)
+	}
+	if err != nil {
+		ps.abandon()
 	}
 
 	return ccresp, err
 }
 
+// shimMessage returns the message as it is sent to the shim, with its type
+// mapped by MessageTypes.
+func (h *Handler) shimMessage(msg *pb.ChaincodeMessage) *pb.ChaincodeMessage {
+	t, ok := h.MessageTypes[msg.Type]
+	if !ok {
+		return msg
+	}
+	mapped := proto.Clone(msg).(*pb.ChaincodeMessage)
+	mapped.Type = t
+	return mapped
+}
+
 func (h *Handler) setChaincodeProposal(signedProp *pb.SignedProposal, prop *pb.Proposal, msg *pb.ChaincodeMessage) error {
 	if prop != nil && signedProp == nil {
 		return errors.New("failed getting proposal context. Signed proposal is nil")
@@ -1260,9 +1654,66 @@ func (h *Handler) getCollectionStore(channelID string) privdata.CollectionStore
 	)
 }
 
+// recordActivity records that the handler is in use.
+func (h *Handler) recordActivity() {
+	h.mutex.Lock()
+	h.lastActivity = time.Now()
+	h.mutex.Unlock()
+}
+
+// recordRegistration records that the chaincode has registered.
+func (h *Handler) recordRegistration() {
+	h.mutex.Lock()
+	h.registeredAt = time.Now()
+	h.lastActivity = h.registeredAt
+	h.mutex.Unlock()
+}
+
+// registeredSince returns when the chaincode registered with the handler.
+func (h *Handler) registeredSince() time.Time {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.registeredAt
+}
+
+// inFlight returns the number of executions in progress on the handler.
+func (h *Handler) inFlight() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.executions
+}
+
+// idleSince returns when the handler registered or last completed an
+// execution. The bool is false while an execution is in progress.
+func (h *Handler) idleSince() (time.Time, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.lastActivity, h.executions == 0
+}
+
+// setState transitions the handler to the state, moving it between the
+// handler state gauges.
+func (h *Handler) setState(s State) {
+	h.trackState(h.state, -1)
+	h.state = s
+	h.trackState(s, 1)
+}
+
+// trackState adds delta to the handler state gauge of the state.
+func (h *Handler) trackState(s State, delta float64) {
+	if h.Metrics == nil || h.Metrics.HandlerStates == nil {
+		return
+	}
+	h.Metrics.HandlerStates.With("state", s.String()).Add(delta)
+}
+
 func (h *Handler) State() State { return h.state }
 func (h *Handler) Close()       { h.TXContexts.Close() }
 
+// RemoteAddress returns the network address from which the chaincode
+// connected to the peer. It is empty if the address is not known.
+func (h *Handler) RemoteAddress() string { return h.remoteAddress }
+
 type State int
 
 const (
@@ -7,6 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/container/ccintf"
 )
 
@@ -27,3 +30,49 @@ func StreamDone(h *Handler) <-chan struct{} {
 func SetStreamDoneChan(h *Handler, ch chan struct{}) {
 	h.streamDoneChan = ch
 }
+
+func SetHandlerRegisteredAt(h *Handler, t time.Time) {
+	h.registeredAt = t
+}
+
+func KeepaliveMisses(h *Handler) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.keepaliveMisses
+}
+
+func SetHandlerState(h *Handler, state State) {
+	h.state = state
+}
+
+func HandleMessage(h *Handler, msg *pb.ChaincodeMessage) error {
+	return h.handleMessage(msg)
+}
+
+func SendKeepalive(h *Handler) {
+	h.sendKeepalive()
+}
+
+func LaunchFailureBackoff(cs *ChaincodeSupport, ccid string) time.Duration {
+	return cs.launchFailures.backoff(ccid)
+}
+
+func LaunchDelay(r *RuntimeLauncher) time.Duration {
+	return r.launchDelay()
+}
+
+func LaunchesInProgress(cs *ChaincodeSupport, ccid string) int {
+	cs.launching.mutex.Lock()
+	defer cs.launching.mutex.Unlock()
+	return cs.launching.counts[ccid]
+}
+
+func InitResult(resp *pb.ChaincodeMessage, err error) error {
+	return initResult(resp, err)
+}
+
+func InitOrderLocks(cs *ChaincodeSupport) int {
+	cs.initOrder.mutex.Lock()
+	defer cs.initOrder.mutex.Unlock()
+	return len(cs.initOrder.locks)
+}
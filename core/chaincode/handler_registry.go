@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/hyperledger/fabric/core/ledger"
@@ -17,21 +18,39 @@ import (
 type HandlerRegistry struct {
 	allowUnsolicitedRegistration bool // from cs.userRunsCC
 
-	mutex     sync.Mutex              // lock covering handlers and launching
-	handlers  map[string]*Handler     // chaincode cname to associated handler
-	launching map[string]*LaunchState // launching chaincodes to LaunchState
+	// ReplaceStaleHandlers allows a new registration of a chaincode to
+	// replace the handler that is already registered for it, rather than
+	// being rejected as a duplicate.
+	ReplaceStaleHandlers bool
+
+	mutex        sync.Mutex               // lock covering handlers, launching, ready, and imageDigests
+	handlers     map[string]*Handler      // chaincode cname to associated handler
+	launching    map[string]*LaunchState  // launching chaincodes to LaunchState
+	ready        map[string]chan struct{} // chaincodes to channel closed when ready
+	imageDigests map[string]string        // launched chaincodes to digest of their image
 }
 
 type LaunchState struct {
-	mutex    sync.Mutex
-	notified bool
-	done     chan struct{}
-	err      error
+	mutex       sync.Mutex
+	notified    bool
+	held        bool
+	established chan struct{}
+	registered  chan struct{}
+	done        chan struct{}
+	progress    chan struct{}
+	err         error
+
+	// onRelease, if set, is called when a launch held by HoldReady completes
+	// successfully.
+	onRelease func()
 }
 
 func NewLaunchState() *LaunchState {
 	return &LaunchState{
-		done: make(chan struct{}),
+		established: make(chan struct{}),
+		registered:  make(chan struct{}),
+		done:        make(chan struct{}),
+		progress:    make(chan struct{}, 1),
 	}
 }
 
@@ -46,14 +65,88 @@ func (l *LaunchState) Err() error {
 	return err
 }
 
+// Progress returns a channel that receives a value whenever the launch
+// advances towards completion.
+func (l *LaunchState) Progress() <-chan struct{} {
+	return l.progress
+}
+
+// NotifyProgress records that the launch has advanced towards completion.
+func (l *LaunchState) NotifyProgress() {
+	select {
+	case l.progress <- struct{}{}:
+	default:
+	}
+}
+
+// Established returns a channel which is closed when the handler of the
+// chaincode completes the registration handshake.
+func (l *LaunchState) Established() <-chan struct{} {
+	return l.established
+}
+
+// NotifyEstablished records that the handler of the chaincode has completed
+// the registration handshake.
+func (l *LaunchState) NotifyEstablished() {
+	l.mutex.Lock()
+	if !isClosed(l.established) {
+		close(l.established)
+	}
+	l.mutex.Unlock()
+}
+
+// HoldReady defers the completion of the launch when the chaincode becomes
+// ready, so that the launcher may check the chaincode before any caller is
+// told it has launched. The launcher learns that the chaincode is ready from
+// Registered and completes the launch with Notify; until the launch completes
+// successfully, the chaincode is not reported as ready by the HandlerRegistry.
+func (l *LaunchState) HoldReady() {
+	l.mutex.Lock()
+	l.held = true
+	l.mutex.Unlock()
+}
+
+// Registered returns a channel which is closed when the chaincode of a launch
+// held by HoldReady becomes ready.
+func (l *LaunchState) Registered() <-chan struct{} {
+	return l.registered
+}
+
+// Ready records that the chaincode has become ready. The launch completes
+// successfully unless it is held by HoldReady, in which case false is
+// returned.
+func (l *LaunchState) Ready() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held {
+		if !isClosed(l.registered) {
+			close(l.registered)
+		}
+		return false
+	}
+	if !l.notified {
+		l.notified = true
+		close(l.done)
+	}
+	return true
+}
+
 func (l *LaunchState) Notify(err error) {
 	l.mutex.Lock()
+	released := false
 	if !l.notified {
 		l.notified = true
 		l.err = err
 		close(l.done)
+		released = l.held && err == nil
 	}
+	onRelease := l.onRelease
 	l.mutex.Unlock()
+
+	if released && onRelease != nil {
+		onRelease()
+	}
 }
 
 // NewHandlerRegistry constructs a HandlerRegistry.
@@ -61,6 +154,8 @@ func NewHandlerRegistry(allowUnsolicitedRegistration bool) *HandlerRegistry {
 	return &HandlerRegistry{
 		handlers:                     map[string]*Handler{},
 		launching:                    map[string]*LaunchState{},
+		ready:                        map[string]chan struct{}{},
+		imageDigests:                 map[string]string{},
 		allowUnsolicitedRegistration: allowUnsolicitedRegistration,
 	}
 }
@@ -87,19 +182,87 @@ func (r *HandlerRegistry) Launching(ccid string) (*LaunchState, bool) {
 
 	// first attempt to launch so the runtime needs to start
 	launchState := NewLaunchState()
+	launchState.onRelease = func() { r.released(ccid, launchState) }
 	r.launching[ccid] = launchState
 	return launchState, false
 }
 
+// Established indicates that the handler of the chaincode has sent the
+// REGISTERED response and moved to the established state.
+func (r *HandlerRegistry) Established(ccid string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if launchStatus := r.launching[ccid]; launchStatus != nil {
+		launchStatus.NotifyEstablished()
+	}
+}
+
 // Ready indicates that the chaincode registration has completed and the
-// READY response has been sent to the chaincode.
+// READY response has been sent to the chaincode. When the launch is held by
+// HoldReady, the chaincode is only ready once its launcher completes the
+// launch successfully.
 func (r *HandlerRegistry) Ready(ccid string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	launchStatus := r.launching[ccid]
-	if launchStatus != nil {
-		launchStatus.Notify(nil)
+	if launchStatus != nil && !launchStatus.Ready() {
+		return
+	}
+	r.closeReady(ccid)
+}
+
+// released marks the chaincode ready once its held launch has completed
+// successfully, provided the launch has not since been deregistered.
+func (r *HandlerRegistry) released(ccid string, launchState *LaunchState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.launching[ccid] != launchState || r.handlers[ccid] == nil {
+		return
+	}
+	r.closeReady(ccid)
+}
+
+// closeReady closes the ready channel of the chaincode. The caller must hold
+// the mutex.
+func (r *HandlerRegistry) closeReady(ccid string) {
+	readyCh, ok := r.ready[ccid]
+	if !ok {
+		readyCh = make(chan struct{})
+		r.ready[ccid] = readyCh
+	}
+	if !isClosed(readyCh) {
+		close(readyCh)
+	}
+}
+
+// ReadyCh returns a channel which is closed once the chaincode has completed
+// registration and, if its launcher checks it with a startup probe, once the
+// probe has passed. If the chaincode is already ready, the returned channel is
+// already closed. Deregistering a ready chaincode forgets its closed channel,
+// so that later callers wait for the chaincode to be ready again, while a
+// channel which was never closed, for example because the launch failed, is
+// kept and closed once a later launch of the chaincode becomes ready.
+func (r *HandlerRegistry) ReadyCh(ccid string) <-chan struct{} {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	readyCh, ok := r.ready[ccid]
+	if !ok {
+		readyCh = make(chan struct{})
+		r.ready[ccid] = readyCh
+	}
+	return readyCh
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -122,15 +285,73 @@ func (r *HandlerRegistry) Handler(ccid string) *Handler {
 	return h
 }
 
+// ReadyHandler retrieves the handler for a chaincode instance whose launch,
+// if any, has completed successfully. Unlike Handler, it does not return the handler of a
+// chaincode which has registered but is still being checked by its launcher.
+func (r *HandlerRegistry) ReadyHandler(ccid string) *Handler {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if launchState, ok := r.launching[ccid]; ok && (!isClosed(launchState.done) || launchState.Err() != nil) {
+		return nil
+	}
+	return r.handlers[ccid]
+}
+
+// Running indicates whether the chaincode is launching or has a registered
+// handler.
+func (r *HandlerRegistry) Running(ccid string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, launching := r.launching[ccid]
+	_, registered := r.handlers[ccid]
+	return launching || registered
+}
+
+// Registered returns the IDs of the chaincodes with a registered handler,
+// sorted.
+func (r *HandlerRegistry) Registered() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ccids := make([]string, 0, len(r.handlers))
+	for ccid := range r.handlers {
+		ccids = append(ccids, ccid)
+	}
+	sort.Strings(ccids)
+	return ccids
+}
+
+// SetImageDigest records the digest of the image a launched chaincode runs
+// from.
+func (r *HandlerRegistry) SetImageDigest(ccid, digest string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.imageDigests[ccid] = digest
+}
+
+// ImageDigest returns the digest of the image a launched chaincode runs from.
+// The bool indicates whether a digest has been recorded for the chaincode.
+func (r *HandlerRegistry) ImageDigest(ccid string) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	digest, ok := r.imageDigests[ccid]
+	return digest, ok
+}
+
 // Register adds a chaincode handler to the registry.
 // An error will be returned if a handler is already registered for the
-// chaincode. An error will also be returned if the chaincode has not already
-// been "launched", and unsolicited registration is not allowed.
+// chaincode, unless stale handlers may be replaced, in which case the
+// registered handler is torn down and replaced. An error will also be
+// returned if the chaincode has not already been "launched", and unsolicited
+// registration is not allowed.
 func (r *HandlerRegistry) Register(h *Handler) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if r.handlers[h.chaincodeID] != nil {
+	stale := r.handlers[h.chaincodeID]
+	if stale != nil && !r.ReplaceStaleHandlers {
 		chaincodeLogger.Debugf("duplicate registered handler(key:%s) return error", h.chaincodeID)
 		return errors.Errorf("duplicate chaincodeID: %s", h.chaincodeID)
 	}
@@ -141,7 +362,15 @@ func (r *HandlerRegistry) Register(h *Handler) error {
 		return errors.Errorf("peer will not accept external chaincode connection %s (except in dev mode)", h.chaincodeID)
 	}
 
+	if stale != nil {
+		chaincodeLogger.Warningf("replacing stale handler for chaincode %s", h.chaincodeID)
+		stale.replace()
+	}
+
 	r.handlers[h.chaincodeID] = h
+	if launchState := r.launching[h.chaincodeID]; launchState != nil {
+		launchState.NotifyProgress()
+	}
 
 	chaincodeLogger.Debugf("registered handler complete for chaincode %s", h.chaincodeID)
 	return nil
@@ -157,6 +386,10 @@ func (r *HandlerRegistry) Deregister(ccid string) error {
 	handler := r.handlers[ccid]
 	delete(r.handlers, ccid)
 	delete(r.launching, ccid)
+	delete(r.imageDigests, ccid)
+	if readyCh, ok := r.ready[ccid]; ok && isClosed(readyCh) {
+		delete(r.ready, ccid)
+	}
 	r.mutex.Unlock()
 
 	if handler == nil {
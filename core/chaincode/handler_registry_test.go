@@ -7,6 +7,10 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode_test
 
 import (
+	"io"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/chaincode/mock"
 	"github.com/hyperledger/fabric/core/common/ccprovider"
@@ -74,6 +78,15 @@ var _ = Describe("HandlerRegistry", func() {
 		})
 	})
 
+	Describe("Established", func() {
+		It("closes the established channel without completing the launch", func() {
+			launchState, _ := hr.Launching("chaincode-id")
+			hr.Established("chaincode-id")
+			Expect(launchState.Established()).To(BeClosed())
+			Expect(launchState.Done()).NotTo(BeClosed())
+		})
+	})
+
 	Describe("Ready", func() {
 		var launchState *chaincode.LaunchState
 
@@ -98,6 +111,143 @@ var _ = Describe("HandlerRegistry", func() {
 			Expect(exists).To(BeTrue())
 			Expect(ls).To(BeIdenticalTo(launchState))
 		})
+
+		Context("when the launch is held", func() {
+			BeforeEach(func() {
+				launchState.HoldReady()
+			})
+
+			It("signals the launcher instead of completing the launch", func() {
+				hr.Ready("chaincode-id")
+				Expect(launchState.Registered()).To(BeClosed())
+				Expect(launchState.Done()).NotTo(BeClosed())
+			})
+		})
+	})
+
+	Describe("ReadyCh", func() {
+		It("is closed when the chaincode becomes ready", func() {
+			readyCh := hr.ReadyCh("chaincode-id")
+			hr.Launching("chaincode-id")
+			Consistently(readyCh).ShouldNot(BeClosed())
+
+			hr.Failed("other-chaincode-id", errors.New("failed"))
+			Consistently(readyCh).ShouldNot(BeClosed())
+
+			hr.Ready("chaincode-id")
+			Expect(readyCh).To(BeClosed())
+		})
+
+		It("is already closed when the chaincode is ready", func() {
+			hr.Launching("chaincode-id")
+			hr.Ready("chaincode-id")
+			Expect(hr.ReadyCh("chaincode-id")).To(BeClosed())
+		})
+
+		It("returns the same channel to every waiter", func() {
+			Expect(hr.ReadyCh("chaincode-id")).To(BeIdenticalTo(hr.ReadyCh("chaincode-id")))
+		})
+
+		Context("when a ready chaincode is deregistered", func() {
+			BeforeEach(func() {
+				handler.TXContexts = chaincode.NewTransactionContexts()
+				hr.Launching("chaincode-id")
+				Expect(hr.Register(handler)).To(Succeed())
+				hr.Ready("chaincode-id")
+				Expect(hr.Deregister("chaincode-id")).To(Succeed())
+			})
+
+			It("waits for the chaincode to be ready again", func() {
+				readyCh := hr.ReadyCh("chaincode-id")
+				Consistently(readyCh).ShouldNot(BeClosed())
+
+				hr.Launching("chaincode-id")
+				hr.Ready("chaincode-id")
+				Expect(readyCh).To(BeClosed())
+			})
+		})
+
+		Context("when the launch is held", func() {
+			var (
+				launchState *chaincode.LaunchState
+				readyCh     <-chan struct{}
+			)
+
+			BeforeEach(func() {
+				launchState, _ = hr.Launching("chaincode-id")
+				launchState.HoldReady()
+				Expect(hr.Register(handler)).To(Succeed())
+				readyCh = hr.ReadyCh("chaincode-id")
+				hr.Ready("chaincode-id")
+			})
+
+			It("is not closed until the launcher completes the launch", func() {
+				Expect(readyCh).NotTo(BeClosed())
+
+				launchState.Notify(nil)
+				Expect(readyCh).To(BeClosed())
+			})
+
+			It("is not closed when the launch fails", func() {
+				launchState.Notify(errors.New("probe failed"))
+				Expect(readyCh).NotTo(BeClosed())
+			})
+		})
+
+		Context("when a launch fails before the chaincode is ready", func() {
+			It("keeps waiting for a later launch", func() {
+				readyCh := hr.ReadyCh("chaincode-id")
+				hr.Launching("chaincode-id")
+				hr.Failed("chaincode-id", errors.New("failed"))
+				hr.Deregister("chaincode-id")
+				Expect(readyCh).NotTo(BeClosed())
+
+				laterCh := hr.ReadyCh("chaincode-id")
+				Expect(laterCh).To(BeIdenticalTo(readyCh))
+				hr.Launching("chaincode-id")
+				hr.Ready("chaincode-id")
+				Expect(readyCh).To(BeClosed())
+			})
+		})
+	})
+
+	Describe("ImageDigest", func() {
+		It("returns the recorded image digest", func() {
+			_, ok := hr.ImageDigest("chaincode-id")
+			Expect(ok).To(BeFalse())
+
+			hr.SetImageDigest("chaincode-id", "sha256:digest")
+			digest, ok := hr.ImageDigest("chaincode-id")
+			Expect(ok).To(BeTrue())
+			Expect(digest).To(Equal("sha256:digest"))
+		})
+
+		It("forgets the image digest when the chaincode is deregistered", func() {
+			hr.SetImageDigest("chaincode-id", "sha256:digest")
+			Expect(hr.Register(handler)).To(Succeed())
+			handler.TXContexts = chaincode.NewTransactionContexts()
+			Expect(hr.Deregister("chaincode-id")).To(Succeed())
+
+			_, ok := hr.ImageDigest("chaincode-id")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Running", func() {
+		It("is false for an unknown chaincode", func() {
+			Expect(hr.Running("chaincode-id")).To(BeFalse())
+		})
+
+		It("is true while the chaincode is launching", func() {
+			hr.Launching("chaincode-id")
+			Expect(hr.Running("chaincode-id")).To(BeTrue())
+		})
+
+		It("is true once a handler is registered", func() {
+			hr = chaincode.NewHandlerRegistry(true)
+			Expect(hr.Register(handler)).To(Succeed())
+			Expect(hr.Running("chaincode-id")).To(BeTrue())
+		})
 	})
 
 	Describe("Failed", func() {
@@ -146,6 +296,28 @@ var _ = Describe("HandlerRegistry", func() {
 		})
 	})
 
+	Describe("ReadyHandler", func() {
+		BeforeEach(func() {
+			hr.Launching("chaincode-id")
+			err := hr.Register(handler)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns nil while the chaincode is launching", func() {
+			Expect(hr.ReadyHandler("chaincode-id")).To(BeNil())
+		})
+
+		It("returns the registered handler once the launch has completed", func() {
+			hr.Ready("chaincode-id")
+			Expect(hr.ReadyHandler("chaincode-id")).To(BeIdenticalTo(handler))
+		})
+
+		It("returns nil once the launch has failed", func() {
+			hr.Failed("chaincode-id", errors.New("failed"))
+			Expect(hr.ReadyHandler("chaincode-id")).To(BeNil())
+		})
+	})
+
 	Describe("Register", func() {
 		Context("when unsolicited registration is disallowed", func() {
 			BeforeEach(func() {
@@ -170,6 +342,15 @@ var _ = Describe("HandlerRegistry", func() {
 				h := hr.Handler("chaincode-id")
 				Expect(h).To(Equal(handler))
 			})
+
+			It("records progress on the launch state", func() {
+				launchState, _ := hr.Launching("chaincode-id")
+				Consistently(launchState.Progress()).ShouldNot(Receive())
+
+				err := hr.Register(handler)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(launchState.Progress()).Should(Receive())
+			})
 		})
 
 		Context("when unsolicited registrations are allowed", func() {
@@ -198,6 +379,79 @@ var _ = Describe("HandlerRegistry", func() {
 				Expect(err).To(MatchError("duplicate chaincodeID: chaincode-id"))
 			})
 		})
+
+		Context("when stale handlers may be replaced", func() {
+			var (
+				fakeResultsIterator *mock.QueryResultsIterator
+				fakeChatStream      *mock.ChaincodeStream
+				newHandler          *chaincode.Handler
+			)
+
+			BeforeEach(func() {
+				fakeResultsIterator = &mock.QueryResultsIterator{}
+				transactionContexts := chaincode.NewTransactionContexts()
+				txContext, err := transactionContexts.Create(&ccprovider.TransactionParams{
+					ChannelID: "chain-id",
+					TxID:      "transaction-id",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				txContext.InitializeQueryContext("query-id", fakeResultsIterator)
+
+				fakeChatStream = &mock.ChaincodeStream{}
+				fakeChatStream.RecvReturns(nil, io.EOF)
+				handler.TXContexts = transactionContexts
+				handler.LedgerGetter = &mock.LedgerGetter{}
+				handler.Registry = hr
+				chaincode.SetHandlerChatStream(handler, fakeChatStream)
+
+				hr.ReplaceStaleHandlers = true
+				Expect(hr.Register(handler)).To(Succeed())
+
+				newHandler = &chaincode.Handler{}
+				chaincode.SetHandlerChaincodeID(newHandler, "chaincode-id")
+			})
+
+			It("replaces the registered handler", func() {
+				err := hr.Register(newHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hr.Handler("chaincode-id")).To(BeIdenticalTo(newHandler))
+			})
+
+			It("closes the transaction contexts of the replaced handler", func() {
+				err := hr.Register(newHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeResultsIterator.CloseCallCount()).To(Equal(1))
+			})
+
+			It("fails transactions in flight on the replaced handler", func() {
+				errCh := make(chan error, 1)
+				go func() {
+					_, err := handler.Execute(&ccprovider.TransactionParams{
+						ChannelID: "chain-id",
+						TxID:      "in-flight-tx-id",
+					}, "chaincode-name", &pb.ChaincodeMessage{
+						Type:      pb.ChaincodeMessage_TRANSACTION,
+						ChannelId: "chain-id",
+						Txid:      "in-flight-tx-id",
+					}, time.Minute)
+					errCh <- err
+				}()
+				Eventually(fakeChatStream.SendCallCount).Should(Equal(1))
+
+				err := hr.Register(newHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(errCh).Should(Receive(MatchError(chaincode.ErrorHandlerReplaced)))
+			})
+
+			It("does not deregister the new handler when the replaced stream ends", func() {
+				err := hr.Register(newHandler)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = handler.ProcessStream(fakeChatStream)
+				Expect(err).To(HaveOccurred())
+				Expect(hr.Handler("chaincode-id")).To(BeIdenticalTo(newHandler))
+			})
+		})
 	})
 
 	Describe("Deregister", func() {
@@ -267,6 +521,15 @@ var _ = Describe("LaunchState", func() {
 		Expect(launchState.Err()).To(BeNil())
 	})
 
+	It("signals progress without blocking", func() {
+		Consistently(launchState.Progress()).ShouldNot(Receive())
+
+		launchState.NotifyProgress()
+		launchState.NotifyProgress()
+		Eventually(launchState.Progress()).Should(Receive())
+		Consistently(launchState.Progress()).ShouldNot(Receive())
+	})
+
 	It("can be notified multitple times but honors the first", func() {
 		Expect(launchState.Done()).NotTo(BeNil())
 		Consistently(launchState.Done()).ShouldNot(BeClosed())
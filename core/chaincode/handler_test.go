@@ -7,10 +7,12 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode_test
 
 import (
+	"context"
 	"io"
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-lib-go/common/metrics"
 	"github.com/hyperledger/fabric-lib-go/common/metrics/metricsfakes"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/common/util"
@@ -293,6 +295,34 @@ var _ = Describe("Handler", func() {
 			})
 		})
 
+		Context("when the delegate panics", func() {
+			var panicked []string
+
+			BeforeEach(func() {
+				panicked = nil
+				handler.OnPanic = func(ccid string) { panicked = append(panicked, ccid) }
+				fakeMessageHandler.HandleStub = func(*pb.ChaincodeMessage, *chaincode.TransactionContext) (*pb.ChaincodeMessage, error) {
+					panic("kaboom")
+				}
+			})
+
+			It("fails only the transaction of the request", func() {
+				handler.HandleTransaction(incomingMessage, fakeMessageHandler.Handle)
+
+				Eventually(fakeChatStream.SendCallCount).Should(Equal(1))
+				msg := fakeChatStream.SendArgsForCall(0)
+				Expect(msg.Type).To(Equal(pb.ChaincodeMessage_ERROR))
+				Expect(msg.Txid).To(Equal("tx-id"))
+				Expect(string(msg.Payload)).To(Equal("GET_STATE failed: transaction ID: tx-id: panic while executing chaincode test-handler-name:1.0: kaboom"))
+				Expect(fakeTransactionRegistry.RemoveCallCount()).To(Equal(1))
+			})
+
+			It("reports the panic", func() {
+				handler.HandleTransaction(incomingMessage, fakeMessageHandler.Handle)
+				Expect(panicked).To(Equal([]string{"test-handler-name:1.0"}))
+			})
+		})
+
 		Context("when the transaction ID has already been registered", func() {
 			BeforeEach(func() {
 				fakeTransactionRegistry.AddReturns(false)
@@ -985,6 +1015,18 @@ var _ = Describe("Handler", func() {
 				})
 			})
 
+			Context("and the transaction has no channel", func() {
+				BeforeEach(func() {
+					txContext.CollectionStore = nil
+				})
+
+				It("returns an error without reading private data", func() {
+					_, err := handler.HandleGetState(incomingMessage, txContext)
+					Expect(err).To(MatchError("private data collection collection-name of chaincode cc-instance-name is not available without a channel"))
+					Expect(fakeTxSimulator.GetPrivateDataCallCount()).To(Equal(0))
+				})
+			})
+
 			Context("and GetPrivateData fails due to no read access permission", func() {
 				BeforeEach(func() {
 					fakeCollectionStore.RetrieveReadWritePermissionReturns(false, false, nil)
@@ -2316,6 +2358,15 @@ var _ = Describe("Handler", func() {
 			Expect(proposal).To(Equal(expectedSignedProp))
 		})
 
+		It("does not mark an invocation on the same channel as idempotent", func() {
+			_, err := handler.HandleInvokeChaincode(incomingMessage, txContext)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeInvoker.InvokeCallCount()).To(Equal(1))
+			txParams, _, _ := fakeInvoker.InvokeArgsForCall(0)
+			Expect(txParams.IsIdempotent).To(BeFalse())
+		})
+
 		Context("when the target channel is different from the context", func() {
 			BeforeEach(func() {
 				request = &pb.ChaincodeSpec{
@@ -2366,6 +2417,15 @@ var _ = Describe("Handler", func() {
 				Expect(txParams.TXSimulator).To(BeIdenticalTo(newTxSimulator)) // same instance, not just equal
 			})
 
+			It("marks the execution as idempotent", func() {
+				_, err := handler.HandleInvokeChaincode(incomingMessage, txContext)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeInvoker.InvokeCallCount()).To(Equal(1))
+				txParams, _, _ := fakeInvoker.InvokeArgsForCall(0)
+				Expect(txParams.IsIdempotent).To(BeTrue())
+			})
+
 			It("creates a new history query executor for target execution", func() {
 				_, err := handler.HandleInvokeChaincode(incomingMessage, txContext)
 				Expect(err).NotTo(HaveOccurred())
@@ -2549,6 +2609,31 @@ var _ = Describe("Handler", func() {
 			Expect(fakeContextRegistry.CreateArgsForCall(0)).To(Equal(txParams))
 		})
 
+		Context("when context keys are configured", func() {
+			BeforeEach(func() {
+				handler.ContextKeys = []chaincode.ContextKey{"tenant", "request-id"}
+				ctx := context.WithValue(context.Background(), chaincode.ContextKey("tenant"), "tenant-a")
+				txParams.Context = context.WithValue(ctx, chaincode.ContextKey("unlisted"), "unlisted-value")
+			})
+
+			It("copies the values of the keys into the transaction context", func() {
+				close(responseNotifier)
+				handler.Execute(txParams, "chaincode-name", incomingMessage, time.Second)
+
+				Expect(txContext.ContextValues).To(Equal(map[chaincode.ContextKey]interface{}{
+					"tenant": "tenant-a",
+				}))
+			})
+		})
+
+		It("copies no context values by default", func() {
+			txParams.Context = context.WithValue(context.Background(), chaincode.ContextKey("tenant"), "tenant-a")
+			close(responseNotifier)
+			handler.Execute(txParams, "chaincode-name", incomingMessage, time.Second)
+
+			Expect(txContext.ContextValues).To(BeNil())
+		})
+
 		It("sends an execute message to the chaincode with the correct proposal", func() {
 			expectedMessage := *incomingMessage
 			expectedMessage.Proposal = expectedSignedProp
@@ -2719,6 +2804,23 @@ var _ = Describe("Handler", func() {
 				Expect(fakeExecuteTimeouts.AddArgsForCall(0)).To(BeNumerically("~", 1.0))
 			})
 
+			Context("when a metric labeler is configured", func() {
+				BeforeEach(func() {
+					handler.Metrics.Labeler = &orgLabeler{org: "org1"}
+				})
+
+				It("records execute timeouts with the derived labels", func() {
+					handler.Execute(txParams, "chaincode-name", incomingMessage, time.Millisecond)
+					Expect(fakeExecuteTimeouts.WithCallCount()).To(Equal(1))
+					labelValues := fakeExecuteTimeouts.WithArgsForCall(0)
+					Expect(labelValues).To(Equal([]string{
+						"chaincode", "test-handler-name:1.0",
+						"org", "org1",
+						"channel", "channel-id",
+					}))
+				})
+			})
+
 			It("deletes the transaction context", func() {
 				handler.Execute(txParams, "chaincode-name", incomingMessage, time.Millisecond)
 
@@ -2728,6 +2830,67 @@ var _ = Describe("Handler", func() {
 				Expect(txid).To(Equal("tx-id"))
 			})
 		})
+
+		Context("when a send timeout is configured", func() {
+			BeforeEach(func() {
+				handler.SendTimeout = 10 * time.Millisecond
+			})
+
+			Context("when the message cannot be sent in time", func() {
+				var unblock chan struct{}
+
+				BeforeEach(func() {
+					unblock = make(chan struct{})
+					fakeChatStream.SendStub = func(*pb.ChaincodeMessage) error {
+						<-unblock
+						return nil
+					}
+				})
+
+				AfterEach(func() {
+					close(unblock)
+				})
+
+				It("returns a send timeout without recording an execute timeout", func() {
+					_, err := handler.Execute(txParams, "chaincode-name", incomingMessage, time.Hour)
+					Expect(err).To(MatchError(chaincode.ErrorSendTimeout))
+					Expect(fakeExecuteTimeouts.AddCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the message times out waiting behind another send", func() {
+				var unblock chan struct{}
+
+				BeforeEach(func() {
+					unblock = make(chan struct{})
+					fakeChatStream.SendStub = func(*pb.ChaincodeMessage) error {
+						<-unblock
+						return nil
+					}
+				})
+
+				It("never sends the message", func() {
+					_, err := handler.Execute(txParams, "chaincode-name", incomingMessage, time.Hour)
+					Expect(err).To(MatchError(chaincode.ErrorSendTimeout))
+					Eventually(fakeChatStream.SendCallCount).Should(Equal(1))
+
+					_, err = handler.Execute(txParams, "chaincode-name", incomingMessage, time.Hour)
+					Expect(err).To(MatchError(chaincode.ErrorSendTimeout))
+
+					close(unblock)
+					Consistently(fakeChatStream.SendCallCount).Should(Equal(1))
+				})
+			})
+
+			Context("when the message is sent but the response is late", func() {
+				It("returns an execute timeout", func() {
+					_, err := handler.Execute(txParams, "chaincode-name", incomingMessage, 10*time.Millisecond)
+					Expect(err).To(MatchError(chaincode.ErrorExecutionTimeout))
+					Expect(fakeChatStream.SendCallCount()).To(Equal(1))
+					Expect(fakeExecuteTimeouts.AddCallCount()).To(Equal(1))
+				})
+			})
+		})
 	})
 
 	Describe("HandleRegister", func() {
@@ -2762,6 +2925,12 @@ var _ = Describe("Handler", func() {
 			Eventually(handler.State).Should(Equal(chaincode.Ready))
 		})
 
+		It("notifies the registry that the handler is established", func() {
+			handler.HandleRegister(incomingMessage)
+			Expect(fakeHandlerRegistry.EstablishedCallCount()).To(Equal(1))
+			Expect(fakeHandlerRegistry.EstablishedArgsForCall(0)).To(Equal("chaincode-id-name"))
+		})
+
 		It("notifies the registry that the handler is ready", func() {
 			handler.HandleRegister(incomingMessage)
 			Expect(fakeHandlerRegistry.FailedCallCount()).To(Equal(0))
@@ -2886,6 +3055,19 @@ var _ = Describe("Handler", func() {
 			Eventually(streamDoneChan).Should(BeClosed())
 		})
 
+		Context("when a receive buffer is configured", func() {
+			BeforeEach(func() {
+				handler.ReceiveBufferSize = 4
+			})
+
+			It("reads ahead until an error is received", func() {
+				fakeChatStream.RecvReturnsOnCall(99, nil, errors.New("done-for-now"))
+				err := handler.ProcessStream(fakeChatStream)
+				Expect(err).To(MatchError("receive from chaincode support stream failed: done-for-now"))
+				Expect(fakeChatStream.RecvCallCount()).To(Equal(100))
+			})
+		})
+
 		Context("when receive fails with an io.EOF", func() {
 			BeforeEach(func() {
 				fakeChatStream.RecvReturns(nil, io.EOF)
@@ -2919,13 +3101,73 @@ var _ = Describe("Handler", func() {
 			})
 		})
 
+		Describe("handler state metrics", func() {
+			var (
+				recvChan    chan *pb.ChaincodeMessage
+				stateGauges map[string]*metricsfakes.Gauge
+			)
+
+			// gaugeValue sums the deltas added to the gauge of the state.
+			gaugeValue := func(state string) func() float64 {
+				return func() float64 {
+					var value float64
+					for i := 0; i < stateGauges[state].AddCallCount(); i++ {
+						value += stateGauges[state].AddArgsForCall(i)
+					}
+					return value
+				}
+			}
+
+			BeforeEach(func() {
+				recvChan = make(chan *pb.ChaincodeMessage, 1)
+				fakeChatStream.RecvStub = func() (*pb.ChaincodeMessage, error) {
+					return <-recvChan, nil
+				}
+
+				stateGauges = map[string]*metricsfakes.Gauge{
+					"created":     {},
+					"established": {},
+					"ready":       {},
+				}
+				fakeHandlerStates := &metricsfakes.Gauge{}
+				fakeHandlerStates.WithStub = func(labelValues ...string) metrics.Gauge {
+					Expect(labelValues).To(HaveLen(2))
+					Expect(labelValues[0]).To(Equal("state"))
+					return stateGauges[labelValues[1]]
+				}
+				handler.Metrics.HandlerStates = fakeHandlerStates
+			})
+
+			It("moves the handler through the states as the chaincode registers", func() {
+				errChan := make(chan error, 1)
+				go func() { errChan <- handler.ProcessStream(fakeChatStream) }()
+
+				Eventually(gaugeValue("created")).Should(Equal(1.0))
+				Expect(gaugeValue("ready")()).To(Equal(0.0))
+
+				payload, err := proto.Marshal(&pb.ChaincodeID{Name: "chaincode-id-name"})
+				Expect(err).NotTo(HaveOccurred())
+				recvChan <- &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTER, Payload: payload}
+
+				Eventually(gaugeValue("ready")).Should(Equal(1.0))
+				Expect(gaugeValue("created")()).To(Equal(0.0))
+				Expect(gaugeValue("established")()).To(Equal(0.0))
+				Expect(stateGauges["established"].AddArgsForCall(0)).To(Equal(1.0))
+
+				recvChan <- nil
+				Eventually(errChan).Should(Receive())
+				Expect(gaugeValue("ready")()).To(Equal(0.0))
+			})
+		})
+
 		Describe("keepalive messages", func() {
 			var recvChan chan *pb.ChaincodeMessage
 
 			BeforeEach(func() {
 				recvChan = make(chan *pb.ChaincodeMessage, 1)
+				recv := recvChan // shadow to avoid race
 				fakeChatStream.RecvStub = func() (*pb.ChaincodeMessage, error) {
-					msg := <-recvChan
+					msg := <-recv
 					return msg, nil
 				}
 
@@ -2946,6 +3188,117 @@ var _ = Describe("Handler", func() {
 				}
 			})
 
+			Context("when keepalive is disabled for the chaincode", func() {
+				BeforeEach(func() {
+					handler.KeepaliveDisabled = map[string]bool{"test-handler-name:1.0": true}
+				})
+
+				It("does not send keep alive messages", func() {
+					errChan := make(chan error, 1)
+					go func() { errChan <- handler.ProcessStream(fakeChatStream) }()
+
+					Consistently(fakeChatStream.SendCallCount, 300*time.Millisecond).Should(Equal(0))
+					recvChan <- nil
+					Eventually(errChan).Should(Receive())
+				})
+			})
+
+			Context("when keepalive is suppressed while executions are in progress", func() {
+				BeforeEach(func() {
+					handler.KeepaliveIdleOnly = true
+				})
+
+				It("resumes keep alive messages once the chaincode is idle", func() {
+					errChan := make(chan error, 1)
+					go func() { errChan <- handler.ProcessStream(fakeChatStream) }()
+					Eventually(fakeChatStream.RecvCallCount).ShouldNot(Equal(0))
+
+					execDone := make(chan struct{})
+					go func() {
+						txParams := &ccprovider.TransactionParams{TxID: "tx-id", ChannelID: "channel-id"}
+						handler.Execute(txParams, "chaincode-name", &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_TRANSACTION, Txid: "tx-id", ChannelId: "channel-id"}, time.Minute)
+						close(execDone)
+					}()
+
+					Eventually(fakeChatStream.SendCallCount).Should(Equal(1))
+					Consistently(fakeChatStream.SendCallCount, 300*time.Millisecond).Should(Equal(1))
+					Expect(fakeChatStream.SendArgsForCall(0).Type).To(Equal(pb.ChaincodeMessage_TRANSACTION))
+
+					responseNotifier <- &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED}
+					Eventually(execDone).Should(BeClosed())
+					Eventually(fakeChatStream.SendCallCount).Should(BeNumerically(">=", 3))
+					Expect(fakeChatStream.SendArgsForCall(1).Type).To(Equal(pb.ChaincodeMessage_KEEPALIVE))
+
+					recvChan <- nil
+					Eventually(errChan).Should(Receive())
+				})
+			})
+
+			Context("when keepalive is disabled for another chaincode", func() {
+				BeforeEach(func() {
+					handler.KeepaliveDisabled = map[string]bool{"other-chaincode:1.0": true}
+				})
+
+				It("sends keep alive messages", func() {
+					errChan := make(chan error, 1)
+					go func() { errChan <- handler.ProcessStream(fakeChatStream) }()
+
+					Eventually(fakeChatStream.SendCallCount).Should(BeNumerically(">=", 2))
+					recvChan <- nil
+					Eventually(errChan).Should(Receive())
+				})
+			})
+
+			Context("when a keepalive send fails transiently", func() {
+				BeforeEach(func() {
+					handler.Keepalive = time.Hour
+					handler.KeepaliveRetries = 3
+					handler.KeepaliveRetryBackoff = 10 * time.Millisecond
+					fakeChatStream.SendReturnsOnCall(0, errors.New("buffer-full"))
+				})
+
+				It("retries with backoff without counting a miss", func() {
+					errChan := make(chan error, 1)
+					go func() { errChan <- handler.ProcessStream(fakeChatStream) }()
+					Eventually(fakeChatStream.RecvCallCount).ShouldNot(Equal(0))
+
+					chaincode.SendKeepalive(handler)
+					Expect(fakeChatStream.SendCallCount()).To(Equal(2))
+					Expect(chaincode.KeepaliveMisses(handler)).To(Equal(0))
+					Consistently(errChan).ShouldNot(Receive())
+
+					recvChan <- nil
+					Eventually(errChan).Should(Receive())
+				})
+			})
+
+			Context("when keepalive sends fail persistently", func() {
+				BeforeEach(func() {
+					handler.Keepalive = time.Hour
+					handler.KeepaliveRetries = 2
+					handler.KeepaliveRetryBackoff = time.Millisecond
+					handler.KeepaliveMissThreshold = 1
+					fakeChatStream.SendReturns(errors.New("broken-pipe"))
+				})
+
+				It("counts misses and ends the stream past the threshold", func() {
+					errChan := make(chan error, 1)
+					go func() { errChan <- handler.ProcessStream(fakeChatStream) }()
+					Eventually(fakeChatStream.RecvCallCount).ShouldNot(Equal(0))
+
+					chaincode.SendKeepalive(handler)
+					Expect(fakeChatStream.SendCallCount()).To(Equal(3))
+					Expect(chaincode.KeepaliveMisses(handler)).To(Equal(1))
+					Consistently(errChan).ShouldNot(Receive())
+
+					go chaincode.SendKeepalive(handler)
+					Eventually(errChan).Should(Receive(MatchError("received error while sending message, ending chaincode support stream: missed 2 consecutive keepalives: [] error sending KEEPALIVE: broken-pipe")))
+
+					// release the receive left blocked by the ended stream
+					recvChan <- nil
+				})
+			})
+
 			Context("when keepalive is disabled", func() {
 				BeforeEach(func() {
 					handler.Keepalive = 0
@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// initOrder orders invocations of a chaincode on a channel behind any init of
+// that chaincode which is in progress. Inits of the same chaincode on the same
+// channel are serialized. The zero value is ready to use.
+type initOrder struct {
+	mutex sync.Mutex
+	locks map[initKey]*initLock
+	inits map[initKey]*initInProgress
+}
+
+// initLock serializes the inits of a chaincode on a channel. It is removed
+// once no init holds or waits for it.
+type initLock struct {
+	sync.Mutex
+	users int
+}
+
+type initKey struct {
+	channelID string
+	ccName    string
+}
+
+type initInProgress struct {
+	txID string
+	done chan struct{}
+	err  error
+}
+
+// begin blocks until no other init of the chaincode is in progress and then
+// marks an init by txID as in progress. The returned function must be called
+// with the outcome of the init once it completes.
+func (o *initOrder) begin(channelID, ccName, txID string) func(error) {
+	key := initKey{channelID: channelID, ccName: ccName}
+
+	o.mutex.Lock()
+	if o.locks == nil {
+		o.locks = map[initKey]*initLock{}
+		o.inits = map[initKey]*initInProgress{}
+	}
+	lock, ok := o.locks[key]
+	if !ok {
+		lock = &initLock{}
+		o.locks[key] = lock
+	}
+	lock.users++
+	o.mutex.Unlock()
+
+	lock.Lock()
+
+	init := &initInProgress{txID: txID, done: make(chan struct{})}
+	o.mutex.Lock()
+	o.inits[key] = init
+	o.mutex.Unlock()
+
+	return func(err error) {
+		o.mutex.Lock()
+		delete(o.inits, key)
+		lock.users--
+		if lock.users == 0 {
+			delete(o.locks, key)
+		}
+		o.mutex.Unlock()
+
+		init.err = err
+		close(init.done)
+		lock.Unlock()
+	}
+}
+
+// wait blocks while an init of the chaincode by another transaction is in
+// progress and returns the error of that init, if any.
+func (o *initOrder) wait(channelID, ccName, txID string) error {
+	o.mutex.Lock()
+	init, ok := o.inits[initKey{channelID: channelID, ccName: ccName}]
+	o.mutex.Unlock()
+
+	if !ok || init.txID == txID {
+		return nil
+	}
+
+	<-init.done
+	return init.err
+}
+
+// initResult determines the outcome of an init execution.
+func initResult(resp *pb.ChaincodeMessage, err error) error {
+	switch {
+	case err != nil:
+		return err
+	case resp == nil:
+		return errors.New("init returned no response")
+	case resp.Type == pb.ChaincodeMessage_ERROR:
+		return errors.Errorf("transaction returned with failure: %s", resp.Payload)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,31 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "time"
+
+// LaunchAudit records the outcome of an attempt to launch a chaincode.
+type LaunchAudit struct {
+	// ChaincodeID is the ID of the chaincode launched. It identifies the
+	// installed package, and so the version, of the chaincode.
+	ChaincodeID string
+	// ChannelID is the channel the launch was made on behalf of, if any.
+	ChannelID string
+	// Success indicates whether the chaincode was launched and registered.
+	Success bool
+	// Duration is the time taken by the attempt, including any wait for a
+	// launch slot.
+	Duration time.Duration
+	// Err is the error which failed the launch, or nil.
+	Err error
+}
+
+// A LaunchAttemptHook is called once for every launch attempted by a
+// RuntimeLauncher, whether it succeeded or failed, so that launches may be
+// audited. Callers which join a launch already in progress do not attempt
+// another. The hook is called synchronously before the launch returns.
+type LaunchAttemptHook func(LaunchAudit)
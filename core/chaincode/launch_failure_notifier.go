@@ -0,0 +1,107 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// launchFailureQueueDepth is the number of launch failure notifications
+// which may await delivery before further notifications are dropped.
+const launchFailureQueueDepth = 64
+
+// defaultWebhookClient is used by a WebhookNotifier without a Client. The
+// timeout keeps an unresponsive endpoint from stalling delivery indefinitely.
+var defaultWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// A LaunchFailure describes a failed attempt to launch a chaincode, as
+// reported to a LaunchFailureNotifier.
+type LaunchFailure struct {
+	ChaincodeID string    `json:"chaincode_id"`
+	ChannelID   string    `json:"channel_id,omitempty"`
+	Error       string    `json:"error"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// A LaunchFailureNotifier reports launch failures to an external system,
+// such as an alerting endpoint. Notifications are delivered one at a time from
+// a background goroutine, so a slow notifier delays later notifications but
+// never a launch.
+type LaunchFailureNotifier interface {
+	Notify(failure LaunchFailure) error
+}
+
+// WebhookNotifier is a LaunchFailureNotifier which posts each failure as JSON
+// to URL. A nil Client uses a client with a 10 second timeout.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// Notify implements LaunchFailureNotifier.
+func (w *WebhookNotifier) Notify(failure LaunchFailure) error {
+	body, err := json.Marshal(failure)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal launch failure")
+	}
+
+	client := w.Client
+	if client == nil {
+		client = defaultWebhookClient
+	}
+	resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "failed to post launch failure to %s", w.URL)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("launch failure webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+type launchFailureNotification struct {
+	notifier LaunchFailureNotifier
+	failure  LaunchFailure
+}
+
+// launchFailureQueue delivers launch failure notifications in the
+// background. Delivery is best-effort: a notification is dropped when the
+// queue is full, and delivery errors are only logged. The zero value is ready
+// to use.
+type launchFailureQueue struct {
+	once          sync.Once
+	notifications chan launchFailureNotification
+}
+
+// enqueue queues the failure for delivery to the notifier without blocking.
+func (q *launchFailureQueue) enqueue(notifier LaunchFailureNotifier, failure LaunchFailure) {
+	q.once.Do(func() {
+		q.notifications = make(chan launchFailureNotification, launchFailureQueueDepth)
+		go q.deliver()
+	})
+
+	select {
+	case q.notifications <- launchFailureNotification{notifier: notifier, failure: failure}:
+	default:
+		chaincodeLogger.Warningf("dropping launch failure notification of chaincode %s: %d notifications are awaiting delivery", failure.ChaincodeID, launchFailureQueueDepth)
+	}
+}
+
+func (q *launchFailureQueue) deliver() {
+	for n := range q.notifications {
+		if err := n.notifier.Notify(n.failure); err != nil {
+			chaincodeLogger.Warningf("failed to report launch failure of chaincode %s: %s", n.failure.ChaincodeID, err)
+		}
+	}
+}
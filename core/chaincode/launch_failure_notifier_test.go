@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebhookNotifier", func() {
+	var (
+		server   *httptest.Server
+		status   int
+		received []map[string]interface{}
+		notifier *chaincode.WebhookNotifier
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		received = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal(http.MethodPost))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+			var body map[string]interface{}
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			received = append(received, body)
+			w.WriteHeader(status)
+		}))
+		notifier = &chaincode.WebhookNotifier{URL: server.URL}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts the failure as JSON", func() {
+		err := notifier.Notify(chaincode.LaunchFailure{
+			ChaincodeID: "chaincode-id",
+			ChannelID:   "channel-id",
+			Error:       "banana",
+			Timestamp:   time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(ConsistOf(map[string]interface{}{
+			"chaincode_id": "chaincode-id",
+			"channel_id":   "channel-id",
+			"error":        "banana",
+			"timestamp":    "2020-01-02T03:04:05Z",
+		}))
+	})
+
+	Context("when the endpoint rejects the notification", func() {
+		BeforeEach(func() {
+			status = http.StatusServiceUnavailable
+		})
+
+		It("returns an error", func() {
+			err := notifier.Notify(chaincode.LaunchFailure{ChaincodeID: "chaincode-id"})
+			Expect(err).To(MatchError("launch failure webhook " + server.URL + " returned 503 Service Unavailable"))
+		})
+	})
+})
@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+)
+
+// launchFailureCache remembers chaincodes which recently failed to launch so
+// that invocations fail fast rather than attempting a launch which is likely
+// to fail again. The zero value is ready to use.
+type launchFailureCache struct {
+	mutex       sync.Mutex
+	failures    map[string]launchFailure
+	consecutive map[string]int
+}
+
+type launchFailure struct {
+	err     error
+	ttl     time.Duration
+	expires time.Time
+}
+
+// add records the launch failure of a chaincode. The failure is remembered for
+// ttl or, when maxTTL exceeds ttl, for ttl doubled with each earlier
+// consecutive failure of the chaincode up to maxTTL.
+func (c *launchFailureCache) add(ccid string, err error, ttl, maxTTL time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failures == nil {
+		c.failures = map[string]launchFailure{}
+		c.consecutive = map[string]int{}
+	}
+	c.consecutive[ccid]++
+
+	backoff := ttl
+	for i := 1; i < c.consecutive[ccid] && backoff < maxTTL; i++ {
+		backoff *= 2
+	}
+	if backoff > ttl && backoff > maxTTL {
+		backoff = maxTTL
+	}
+	c.failures[ccid] = launchFailure{err: err, ttl: backoff, expires: time.Now().Add(backoff)}
+}
+
+// reset forgets the launch failures of a chaincode which has launched
+// successfully, so that its next failure is remembered for the initial ttl.
+func (c *launchFailureCache) reset(ccid string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.failures, ccid)
+	delete(c.consecutive, ccid)
+}
+
+// backoff returns how long the current launch failure of the chaincode is
+// remembered for, or zero if the chaincode has no launch failure.
+func (c *launchFailureCache) backoff(ccid string) time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.failures[ccid].ttl
+}
+
+// get returns the error of a launch failure of the chaincode which has not
+// yet expired, or nil.
+func (c *launchFailureCache) get(ccid string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	failure, ok := c.failures[ccid]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(failure.expires) {
+		delete(c.failures, ccid)
+		return nil
+	}
+	return failure.err
+}
+
+// list returns the errors of the launch failures which have not yet expired,
+// by chaincode ID.
+func (c *launchFailureCache) list() map[string]error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	errs := map[string]error{}
+	for ccid, failure := range c.failures {
+		if now.Before(failure.expires) {
+			errs[ccid] = failure.err
+		}
+	}
+	return errs
+}
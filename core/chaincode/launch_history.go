@@ -0,0 +1,74 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+)
+
+// launchHistoryDepth is the number of launch attempts remembered for each
+// chaincode.
+const launchHistoryDepth = 16
+
+// A LaunchRecord describes an attempt to launch a chaincode.
+type LaunchRecord struct {
+	ChannelID string
+	StartTime time.Time
+	Duration  time.Duration
+	Success   bool
+	Err       error
+}
+
+func newLaunchRecord(channelID string, startTime time.Time, err error) LaunchRecord {
+	return LaunchRecord{
+		ChannelID: channelID,
+		StartTime: startTime,
+		Duration:  time.Since(startTime),
+		Success:   err == nil,
+		Err:       err,
+	}
+}
+
+// LaunchHistory returns the most recent attempts to launch the chaincode,
+// oldest first, for example to spot a chaincode which keeps failing and being
+// relaunched. At most launchHistoryDepth attempts are remembered, and the
+// history is kept across relaunches until the peer restarts.
+func (cs *ChaincodeSupport) LaunchHistory(ccid string) []LaunchRecord {
+	return cs.launchHistory.get(ccid)
+}
+
+// launchHistory remembers the recent launch attempts of each chaincode. The
+// zero value is ready to use.
+type launchHistory struct {
+	mutex   sync.Mutex
+	records map[string][]LaunchRecord
+}
+
+// add records a launch attempt of the chaincode, forgetting the oldest
+// attempt once launchHistoryDepth attempts are remembered.
+func (h *launchHistory) add(ccid string, record LaunchRecord) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.records == nil {
+		h.records = map[string][]LaunchRecord{}
+	}
+	records := append(h.records[ccid], record)
+	if len(records) > launchHistoryDepth {
+		records = append(records[:0:0], records[len(records)-launchHistoryDepth:]...)
+	}
+	h.records[ccid] = records
+}
+
+// get returns a copy of the launch attempts of the chaincode.
+func (h *launchHistory) get(ccid string) []LaunchRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return append([]LaunchRecord(nil), h.records[ccid]...)
+}
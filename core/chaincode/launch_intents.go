@@ -0,0 +1,149 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/pkg/errors"
+)
+
+// A LaunchIntent records a chaincode launch in progress so that the launch
+// can be resumed if the peer restarts before it completes. The chaincode ID
+// identifies the name and version of the chaincode package.
+type LaunchIntent struct {
+	ChaincodeID string `json:"chaincode_id"`
+	ChannelID   string `json:"channel_id,omitempty"`
+}
+
+// A LaunchIntentStore durably records the chaincode launches in progress.
+type LaunchIntentStore interface {
+	// Put records the intent, replacing any intent recorded for the same
+	// chaincode.
+	Put(intent LaunchIntent) error
+	// Remove forgets the intent recorded for the chaincode, if any.
+	Remove(ccid string) error
+	// List returns the recorded intents.
+	List() ([]LaunchIntent, error)
+}
+
+// FileLaunchIntentStore is a LaunchIntentStore which keeps each intent in a
+// file of its own in the directory at Path. The directory is created when the
+// first intent is recorded.
+type FileLaunchIntentStore struct {
+	Path string
+}
+
+const launchIntentSuffix = ".json"
+
+func (s *FileLaunchIntentStore) fileName(ccid string) string {
+	return hex.EncodeToString([]byte(ccid)) + launchIntentSuffix
+}
+
+// Put implements LaunchIntentStore.
+func (s *FileLaunchIntentStore) Put(intent LaunchIntent) error {
+	data, err := json.Marshal(intent)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal launch intent of chaincode %s", intent.ChaincodeID)
+	}
+	if err := os.MkdirAll(s.Path, 0o750); err != nil {
+		return errors.Wrapf(err, "failed to create launch intent directory %s", s.Path)
+	}
+	fileIO := &persistence.FilesystemIO{}
+	if err := fileIO.WriteFile(s.Path, s.fileName(intent.ChaincodeID), data); err != nil {
+		return errors.WithMessagef(err, "failed to record launch intent of chaincode %s", intent.ChaincodeID)
+	}
+	return nil
+}
+
+// Remove implements LaunchIntentStore.
+func (s *FileLaunchIntentStore) Remove(ccid string) error {
+	err := os.Remove(filepath.Join(s.Path, s.fileName(ccid)))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove launch intent of chaincode %s", ccid)
+	}
+	return nil
+}
+
+// List implements LaunchIntentStore.
+func (s *FileLaunchIntentStore) List() ([]LaunchIntent, error) {
+	entries, err := os.ReadDir(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read launch intent directory %s", s.Path)
+	}
+
+	var intents []LaunchIntent
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), launchIntentSuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Path, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read launch intent %s", entry.Name())
+		}
+		var intent LaunchIntent
+		if err := json.Unmarshal(data, &intent); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal launch intent %s", entry.Name())
+		}
+		intents = append(intents, intent)
+	}
+	return intents, nil
+}
+
+// recordLaunchIntent records the launch of the chaincode as in progress. A
+// launch proceeds even if the intent cannot be recorded, as the store only
+// serves to resume it after a restart.
+func (cs *ChaincodeSupport) recordLaunchIntent(channelID, ccid string) {
+	if err := cs.LaunchIntents.Put(LaunchIntent{ChaincodeID: ccid, ChannelID: channelID}); err != nil {
+		chaincodeLogger.Warningf("launch of chaincode %s will not be resumed after a restart: %s", ccid, err)
+	}
+}
+
+// clearLaunchIntent forgets the launch of the chaincode once it completed,
+// whether or not it succeeded.
+func (cs *ChaincodeSupport) clearLaunchIntent(ccid string) {
+	if err := cs.LaunchIntents.Remove(ccid); err != nil {
+		chaincodeLogger.Warningf("completed launch of chaincode %s may be resumed after a restart: %s", ccid, err)
+	}
+}
+
+// ResumeLaunches launches the chaincodes whose launches were recorded as in
+// progress in the LaunchIntents store, such as launches interrupted by a
+// restart of the peer, and waits for the launches to complete. A failed
+// launch is logged and, like any completed launch, not resumed again.
+func (cs *ChaincodeSupport) ResumeLaunches() error {
+	if cs.LaunchIntents == nil {
+		return nil
+	}
+	intents, err := cs.LaunchIntents.List()
+	if err != nil {
+		return errors.WithMessage(err, "failed to list interrupted launches")
+	}
+
+	var wg sync.WaitGroup
+	for _, intent := range intents {
+		wg.Add(1)
+		go func(intent LaunchIntent) {
+			defer wg.Done()
+			chaincodeLogger.Infof("resuming interrupted launch of chaincode %s", intent.ChaincodeID)
+			if _, err := cs.launch(nil, intent.ChannelID, intent.ChaincodeID, false, chaincodeLogger); err != nil {
+				chaincodeLogger.Warningf("failed to resume launch of chaincode %s: %s", intent.ChaincodeID, err)
+			}
+		}(intent)
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "sync"
+
+// LaunchLimiter bounds the number of chaincode launches which may be in
+// progress at once. When the limit is reached, waiting launches are admitted
+// round-robin across channels rather than strictly in arrival order, so that
+// a burst of launches on one channel cannot starve launches on another.
+// Launches waiting in AcquirePriority are admitted ahead of all others.
+type LaunchLimiter struct {
+	mutex    sync.Mutex
+	limit    int
+	active   int
+	waiters  map[string][]chan struct{}
+	order    []string
+	priority []priorityWaiter
+}
+
+type priorityWaiter struct {
+	channelID string
+	admitted  chan struct{}
+}
+
+// NewLaunchLimiter creates a LaunchLimiter which admits at most limit
+// concurrent launches.
+func NewLaunchLimiter(limit int) *LaunchLimiter {
+	return &LaunchLimiter{
+		limit:   limit,
+		waiters: map[string][]chan struct{}{},
+	}
+}
+
+// Limit returns the maximum number of concurrent launches.
+func (l *LaunchLimiter) Limit() int {
+	return l.limit
+}
+
+// Acquire blocks until a launch slot is available for the given channel.
+// Every call to Acquire must be paired with a call to Release.
+func (l *LaunchLimiter) Acquire(channelID string) {
+	l.AcquireUntil(nil, channelID)
+}
+
+// AcquirePriority is Acquire, except that when the limit is reached the launch
+// is admitted ahead of those waiting in Acquire, in arrival order with other
+// priority launches.
+func (l *LaunchLimiter) AcquirePriority(channelID string) {
+	l.AcquirePriorityUntil(nil, channelID)
+}
+
+// AcquireUntil is Acquire, giving up the wait when done is closed before a
+// slot is available. It returns false, holding no slot, if it gave up; a
+// successful call must be paired with a call to Release.
+func (l *LaunchLimiter) AcquireUntil(done <-chan struct{}, channelID string) bool {
+	return l.acquire(done, channelID, false)
+}
+
+// AcquirePriorityUntil is AcquirePriority, giving up the wait when done is
+// closed before a slot is available.
+func (l *LaunchLimiter) AcquirePriorityUntil(done <-chan struct{}, channelID string) bool {
+	return l.acquire(done, channelID, true)
+}
+
+func (l *LaunchLimiter) acquire(done <-chan struct{}, channelID string, priority bool) bool {
+	l.mutex.Lock()
+	if l.active < l.limit {
+		l.active++
+		l.mutex.Unlock()
+		return true
+	}
+
+	admitted := make(chan struct{})
+	if priority {
+		l.priority = append(l.priority, priorityWaiter{channelID: channelID, admitted: admitted})
+	} else {
+		if len(l.waiters[channelID]) == 0 {
+			l.order = append(l.order, channelID)
+		}
+		l.waiters[channelID] = append(l.waiters[channelID], admitted)
+	}
+	l.mutex.Unlock()
+
+	select {
+	case <-admitted:
+		return true
+	case <-done:
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	select {
+	case <-admitted:
+		// the slot was handed over as the wait was given up
+		l.release()
+	default:
+		l.removeWaiter(channelID, admitted, priority)
+	}
+	return false
+}
+
+// removeWaiter removes a waiter which gave up from its queue.
+func (l *LaunchLimiter) removeWaiter(channelID string, admitted chan struct{}, priority bool) {
+	if priority {
+		for i, w := range l.priority {
+			if w.admitted == admitted {
+				l.priority = append(l.priority[:i:i], l.priority[i+1:]...)
+				return
+			}
+		}
+		return
+	}
+
+	queue := l.waiters[channelID]
+	for i, a := range queue {
+		if a == admitted {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) > 0 {
+		l.waiters[channelID] = queue
+		return
+	}
+	delete(l.waiters, channelID)
+	for i, c := range l.order {
+		if c == channelID {
+			l.order = append(l.order[:i:i], l.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// TryAcquire acquires a launch slot if one is available without waiting. It
+// returns false if all slots are in use. A successful call to TryAcquire must
+// be paired with a call to Release.
+func (l *LaunchLimiter) TryAcquire() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.active < l.limit {
+		l.active++
+		return true
+	}
+	return false
+}
+
+// Release returns a launch slot. If launches are waiting, the slot is handed
+// to the oldest priority waiter or else to the oldest waiter of the next
+// channel in turn.
+func (l *LaunchLimiter) Release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.release()
+}
+
+func (l *LaunchLimiter) release() {
+	if len(l.priority) > 0 {
+		admitted := l.priority[0].admitted
+		l.priority = l.priority[1:]
+		close(admitted)
+		return
+	}
+
+	if len(l.order) == 0 {
+		l.active--
+		return
+	}
+
+	channelID := l.order[0]
+	l.order = l.order[1:]
+	queue := l.waiters[channelID]
+	admitted := queue[0]
+	if len(queue) > 1 {
+		l.waiters[channelID] = queue[1:]
+		l.order = append(l.order, channelID)
+	} else {
+		delete(l.waiters, channelID)
+	}
+
+	close(admitted)
+}
+
+// Waiting returns the number of launches waiting for a slot on the given
+// channel, including priority launches.
+func (l *LaunchLimiter) Waiting(channelID string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	waiting := len(l.waiters[channelID])
+	for _, w := range l.priority {
+		if w.channelID == channelID {
+			waiting++
+		}
+	}
+	return waiting
+}
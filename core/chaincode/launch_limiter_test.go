@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode_test
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LaunchLimiter", func() {
+	var limiter *chaincode.LaunchLimiter
+
+	BeforeEach(func() {
+		limiter = chaincode.NewLaunchLimiter(2)
+	})
+
+	It("admits launches up to the limit without waiting", func() {
+		limiter.Acquire("channel-a")
+		limiter.Acquire("channel-b")
+		Expect(limiter.Waiting("channel-a")).To(Equal(0))
+		Expect(limiter.Waiting("channel-b")).To(Equal(0))
+	})
+
+	It("acquires available slots without waiting", func() {
+		Expect(limiter.TryAcquire()).To(BeTrue())
+		Expect(limiter.TryAcquire()).To(BeTrue())
+		Expect(limiter.TryAcquire()).To(BeFalse())
+
+		limiter.Release()
+		Expect(limiter.TryAcquire()).To(BeTrue())
+	})
+
+	Context("when the limit is saturated", func() {
+		var admitted chan string
+
+		// enqueue acquires a slot for channelID in the background and waits
+		// until it is queued, so the queue order is deterministic.
+		enqueue := func(channelID string) {
+			queued := limiter.Waiting(channelID)
+			go func() {
+				limiter.Acquire(channelID)
+				admitted <- channelID
+			}()
+			Eventually(func() int { return limiter.Waiting(channelID) }).Should(Equal(queued + 1))
+		}
+
+		BeforeEach(func() {
+			admitted = make(chan string, 10)
+			limiter.Acquire("channel-a")
+			limiter.Acquire("channel-a")
+		})
+
+		It("blocks until a slot is released", func() {
+			enqueue("channel-a")
+			Consistently(admitted).ShouldNot(Receive())
+
+			limiter.Release()
+			Eventually(admitted).Should(Receive(Equal("channel-a")))
+			Expect(limiter.Waiting("channel-a")).To(Equal(0))
+		})
+
+		It("does not starve a channel behind another channel's queue", func() {
+			for i := 0; i < 5; i++ {
+				enqueue("channel-a")
+			}
+			enqueue("channel-b")
+
+			limiter.Release()
+			Eventually(admitted).Should(Receive(Equal("channel-a")))
+			limiter.Release()
+			Eventually(admitted).Should(Receive(Equal("channel-b")))
+
+			Expect(limiter.Waiting("channel-a")).To(Equal(4))
+			Expect(limiter.Waiting("channel-b")).To(Equal(0))
+		})
+
+		It("interleaves waiting launches across channels", func() {
+			enqueue("channel-a")
+			enqueue("channel-a")
+			enqueue("channel-b")
+			enqueue("channel-b")
+
+			var order []string
+			for i := 0; i < 4; i++ {
+				limiter.Release()
+				var channelID string
+				Eventually(admitted).Should(Receive(&channelID))
+				order = append(order, channelID)
+			}
+			Expect(order).To(Equal([]string{"channel-a", "channel-b", "channel-a", "channel-b"}))
+		})
+
+		It("admits priority launches ahead of waiting launches", func() {
+			enqueue("channel-a")
+			queued := limiter.Waiting("channel-b")
+			go func() {
+				limiter.AcquirePriority("channel-b")
+				admitted <- "priority"
+			}()
+			Eventually(func() int { return limiter.Waiting("channel-b") }).Should(Equal(queued + 1))
+
+			limiter.Release()
+			Eventually(admitted).Should(Receive(Equal("priority")))
+			limiter.Release()
+			Eventually(admitted).Should(Receive(Equal("channel-a")))
+		})
+
+		It("gives up waiting when done is closed", func() {
+			done := make(chan struct{})
+			acquired := make(chan bool, 1)
+			go func() {
+				acquired <- limiter.AcquireUntil(done, "channel-a")
+			}()
+			Eventually(func() int { return limiter.Waiting("channel-a") }).Should(Equal(1))
+
+			close(done)
+			Eventually(acquired).Should(Receive(BeFalse()))
+			Expect(limiter.Waiting("channel-a")).To(Equal(0))
+
+			enqueue("channel-b")
+			limiter.Release()
+			Eventually(admitted).Should(Receive(Equal("channel-b")))
+		})
+
+		It("gives up a priority wait when done is closed", func() {
+			done := make(chan struct{})
+			acquired := make(chan bool, 1)
+			go func() {
+				acquired <- limiter.AcquirePriorityUntil(done, "channel-a")
+			}()
+			Eventually(func() int { return limiter.Waiting("channel-a") }).Should(Equal(1))
+
+			close(done)
+			Eventually(acquired).Should(Receive(BeFalse()))
+			Expect(limiter.Waiting("channel-a")).To(Equal(0))
+
+			limiter.Release()
+			Expect(limiter.TryAcquire()).To(BeTrue())
+			Expect(limiter.TryAcquire()).To(BeFalse())
+		})
+	})
+})
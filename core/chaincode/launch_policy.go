@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrLaunchInProgress is returned for invocations rejected because the
+// chaincode is already being launched for another caller.
+var ErrLaunchInProgress = errors.New("chaincode launch in progress")
+
+// LaunchPolicy determines what happens to an invocation of a chaincode which
+// is already being launched for another caller.
+type LaunchPolicy string
+
+const (
+	// WaitDuringLaunch makes the invocation wait for the launch in progress
+	// to complete. This is the default.
+	WaitDuringLaunch LaunchPolicy = "wait"
+	// RejectDuringLaunch fails the invocation with ErrLaunchInProgress so
+	// that invocations do not pile up behind a slow launch.
+	RejectDuringLaunch LaunchPolicy = "reject"
+)
+
+// launchesInProgress counts the callers launching each chaincode. The zero
+// value is ready to use.
+type launchesInProgress struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+// begin records a caller launching the chaincode. When exclusive is set and
+// the chaincode is already being launched, nothing is recorded and begin
+// returns false. first reports whether no other caller was launching the
+// chaincode.
+func (l *launchesInProgress) begin(ccid string, exclusive bool) (admitted, first bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if exclusive && l.counts[ccid] > 0 {
+		return false, false
+	}
+	if l.counts == nil {
+		l.counts = map[string]int{}
+	}
+	l.counts[ccid]++
+	return true, l.counts[ccid] == 1
+}
+
+func (l *launchesInProgress) end(ccid string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.counts[ccid]--
+	if l.counts[ccid] <= 0 {
+		delete(l.counts, ccid)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/extcc"
+)
+
+// LaunchTimings is the breakdown of the time taken by a chaincode launch. A
+// phase the launch did not reach is zero, and the time spent in a phase which
+// was still in progress when the launch completed is attributed to
+// Registration.
+type LaunchTimings struct {
+	// QueueWait is the time spent waiting for a launch slot.
+	QueueWait time.Duration
+	// Jitter is the random delay applied before the launch started.
+	Jitter time.Duration
+	// Build is the time taken to build the chaincode or fetch its package.
+	Build time.Duration
+	// Start is the time taken to start the chaincode container.
+	Start time.Duration
+	// Registration is the time spent waiting for the chaincode to register
+	// once the previous phases completed.
+	Registration time.Duration
+	// Total is the duration of the whole launch.
+	Total time.Duration
+}
+
+// launchTimer records the phases of a launch as they complete, from the
+// launching goroutine and from the goroutine starting the runtime.
+type launchTimer struct {
+	mutex      sync.Mutex
+	started    time.Time
+	phaseStart time.Time
+	timings    LaunchTimings
+}
+
+func newLaunchTimer(started time.Time) *launchTimer {
+	return &launchTimer{
+		started:    started,
+		phaseStart: started,
+	}
+}
+
+// end records the time since the previous phase ended as the duration of
+// phase, which must be a field of the timer's timings.
+func (t *launchTimer) end(phase *time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	*phase = now.Sub(t.phaseStart)
+	t.phaseStart = now
+}
+
+// complete ends the registration phase and returns the timings of the launch.
+func (t *launchTimer) complete() *LaunchTimings {
+	t.end(&t.timings.Registration)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	timings := t.timings
+	timings.Total = t.phaseStart.Sub(t.started)
+	return &timings
+}
+
+// LaunchWithTimings is Launch, additionally returning how long each phase of
+// the launch took. When the chaincode was already launching, all of the time
+// is spent waiting for its registration.
+func (r *RuntimeLauncher) LaunchWithTimings(ccid string, streamHandler extcc.StreamHandler) (*LaunchTimings, error) {
+	return r.launch(nil, "", ccid, streamHandler, false, false)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+
+	commonledger "github.com/hyperledger/fabric/common/ledger"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/pkg/errors"
+)
+
+// ErrLifecycleTimeout is returned when the lifecycle does not resolve a
+// chaincode within the LifecycleLookupTimeout.
+var ErrLifecycleTimeout = errors.New("lifecycle lookup timed out")
+
+type endorsementInfoResult struct {
+	cii *lifecycle.ChaincodeEndorsementInfo
+	err error
+}
+
+// endorsementInfo resolves the chaincode through the lifecycle. When
+// LifecycleLookupTimeout is set, a lookup which does not complete in time,
+// for example because the ledger is slow, fails with ErrLifecycleTimeout.
+//
+// The lookup reads through the caller's query executor so that the reads of
+// the chaincode definition are kept in the transaction's read set. On timeout
+// the query executor is released from the abandoned lookup: a ledger read
+// already in progress is waited for, and any later read fails, so the caller
+// is free to continue using or to close the query executor.
+func (cs *ChaincodeSupport) endorsementInfo(channelID, chaincodeName string, qe ledger.SimpleQueryExecutor) (*lifecycle.ChaincodeEndorsementInfo, error) {
+	if cs.LifecycleLookupTimeout <= 0 {
+		return cs.Lifecycle.ChaincodeEndorsementInfo(channelID, chaincodeName, qe)
+	}
+
+	var lookupQE *lookupQueryExecutor
+	if qe != nil {
+		lookupQE = &lookupQueryExecutor{qe: qe}
+		qe = lookupQE
+	}
+
+	resultCh := make(chan endorsementInfoResult, 1)
+	go func() {
+		cii, err := cs.Lifecycle.ChaincodeEndorsementInfo(channelID, chaincodeName, qe)
+		resultCh <- endorsementInfoResult{cii: cii, err: err}
+	}()
+
+	timer := time.NewTimer(cs.LifecycleLookupTimeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-resultCh:
+		return result.cii, result.err
+	case <-timer.C:
+		if lookupQE != nil {
+			lookupQE.release()
+		}
+		return nil, errors.WithMessagef(ErrLifecycleTimeout, "lookup of chaincode %s on channel %s did not complete within %s", chaincodeName, channelID, cs.LifecycleLookupTimeout)
+	}
+}
+
+// lookupQueryExecutor serializes a lifecycle lookup's use of the caller's
+// query executor so that it can be released when the lookup is abandoned.
+type lookupQueryExecutor struct {
+	mutex    sync.Mutex
+	qe       ledger.SimpleQueryExecutor
+	released bool
+}
+
+// release waits for any read in progress and fails all later reads.
+func (l *lookupQueryExecutor) release() {
+	l.mutex.Lock()
+	l.released = true
+	l.mutex.Unlock()
+}
+
+func (l *lookupQueryExecutor) GetState(namespace, key string) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		return nil, ErrLifecycleTimeout
+	}
+	return l.qe.GetState(namespace, key)
+}
+
+func (l *lookupQueryExecutor) GetStateRangeScanIterator(namespace, startKey, endKey string) (commonledger.ResultsIterator, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		return nil, ErrLifecycleTimeout
+	}
+	itr, err := l.qe.GetStateRangeScanIterator(namespace, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return &lookupResultsIterator{lookupQE: l, itr: itr}, nil
+}
+
+func (l *lookupQueryExecutor) GetPrivateDataHash(namespace, collection, key string) ([]byte, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released {
+		return nil, ErrLifecycleTimeout
+	}
+	return l.qe.GetPrivateDataHash(namespace, collection, key)
+}
+
+// lookupResultsIterator guards an iterator obtained through a
+// lookupQueryExecutor in the same way as the query executor itself.
+type lookupResultsIterator struct {
+	lookupQE *lookupQueryExecutor
+	itr      commonledger.ResultsIterator
+}
+
+func (l *lookupResultsIterator) Next() (commonledger.QueryResult, error) {
+	l.lookupQE.mutex.Lock()
+	defer l.lookupQE.mutex.Unlock()
+	if l.lookupQE.released {
+		return nil, ErrLifecycleTimeout
+	}
+	return l.itr.Next()
+}
+
+func (l *lookupResultsIterator) Close() {
+	l.lookupQE.mutex.Lock()
+	defer l.lookupQE.mutex.Unlock()
+	if l.lookupQE.released {
+		return
+	}
+	l.itr.Close()
+}
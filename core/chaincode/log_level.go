@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"github.com/hyperledger/fabric-lib-go/common/flogging"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevelDecoration is the proposal decoration which requests a log level
+// for the peer's log lines of an invocation. A decoration plugin may set it
+// for the transactions an operator is debugging.
+const LogLevelDecoration = "log_level"
+
+// txLogger returns the logger for the log lines of an invocation. When the
+// invocation requests a log level, lines at or above that level are logged
+// for the invocation even if the chaincode logger is less verbose.
+func txLogger(level string) *flogging.FabricLogger {
+	if level == "" {
+		return chaincodeLogger
+	}
+	if !flogging.IsValidLevel(level) {
+		chaincodeLogger.Warningf("ignoring invalid log level %s requested for invocation", level)
+		return chaincodeLogger
+	}
+
+	override := flogging.NameToLevel(level)
+	return chaincodeLogger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &levelOverrideCore{Core: core, level: override}
+	}))
+}
+
+// levelOverrideCore enables the entries at or above level in addition to
+// those enabled by the wrapped core.
+type levelOverrideCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+func (c *levelOverrideCore) Enabled(l zapcore.Level) bool {
+	return c.level.Enabled(l) || c.Core.Enabled(l)
+}
+
+func (c *levelOverrideCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelOverrideCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelOverrideCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.level.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return c.Core.Check(e, ce)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMemoryBudgetExceeded is returned when starting another chaincode
+// container would exceed the peer-wide memory budget.
+var ErrMemoryBudgetExceeded = errors.New("chaincode memory budget exceeded")
+
+const bytesPerMB = 1 << 20
+
+// A MemoryBudget caps the sum of the memory limits of the chaincode
+// containers running at once. Each container reserves its memory limit from
+// the budget when it starts and returns it when it exits.
+type MemoryBudget struct {
+	mutex     sync.Mutex
+	total     uint64
+	container uint64
+	reserved  map[string]uint64
+	used      uint64
+}
+
+// NewMemoryBudget creates a budget of total bytes shared by containers which
+// are each limited to container bytes.
+func NewMemoryBudget(total, container uint64) *MemoryBudget {
+	return &MemoryBudget{
+		total:     total,
+		container: container,
+		reserved:  map[string]uint64{},
+	}
+}
+
+// Reserve reserves the memory limit of a container for the chaincode, or
+// returns ErrMemoryBudgetExceeded if the budget cannot accommodate it. A
+// chaincode which already holds a reservation is not charged again.
+func (b *MemoryBudget) Reserve(ccid string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.reserved[ccid]; ok {
+		return nil
+	}
+	if b.used+b.container > b.total {
+		return errors.WithMessagef(ErrMemoryBudgetExceeded,
+			"cannot start chaincode %s with a memory limit of %d MB: %d of %d MB are reserved by running chaincodes",
+			ccid, b.container/bytesPerMB, b.used/bytesPerMB, b.total/bytesPerMB)
+	}
+	b.reserved[ccid] = b.container
+	b.used += b.container
+	return nil
+}
+
+// Release returns the memory reserved for the chaincode to the budget.
+func (b *MemoryBudget) Release(ccid string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.used -= b.reserved[ccid]
+	delete(b.reserved, ccid)
+}
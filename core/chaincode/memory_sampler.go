@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+)
+
+// memorySamples holds the latest memory usage sampled for each running
+// chaincode. The zero value is ready to use.
+type memorySamples struct {
+	mutex sync.RWMutex
+	usage map[string]uint64
+}
+
+func (m *memorySamples) get(ccid string) (uint64, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	usage, ok := m.usage[ccid]
+	return usage, ok
+}
+
+func (m *memorySamples) replace(usage map[string]uint64) {
+	m.mutex.Lock()
+	m.usage = usage
+	m.mutex.Unlock()
+}
+
+// SampleMemory samples the memory usage of the running user chaincodes. The
+// samples replace those of the previous call, so chaincodes which are no
+// longer running, or whose usage the runtime cannot report, have none.
+// Sampling waits on the runtime, which for docker takes on the order of a
+// second per chaincode, so executions only read the latest samples.
+func (cs *ChaincodeSupport) SampleMemory() {
+	usage := map[string]uint64{}
+	for _, ccid := range cs.HandlerRegistry.Registered() {
+		if cs.isSysCCID(ccid) {
+			continue
+		}
+		sample, ok, err := cs.Runtime.MemoryUsage(ccid)
+		if err != nil {
+			chaincodeLogger.Debugf("could not determine memory usage of chaincode %s: %s", ccid, err)
+			continue
+		}
+		if ok {
+			usage[ccid] = sample
+		}
+	}
+	cs.memorySamples.replace(usage)
+}
+
+// SampleMemoryEvery calls SampleMemory every interval until done is closed.
+// A nil done channel samples for the life of the peer.
+func (cs *ChaincodeSupport) SampleMemoryEvery(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cs.SampleMemory()
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
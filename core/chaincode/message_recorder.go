@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/core/common/ccprovider"
+	"github.com/pkg/errors"
+)
+
+// A RecordedExchange is an execution of a chaincode as seen on the wire: the
+// INIT or TRANSACTION message sent to the chaincode and the message, typically
+// COMPLETED or ERROR, it sent back. Response is nil when the execution failed
+// without a response, in which case Err is the failure.
+type RecordedExchange struct {
+	ChaincodeName string
+	ChannelID     string
+	TxID          string
+	Request       *pb.ChaincodeMessage
+	Response      *pb.ChaincodeMessage
+	Err           error
+}
+
+// A MessageRecorder is called with the exchange of each chaincode execution,
+// for example to capture invocations for debugging or regression testing.
+// Recording is disabled when no recorder is configured.
+type MessageRecorder func(RecordedExchange)
+
+// record passes the exchange of an execution to the MessageRecorder.
+func (cs *ChaincodeSupport) record(txParams *ccprovider.TransactionParams, namespace string, req, resp *pb.ChaincodeMessage, err error) {
+	exchange := RecordedExchange{
+		ChaincodeName: namespace,
+		ChannelID:     txParams.ChannelID,
+		TxID:          txParams.TxID,
+		Request:       proto.Clone(req).(*pb.ChaincodeMessage),
+		Err:           err,
+	}
+	if resp != nil {
+		exchange.Response = proto.Clone(resp).(*pb.ChaincodeMessage)
+	}
+	cs.MessageRecorder(exchange)
+}
+
+// Replay executes the request of a recorded exchange again through
+// ExecuteMessage as part of the transaction of txParams, which need not be the
+// transaction the exchange was recorded in. The returned message is exactly
+// what the chaincode sent back.
+func (cs *ChaincodeSupport) Replay(txParams *ccprovider.TransactionParams, exchange RecordedExchange) (*pb.ChaincodeMessage, error) {
+	if exchange.Request == nil {
+		return nil, errors.Errorf("recorded exchange of chaincode %s has no request", exchange.ChaincodeName)
+	}
+
+	msg := proto.Clone(exchange.Request).(*pb.ChaincodeMessage)
+	msg.Txid = txParams.TxID
+	msg.ChannelId = txParams.ChannelID
+	return cs.ExecuteMessage(txParams, exchange.ChaincodeName, msg)
+}
@@ -16,6 +16,13 @@ var (
 		LabelNames:   []string{"chaincode", "success"},
 		StatsdFormat: "%{#fqname}.%{chaincode}.%{success}",
 	}
+	launchQueueWait = metrics.HistogramOpts{
+		Namespace:    "chaincode",
+		Name:         "launch_queue_wait",
+		Help:         "The time a chaincode launch waited for a slot under the launch concurrency limit.",
+		LabelNames:   []string{"chaincode"},
+		StatsdFormat: "%{#fqname}.%{chaincode}",
+	}
 	launchFailures = metrics.CounterOpts{
 		Namespace:    "chaincode",
 		Name:         "launch_failures",
@@ -59,34 +66,192 @@ var (
 		LabelNames:   []string{"chaincode"},
 		StatsdFormat: "%{#fqname}.%{chaincode}",
 	}
+	responseSize = metrics.HistogramOpts{
+		Namespace:    "chaincode",
+		Name:         "response_size",
+		Help:         "The size in bytes of completed chaincode response payloads.",
+		LabelNames:   []string{"chaincode"},
+		StatsdFormat: "%{#fqname}.%{chaincode}",
+		Buckets:      []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304},
+	}
+	messageMarshalFailures = metrics.CounterOpts{
+		Namespace:    "chaincode",
+		Name:         "message_marshal_failures",
+		Help:         "The number of chaincode messages whose input could not be marshaled.",
+		LabelNames:   []string{"chaincode"},
+		StatsdFormat: "%{#fqname}.%{chaincode}",
+	}
+	channelExecutionsInFlight = metrics.GaugeOpts{
+		Namespace:    "chaincode",
+		Name:         "channel_executions_in_flight",
+		Help:         "The number of transactions executing chaincode on a channel.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+	channelExecuteRejections = metrics.CounterOpts{
+		Namespace:    "chaincode",
+		Name:         "channel_execute_rejections",
+		Help:         "The number of chaincode executions rejected because their channel was at its concurrency limit.",
+		LabelNames:   []string{"channel"},
+		StatsdFormat: "%{#fqname}.%{channel}",
+	}
+	executeSoftLimitExceeded = metrics.CounterOpts{
+		Namespace:    "chaincode",
+		Name:         "execute_soft_limit_exceeded",
+		Help:         "The number of chaincode executions admitted while more executions than the soft concurrency limit were in progress.",
+		StatsdFormat: "%{#fqname}",
+	}
+	executeQueueDepth = metrics.GaugeOpts{
+		Namespace:    "chaincode",
+		Name:         "execute_queue_depth",
+		Help:         "The number of chaincode executions waiting for the execution concurrency limit.",
+		StatsdFormat: "%{#fqname}",
+	}
+	systemExecuteQueueDepth = metrics.GaugeOpts{
+		Namespace:    "chaincode",
+		Name:         "system_execute_queue_depth",
+		Help:         "The number of system chaincode executions waiting for the reserved system execution capacity.",
+		StatsdFormat: "%{#fqname}",
+	}
+	handlerStates = metrics.GaugeOpts{
+		Namespace:    "chaincode",
+		Name:         "handler_states",
+		Help:         "The number of chaincode handlers in each state of registration.",
+		LabelNames:   []string{"state"},
+		StatsdFormat: "%{#fqname}.%{state}",
+	}
 )
 
+// A MetricLabeler derives additional labels that are applied to chaincode
+// execute and launch metrics so that they can be aggregated by attributes
+// such as organization or collection.
+type MetricLabeler interface {
+	// LabelNames returns the names of the additional labels.
+	LabelNames() []string
+	// LabelValues returns the values of the additional labels, in the same
+	// order as LabelNames, for a chaincode on a channel. For launch metrics
+	// the channel is that of the invocation which launched the chaincode, and
+	// is empty for launches made on behalf of no particular channel.
+	LabelValues(channelID, ccid string) []string
+}
+
+// ChannelMetricLabeler is a MetricLabeler which labels metrics with the
+// channel of the execution or launch.
+type ChannelMetricLabeler struct{}
+
+// LabelNames returns the name of the channel label.
+func (ChannelMetricLabeler) LabelNames() []string {
+	return []string{"channel"}
+}
+
+// LabelValues returns the channel ID.
+func (ChannelMetricLabeler) LabelValues(channelID, ccid string) []string {
+	return []string{channelID}
+}
+
 type HandlerMetrics struct {
-	ShimRequestsReceived  metrics.Counter
-	ShimRequestsCompleted metrics.Counter
-	ShimRequestDuration   metrics.Histogram
-	ExecuteTimeouts       metrics.Counter
+	ShimRequestsReceived      metrics.Counter
+	ShimRequestsCompleted     metrics.Counter
+	ShimRequestDuration       metrics.Histogram
+	ExecuteTimeouts           metrics.Counter
+	ResponseSize              metrics.Histogram
+	ExecuteQueueDepth         metrics.Gauge
+	SystemExecuteQueueDepth   metrics.Gauge
+	MessageMarshalFailures    metrics.Counter
+	ChannelExecutionsInFlight metrics.Gauge
+	ChannelExecuteRejections  metrics.Counter
+	HandlerStates             metrics.Gauge
+	ExecuteSoftLimitExceeded  metrics.Counter
+	// Labeler derives additional labels for the execute metrics. It must be
+	// the labeler the metrics were created with.
+	Labeler MetricLabeler
 }
 
 func NewHandlerMetrics(p metrics.Provider) *HandlerMetrics {
+	return NewLabeledHandlerMetrics(p, nil)
+}
+
+// NewLabeledHandlerMetrics creates handler metrics where the execute metrics
+// carry the additional labels derived by the labeler.
+func NewLabeledHandlerMetrics(p metrics.Provider, l MetricLabeler) *HandlerMetrics {
 	return &HandlerMetrics{
-		ShimRequestsReceived:  p.NewCounter(shimRequestsReceived),
-		ShimRequestsCompleted: p.NewCounter(shimRequestsCompleted),
-		ShimRequestDuration:   p.NewHistogram(shimRequestDuration),
-		ExecuteTimeouts:       p.NewCounter(executeTimeouts),
+		ShimRequestsReceived:      p.NewCounter(shimRequestsReceived),
+		ShimRequestsCompleted:     p.NewCounter(shimRequestsCompleted),
+		ShimRequestDuration:       p.NewHistogram(shimRequestDuration),
+		ExecuteTimeouts:           p.NewCounter(labeledCounterOpts(executeTimeouts, l)),
+		ResponseSize:              p.NewHistogram(responseSize),
+		ExecuteQueueDepth:         p.NewGauge(executeQueueDepth),
+		MessageMarshalFailures:    p.NewCounter(messageMarshalFailures),
+		ChannelExecutionsInFlight: p.NewGauge(channelExecutionsInFlight),
+		ChannelExecuteRejections:  p.NewCounter(channelExecuteRejections),
+		HandlerStates:             p.NewGauge(handlerStates),
+		SystemExecuteQueueDepth:   p.NewGauge(systemExecuteQueueDepth),
+		ExecuteSoftLimitExceeded:  p.NewCounter(executeSoftLimitExceeded),
+		Labeler:                   l,
 	}
 }
 
 type LaunchMetrics struct {
-	LaunchDuration metrics.Histogram
-	LaunchFailures metrics.Counter
-	LaunchTimeouts metrics.Counter
+	LaunchDuration  metrics.Histogram
+	LaunchQueueWait metrics.Histogram
+	LaunchFailures  metrics.Counter
+	LaunchTimeouts  metrics.Counter
+	// Labeler derives additional labels for the launch metrics. It must be
+	// the labeler the metrics were created with.
+	Labeler MetricLabeler
 }
 
 func NewLaunchMetrics(p metrics.Provider) *LaunchMetrics {
+	return NewLabeledLaunchMetrics(p, nil)
+}
+
+// NewLabeledLaunchMetrics creates launch metrics that carry the additional
+// labels derived by the labeler.
+func NewLabeledLaunchMetrics(p metrics.Provider, l MetricLabeler) *LaunchMetrics {
 	return &LaunchMetrics{
-		LaunchDuration: p.NewHistogram(launchDuration),
-		LaunchFailures: p.NewCounter(launchFailures),
-		LaunchTimeouts: p.NewCounter(launchTimeouts),
+		LaunchDuration:  p.NewHistogram(labeledHistogramOpts(launchDuration, l)),
+		LaunchQueueWait: p.NewHistogram(labeledHistogramOpts(launchQueueWait, l)),
+		LaunchFailures:  p.NewCounter(labeledCounterOpts(launchFailures, l)),
+		LaunchTimeouts:  p.NewCounter(labeledCounterOpts(launchTimeouts, l)),
+		Labeler:         l,
+	}
+}
+
+func labeledCounterOpts(opts metrics.CounterOpts, l MetricLabeler) metrics.CounterOpts {
+	opts.LabelNames, opts.StatsdFormat = extendLabels(opts.LabelNames, opts.StatsdFormat, l)
+	return opts
+}
+
+func labeledHistogramOpts(opts metrics.HistogramOpts, l MetricLabeler) metrics.HistogramOpts {
+	opts.LabelNames, opts.StatsdFormat = extendLabels(opts.LabelNames, opts.StatsdFormat, l)
+	return opts
+}
+
+func extendLabels(labelNames []string, statsdFormat string, l MetricLabeler) ([]string, string) {
+	if l == nil {
+		return labelNames, statsdFormat
+	}
+	names := append([]string{}, labelNames...)
+	for _, name := range l.LabelNames() {
+		names = append(names, name)
+		statsdFormat += ".%{" + name + "}"
+	}
+	return names, statsdFormat
+}
+
+// metricLabels appends the label name and value pairs derived by the labeler
+// to the provided label name and value pairs.
+func metricLabels(l MetricLabeler, channelID, ccid string, labels ...string) []string {
+	if l == nil {
+		return labels
+	}
+	values := l.LabelValues(channelID, ccid)
+	for i, name := range l.LabelNames() {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		labels = append(labels, name, value)
 	}
+	return labels
 }
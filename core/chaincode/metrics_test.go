@@ -0,0 +1,169 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode_test
+
+import (
+	"github.com/hyperledger/fabric-lib-go/common/metrics"
+	"github.com/hyperledger/fabric-lib-go/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/core/chaincode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type orgLabeler struct {
+	org string
+}
+
+func (o *orgLabeler) LabelNames() []string {
+	return []string{"org", "channel"}
+}
+
+func (o *orgLabeler) LabelValues(channelID, ccid string) []string {
+	return []string{o.org, channelID}
+}
+
+var _ = Describe("Metrics", func() {
+	var fakeProvider *metricsfakes.Provider
+
+	BeforeEach(func() {
+		fakeProvider = &metricsfakes.Provider{}
+		fakeProvider.NewCounterReturns(&metricsfakes.Counter{})
+		fakeProvider.NewHistogramReturns(&metricsfakes.Histogram{})
+		fakeProvider.NewGaugeReturns(&metricsfakes.Gauge{})
+	})
+
+	Describe("NewLabeledHandlerMetrics", func() {
+		It("adds the derived labels to the execute metrics", func() {
+			labeler := &orgLabeler{}
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, labeler)
+			Expect(handlerMetrics.Labeler).To(Equal(labeler))
+
+			Expect(fakeProvider.NewCounterCallCount()).To(Equal(6))
+			opts := fakeProvider.NewCounterArgsForCall(2)
+			Expect(opts.Name).To(Equal("execute_timeouts"))
+			Expect(opts.LabelNames).To(Equal([]string{"chaincode", "org", "channel"}))
+			Expect(opts.StatsdFormat).To(Equal("%{#fqname}.%{chaincode}.%{org}.%{channel}"))
+		})
+
+		It("creates the response size metric", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.ResponseSize).NotTo(BeNil())
+
+			Expect(fakeProvider.NewHistogramCallCount()).To(Equal(2))
+			opts := fakeProvider.NewHistogramArgsForCall(1)
+			Expect(opts.Name).To(Equal("response_size"))
+			Expect(opts.LabelNames).To(Equal([]string{"chaincode"}))
+		})
+
+		It("creates the execute queue depth metric", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.ExecuteQueueDepth).NotTo(BeNil())
+
+			Expect(fakeProvider.NewGaugeCallCount()).To(Equal(4))
+			opts := fakeProvider.NewGaugeArgsForCall(0)
+			Expect(opts.Name).To(Equal("execute_queue_depth"))
+			Expect(opts.LabelNames).To(BeEmpty())
+		})
+
+		It("creates the message marshal failures metric", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.MessageMarshalFailures).NotTo(BeNil())
+
+			opts := fakeProvider.NewCounterArgsForCall(3)
+			Expect(opts.Name).To(Equal("message_marshal_failures"))
+			Expect(opts.LabelNames).To(Equal([]string{"chaincode"}))
+		})
+
+		It("creates the channel concurrency metrics", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.ChannelExecutionsInFlight).NotTo(BeNil())
+			Expect(handlerMetrics.ChannelExecuteRejections).NotTo(BeNil())
+
+			gopts := fakeProvider.NewGaugeArgsForCall(1)
+			Expect(gopts.Name).To(Equal("channel_executions_in_flight"))
+			Expect(gopts.LabelNames).To(Equal([]string{"channel"}))
+			copts := fakeProvider.NewCounterArgsForCall(4)
+			Expect(copts.Name).To(Equal("channel_execute_rejections"))
+			Expect(copts.LabelNames).To(Equal([]string{"channel"}))
+		})
+
+		It("creates the handler states metric", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.HandlerStates).NotTo(BeNil())
+
+			opts := fakeProvider.NewGaugeArgsForCall(2)
+			Expect(opts.Name).To(Equal("handler_states"))
+			Expect(opts.LabelNames).To(Equal([]string{"state"}))
+		})
+
+		It("creates the execute soft limit metric", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.ExecuteSoftLimitExceeded).NotTo(BeNil())
+
+			opts := fakeProvider.NewCounterArgsForCall(5)
+			Expect(opts.Name).To(Equal("execute_soft_limit_exceeded"))
+			Expect(opts.LabelNames).To(BeEmpty())
+		})
+
+		It("creates the system execute queue depth metric", func() {
+			handlerMetrics := chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			Expect(handlerMetrics.SystemExecuteQueueDepth).NotTo(BeNil())
+
+			opts := fakeProvider.NewGaugeArgsForCall(3)
+			Expect(opts.Name).To(Equal("system_execute_queue_depth"))
+			Expect(opts.LabelNames).To(BeEmpty())
+		})
+
+		It("does not modify the shim request metrics", func() {
+			chaincode.NewLabeledHandlerMetrics(fakeProvider, &orgLabeler{})
+			opts := fakeProvider.NewCounterArgsForCall(0)
+			Expect(opts.LabelNames).To(Equal([]string{"type", "channel", "chaincode"}))
+		})
+	})
+
+	Describe("ChannelMetricLabeler", func() {
+		It("labels metrics with the channel", func() {
+			labeler := chaincode.ChannelMetricLabeler{}
+			Expect(labeler.LabelNames()).To(Equal([]string{"channel"}))
+			Expect(labeler.LabelValues("channel-id", "chaincode-id")).To(Equal([]string{"channel-id"}))
+		})
+	})
+
+	Describe("NewLabeledLaunchMetrics", func() {
+		It("adds the derived labels to the launch metrics", func() {
+			chaincode.NewLabeledLaunchMetrics(fakeProvider, &orgLabeler{})
+
+			Expect(fakeProvider.NewHistogramCallCount()).To(Equal(2))
+			hopts := fakeProvider.NewHistogramArgsForCall(0)
+			Expect(hopts.LabelNames).To(Equal([]string{"chaincode", "success", "org", "channel"}))
+			hopts = fakeProvider.NewHistogramArgsForCall(1)
+			Expect(hopts.Name).To(Equal("launch_queue_wait"))
+			Expect(hopts.LabelNames).To(Equal([]string{"chaincode", "org", "channel"}))
+
+			Expect(fakeProvider.NewCounterCallCount()).To(Equal(2))
+			for i := 0; i < 2; i++ {
+				copts := fakeProvider.NewCounterArgsForCall(i)
+				Expect(copts.LabelNames).To(Equal([]string{"chaincode", "org", "channel"}))
+			}
+		})
+	})
+
+	Describe("NewLaunchMetrics", func() {
+		It("uses the default labels", func() {
+			launchMetrics := chaincode.NewLaunchMetrics(fakeProvider)
+			Expect(launchMetrics.Labeler).To(BeNil())
+
+			var opts []metrics.CounterOpts
+			for i := 0; i < fakeProvider.NewCounterCallCount(); i++ {
+				opts = append(opts, fakeProvider.NewCounterArgsForCall(i))
+			}
+			Expect(opts).To(HaveLen(2))
+			Expect(opts[0].LabelNames).To(Equal([]string{"chaincode"}))
+			Expect(opts[1].LabelNames).To(Equal([]string{"chaincode"}))
+		})
+	})
+})
@@ -0,0 +1,37 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric/core/common/ccprovider"
+)
+
+// An InvokeFunc invokes a chaincode and returns its raw response message, as
+// ChaincodeSupport.Invoke does.
+type InvokeFunc func(txParams *ccprovider.TransactionParams, chaincodeName string, input *pb.ChaincodeInput) (*pb.ChaincodeMessage, error)
+
+// An InvokeMiddleware wraps the invocation of a chaincode. It may act before
+// and after calling next, or fail the invocation without calling it.
+type InvokeMiddleware func(next InvokeFunc) InvokeFunc
+
+// Use adds a middleware around invocations of chaincodes, including those
+// made by Execute. Middlewares run in the order they were added; the first is
+// outermost. Use must not be called concurrently with invocations, and so is
+// meant to be called while the peer starts.
+func (cs *ChaincodeSupport) Use(mw InvokeMiddleware) {
+	cs.middlewares = append(cs.middlewares, mw)
+}
+
+// chain wraps the core invocation in the middlewares.
+func (cs *ChaincodeSupport) chain(core InvokeFunc) InvokeFunc {
+	invoke := core
+	for i := len(cs.middlewares) - 1; i >= 0; i-- {
+		invoke = cs.middlewares[i](invoke)
+	}
+	return invoke
+}
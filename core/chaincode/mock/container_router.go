@@ -3,6 +3,7 @@ package mock
 
 import (
 	"sync"
+	"time"
 
 	"github.com/hyperledger/fabric/core/container/ccintf"
 )
@@ -32,6 +33,47 @@ type ContainerRouter struct {
 		result1 *ccintf.ChaincodeServerInfo
 		result2 error
 	}
+	ImageDigestStub        func(string) (string, error)
+	imageDigestMutex       sync.RWMutex
+	imageDigestArgsForCall []struct {
+		arg1 string
+	}
+	imageDigestReturns struct {
+		result1 string
+		result2 error
+	}
+	imageDigestReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	MemoryUsageStub        func(string) (uint64, bool, error)
+	memoryUsageMutex       sync.RWMutex
+	memoryUsageArgsForCall []struct {
+		arg1 string
+	}
+	memoryUsageReturns struct {
+		result1 uint64
+		result2 bool
+		result3 error
+	}
+	memoryUsageReturnsOnCall map[int]struct {
+		result1 uint64
+		result2 bool
+		result3 error
+	}
+	ProbeStub        func(string, []string, time.Duration) error
+	probeMutex       sync.RWMutex
+	probeArgsForCall []struct {
+		arg1 string
+		arg2 []string
+		arg3 time.Duration
+	}
+	probeReturns struct {
+		result1 error
+	}
+	probeReturnsOnCall map[int]struct {
+		result1 error
+	}
 	StartStub        func(string, *ccintf.PeerConnection) error
 	startMutex       sync.RWMutex
 	startArgsForCall []struct {
@@ -78,15 +120,16 @@ func (fake *ContainerRouter) Build(arg1 string) error {
 	fake.buildArgsForCall = append(fake.buildArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.BuildStub
+	fakeReturns := fake.buildReturns
 	fake.recordInvocation("Build", []interface{}{arg1})
 	fake.buildMutex.Unlock()
-	if fake.BuildStub != nil {
-		return fake.BuildStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.buildReturns
 	return fakeReturns.result1
 }
 
@@ -138,15 +181,16 @@ func (fake *ContainerRouter) ChaincodeServerInfo(arg1 string) (*ccintf.Chaincode
 	fake.chaincodeServerInfoArgsForCall = append(fake.chaincodeServerInfoArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.ChaincodeServerInfoStub
+	fakeReturns := fake.chaincodeServerInfoReturns
 	fake.recordInvocation("ChaincodeServerInfo", []interface{}{arg1})
 	fake.chaincodeServerInfoMutex.Unlock()
-	if fake.ChaincodeServerInfoStub != nil {
-		return fake.ChaincodeServerInfoStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.chaincodeServerInfoReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -195,6 +239,205 @@ func (fake *ContainerRouter) ChaincodeServerInfoReturnsOnCall(i int, result1 *cc
 	}{result1, result2}
 }
 
+func (fake *ContainerRouter) ImageDigest(arg1 string) (string, error) {
+	fake.imageDigestMutex.Lock()
+	ret, specificReturn := fake.imageDigestReturnsOnCall[len(fake.imageDigestArgsForCall)]
+	fake.imageDigestArgsForCall = append(fake.imageDigestArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ImageDigestStub
+	fakeReturns := fake.imageDigestReturns
+	fake.recordInvocation("ImageDigest", []interface{}{arg1})
+	fake.imageDigestMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ContainerRouter) ImageDigestCallCount() int {
+	fake.imageDigestMutex.RLock()
+	defer fake.imageDigestMutex.RUnlock()
+	return len(fake.imageDigestArgsForCall)
+}
+
+func (fake *ContainerRouter) ImageDigestCalls(stub func(string) (string, error)) {
+	fake.imageDigestMutex.Lock()
+	defer fake.imageDigestMutex.Unlock()
+	fake.ImageDigestStub = stub
+}
+
+func (fake *ContainerRouter) ImageDigestArgsForCall(i int) string {
+	fake.imageDigestMutex.RLock()
+	defer fake.imageDigestMutex.RUnlock()
+	argsForCall := fake.imageDigestArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *ContainerRouter) ImageDigestReturns(result1 string, result2 error) {
+	fake.imageDigestMutex.Lock()
+	defer fake.imageDigestMutex.Unlock()
+	fake.ImageDigestStub = nil
+	fake.imageDigestReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ContainerRouter) ImageDigestReturnsOnCall(i int, result1 string, result2 error) {
+	fake.imageDigestMutex.Lock()
+	defer fake.imageDigestMutex.Unlock()
+	fake.ImageDigestStub = nil
+	if fake.imageDigestReturnsOnCall == nil {
+		fake.imageDigestReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.imageDigestReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ContainerRouter) MemoryUsage(arg1 string) (uint64, bool, error) {
+	fake.memoryUsageMutex.Lock()
+	ret, specificReturn := fake.memoryUsageReturnsOnCall[len(fake.memoryUsageArgsForCall)]
+	fake.memoryUsageArgsForCall = append(fake.memoryUsageArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.MemoryUsageStub
+	fakeReturns := fake.memoryUsageReturns
+	fake.recordInvocation("MemoryUsage", []interface{}{arg1})
+	fake.memoryUsageMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *ContainerRouter) MemoryUsageCallCount() int {
+	fake.memoryUsageMutex.RLock()
+	defer fake.memoryUsageMutex.RUnlock()
+	return len(fake.memoryUsageArgsForCall)
+}
+
+func (fake *ContainerRouter) MemoryUsageCalls(stub func(string) (uint64, bool, error)) {
+	fake.memoryUsageMutex.Lock()
+	defer fake.memoryUsageMutex.Unlock()
+	fake.MemoryUsageStub = stub
+}
+
+func (fake *ContainerRouter) MemoryUsageArgsForCall(i int) string {
+	fake.memoryUsageMutex.RLock()
+	defer fake.memoryUsageMutex.RUnlock()
+	argsForCall := fake.memoryUsageArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *ContainerRouter) MemoryUsageReturns(result1 uint64, result2 bool, result3 error) {
+	fake.memoryUsageMutex.Lock()
+	defer fake.memoryUsageMutex.Unlock()
+	fake.MemoryUsageStub = nil
+	fake.memoryUsageReturns = struct {
+		result1 uint64
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *ContainerRouter) MemoryUsageReturnsOnCall(i int, result1 uint64, result2 bool, result3 error) {
+	fake.memoryUsageMutex.Lock()
+	defer fake.memoryUsageMutex.Unlock()
+	fake.MemoryUsageStub = nil
+	if fake.memoryUsageReturnsOnCall == nil {
+		fake.memoryUsageReturnsOnCall = make(map[int]struct {
+			result1 uint64
+			result2 bool
+			result3 error
+		})
+	}
+	fake.memoryUsageReturnsOnCall[i] = struct {
+		result1 uint64
+		result2 bool
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *ContainerRouter) Probe(arg1 string, arg2 []string, arg3 time.Duration) error {
+	var arg2Copy []string
+	if arg2 != nil {
+		arg2Copy = make([]string, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.probeMutex.Lock()
+	ret, specificReturn := fake.probeReturnsOnCall[len(fake.probeArgsForCall)]
+	fake.probeArgsForCall = append(fake.probeArgsForCall, struct {
+		arg1 string
+		arg2 []string
+		arg3 time.Duration
+	}{arg1, arg2Copy, arg3})
+	stub := fake.ProbeStub
+	fakeReturns := fake.probeReturns
+	fake.recordInvocation("Probe", []interface{}{arg1, arg2Copy, arg3})
+	fake.probeMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *ContainerRouter) ProbeCallCount() int {
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	return len(fake.probeArgsForCall)
+}
+
+func (fake *ContainerRouter) ProbeCalls(stub func(string, []string, time.Duration) error) {
+	fake.probeMutex.Lock()
+	defer fake.probeMutex.Unlock()
+	fake.ProbeStub = stub
+}
+
+func (fake *ContainerRouter) ProbeArgsForCall(i int) (string, []string, time.Duration) {
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
+	argsForCall := fake.probeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *ContainerRouter) ProbeReturns(result1 error) {
+	fake.probeMutex.Lock()
+	defer fake.probeMutex.Unlock()
+	fake.ProbeStub = nil
+	fake.probeReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *ContainerRouter) ProbeReturnsOnCall(i int, result1 error) {
+	fake.probeMutex.Lock()
+	defer fake.probeMutex.Unlock()
+	fake.ProbeStub = nil
+	if fake.probeReturnsOnCall == nil {
+		fake.probeReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.probeReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *ContainerRouter) Start(arg1 string, arg2 *ccintf.PeerConnection) error {
 	fake.startMutex.Lock()
 	ret, specificReturn := fake.startReturnsOnCall[len(fake.startArgsForCall)]
@@ -202,15 +445,16 @@ func (fake *ContainerRouter) Start(arg1 string, arg2 *ccintf.PeerConnection) err
 		arg1 string
 		arg2 *ccintf.PeerConnection
 	}{arg1, arg2})
+	stub := fake.StartStub
+	fakeReturns := fake.startReturns
 	fake.recordInvocation("Start", []interface{}{arg1, arg2})
 	fake.startMutex.Unlock()
-	if fake.StartStub != nil {
-		return fake.StartStub(arg1, arg2)
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.startReturns
 	return fakeReturns.result1
 }
 
@@ -262,15 +506,16 @@ func (fake *ContainerRouter) Stop(arg1 string) error {
 	fake.stopArgsForCall = append(fake.stopArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.StopStub
+	fakeReturns := fake.stopReturns
 	fake.recordInvocation("Stop", []interface{}{arg1})
 	fake.stopMutex.Unlock()
-	if fake.StopStub != nil {
-		return fake.StopStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.stopReturns
 	return fakeReturns.result1
 }
 
@@ -322,15 +567,16 @@ func (fake *ContainerRouter) Wait(arg1 string) (int, error) {
 	fake.waitArgsForCall = append(fake.waitArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.WaitStub
+	fakeReturns := fake.waitReturns
 	fake.recordInvocation("Wait", []interface{}{arg1})
 	fake.waitMutex.Unlock()
-	if fake.WaitStub != nil {
-		return fake.WaitStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.waitReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -386,6 +632,12 @@ func (fake *ContainerRouter) Invocations() map[string][][]interface{} {
 	defer fake.buildMutex.RUnlock()
 	fake.chaincodeServerInfoMutex.RLock()
 	defer fake.chaincodeServerInfoMutex.RUnlock()
+	fake.imageDigestMutex.RLock()
+	defer fake.imageDigestMutex.RUnlock()
+	fake.memoryUsageMutex.RLock()
+	defer fake.memoryUsageMutex.RUnlock()
+	fake.probeMutex.RLock()
+	defer fake.probeMutex.RUnlock()
 	fake.startMutex.RLock()
 	defer fake.startMutex.RUnlock()
 	fake.stopMutex.RLock()
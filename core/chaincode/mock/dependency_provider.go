@@ -0,0 +1,107 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+)
+
+type DependencyProvider struct {
+	DependenciesStub        func(string) []string
+	dependenciesMutex       sync.RWMutex
+	dependenciesArgsForCall []struct {
+		arg1 string
+	}
+	dependenciesReturns struct {
+		result1 []string
+	}
+	dependenciesReturnsOnCall map[int]struct {
+		result1 []string
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *DependencyProvider) Dependencies(arg1 string) []string {
+	fake.dependenciesMutex.Lock()
+	ret, specificReturn := fake.dependenciesReturnsOnCall[len(fake.dependenciesArgsForCall)]
+	fake.dependenciesArgsForCall = append(fake.dependenciesArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.DependenciesStub
+	fakeReturns := fake.dependenciesReturns
+	fake.recordInvocation("Dependencies", []interface{}{arg1})
+	fake.dependenciesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *DependencyProvider) DependenciesCallCount() int {
+	fake.dependenciesMutex.RLock()
+	defer fake.dependenciesMutex.RUnlock()
+	return len(fake.dependenciesArgsForCall)
+}
+
+func (fake *DependencyProvider) DependenciesCalls(stub func(string) []string) {
+	fake.dependenciesMutex.Lock()
+	defer fake.dependenciesMutex.Unlock()
+	fake.DependenciesStub = stub
+}
+
+func (fake *DependencyProvider) DependenciesArgsForCall(i int) string {
+	fake.dependenciesMutex.RLock()
+	defer fake.dependenciesMutex.RUnlock()
+	argsForCall := fake.dependenciesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *DependencyProvider) DependenciesReturns(result1 []string) {
+	fake.dependenciesMutex.Lock()
+	defer fake.dependenciesMutex.Unlock()
+	fake.DependenciesStub = nil
+	fake.dependenciesReturns = struct {
+		result1 []string
+	}{result1}
+}
+
+func (fake *DependencyProvider) DependenciesReturnsOnCall(i int, result1 []string) {
+	fake.dependenciesMutex.Lock()
+	defer fake.dependenciesMutex.Unlock()
+	fake.DependenciesStub = nil
+	if fake.dependenciesReturnsOnCall == nil {
+		fake.dependenciesReturnsOnCall = make(map[int]struct {
+			result1 []string
+		})
+	}
+	fake.dependenciesReturnsOnCall[i] = struct {
+		result1 []string
+	}{result1}
+}
+
+func (fake *DependencyProvider) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.dependenciesMutex.RLock()
+	defer fake.dependenciesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *DependencyProvider) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
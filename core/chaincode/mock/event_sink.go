@@ -0,0 +1,74 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+type EventSink struct {
+	PublishStub        func(*peer.ChaincodeEvent)
+	publishMutex       sync.RWMutex
+	publishArgsForCall []struct {
+		arg1 *peer.ChaincodeEvent
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *EventSink) Publish(arg1 *peer.ChaincodeEvent) {
+	fake.publishMutex.Lock()
+	fake.publishArgsForCall = append(fake.publishArgsForCall, struct {
+		arg1 *peer.ChaincodeEvent
+	}{arg1})
+	stub := fake.PublishStub
+	fake.recordInvocation("Publish", []interface{}{arg1})
+	fake.publishMutex.Unlock()
+	if stub != nil {
+		fake.PublishStub(arg1)
+	}
+}
+
+func (fake *EventSink) PublishCallCount() int {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	return len(fake.publishArgsForCall)
+}
+
+func (fake *EventSink) PublishCalls(stub func(*peer.ChaincodeEvent)) {
+	fake.publishMutex.Lock()
+	defer fake.publishMutex.Unlock()
+	fake.PublishStub = stub
+}
+
+func (fake *EventSink) PublishArgsForCall(i int) *peer.ChaincodeEvent {
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	argsForCall := fake.publishArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *EventSink) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.publishMutex.RLock()
+	defer fake.publishMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *EventSink) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
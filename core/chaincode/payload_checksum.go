@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"crypto/sha256"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/pkg/errors"
+)
+
+// ErrPayloadChecksumMismatch is returned when the checksum carried with a
+// chaincode message payload does not match the payload.
+var ErrPayloadChecksumMismatch = errors.New("payload checksum mismatch")
+
+// PayloadChecksumDecoration is the decoration of a ChaincodeInput which
+// carries the checksum of the input. A shim which does not know about
+// checksums ignores the decoration.
+const PayloadChecksumDecoration = "payload_checksum"
+
+// inputChecksum returns the SHA-256 checksum of the deterministically
+// marshaled input, leaving out its checksum decoration.
+func inputChecksum(input *pb.ChaincodeInput) ([]byte, error) {
+	stripped := proto.Clone(input).(*pb.ChaincodeInput)
+	delete(stripped.Decorations, PayloadChecksumDecoration)
+
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(stripped); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return sum[:], nil
+}
+
+// addInputChecksum returns a copy of the input decorated with its checksum.
+func addInputChecksum(input *pb.ChaincodeInput) (*pb.ChaincodeInput, error) {
+	sum, err := inputChecksum(input)
+	if err != nil {
+		return nil, err
+	}
+	decorated := proto.Clone(input).(*pb.ChaincodeInput)
+	if decorated.Decorations == nil {
+		decorated.Decorations = map[string][]byte{}
+	}
+	decorated.Decorations[PayloadChecksumDecoration] = sum
+	return decorated, nil
+}
+
+// verifyInputChecksum verifies the checksum decorating the input.
+func verifyInputChecksum(input *pb.ChaincodeInput) error {
+	checksum, ok := input.Decorations[PayloadChecksumDecoration]
+	if !ok {
+		return errors.WithMessage(ErrPayloadChecksumMismatch, "input carries no checksum")
+	}
+	sum, err := inputChecksum(input)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(sum, checksum) {
+		return ErrPayloadChecksumMismatch
+	}
+	return nil
+}
+
+// verifyPayloadChecksum verifies the SHA-256 checksum which trails the
+// payload of a response and returns the payload without it.
+func verifyPayloadChecksum(payload []byte) ([]byte, error) {
+	if len(payload) < sha256.Size {
+		return nil, errors.WithMessagef(ErrPayloadChecksumMismatch, "payload of %d bytes is too short to carry a checksum", len(payload))
+	}
+
+	data, checksum := payload[:len(payload)-sha256.Size], payload[len(payload)-sha256.Size:]
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], checksum) {
+		return nil, ErrPayloadChecksumMismatch
+	}
+	return data, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// pendingLaunches tracks chaincodes whose launch has begun but which have not
+// yet registered with the peer. The zero value is ready to use.
+type pendingLaunches struct {
+	mutex   sync.Mutex
+	ccids   map[string]struct{}
+	waiters []chan struct{}
+}
+
+func (p *pendingLaunches) add(ccid string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.ccids == nil {
+		p.ccids = map[string]struct{}{}
+	}
+	p.ccids[ccid] = struct{}{}
+}
+
+func (p *pendingLaunches) remove(ccid string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.ccids, ccid)
+	if len(p.ccids) == 0 {
+		for _, settled := range p.waiters {
+			close(settled)
+		}
+		p.waiters = nil
+	}
+}
+
+// settled returns a channel which is closed once no launches are pending. If
+// none are pending, the returned channel is already closed.
+func (p *pendingLaunches) settled() <-chan struct{} {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	settled := make(chan struct{})
+	if len(p.ccids) == 0 {
+		close(settled)
+		return settled
+	}
+	p.waiters = append(p.waiters, settled)
+	return settled
+}
+
+func (p *pendingLaunches) list() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ccids := make([]string, 0, len(p.ccids))
+	for ccid := range p.ccids {
+		ccids = append(ccids, ccid)
+	}
+	sort.Strings(ccids)
+	return ccids
+}
+
+// Pending returns the sorted IDs of the chaincodes which are waiting for a
+// launch slot or for their runtime to start and register.
+func (r *RuntimeLauncher) Pending() []string {
+	return r.pending.list()
+}
+
+// PendingLaunches returns the sorted IDs of the chaincodes whose launch is
+// queued or in progress. It is empty unless the Launcher is a
+// RuntimeLauncher.
+func (cs *ChaincodeSupport) PendingLaunches() []string {
+	if rl, ok := cs.Launcher.(*RuntimeLauncher); ok {
+		return rl.Pending()
+	}
+	return nil
+}
+
+// WaitLaunchesSettled blocks until no launches are queued or in progress, or
+// until the context is done. It returns immediately unless the Launcher is a
+// RuntimeLauncher.
+func (cs *ChaincodeSupport) WaitLaunchesSettled(ctx context.Context) error {
+	rl, ok := cs.Launcher.(*RuntimeLauncher)
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-rl.pending.settled():
+		return nil
+	case <-ctx.Done():
+		return errors.WithMessagef(ctx.Err(), "launches of %v did not settle", rl.Pending())
+	}
+}
@@ -17,6 +17,10 @@ type Builder struct {
 	Client   *docker.Client
 }
 
+func (b *Builder) ValidatePlatform(ccType string) error {
+	return b.Registry.ValidatePlatform(ccType)
+}
+
 func (b *Builder) GenerateDockerBuild(ccType, path string, codePackage io.Reader) (io.Reader, error) {
 	return b.Registry.GenerateDockerBuild(ccType, path, codePackage, b.Client)
 }
@@ -74,11 +74,22 @@ func NewRegistry(platformTypes ...Platform) *Registry {
 	}
 }
 
+// ValidatePlatform returns an error if no platform supports the chaincode
+// type. It is checked both when a chaincode package is installed and when the
+// chaincode is launched, so that an unsupported type is reported before any
+// attempt to build or start the chaincode.
+func (r *Registry) ValidatePlatform(ccType string) error {
+	if _, ok := r.Platforms[ccType]; !ok {
+		return fmt.Errorf("Unknown chaincodeType: %s", ccType)
+	}
+	return nil
+}
+
 func (r *Registry) GenerateDockerfile(ccType string) (string, error) {
-	platform, ok := r.Platforms[ccType]
-	if !ok {
-		return "", fmt.Errorf("Unknown chaincodeType: %s", ccType)
+	if err := r.ValidatePlatform(ccType); err != nil {
+		return "", err
 	}
+	platform := r.Platforms[ccType]
 
 	var buf []string
 
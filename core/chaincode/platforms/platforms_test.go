@@ -37,6 +37,20 @@ var _ = Describe("Platforms", func() {
 		}
 	})
 
+	Describe("ValidatePlatform", func() {
+		It("accepts a registered platform", func() {
+			Expect(registry.ValidatePlatform("fakeType")).To(Succeed())
+		})
+
+		Context("when the platform is unknown", func() {
+			It("rejects the chaincode type", func() {
+				err := registry.ValidatePlatform("badType")
+				Expect(err).To(MatchError("Unknown chaincodeType: badType"))
+				Expect(fakePlatform.GenerateDockerfileCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Describe("GenerateDockerfile", func() {
 		It("calls the underlying platform, then appends some boilerplate", func() {
 			fakePlatform.GenerateDockerfileReturns("docker-header", nil)
@@ -0,0 +1,40 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "github.com/pkg/errors"
+
+// PreloadPackage fetches and builds the code package of the chaincode defined
+// on the channel without starting it, so that operators may stage packages
+// ahead of the first invocation. A later launch of the chaincode reuses the
+// build rather than fetching the package again. Preloading a chaincode which
+// is already running does nothing.
+func (cs *ChaincodeSupport) PreloadPackage(channelID, chaincodeName string) error {
+	l := cs.LedgerGetter.GetLedger(channelID)
+	if l == nil {
+		return errors.Errorf("channel %s not found", channelID)
+	}
+	qe, err := l.NewQueryExecutor()
+	if err != nil {
+		return errors.WithMessagef(err, "failed to get query executor for channel %s", channelID)
+	}
+	defer qe.Done()
+
+	cii, err := cs.endorsementInfo(channelID, chaincodeName, qe)
+	if err != nil {
+		return errors.Wrapf(err, "[channel %s] failed to get chaincode container info for %s", channelID, chaincodeName)
+	}
+	if cs.HandlerRegistry.Handler(cii.ChaincodeID) != nil {
+		return nil
+	}
+
+	chaincodeLogger.Debugf("preloading package of chaincode %s on channel %s", cii.ChaincodeID, channelID)
+	if _, err := cs.Runtime.Build(cii.ChaincodeID); err != nil {
+		return errors.WithMessagef(err, "failed to preload package of chaincode %s", cii.ChaincodeID)
+	}
+	return nil
+}
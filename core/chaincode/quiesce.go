@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrQuiesced is returned for executions of a chaincode which is quiesced.
+var ErrQuiesced = errors.New("chaincode is quiesced")
+
+// quiescedSet tracks quiesced chaincodes by name. The zero value is ready to
+// use.
+type quiescedSet struct {
+	mutex sync.RWMutex
+	names map[string]struct{}
+}
+
+func (q *quiescedSet) set(name string, quiesced bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if !quiesced {
+		delete(q.names, name)
+		return
+	}
+	if q.names == nil {
+		q.names = map[string]struct{}{}
+	}
+	q.names[name] = struct{}{}
+}
+
+func (q *quiescedSet) contains(name string) bool {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	_, ok := q.names[name]
+	return ok
+}
+
+// Quiesce stops the chaincode with the given name from accepting new
+// executions, which fail with ErrQuiesced. Executions already in progress
+// complete and the chaincode keeps running so that it can resume quickly
+// once unquiesced.
+func (cs *ChaincodeSupport) Quiesce(name string) {
+	chaincodeLogger.Infof("quiescing chaincode %s", name)
+	cs.quiesced.set(name, true)
+}
+
+// Unquiesce resumes executions of a quiesced chaincode.
+func (cs *ChaincodeSupport) Unquiesce(name string) {
+	chaincodeLogger.Infof("unquiescing chaincode %s", name)
+	cs.quiesced.set(name, false)
+}
+
+// checkQuiesced fails an execution of a quiesced chaincode. Executions are
+// checked before the chaincode is launched, so that a quiesced chaincode is
+// not started, and again before they are sent, in case the chaincode was
+// quiesced while they waited for its launch.
+func (cs *ChaincodeSupport) checkQuiesced(name string) error {
+	if cs.quiesced.contains(name) {
+		return errors.WithMessagef(ErrQuiesced, "cannot execute chaincode %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRateLimited is returned for executions rejected because their chaincode
+// has exceeded its execution rate.
+var ErrRateLimited = errors.New("chaincode execution rate exceeded")
+
+// RateLimitPolicy determines what happens to an execution of a chaincode
+// which has exceeded its execution rate.
+type RateLimitPolicy string
+
+const (
+	// BlockAtRateLimit makes the execution wait until the rate allows it.
+	// This is the default.
+	BlockAtRateLimit RateLimitPolicy = "block"
+	// RejectAtRateLimit fails the execution with ErrRateLimited.
+	RejectAtRateLimit RateLimitPolicy = "reject"
+)
+
+// A RateLimit bounds the executions of a chaincode to Rate per second, with
+// bursts of up to Burst executions.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// tokenBucket holds the executions a chaincode may start without waiting. It
+// goes negative while blocked executions wait for tokens they have reserved.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter bounds the rate at which each chaincode with a limit is
+// executed, so that a chaincode cannot overwhelm resources it shares with
+// others. Limits are keyed by chaincode name, so that they hold across
+// chaincode upgrades.
+type RateLimiter struct {
+	limits map[string]RateLimit
+	policy RateLimitPolicy
+
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewRateLimiter creates a RateLimiter which applies the limit in limits to
+// each named chaincode, applying the policy to executions beyond it.
+// Chaincodes without a positive rate are not limited.
+func NewRateLimiter(limits map[string]RateLimit, policy RateLimitPolicy) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		policy:  policy,
+		buckets: map[string]*tokenBucket{},
+	}
+}
+
+// Limits returns the rate limit of each limited chaincode.
+func (l *RateLimiter) Limits() map[string]RateLimit {
+	return l.limits
+}
+
+// Policy returns the policy applied to executions beyond a chaincode's rate.
+func (l *RateLimiter) Policy() RateLimitPolicy {
+	return l.policy
+}
+
+// Wait admits an execution of the named chaincode. When the chaincode has
+// exceeded its rate, Wait blocks until the rate allows the execution or, under
+// RejectAtRateLimit, returns ErrRateLimited.
+func (l *RateLimiter) Wait(chaincodeName string) error {
+	return l.WaitAtMost(chaincodeName, 0)
+}
+
+// WaitAtMost is Wait, except that an execution which would have to wait
+// longer than maxWait for its rate fails with ErrRateLimited without waiting.
+// A maxWait <= 0 does not bound the wait.
+func (l *RateLimiter) WaitAtMost(chaincodeName string, maxWait time.Duration) error {
+	delay, err := l.reserve(chaincodeName, time.Now(), maxWait)
+	if err != nil {
+		return err
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// reserve takes a token for an execution of the chaincode at now and returns
+// how long the execution must wait for it. No token is taken for an execution
+// which would wait longer than a positive maxWait.
+func (l *RateLimiter) reserve(chaincodeName string, now time.Time, maxWait time.Duration) (time.Duration, error) {
+	limit, ok := l.limits[chaincodeName]
+	if !ok || limit.Rate <= 0 {
+		return 0, nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, ok := l.buckets[chaincodeName]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[chaincodeName] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * limit.Rate
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	if l.policy == RejectAtRateLimit {
+		return 0, errors.WithMessagef(ErrRateLimited, "cannot execute chaincode %s at more than %g transactions per second", chaincodeName, limit.Rate)
+	}
+
+	delay := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	if maxWait > 0 && delay > maxWait {
+		return 0, errors.WithMessagef(ErrRateLimited, "cannot execute chaincode %s at more than %g transactions per second within %s", chaincodeName, limit.Rate, maxWait)
+	}
+	b.tokens--
+	return delay, nil
+}
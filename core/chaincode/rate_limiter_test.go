@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode_test
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("RateLimiter", func() {
+	var limiter *chaincode.RateLimiter
+
+	BeforeEach(func() {
+		limiter = chaincode.NewRateLimiter(map[string]chaincode.RateLimit{
+			"limited-cc": {Rate: 20, Burst: 2},
+		}, chaincode.BlockAtRateLimit)
+	})
+
+	It("admits a burst without waiting", func() {
+		start := time.Now()
+		Expect(limiter.Wait("limited-cc")).To(Succeed())
+		Expect(limiter.Wait("limited-cc")).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", 25*time.Millisecond))
+	})
+
+	It("delays executions beyond the rate", func() {
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			Expect(limiter.Wait("limited-cc")).To(Succeed())
+		}
+		// two executions wait 50ms each for a token at 20 per second
+		Expect(time.Since(start)).To(BeNumerically(">=", 90*time.Millisecond))
+	})
+
+	It("fails executions which would wait longer than the bound", func() {
+		Expect(limiter.Wait("limited-cc")).To(Succeed())
+		Expect(limiter.Wait("limited-cc")).To(Succeed())
+
+		start := time.Now()
+		err := limiter.WaitAtMost("limited-cc", 10*time.Millisecond)
+		Expect(errors.Cause(err)).To(Equal(chaincode.ErrRateLimited))
+		Expect(err).To(MatchError("cannot execute chaincode limited-cc at more than 20 transactions per second within 10ms: chaincode execution rate exceeded"))
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Millisecond))
+
+		// the failed execution did not take a token
+		start = time.Now()
+		Expect(limiter.WaitAtMost("limited-cc", time.Second)).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", 60*time.Millisecond))
+	})
+
+	It("does not limit chaincodes without a limit", func() {
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			Expect(limiter.Wait("other-cc")).To(Succeed())
+		}
+		Expect(time.Since(start)).To(BeNumerically("<", 25*time.Millisecond))
+	})
+
+	Context("when the policy is to reject", func() {
+		BeforeEach(func() {
+			limiter = chaincode.NewRateLimiter(map[string]chaincode.RateLimit{
+				"limited-cc": {Rate: 20, Burst: 2},
+			}, chaincode.RejectAtRateLimit)
+		})
+
+		It("rejects executions beyond the rate", func() {
+			Expect(limiter.Wait("limited-cc")).To(Succeed())
+			Expect(limiter.Wait("limited-cc")).To(Succeed())
+
+			err := limiter.Wait("limited-cc")
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrRateLimited))
+			Expect(err).To(MatchError("cannot execute chaincode limited-cc at more than 20 transactions per second: chaincode execution rate exceeded"))
+		})
+
+		It("admits executions again once tokens are replenished", func() {
+			Expect(limiter.Wait("limited-cc")).To(Succeed())
+			Expect(limiter.Wait("limited-cc")).To(Succeed())
+			Expect(limiter.Wait("limited-cc")).NotTo(Succeed())
+
+			Eventually(func() error { return limiter.Wait("limited-cc") }, time.Second, 10*time.Millisecond).Should(Succeed())
+		})
+	})
+})
@@ -7,7 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"math/rand"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/accesscontrol"
@@ -16,9 +18,36 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrNotRunning is returned when stopping a chaincode which is neither
+// launching nor registered with the peer.
+var ErrNotRunning = errors.New("chaincode is not running")
+
+// NotRunningPolicy determines what Stop does with a chaincode which is
+// neither launching nor registered with the peer.
+type NotRunningPolicy string
+
+const (
+	// StopNotRunning stops the runtime of the chaincode regardless, as it
+	// may still have a container the peer has lost track of. This is the
+	// default.
+	StopNotRunning NotRunningPolicy = "stop"
+	// RejectNotRunning fails the stop with ErrNotRunning without consulting
+	// the runtime.
+	RejectNotRunning NotRunningPolicy = "reject"
+	// IgnoreNotRunning makes the stop succeed without consulting the
+	// runtime.
+	IgnoreNotRunning NotRunningPolicy = "ignore"
+)
+
+// ErrLaunchAborted is returned by LaunchUntil when the launch is abandoned
+// before the chaincode has registered.
+var ErrLaunchAborted = errors.New("chaincode launch aborted")
+
 // LaunchRegistry tracks launching chaincode instances.
 type LaunchRegistry interface {
 	Launching(ccid string) (launchState *LaunchState, started bool)
+	Running(ccid string) bool
+	SetImageDigest(ccid, digest string)
 	Deregister(ccid string) error
 }
 
@@ -27,16 +56,53 @@ type ConnectionHandler interface {
 	Stream(ccid string, ccinfo *ccintf.ChaincodeServerInfo, sHandler extcc.StreamHandler) error
 }
 
+// A LaunchPrecondition is consulted before a chaincode container is started.
+// A non-nil error aborts the launch, for example when the host lacks the
+// resources to run another container.
+type LaunchPrecondition func(ccid string) error
+
+// A LaunchAffinity returns the runtime node a chaincode container should be
+// started on. The node is passed to the runtime as a placement hint; an empty
+// node expresses no affinity.
+type LaunchAffinity func(ccid string) (nodeID string)
+
+// A StartupProbe is a command run inside a chaincode container once the
+// chaincode has registered. The launch succeeds only if the command exits
+// successfully within the timeout; until then, callers waiting on the launch
+// are held and invocations do not reach the chaincode.
+type StartupProbe struct {
+	Command []string
+	Timeout time.Duration
+}
+
 // RuntimeLauncher is responsible for launching chaincode runtimes.
 type RuntimeLauncher struct {
-	Runtime           Runtime
-	Registry          LaunchRegistry
-	StartupTimeout    time.Duration
-	Metrics           *LaunchMetrics
-	PeerAddress       string
-	CACert            []byte
-	CertGenerator     CertGenerator
-	ConnectionHandler ConnectionHandler
+	Runtime             Runtime
+	Registry            LaunchRegistry
+	StartupTimeout      time.Duration
+	StallTimeout        time.Duration
+	RegistrationTimeout time.Duration
+	Jitter              time.Duration
+	StartupProbe        *StartupProbe
+	NotRunningPolicy    NotRunningPolicy
+	Limiter             *LaunchLimiter
+	MemoryBudget        *MemoryBudget
+	PrioritizeInit      bool
+	LaunchPrecondition  LaunchPrecondition
+	LaunchAffinity      LaunchAffinity
+	ContainerNetwork    string
+	Metrics             *LaunchMetrics
+	OnLaunchAttempt     LaunchAttemptHook
+	FailureNotifier     LaunchFailureNotifier
+	PeerAddress         string
+	CACert              []byte
+	CertGenerator       CertGenerator
+	ConnectionHandler   ConnectionHandler
+
+	pending       pendingLaunches
+	stops         stopRequests
+	waiters       launchWaiters
+	notifications launchFailureQueue
 }
 
 // CertGenerator generates client certificates for chaincode.
@@ -67,26 +133,137 @@ func (r *RuntimeLauncher) ChaincodeClientInfo(ccid string) (*ccintf.PeerConnecti
 	}, nil
 }
 
+// Launch starts the chaincode runtime for ccid on behalf of no particular
+// channel.
 func (r *RuntimeLauncher) Launch(ccid string, streamHandler extcc.StreamHandler) error {
+	return r.LaunchOnChannel("", ccid, streamHandler)
+}
+
+// LaunchOnChannel starts the chaincode runtime for ccid on behalf of an
+// invocation on channelID. When a Limiter is configured, the channel is used
+// to interleave waiting launches fairly across channels.
+func (r *RuntimeLauncher) LaunchOnChannel(channelID, ccid string, streamHandler extcc.StreamHandler) error {
+	_, err := r.launch(nil, channelID, ccid, streamHandler, false, false)
+	return err
+}
+
+// LaunchInitOnChannel is LaunchOnChannel on behalf of an init. When
+// PrioritizeInit is set, a launch waiting for a slot from the Limiter is
+// admitted ahead of launches on behalf of ordinary invocations, so that
+// deployments are not held up behind a backlog of invocations.
+func (r *RuntimeLauncher) LaunchInitOnChannel(channelID, ccid string, streamHandler extcc.StreamHandler) error {
+	_, err := r.launch(nil, channelID, ccid, streamHandler, false, r.PrioritizeInit)
+	return err
+}
+
+// LaunchUntil is Launch, abandoning the launch when done is closed before the
+// chaincode has registered. An abandoned launch stops any container it has
+// started, including one still starting when the launch is abandoned, and
+// returns ErrLaunchAborted. A launch started by another caller is
+// left to complete; only the wait for it is abandoned.
+func (r *RuntimeLauncher) LaunchUntil(done <-chan struct{}, ccid string, streamHandler extcc.StreamHandler) error {
+	_, err := r.launch(done, "", ccid, streamHandler, false, false)
+	return err
+}
+
+// TryLaunch is LaunchOnChannel without waiting for a launch slot. It returns
+// false without launching if a Limiter is configured and all of its launch
+// slots are in use.
+func (r *RuntimeLauncher) TryLaunch(channelID, ccid string, streamHandler extcc.StreamHandler) (bool, error) {
+	if r.Limiter != nil && !r.Limiter.TryAcquire() {
+		return false, nil
+	}
+	_, err := r.launch(nil, channelID, ccid, streamHandler, r.Limiter != nil, false)
+	return true, err
+}
+
+// acquireSlot acquires a launch slot from the Limiter, giving up the wait if
+// done is closed first.
+func (r *RuntimeLauncher) acquireSlot(done <-chan struct{}, channelID string, priority bool) bool {
+	if priority {
+		return r.Limiter.AcquirePriorityUntil(done, channelID)
+	}
+	return r.Limiter.AcquireUntil(done, channelID)
+}
+
+// launch starts the chaincode runtime. If holdingSlot is true, the caller has
+// already acquired a launch slot from the Limiter and launch releases it. If
+// priority is true, a launch slot is acquired ahead of other waiting launches.
+// A nil done channel never aborts the launch.
+func (r *RuntimeLauncher) launch(done <-chan struct{}, channelID, ccid string, streamHandler extcc.StreamHandler, holdingSlot, priority bool) (*LaunchTimings, error) {
 	var startFailCh chan error
 	var timeoutCh <-chan time.Time
+	var stallTimer *time.Timer
+	var progressCh <-chan struct{}
+	var startedCh chan struct{}
+	var registeredCh <-chan struct{}
+	containerStop := &launchStopGuard{}
 
 	startTime := time.Now()
+	timer := newLaunchTimer(startTime)
 	launchState, alreadyStarted := r.Registry.Launching(ccid)
+	if holdingSlot && alreadyStarted {
+		// a launch that is already in progress holds its own slot
+		r.Limiter.Release()
+	}
 	if !alreadyStarted {
+		r.pending.add(ccid)
+		defer r.pending.remove(ccid)
+
+		if r.Limiter != nil {
+			if !holdingSlot && !r.acquireSlot(done, channelID, priority) {
+				// the launch was abandoned while waiting for a slot
+				err := errors.WithMessagef(ErrLaunchAborted, "failed to launch chaincode %s", ccid)
+				launchState.Notify(err)
+				r.Registry.Deregister(ccid)
+				return timer.complete(), err
+			}
+			defer r.Limiter.Release()
+			r.Metrics.LaunchQueueWait.With(metricLabels(r.Metrics.Labeler, channelID, ccid, "chaincode", ccid)...).Observe(time.Since(startTime).Seconds())
+		}
+		timer.end(&timer.timings.QueueWait)
+
+		// the startup probe must pass before callers waiting on the launch,
+		// or invoking the registered chaincode, are told it has launched
+		if r.StartupProbe != nil {
+			launchState.HoldReady()
+			registeredCh = launchState.Registered()
+		}
+
+		// the delay spreads out launches which would otherwise start, and
+		// time out, together; it does not count against the timeouts
+		delay := r.launchDelay()
+
 		startFailCh = make(chan error, 1)
-		timeoutCh = time.NewTimer(r.StartupTimeout).C
+		startedCh = make(chan struct{})
+		timeoutCh = time.NewTimer(r.StartupTimeout + delay).C
+
+		// the watchdog aborts launches that make no progress long
+		// before the startup timeout would
+		if r.StallTimeout > 0 {
+			stallTimer = time.NewTimer(r.StallTimeout + delay)
+			defer stallTimer.Stop()
+			progressCh = launchState.Progress()
+		}
 
 		go func() {
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+			timer.end(&timer.timings.Jitter)
+
 			// go through the build process to obtain connecion information
 			ccservinfo, err := r.Runtime.Build(ccid)
 			if err != nil {
 				startFailCh <- errors.WithMessage(err, "error building chaincode")
 				return
 			}
+			timer.end(&timer.timings.Build)
+			launchState.NotifyProgress()
 
 			// chaincode server model indicated... proceed to connect to CC
 			if ccservinfo != nil {
+				close(startedCh)
 				if err = r.ConnectionHandler.Stream(ccid, ccservinfo, streamHandler); err != nil {
 					startFailCh <- errors.WithMessagef(err, "connection to %s failed", ccid)
 					return
@@ -107,11 +284,51 @@ func (r *RuntimeLauncher) Launch(ccid string, streamHandler extcc.StreamHandler)
 				startFailCh <- errors.New("could not get connection info")
 				return
 			}
-			if err = r.Runtime.Start(ccid, ccinfo); err != nil {
+			if r.LaunchPrecondition != nil {
+				if err = r.LaunchPrecondition(ccid); err != nil {
+					startFailCh <- errors.WithMessage(err, "launch precondition failed")
+					return
+				}
+			}
+			if r.LaunchAffinity != nil {
+				ccinfo.NodeAffinity = r.LaunchAffinity(ccid)
+			}
+			ccinfo.Network = r.ContainerNetwork
+			select {
+			case <-done:
+				// the launch was abandoned while building
+				return
+			default:
+			}
+			if !containerStop.mayStart() {
+				// the launch failed before the container was started
+				return
+			}
+			if r.MemoryBudget != nil {
+				if err = r.MemoryBudget.Reserve(ccid); err != nil {
+					startFailCh <- err
+					return
+				}
+			}
+			if err = r.startContainer(launchState.Done(), ccid, ccinfo); err != nil {
+				r.releaseMemory(ccid)
 				startFailCh <- errors.WithMessage(err, "error starting container")
 				return
 			}
+			if !containerStop.start() {
+				// the launch failed while the container was starting,
+				// too early for its stop to reach the container
+				if stopErr := r.Runtime.Stop(ccid); stopErr != nil {
+					chaincodeLogger.Debugf("failed to stop chaincode %s started after its launch failed: %s", ccid, stopErr)
+				}
+			}
+			timer.end(&timer.timings.Start)
+			launchState.NotifyProgress()
+			close(startedCh)
+			r.recordImageDigest(ccid)
 			exitCode, err := r.Runtime.Wait(ccid)
+			// the container holds its reservation until it exits
+			r.releaseMemory(ccid)
 			if err != nil {
 				launchState.Notify(errors.Wrap(err, "failed to wait on container exit"))
 			}
@@ -119,17 +336,97 @@ func (r *RuntimeLauncher) Launch(ccid string, streamHandler extcc.StreamHandler)
 		}()
 	}
 
+	var stallCh <-chan time.Time
+	if stallTimer != nil {
+		stallCh = stallTimer.C
+	}
+
+	// the registration timer is armed once the chaincode is running and
+	// bounds the wait for its handler to reach the established state,
+	// independent of StartupTimeout
+	var registrationTimer *time.Timer
+	var registerCh <-chan time.Time
+	var establishedCh <-chan struct{}
+	if startedCh != nil {
+		establishedCh = launchState.Established()
+	}
+
 	var err error
-	select {
-	case <-launchState.Done():
-		err = errors.WithMessage(launchState.Err(), "chaincode registration failed")
-	case err = <-startFailCh:
-		launchState.Notify(err)
-		r.Metrics.LaunchFailures.With("chaincode", ccid).Add(1)
-	case <-timeoutCh:
-		err = errors.Errorf("timeout expired while starting chaincode %s for transaction", ccid)
-		launchState.Notify(err)
-		r.Metrics.LaunchTimeouts.With("chaincode", ccid).Add(1)
+	for waiting := true; waiting; {
+		waiting = false
+		select {
+		case <-launchState.Done():
+			err = errors.WithMessage(launchState.Err(), "chaincode registration failed")
+		case <-registeredCh:
+			err = r.probe(ccid)
+			launchState.Notify(err)
+			if err != nil {
+				r.Metrics.LaunchFailures.With(metricLabels(r.Metrics.Labeler, channelID, ccid, "chaincode", ccid)...).Add(1)
+				if stopErr := r.Runtime.Stop(ccid); stopErr != nil {
+					chaincodeLogger.Debugf("failed to stop chaincode %s which failed its startup probe: %s", ccid, stopErr)
+				}
+			}
+		case err = <-startFailCh:
+			launchState.Notify(err)
+			r.Metrics.LaunchFailures.With(metricLabels(r.Metrics.Labeler, channelID, ccid, "chaincode", ccid)...).Add(1)
+		case <-timeoutCh:
+			err = errors.Errorf("timeout expired while starting chaincode %s for transaction", ccid)
+			launchState.Notify(err)
+			r.Metrics.LaunchTimeouts.With(metricLabels(r.Metrics.Labeler, channelID, ccid, "chaincode", ccid)...).Add(1)
+		case <-progressCh:
+			waiting = true
+			if !stallTimer.Stop() {
+				select {
+				case <-stallTimer.C:
+				default:
+				}
+			}
+			stallTimer.Reset(r.StallTimeout)
+		case <-startedCh:
+			waiting = true
+			startedCh = nil
+			if r.RegistrationTimeout > 0 && establishedCh != nil {
+				registrationTimer = time.NewTimer(r.RegistrationTimeout)
+				defer registrationTimer.Stop()
+				registerCh = registrationTimer.C
+			}
+		case <-establishedCh:
+			waiting = true
+			establishedCh = nil
+			if registrationTimer != nil {
+				registrationTimer.Stop()
+				registerCh = nil
+			}
+		case <-registerCh:
+			err = errors.Errorf("chaincode %s did not register within %s of starting", ccid, r.RegistrationTimeout)
+			launchState.Notify(err)
+			r.Metrics.LaunchTimeouts.With(metricLabels(r.Metrics.Labeler, channelID, ccid, "chaincode", ccid)...).Add(1)
+			if stopErr := r.Runtime.Stop(ccid); stopErr != nil {
+				chaincodeLogger.Debugf("failed to stop unregistered chaincode %s: %s", ccid, stopErr)
+			}
+		case <-stallCh:
+			err = errors.Errorf("launch of chaincode %s stalled: no progress for %s", ccid, r.StallTimeout)
+			launchState.Notify(err)
+			r.Metrics.LaunchTimeouts.With(metricLabels(r.Metrics.Labeler, channelID, ccid, "chaincode", ccid)...).Add(1)
+			if !containerStop.stop() {
+				break
+			}
+			if stopErr := r.Runtime.Stop(ccid); stopErr != nil {
+				chaincodeLogger.Debugf("failed to stop stalled chaincode %s: %s", ccid, stopErr)
+			}
+		case <-done:
+			err = errors.WithMessagef(ErrLaunchAborted, "failed to launch chaincode %s", ccid)
+			if alreadyStarted {
+				break
+			}
+			launchState.Notify(err)
+			if !containerStop.stop() {
+				break
+			}
+			if stopErr := r.Runtime.Stop(ccid); stopErr != nil {
+				chaincodeLogger.Debugf("failed to stop aborted chaincode %s: %s", ccid, stopErr)
+			}
+		}
 	}
 
 	success := true
@@ -139,18 +436,140 @@ func (r *RuntimeLauncher) Launch(ccid string, streamHandler extcc.StreamHandler)
 		defer r.Registry.Deregister(ccid)
 	}
 
-	r.Metrics.LaunchDuration.With(
+	r.Metrics.LaunchDuration.With(metricLabels(r.Metrics.Labeler, channelID, ccid,
 		"chaincode", ccid,
 		"success", strconv.FormatBool(success),
-	).Observe(time.Since(startTime).Seconds())
+	)...).Observe(time.Since(startTime).Seconds())
+
+	if r.OnLaunchAttempt != nil && !alreadyStarted {
+		r.OnLaunchAttempt(LaunchAudit{
+			ChaincodeID: ccid,
+			ChannelID:   channelID,
+			Success:     success,
+			Duration:    time.Since(startTime),
+			Err:         err,
+		})
+	}
+
+	if r.FailureNotifier != nil && !success {
+		r.notifications.enqueue(r.FailureNotifier, LaunchFailure{
+			ChaincodeID: ccid,
+			ChannelID:   channelID,
+			Error:       err.Error(),
+			Timestamp:   time.Now(),
+		})
+	}
 
 	chaincodeLogger.Debug("launch complete")
-	return err
+	return timer.complete(), err
 }
 
+// untilStarter is implemented by runtimes, such as the ContainerRuntime, which
+// can stop retrying a container start once its launch has ended.
+type untilStarter interface {
+	StartUntil(done <-chan struct{}, ccid string, ccinfo *ccintf.PeerConnection) error
+}
+
+// startContainer starts the chaincode container. A runtime which retries
+// failed starts gives up once done is closed.
+func (r *RuntimeLauncher) startContainer(done <-chan struct{}, ccid string, ccinfo *ccintf.PeerConnection) error {
+	if s, ok := r.Runtime.(untilStarter); ok {
+		return s.StartUntil(done, ccid, ccinfo)
+	}
+	return r.Runtime.Start(ccid, ccinfo)
+}
+
+// launchStopGuard serializes stopping the container of a failed launch
+// against starting it. A container which is still being started when the
+// launch fails is stopped by the launch once it has started, rather than by a
+// stop which would reach the runtime before the container exists.
+type launchStopGuard struct {
+	mutex   sync.Mutex
+	started bool
+	stopped bool
+}
+
+// mayStart reports whether the container may still be started.
+func (g *launchStopGuard) mayStart() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return !g.stopped
+}
+
+// start records that the container has started. It returns false if the
+// launch failed meanwhile, in which case the caller must stop the container.
+func (g *launchStopGuard) start() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.started = true
+	return !g.stopped
+}
+
+// stop records that the launch failed. It returns true if the container has
+// started, in which case the caller must stop it.
+func (g *launchStopGuard) stop() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.stopped = true
+	return g.started
+}
+
+// releaseMemory returns the memory reserved by the chaincode container to the
+// MemoryBudget, if any.
+func (r *RuntimeLauncher) releaseMemory(ccid string) {
+	if r.MemoryBudget != nil {
+		r.MemoryBudget.Release(ccid)
+	}
+}
+
+// probe runs the startup probe against the registered chaincode.
+func (r *RuntimeLauncher) probe(ccid string) error {
+	if err := r.Runtime.Probe(ccid, r.StartupProbe.Command, r.StartupProbe.Timeout); err != nil {
+		return errors.WithMessagef(err, "startup probe of chaincode %s failed", ccid)
+	}
+	return nil
+}
+
+// launchDelay returns a random delay of up to Jitter before a launch starts.
+func (r *RuntimeLauncher) launchDelay() time.Duration {
+	if r.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(r.Jitter)))
+}
+
+// recordImageDigest records the digest of the image the started chaincode
+// runs from. Failing to determine the digest does not fail the launch.
+func (r *RuntimeLauncher) recordImageDigest(ccid string) {
+	digest, err := r.Runtime.ImageDigest(ccid)
+	if err != nil {
+		chaincodeLogger.Warningf("could not determine image digest for chaincode %s: %s", ccid, err)
+		return
+	}
+	if digest != "" {
+		r.Registry.SetImageDigest(ccid, digest)
+	}
+}
+
+// Stop stops the chaincode runtime for ccid. A chaincode which is not running
+// is handled according to the NotRunningPolicy.
 func (r *RuntimeLauncher) Stop(ccid string) error {
+	switch r.NotRunningPolicy {
+	case RejectNotRunning:
+		if !r.Registry.Running(ccid) {
+			return errors.WithMessagef(ErrNotRunning, "failed to stop chaincode %s", ccid)
+		}
+	case IgnoreNotRunning:
+		if !r.Registry.Running(ccid) {
+			chaincodeLogger.Debugf("ignoring stop of chaincode %s which is not running", ccid)
+			return nil
+		}
+	}
+
+	r.stops.add(ccid)
 	err := r.Runtime.Stop(ccid)
 	if err != nil {
+		r.stops.remove(ccid)
 		return errors.WithMessagef(err, "failed to stop chaincode %s", ccid)
 	}
 
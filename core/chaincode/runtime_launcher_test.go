@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode_test
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/hyperledger/fabric-lib-go/common/metrics/metricsfakes"
@@ -24,17 +26,18 @@ import (
 
 var _ = Describe("RuntimeLauncher", func() {
 	var (
-		fakeRuntime        *mock.Runtime
-		fakeRegistry       *fake.LaunchRegistry
-		launchState        *chaincode.LaunchState
-		fakeLaunchDuration *metricsfakes.Histogram
-		fakeLaunchFailures *metricsfakes.Counter
-		fakeLaunchTimeouts *metricsfakes.Counter
-		fakeCertGenerator  *mock.CertGenerator
-		exitedCh           chan int
-		extCCConnExited    chan struct{}
-		fakeConnHandler    *mock.ConnectionHandler
-		fakeStreamHandler  *extccmock.StreamHandler
+		fakeRuntime         *mock.Runtime
+		fakeRegistry        *fake.LaunchRegistry
+		launchState         *chaincode.LaunchState
+		fakeLaunchDuration  *metricsfakes.Histogram
+		fakeLaunchFailures  *metricsfakes.Counter
+		fakeLaunchTimeouts  *metricsfakes.Counter
+		fakeLaunchQueueWait *metricsfakes.Histogram
+		fakeCertGenerator   *mock.CertGenerator
+		exitedCh            chan int
+		extCCConnExited     chan struct{}
+		fakeConnHandler     *mock.ConnectionHandler
+		fakeStreamHandler   *extccmock.StreamHandler
 
 		runtimeLauncher *chaincode.RuntimeLauncher
 	)
@@ -43,6 +46,7 @@ var _ = Describe("RuntimeLauncher", func() {
 		launchState = chaincode.NewLaunchState()
 		fakeRegistry = &fake.LaunchRegistry{}
 		fakeRegistry.LaunchingReturns(launchState, false)
+		fakeRegistry.RunningReturns(true)
 
 		fakeRuntime = &mock.Runtime{}
 		fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
@@ -72,11 +76,14 @@ var _ = Describe("RuntimeLauncher", func() {
 		fakeLaunchFailures.WithReturns(fakeLaunchFailures)
 		fakeLaunchTimeouts = &metricsfakes.Counter{}
 		fakeLaunchTimeouts.WithReturns(fakeLaunchTimeouts)
+		fakeLaunchQueueWait = &metricsfakes.Histogram{}
+		fakeLaunchQueueWait.WithReturns(fakeLaunchQueueWait)
 
 		launchMetrics := &chaincode.LaunchMetrics{
-			LaunchDuration: fakeLaunchDuration,
-			LaunchFailures: fakeLaunchFailures,
-			LaunchTimeouts: fakeLaunchTimeouts,
+			LaunchDuration:  fakeLaunchDuration,
+			LaunchQueueWait: fakeLaunchQueueWait,
+			LaunchFailures:  fakeLaunchFailures,
+			LaunchTimeouts:  fakeLaunchTimeouts,
 		}
 		fakeCertGenerator = &mock.CertGenerator{}
 		fakeCertGenerator.GenerateReturns(&accesscontrol.CertAndPrivKeyPair{Cert: []byte("cert"), Key: []byte("key")}, nil)
@@ -96,6 +103,12 @@ var _ = Describe("RuntimeLauncher", func() {
 		close(extCCConnExited)
 	})
 
+	It("does not list the chaincode as pending once launched", func() {
+		err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runtimeLauncher.Pending()).To(BeEmpty())
+	})
+
 	It("registers the chaincode as launching", func() {
 		err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
 		Expect(err).NotTo(HaveOccurred())
@@ -184,6 +197,47 @@ var _ = Describe("RuntimeLauncher", func() {
 		Eventually(errCh).Should(Receive(BeNil()))
 	})
 
+	It("records the image digest of the started chaincode", func() {
+		fakeRuntime.ImageDigestReturns("sha256:digest", nil)
+
+		err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(fakeRegistry.SetImageDigestCallCount).Should(Equal(1))
+		ccid, digest := fakeRegistry.SetImageDigestArgsForCall(0)
+		Expect(ccid).To(Equal("chaincode-name:chaincode-version"))
+		Expect(digest).To(Equal("sha256:digest"))
+		Expect(fakeRuntime.ImageDigestArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+	})
+
+	Context("when the chaincode does not run from an image", func() {
+		BeforeEach(func() {
+			fakeRuntime.ImageDigestReturns("", nil)
+		})
+
+		It("does not record an image digest", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fakeRuntime.ImageDigestCallCount).Should(Equal(1))
+			Consistently(fakeRegistry.SetImageDigestCallCount).Should(Equal(0))
+		})
+	})
+
+	Context("when the image digest cannot be determined", func() {
+		BeforeEach(func() {
+			fakeRuntime.ImageDigestReturns("", errors.New("fake-digest-error"))
+		})
+
+		It("does not fail the launch", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fakeRuntime.ImageDigestCallCount).Should(Equal(1))
+			Consistently(fakeRegistry.SetImageDigestCallCount).Should(Equal(0))
+		})
+	})
+
 	It("does not deregister the chaincode", func() {
 		err := runtimeLauncher.Launch("chaincode-name:chaincode-version, fakeStreamHandler", fakeStreamHandler)
 		Expect(err).NotTo(HaveOccurred())
@@ -205,6 +259,156 @@ var _ = Describe("RuntimeLauncher", func() {
 		Expect(fakeLaunchDuration.ObserveArgsForCall(0)).To(BeNumerically("<", 1.0))
 	})
 
+	Context("when a launch audit hook is configured", func() {
+		var audits []chaincode.LaunchAudit
+
+		BeforeEach(func() {
+			audits = nil
+			runtimeLauncher.OnLaunchAttempt = func(audit chaincode.LaunchAudit) {
+				audits = append(audits, audit)
+			}
+		})
+
+		It("records a successful launch", func() {
+			err := runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(audits).To(HaveLen(1))
+			Expect(audits[0].ChaincodeID).To(Equal("chaincode-name:chaincode-version"))
+			Expect(audits[0].ChannelID).To(Equal("channel-id"))
+			Expect(audits[0].Success).To(BeTrue())
+			Expect(audits[0].Duration).To(BeNumerically(">", 0))
+			Expect(audits[0].Err).To(BeNil())
+		})
+
+		It("records a failed launch", func() {
+			fakeRuntime.StartReturns(errors.New("banana"))
+
+			err := runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).To(MatchError("error starting container: banana"))
+
+			Expect(audits).To(HaveLen(1))
+			Expect(audits[0].ChaincodeID).To(Equal("chaincode-name:chaincode-version"))
+			Expect(audits[0].ChannelID).To(Equal("channel-id"))
+			Expect(audits[0].Success).To(BeFalse())
+			Expect(audits[0].Err).To(MatchError("error starting container: banana"))
+		})
+
+		It("does not record joining a launch already in progress", func() {
+			fakeRegistry.LaunchingReturns(launchState, true)
+			launchState.Notify(nil)
+
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(audits).To(BeEmpty())
+		})
+	})
+
+	Context("when a memory budget is configured", func() {
+		BeforeEach(func() {
+			runtimeLauncher.MemoryBudget = chaincode.NewMemoryBudget(2<<20, 1<<20)
+
+			var mutex sync.Mutex
+			launchStates := map[string]*chaincode.LaunchState{}
+			fakeRegistry.LaunchingStub = func(ccid string) (*chaincode.LaunchState, bool) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				launchStates[ccid] = chaincode.NewLaunchState()
+				return launchStates[ccid], false
+			}
+			fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				launchStates[ccid].Notify(nil)
+				return nil
+			}
+			exited := exitedCh // shadow to avoid race
+			fakeRuntime.StopStub = func(string) error {
+				exited <- 0
+				return nil
+			}
+		})
+
+		It("rejects launches past the budget until a container stops", func() {
+			Expect(runtimeLauncher.Launch("chaincode-1", fakeStreamHandler)).To(Succeed())
+			Expect(runtimeLauncher.Launch("chaincode-2", fakeStreamHandler)).To(Succeed())
+
+			err := runtimeLauncher.Launch("chaincode-3", fakeStreamHandler)
+			Expect(errors.Is(err, chaincode.ErrMemoryBudgetExceeded)).To(BeTrue())
+			Expect(err).To(MatchError("cannot start chaincode chaincode-3 with a memory limit of 1 MB: 2 of 2 MB are reserved by running chaincodes: chaincode memory budget exceeded"))
+			Expect(fakeRuntime.StartCallCount()).To(Equal(2))
+
+			Expect(runtimeLauncher.Stop("chaincode-1")).To(Succeed())
+			Eventually(func() error {
+				return runtimeLauncher.Launch("chaincode-3", fakeStreamHandler)
+			}).Should(Succeed())
+			Expect(fakeRuntime.StartCallCount()).To(Equal(3))
+		})
+
+		It("frees the budget of a container which fails to start", func() {
+			start := fakeRuntime.StartStub
+			fakeRuntime.StartStub = func(ccid string, ccinfo *ccintf.PeerConnection) error {
+				if ccid == "chaincode-1" {
+					return errors.New("banana")
+				}
+				return start(ccid, ccinfo)
+			}
+
+			err := runtimeLauncher.Launch("chaincode-1", fakeStreamHandler)
+			Expect(err).To(MatchError("error starting container: banana"))
+			Expect(runtimeLauncher.Launch("chaincode-2", fakeStreamHandler)).To(Succeed())
+			Expect(runtimeLauncher.Launch("chaincode-3", fakeStreamHandler)).To(Succeed())
+		})
+	})
+
+	Context("when a launch failure notifier is configured", func() {
+		var fakeNotifier *fake.LaunchFailureNotifier
+
+		BeforeEach(func() {
+			fakeNotifier = &fake.LaunchFailureNotifier{}
+			runtimeLauncher.FailureNotifier = fakeNotifier
+		})
+
+		It("enqueues one notification for a failed launch", func() {
+			fakeRuntime.StartReturns(errors.New("banana"))
+
+			beforeLaunch := time.Now()
+			err := runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).To(MatchError("error starting container: banana"))
+
+			Eventually(fakeNotifier.NotifyCallCount).Should(Equal(1))
+			Consistently(fakeNotifier.NotifyCallCount).Should(Equal(1))
+			failure := fakeNotifier.NotifyArgsForCall(0)
+			Expect(failure.ChaincodeID).To(Equal("chaincode-name:chaincode-version"))
+			Expect(failure.ChannelID).To(Equal("channel-id"))
+			Expect(failure.Error).To(Equal("error starting container: banana"))
+			Expect(failure.Timestamp).To(BeTemporally(">=", beforeLaunch))
+			Expect(failure.Timestamp).To(BeTemporally("<=", time.Now()))
+		})
+
+		It("does not notify of a successful launch", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+			Consistently(fakeNotifier.NotifyCallCount).Should(Equal(0))
+		})
+
+		It("does not wait for the notifier", func() {
+			fakeRuntime.StartReturns(errors.New("banana"))
+			unblock := make(chan struct{})
+			defer close(unblock)
+			fakeNotifier.NotifyStub = func(chaincode.LaunchFailure) error {
+				<-unblock
+				return nil
+			}
+
+			for i := 0; i < 3; i++ {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).To(MatchError(ContainSubstring("error starting container: banana")))
+			}
+			Eventually(fakeNotifier.NotifyCallCount).Should(Equal(1))
+		})
+	})
+
 	Context("when starting connection to external chaincode", func() {
 		BeforeEach(func() {
 			fakeRuntime.BuildReturns(&ccintf.ChaincodeServerInfo{Address: "peer-address"}, nil)
@@ -291,6 +495,748 @@ var _ = Describe("RuntimeLauncher", func() {
 		})
 	})
 
+	Context("when the launch stalls", func() {
+		BeforeEach(func() {
+			runtimeLauncher.StallTimeout = 100 * time.Millisecond
+			fakeRuntime.StartStub = nil
+			fakeRuntime.StartReturns(nil)
+		})
+
+		It("aborts the launch before the startup timeout", func() {
+			errCh := make(chan error, 1)
+			go func() { errCh <- runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler) }()
+
+			Eventually(errCh, time.Second).Should(Receive(MatchError("launch of chaincode chaincode-name:chaincode-version stalled: no progress for 100ms")))
+			Expect(launchState.Err()).To(MatchError("launch of chaincode chaincode-name:chaincode-version stalled: no progress for 100ms"))
+		})
+
+		It("stops the partially started container", func() {
+			runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+
+			Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+			Expect(fakeRegistry.DeregisterCallCount()).To(Equal(1))
+		})
+
+		It("records a launch timeout", func() {
+			runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(fakeLaunchTimeouts.WithCallCount()).To(Equal(1))
+			Expect(fakeLaunchTimeouts.AddCallCount()).To(Equal(1))
+		})
+
+		Context("when the launch keeps making progress", func() {
+			BeforeEach(func() {
+				fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+					go func() {
+						for i := 0; i < 3; i++ {
+							time.Sleep(50 * time.Millisecond)
+							launchState.NotifyProgress()
+						}
+						launchState.Notify(nil)
+					}()
+					return nil
+				}
+			})
+
+			It("does not abort the launch", func() {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("when the chaincode starts but never registers", func() {
+		BeforeEach(func() {
+			runtimeLauncher.RegistrationTimeout = 100 * time.Millisecond
+			fakeRuntime.StartStub = nil
+			fakeRuntime.StartReturns(nil)
+		})
+
+		It("fails the launch at the registration timeout", func() {
+			errCh := make(chan error, 1)
+			go func() { errCh <- runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler) }()
+
+			Eventually(errCh, time.Second).Should(Receive(MatchError("chaincode chaincode-name:chaincode-version did not register within 100ms of starting")))
+			Expect(launchState.Err()).To(MatchError("chaincode chaincode-name:chaincode-version did not register within 100ms of starting"))
+		})
+
+		It("stops the container and records a launch timeout", func() {
+			runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+
+			Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+			Expect(fakeRegistry.DeregisterCallCount()).To(Equal(1))
+			Expect(fakeLaunchTimeouts.AddCallCount()).To(Equal(1))
+		})
+
+		Context("when the container is slow to start", func() {
+			BeforeEach(func() {
+				fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+					time.Sleep(200 * time.Millisecond)
+					launchState.Notify(nil)
+					return nil
+				}
+			})
+
+			It("does not count the startup against the registration timeout", func() {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the handler is established before a slow startup probe", func() {
+			BeforeEach(func() {
+				runtimeLauncher.StartupProbe = &chaincode.StartupProbe{Command: []string{"/bin/ready"}}
+				fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+					launchState.NotifyEstablished()
+					launchState.Ready()
+					return nil
+				}
+				fakeRuntime.ProbeStub = func(string, []string, time.Duration) error {
+					time.Sleep(200 * time.Millisecond)
+					return nil
+				}
+			})
+
+			It("does not count the probe against the registration timeout", func() {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+				Expect(fakeLaunchTimeouts.AddCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("when launching until done is closed", func() {
+		var done chan struct{}
+
+		BeforeEach(func() {
+			done = make(chan struct{})
+			fakeRuntime.StartStub = nil
+			fakeRuntime.StartReturns(nil)
+		})
+
+		It("launches the chaincode while done is open", func() {
+			fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+				launchState.Notify(nil)
+				return nil
+			}
+
+			err := runtimeLauncher.LaunchUntil(done, "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("aborts the launch promptly when done is closed", func() {
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runtimeLauncher.LaunchUntil(done, "chaincode-name:chaincode-version", fakeStreamHandler)
+			}()
+
+			Eventually(fakeRuntime.StartCallCount).Should(Equal(1))
+			close(done)
+
+			var err error
+			Eventually(errCh, time.Second).Should(Receive(&err))
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+			Expect(err).To(MatchError("failed to launch chaincode chaincode-name:chaincode-version: chaincode launch aborted"))
+			Expect(launchState.Err()).To(Equal(err))
+		})
+
+		It("stops and deregisters the partially started container", func() {
+			fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+				close(done)
+				return nil
+			}
+
+			runtimeLauncher.LaunchUntil(done, "chaincode-name:chaincode-version", fakeStreamHandler)
+
+			Eventually(fakeRuntime.StopCallCount).Should(Equal(1))
+			Consistently(fakeRuntime.StopCallCount, 50*time.Millisecond).Should(Equal(1))
+			Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+			Expect(fakeRegistry.DeregisterCallCount()).To(Equal(1))
+		})
+
+		It("stops a container which finishes starting after the launch was aborted", func() {
+			aborted := make(chan struct{})
+			stopsWhileStarting := make(chan int, 1)
+			fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+				close(done)
+				<-aborted
+				stopsWhileStarting <- fakeRuntime.StopCallCount()
+				return nil
+			}
+
+			err := runtimeLauncher.LaunchUntil(done, "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+			close(aborted)
+
+			Eventually(stopsWhileStarting).Should(Receive(Equal(0)))
+			Eventually(fakeRuntime.StopCallCount).Should(Equal(1))
+			Consistently(fakeRuntime.StopCallCount, 50*time.Millisecond).Should(Equal(1))
+		})
+
+		Context("when done is closed while the chaincode is building", func() {
+			BeforeEach(func() {
+				built := make(chan struct{})
+				fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+					close(done)
+					close(built)
+					return nil, nil
+				}
+				DeferCleanup(func() { Eventually(built).Should(BeClosed()) })
+			})
+
+			It("does not start the container", func() {
+				err := runtimeLauncher.LaunchUntil(done, "chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+				Consistently(fakeRuntime.StartCallCount, 50*time.Millisecond).Should(Equal(0))
+			})
+		})
+
+		Context("when the chaincode is already launching", func() {
+			BeforeEach(func() {
+				fakeRegistry.LaunchingReturns(launchState, true)
+			})
+
+			It("abandons the wait without stopping the chaincode", func() {
+				close(done)
+
+				err := runtimeLauncher.LaunchUntil(done, "chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+				Expect(fakeRegistry.DeregisterCallCount()).To(Equal(0))
+				Expect(launchState.Err()).To(BeNil())
+			})
+		})
+	})
+
+	Describe("LaunchContext", func() {
+		It("launches the chaincode", func() {
+			err := runtimeLauncher.LaunchContext(context.Background(), "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+		})
+
+		Context("when several callers wait on the launch", func() {
+			var (
+				ctx1, ctx2       context.Context
+				cancel1, cancel2 context.CancelFunc
+				errCh1, errCh2   chan error
+			)
+
+			BeforeEach(func() {
+				// the chaincode never registers
+				fakeRuntime.StartStub = nil
+				fakeRegistry.LaunchingReturnsOnCall(0, launchState, false)
+				fakeRegistry.LaunchingReturnsOnCall(1, launchState, true)
+
+				ctx1, cancel1 = context.WithCancel(context.Background())
+				ctx2, cancel2 = context.WithCancel(context.Background())
+				errCh1 = make(chan error, 1)
+				errCh2 = make(chan error, 1)
+				go func() {
+					errCh1 <- runtimeLauncher.LaunchContext(ctx1, "chaincode-name:chaincode-version", fakeStreamHandler)
+				}()
+				go func() {
+					errCh2 <- runtimeLauncher.LaunchContext(ctx2, "chaincode-name:chaincode-version", fakeStreamHandler)
+				}()
+
+				Eventually(fakeRegistry.LaunchingCallCount).Should(Equal(2))
+				Eventually(fakeRuntime.StartCallCount).Should(Equal(1))
+			})
+
+			AfterEach(func() {
+				cancel1()
+				cancel2()
+			})
+
+			It("continues the launch while any caller is waiting", func() {
+				cancel1()
+
+				var err error
+				Eventually(errCh1).Should(Receive(&err))
+				Expect(err).To(MatchError("failed to launch chaincode chaincode-name:chaincode-version: chaincode launch aborted"))
+				Consistently(fakeRuntime.StopCallCount, 50*time.Millisecond).Should(Equal(0))
+				Expect(launchState.Err()).To(BeNil())
+
+				launchState.Notify(nil)
+				Eventually(errCh2).Should(Receive(BeNil()))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+			})
+
+			It("abandons the launch once every caller has cancelled", func() {
+				cancel1()
+				cancel2()
+
+				var err error
+				Eventually(errCh1).Should(Receive(&err))
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+				Eventually(errCh2).Should(Receive(&err))
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+
+				Eventually(fakeRuntime.StopCallCount).Should(Equal(1))
+				Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+				Eventually(fakeRegistry.DeregisterCallCount).Should(Equal(1))
+				Expect(errors.Cause(launchState.Err())).To(Equal(chaincode.ErrLaunchAborted))
+			})
+		})
+	})
+
+	Context("when a launch limiter is configured", func() {
+		var limiter *chaincode.LaunchLimiter
+
+		BeforeEach(func() {
+			limiter = chaincode.NewLaunchLimiter(1)
+			runtimeLauncher.Limiter = limiter
+		})
+
+		It("records the time spent waiting for a launch slot", func() {
+			err := runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeLaunchQueueWait.WithCallCount()).To(Equal(1))
+			Expect(fakeLaunchQueueWait.WithArgsForCall(0)).To(Equal([]string{"chaincode", "chaincode-name:chaincode-version"}))
+			Expect(fakeLaunchQueueWait.ObserveCallCount()).To(Equal(1))
+			Expect(fakeLaunchQueueWait.ObserveArgsForCall(0)).To(BeNumerically("<", 0.1))
+		})
+
+		It("releases the launch slot once the launch completes", func() {
+			err := runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			acquired := make(chan struct{})
+			go func() {
+				limiter.Acquire("channel-id")
+				close(acquired)
+			}()
+			Eventually(acquired).Should(BeClosed())
+		})
+
+		It("tries to launch the chaincode when a slot is available", func() {
+			launched, err := runtimeLauncher.TryLaunch("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(launched).To(BeTrue())
+			Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+			Expect(limiter.TryAcquire()).To(BeTrue())
+		})
+
+		Context("when the limit is saturated", func() {
+			BeforeEach(func() {
+				limiter.Acquire("other-channel-id")
+			})
+
+			It("does not try to launch the chaincode", func() {
+				launched, err := runtimeLauncher.TryLaunch("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(launched).To(BeFalse())
+				Expect(fakeRegistry.LaunchingCallCount()).To(Equal(0))
+				Expect(fakeRuntime.BuildCallCount()).To(Equal(0))
+			})
+
+			It("waits for a launch slot before building the chaincode", func() {
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				}()
+
+				Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(1))
+				Consistently(fakeRuntime.BuildCallCount).Should(Equal(0))
+
+				limiter.Release()
+				Eventually(errCh).Should(Receive(BeNil()))
+				Expect(fakeRuntime.BuildCallCount()).To(Equal(1))
+			})
+
+			It("gives up its place in the queue when every caller cancels", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- runtimeLauncher.LaunchContextOnChannel(ctx, "channel-id", "chaincode-name:chaincode-version", fakeStreamHandler, false)
+				}()
+				Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(1))
+
+				cancel()
+				var err error
+				Eventually(errCh).Should(Receive(&err))
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrLaunchAborted))
+				Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(0))
+				Eventually(fakeRegistry.DeregisterCallCount).Should(Equal(1))
+				Expect(errors.Cause(launchState.Err())).To(Equal(chaincode.ErrLaunchAborted))
+
+				limiter.Release()
+				Expect(limiter.TryAcquire()).To(BeTrue())
+				Expect(fakeRuntime.BuildCallCount()).To(Equal(0))
+			})
+
+			Context("when a launch for an init is waiting behind a launch for an invoke", func() {
+				var errCh chan error
+
+				BeforeEach(func() {
+					launchStates := map[string]*chaincode.LaunchState{
+						"invoke-ccid": chaincode.NewLaunchState(),
+						"init-ccid":   chaincode.NewLaunchState(),
+					}
+					fakeRegistry.LaunchingStub = func(ccid string) (*chaincode.LaunchState, bool) {
+						return launchStates[ccid], false
+					}
+					fakeRuntime.StartStub = func(ccid string, _ *ccintf.PeerConnection) error {
+						launchStates[ccid].Notify(nil)
+						return nil
+					}
+					errCh = make(chan error, 2)
+				})
+
+				// enqueue launches in the background and waits until the
+				// launch is queued, so the queue order is deterministic.
+				enqueue := func(launch func() error) {
+					queued := limiter.Waiting("channel-id")
+					go func() { errCh <- launch() }()
+					Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(queued + 1))
+				}
+
+				launchBoth := func() {
+					enqueue(func() error {
+						return runtimeLauncher.LaunchOnChannel("channel-id", "invoke-ccid", fakeStreamHandler)
+					})
+					enqueue(func() error {
+						return runtimeLauncher.LaunchInitOnChannel("channel-id", "init-ccid", fakeStreamHandler)
+					})
+
+					limiter.Release()
+					Eventually(errCh).Should(Receive(BeNil()))
+					Eventually(errCh).Should(Receive(BeNil()))
+					Expect(fakeRuntime.BuildCallCount()).To(Equal(2))
+				}
+
+				It("launches the chaincodes in arrival order", func() {
+					launchBoth()
+					Expect(fakeRuntime.BuildArgsForCall(0)).To(Equal("invoke-ccid"))
+					Expect(fakeRuntime.BuildArgsForCall(1)).To(Equal("init-ccid"))
+				})
+
+				Context("when init launches are prioritized", func() {
+					BeforeEach(func() {
+						runtimeLauncher.PrioritizeInit = true
+					})
+
+					It("launches the chaincode for the init first", func() {
+						launchBoth()
+						Expect(fakeRuntime.BuildArgsForCall(0)).To(Equal("init-ccid"))
+						Expect(fakeRuntime.BuildArgsForCall(1)).To(Equal("invoke-ccid"))
+					})
+				})
+			})
+
+			It("lists the chaincode as pending until it is ready", func() {
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				}()
+
+				Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(1))
+				Expect(runtimeLauncher.Pending()).To(Equal([]string{"chaincode-name:chaincode-version"}))
+
+				limiter.Release()
+				Eventually(errCh).Should(Receive(BeNil()))
+				Expect(runtimeLauncher.Pending()).To(BeEmpty())
+			})
+
+			It("unblocks waiters once the pending launches settle", func() {
+				chaincodeSupport := &chaincode.ChaincodeSupport{Launcher: runtimeLauncher}
+
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				}()
+				Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(1))
+
+				settledCh := make(chan error, 1)
+				go func() { settledCh <- chaincodeSupport.WaitLaunchesSettled(context.Background()) }()
+				Consistently(settledCh).ShouldNot(Receive())
+
+				limiter.Release()
+				Eventually(errCh).Should(Receive(BeNil()))
+				Eventually(settledCh).Should(Receive(BeNil()))
+			})
+
+			It("stops waiting for the launches to settle when the context is done", func() {
+				chaincodeSupport := &chaincode.ChaincodeSupport{Launcher: runtimeLauncher}
+
+				errCh := make(chan error, 1)
+				go func() {
+					errCh <- runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				}()
+				Eventually(func() int { return limiter.Waiting("channel-id") }).Should(Equal(1))
+
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				err := chaincodeSupport.WaitLaunchesSettled(ctx)
+				Expect(err).To(MatchError("launches of [chaincode-name:chaincode-version] did not settle: context deadline exceeded"))
+
+				limiter.Release()
+				Eventually(errCh).Should(Receive(BeNil()))
+			})
+		})
+
+		Context("when the chaincode is already launching", func() {
+			BeforeEach(func() {
+				fakeRegistry.LaunchingReturns(launchState, true)
+				launchState.Notify(nil)
+				limiter.Acquire("other-channel-id")
+			})
+
+			It("does not wait for a launch slot", func() {
+				err := runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(limiter.Waiting("channel-id")).To(Equal(0))
+			})
+
+			It("returns the slot taken by a try", func() {
+				limiter.Release()
+				launched, err := runtimeLauncher.TryLaunch("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(launched).To(BeTrue())
+				Expect(limiter.TryAcquire()).To(BeTrue())
+			})
+		})
+	})
+
+	Context("when a launch precondition is configured", func() {
+		var preconditionCCID string
+
+		BeforeEach(func() {
+			runtimeLauncher.LaunchPrecondition = func(ccid string) error {
+				preconditionCCID = ccid
+				return nil
+			}
+		})
+
+		It("consults the precondition before starting the container", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(preconditionCCID).To(Equal("chaincode-name:chaincode-version"))
+			Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+		})
+
+		Context("when the precondition fails", func() {
+			BeforeEach(func() {
+				runtimeLauncher.LaunchPrecondition = func(string) error {
+					return errors.New("insufficient-memory")
+				}
+			})
+
+			It("does not start the container", func() {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).To(MatchError("launch precondition failed: insufficient-memory"))
+				Expect(fakeRuntime.StartCallCount()).To(Equal(0))
+			})
+
+			It("records a launch failure", func() {
+				runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(fakeLaunchFailures.AddCallCount()).To(Equal(1))
+				Expect(fakeRegistry.DeregisterCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when a launch affinity is configured", func() {
+		BeforeEach(func() {
+			runtimeLauncher.LaunchAffinity = func(ccid string) string {
+				return "node-for-" + ccid
+			}
+		})
+
+		It("passes the node to the runtime as a placement hint", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+			_, ccinfoArg := fakeRuntime.StartArgsForCall(0)
+			Expect(ccinfoArg.NodeAffinity).To(Equal("node-for-chaincode-name:chaincode-version"))
+			Expect(ccinfoArg.Address).To(Equal("peer-address"))
+		})
+	})
+
+	Context("when a container network is configured", func() {
+		BeforeEach(func() {
+			runtimeLauncher.ContainerNetwork = "sidecar-net"
+		})
+
+		It("passes the network to the runtime", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+			_, peerConnection := fakeRuntime.StartArgsForCall(0)
+			Expect(peerConnection.Network).To(Equal("sidecar-net"))
+		})
+	})
+
+	Context("when a startup probe is configured", func() {
+		BeforeEach(func() {
+			runtimeLauncher.StartupProbe = &chaincode.StartupProbe{
+				Command: []string{"/bin/ready"},
+				Timeout: time.Second,
+			}
+			fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+				launchState.Ready()
+				return nil
+			}
+		})
+
+		It("probes the chaincode once it has registered", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeRuntime.ProbeCallCount()).To(Equal(1))
+			ccid, command, timeout := fakeRuntime.ProbeArgsForCall(0)
+			Expect(ccid).To(Equal("chaincode-name:chaincode-version"))
+			Expect(command).To(Equal([]string{"/bin/ready"}))
+			Expect(timeout).To(Equal(time.Second))
+		})
+
+		It("holds callers waiting on the launch until the probe passes", func() {
+			probing := make(chan struct{})
+			passed := make(chan struct{})
+			fakeRuntime.ProbeStub = func(string, []string, time.Duration) error {
+				close(probing)
+				<-passed
+				return nil
+			}
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			}()
+
+			Eventually(probing).Should(BeClosed())
+			Consistently(launchState.Done(), 50*time.Millisecond).ShouldNot(BeClosed())
+			close(passed)
+			Eventually(launchState.Done()).Should(BeClosed())
+			Expect(launchState.Err()).NotTo(HaveOccurred())
+			Eventually(errCh).Should(Receive(BeNil()))
+		})
+
+		Context("when the probe fails", func() {
+			BeforeEach(func() {
+				fakeRuntime.ProbeReturns(errors.New("not-ready"))
+			})
+
+			It("fails the launch for every caller even though the chaincode registered", func() {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).To(MatchError("startup probe of chaincode chaincode-name:chaincode-version failed: not-ready"))
+				Expect(launchState.Err()).To(Equal(err))
+			})
+
+			It("stops and deregisters the chaincode", func() {
+				runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+				Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+				Expect(fakeRegistry.DeregisterCallCount()).To(Equal(1))
+			})
+
+			It("records a launch failure", func() {
+				runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(fakeLaunchFailures.AddCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the chaincode is already launching", func() {
+			BeforeEach(func() {
+				fakeRegistry.LaunchingReturns(launchState, true)
+				launchState.Notify(nil)
+			})
+
+			It("does not probe it again", func() {
+				err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeRuntime.ProbeCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("when launching with timings", func() {
+		BeforeEach(func() {
+			fakeRuntime.BuildStub = func(string) (*ccintf.ChaincodeServerInfo, error) {
+				time.Sleep(10 * time.Millisecond)
+				return nil, nil
+			}
+			fakeRuntime.StartStub = func(string, *ccintf.PeerConnection) error {
+				time.Sleep(10 * time.Millisecond)
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					launchState.Notify(nil)
+				}()
+				return nil
+			}
+		})
+
+		It("returns the duration of each phase", func() {
+			timings, err := runtimeLauncher.LaunchWithTimings("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(timings.QueueWait).To(BeNumerically(">=", 0))
+			Expect(timings.Jitter).To(BeNumerically(">=", 0))
+			Expect(timings.Build).To(BeNumerically(">=", 10*time.Millisecond))
+			Expect(timings.Start).To(BeNumerically(">=", 10*time.Millisecond))
+			Expect(timings.Registration).To(BeNumerically(">=", 10*time.Millisecond))
+
+			sum := timings.QueueWait + timings.Jitter + timings.Build + timings.Start + timings.Registration
+			Expect(sum).To(BeNumerically("~", timings.Total, time.Millisecond))
+		})
+
+		Context("when the chaincode is already launching", func() {
+			BeforeEach(func() {
+				fakeRegistry.LaunchingReturns(launchState, true)
+				launchState.Notify(nil)
+			})
+
+			It("attributes the launch to registration", func() {
+				timings, err := runtimeLauncher.LaunchWithTimings("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(timings.Build).To(BeZero())
+				Expect(timings.Start).To(BeZero())
+				Expect(timings.Registration).To(Equal(timings.Total))
+			})
+		})
+	})
+
+	Context("when launch jitter is configured", func() {
+		BeforeEach(func() {
+			runtimeLauncher.Jitter = 10 * time.Millisecond
+		})
+
+		It("delays launches by varying amounts of up to the jitter", func() {
+			delays := map[time.Duration]struct{}{}
+			for i := 0; i < 20; i++ {
+				delay := chaincode.LaunchDelay(runtimeLauncher)
+				Expect(delay).To(BeNumerically(">=", 0))
+				Expect(delay).To(BeNumerically("<", 10*time.Millisecond))
+				delays[delay] = struct{}{}
+			}
+			Expect(len(delays)).To(BeNumerically(">", 1))
+		})
+
+		It("launches the chaincode", func() {
+			err := runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeRuntime.StartCallCount()).To(Equal(1))
+		})
+
+		Context("when jitter is disabled", func() {
+			BeforeEach(func() {
+				runtimeLauncher.Jitter = 0
+			})
+
+			It("does not delay launches", func() {
+				Expect(chaincode.LaunchDelay(runtimeLauncher)).To(BeZero())
+			})
+		})
+	})
+
 	Context("when starting the runtime fails", func() {
 		BeforeEach(func() {
 			fakeRuntime.StartReturns(errors.New("banana"))
@@ -318,6 +1264,42 @@ var _ = Describe("RuntimeLauncher", func() {
 			Expect(fakeLaunchFailures.AddArgsForCall(0)).To(BeNumerically("~", 1.0))
 		})
 
+		Context("when a metric labeler is configured", func() {
+			BeforeEach(func() {
+				runtimeLauncher.Metrics.Labeler = &orgLabeler{org: "org1"}
+			})
+
+			It("records chaincode launch failures with the derived labels", func() {
+				runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(fakeLaunchFailures.WithCallCount()).To(Equal(1))
+				labelValues := fakeLaunchFailures.WithArgsForCall(0)
+				Expect(labelValues).To(Equal([]string{
+					"chaincode", "chaincode-name:chaincode-version",
+					"org", "org1",
+					"channel", "",
+				}))
+
+				Expect(fakeLaunchDuration.WithCallCount()).To(Equal(1))
+				labelValues = fakeLaunchDuration.WithArgsForCall(0)
+				Expect(labelValues).To(Equal([]string{
+					"chaincode", "chaincode-name:chaincode-version",
+					"success", "false",
+					"org", "org1",
+					"channel", "",
+				}))
+			})
+
+			It("derives the labels for the channel of the launch", func() {
+				runtimeLauncher.LaunchOnChannel("channel-id", "chaincode-name:chaincode-version", fakeStreamHandler)
+				Expect(fakeLaunchFailures.WithCallCount()).To(Equal(1))
+				Expect(fakeLaunchFailures.WithArgsForCall(0)).To(Equal([]string{
+					"chaincode", "chaincode-name:chaincode-version",
+					"org", "org1",
+					"channel", "channel-id",
+				}))
+			})
+		})
+
 		It("deregisters the chaincode", func() {
 			runtimeLauncher.Launch("chaincode-name:chaincode-version", fakeStreamHandler)
 
@@ -465,6 +1447,46 @@ var _ = Describe("RuntimeLauncher", func() {
 		Expect(ccidArg).To(Equal("chaincode-name:chaincode-version"))
 	})
 
+	Context("when the chaincode is not running", func() {
+		BeforeEach(func() {
+			fakeRegistry.RunningReturns(false)
+		})
+
+		It("stops the runtime regardless", func() {
+			err := runtimeLauncher.Stop("chaincode-name:chaincode-version")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeRuntime.StopCallCount()).To(Equal(1))
+			Expect(fakeRuntime.StopArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+		})
+
+		Context("when the policy is to reject", func() {
+			BeforeEach(func() {
+				runtimeLauncher.NotRunningPolicy = chaincode.RejectNotRunning
+			})
+
+			It("returns ErrNotRunning without stopping the runtime", func() {
+				err := runtimeLauncher.Stop("chaincode-name:chaincode-version")
+				Expect(err).To(MatchError("failed to stop chaincode chaincode-name:chaincode-version: chaincode is not running"))
+				Expect(errors.Cause(err)).To(Equal(chaincode.ErrNotRunning))
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+				Expect(fakeRegistry.RunningCallCount()).To(Equal(1))
+				Expect(fakeRegistry.RunningArgsForCall(0)).To(Equal("chaincode-name:chaincode-version"))
+			})
+		})
+
+		Context("when the policy is to ignore", func() {
+			BeforeEach(func() {
+				runtimeLauncher.NotRunningPolicy = chaincode.IgnoreNotRunning
+			})
+
+			It("does nothing", func() {
+				err := runtimeLauncher.Stop("chaincode-name:chaincode-version")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeRuntime.StopCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Context("when stopping the runtime fails while stopping", func() {
 		BeforeEach(func() {
 			fakeRuntime.StopReturns(errors.New("liver-mush"))
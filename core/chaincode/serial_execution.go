@@ -0,0 +1,34 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import "sync"
+
+// serialLocks holds a mutex per chaincode name for chaincodes whose
+// executions must not overlap. The zero value is ready to use.
+type serialLocks struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock blocks until no other execution of the named chaincode holds its lock
+// and returns the function which releases it.
+func (s *serialLocks) lock(name string) (unlock func()) {
+	s.mutex.Lock()
+	if s.locks == nil {
+		s.locks = map[string]*sync.Mutex{}
+	}
+	l, ok := s.locks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[name] = l
+	}
+	s.mutex.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
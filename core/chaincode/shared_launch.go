@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/extcc"
+	"github.com/pkg/errors"
+)
+
+// sharedLaunch is a launch of a chaincode awaited by one or more callers of
+// LaunchContext. The abandoned channel is closed once every caller has given
+// up waiting.
+type sharedLaunch struct {
+	waiting   int
+	abandoned chan struct{}
+}
+
+// launchWaiters tracks the callers waiting on each shared launch.
+type launchWaiters struct {
+	mutex    sync.Mutex
+	launches map[string]*sharedLaunch
+}
+
+// join adds a caller to the shared launch of the chaincode, creating it if
+// there is none.
+func (w *launchWaiters) join(ccid string) *sharedLaunch {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.launches == nil {
+		w.launches = map[string]*sharedLaunch{}
+	}
+	s, ok := w.launches[ccid]
+	if !ok {
+		s = &sharedLaunch{abandoned: make(chan struct{})}
+		w.launches[ccid] = s
+	}
+	s.waiting++
+	return s
+}
+
+// leave removes a caller from the shared launch. When the last caller leaves
+// because it gave up waiting, the launch is abandoned.
+func (w *launchWaiters) leave(ccid string, s *sharedLaunch, gaveUp bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	s.waiting--
+	if s.waiting > 0 {
+		return
+	}
+	if w.launches[ccid] == s {
+		delete(w.launches, ccid)
+	}
+	if gaveUp {
+		close(s.abandoned)
+	}
+}
+
+// LaunchContext is Launch on behalf of a caller which may stop waiting by
+// cancelling ctx. Concurrent calls for a chaincode share a single launch,
+// which is abandoned, stopping any container it started, once every caller
+// waiting on it has cancelled. A launch started by another method is left to
+// complete.
+func (r *RuntimeLauncher) LaunchContext(ctx context.Context, ccid string, streamHandler extcc.StreamHandler) error {
+	return r.launchContext(ctx, "", ccid, streamHandler, false)
+}
+
+// LaunchContextOnChannel is LaunchContext on behalf of an invocation on
+// channelID, or an init if init is true, scheduled as LaunchOnChannel and
+// LaunchInitOnChannel would schedule it. A launch abandoned while waiting for
+// a slot from the Limiter gives up its place in the queue.
+func (r *RuntimeLauncher) LaunchContextOnChannel(ctx context.Context, channelID, ccid string, streamHandler extcc.StreamHandler, init bool) error {
+	return r.launchContext(ctx, channelID, ccid, streamHandler, init && r.PrioritizeInit)
+}
+
+func (r *RuntimeLauncher) launchContext(ctx context.Context, channelID, ccid string, streamHandler extcc.StreamHandler, priority bool) error {
+	shared := r.waiters.join(ccid)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := r.launch(shared.abandoned, channelID, ccid, streamHandler, false, priority)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		r.waiters.leave(ccid, shared, false)
+		return err
+	case <-ctx.Done():
+		r.waiters.leave(ccid, shared, true)
+		return errors.WithMessagef(ErrLaunchAborted, "failed to launch chaincode %s", ccid)
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/scc"
+)
+
+// A DependencyProvider declares which chaincodes a chaincode invokes so that
+// they are stopped after it on shutdown.
+type DependencyProvider interface {
+	// Dependencies returns the IDs of the chaincodes invoked by the chaincode
+	// with the given ID.
+	Dependencies(ccid string) []string
+}
+
+// Shutdown stops all registered chaincodes. A chaincode is stopped before the
+// chaincodes it depends on, as declared by ShutdownDependencies, and system
+// chaincodes, which any chaincode may invoke, are stopped last. Shutdown
+// stops waiting for the chaincodes to stop after timeout.
+func (cs *ChaincodeSupport) Shutdown(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, ccid := range cs.shutdownOrder(cs.HandlerRegistry.Registered()) {
+			cs.stop(ccid)
+		}
+	}()
+
+	select {
+	case <-time.After(timeout):
+		chaincodeLogger.Warning("timeout while stopping chaincodes")
+	case <-done:
+	}
+}
+
+// shutdownOrder orders the chaincodes so that no chaincode follows one which
+// it depends on. Chaincodes which are not ordered by their dependencies keep
+// the order of ccids, and dependency cycles are broken deterministically.
+func (cs *ChaincodeSupport) shutdownOrder(ccids []string) []string {
+	running := map[string]bool{}
+	var sysccids []string
+	for _, ccid := range ccids {
+		running[ccid] = true
+		if cs.isSysCCID(ccid) {
+			sysccids = append(sysccids, ccid)
+		}
+	}
+
+	dependencies := map[string][]string{}
+	for _, ccid := range ccids {
+		if cs.isSysCCID(ccid) {
+			continue
+		}
+		dependencies[ccid] = append(dependencies[ccid], sysccids...)
+		if cs.ShutdownDependencies == nil {
+			continue
+		}
+		for _, dependency := range cs.ShutdownDependencies.Dependencies(ccid) {
+			if running[dependency] && dependency != ccid {
+				dependencies[ccid] = append(dependencies[ccid], dependency)
+			}
+		}
+	}
+
+	var order []string
+	for remaining := ccids; len(remaining) > 0; {
+		dependedOn := map[string]bool{}
+		for _, ccid := range remaining {
+			for _, dependency := range dependencies[ccid] {
+				dependedOn[dependency] = true
+			}
+		}
+
+		var next, rest []string
+		for _, ccid := range remaining {
+			if dependedOn[ccid] {
+				rest = append(rest, ccid)
+			} else {
+				next = append(next, ccid)
+			}
+		}
+		if len(next) == 0 {
+			// only user chaincodes have dependencies, so the cycle is
+			// broken at the first of them
+			for i, ccid := range rest {
+				if !cs.isSysCCID(ccid) {
+					chaincodeLogger.Warningf("dependency cycle among chaincodes %s, stopping %s first", strings.Join(rest, ", "), ccid)
+					next = []string{ccid}
+					rest = append(rest[:i:i], rest[i+1:]...)
+					break
+				}
+			}
+		}
+
+		order = append(order, next...)
+		remaining = rest
+	}
+
+	return order
+}
+
+// stop stops a chaincode on shutdown. System chaincodes run in process and
+// only have their handler deregistered.
+func (cs *ChaincodeSupport) stop(ccid string) {
+	chaincodeLogger.Debugf("stopping chaincode %s on shutdown", ccid)
+
+	var err error
+	if cs.isSysCCID(ccid) {
+		err = cs.HandlerRegistry.Deregister(ccid)
+	} else {
+		err = cs.Stop(ccid)
+	}
+	if err != nil {
+		chaincodeLogger.Warningf("failed to stop chaincode %s: %s", ccid, err)
+	}
+}
+
+// isSysCCID reports whether ccid is the ID of a system chaincode.
+func (cs *ChaincodeSupport) isSysCCID(ccid string) bool {
+	name := strings.TrimSuffix(ccid, "."+scc.SysCCVersion)
+	return name != ccid && cs.BuiltinSCCs.IsSysCC(name)
+}
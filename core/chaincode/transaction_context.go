@@ -26,6 +26,10 @@ type TransactionContext struct {
 	CollectionStore      privdata.CollectionStore
 	IsInitTransaction    bool
 
+	// ContextValues holds the request-scoped values copied from the context
+	// of the invocation.
+	ContextValues map[ContextKey]interface{}
+
 	// tracks open iterators used for range queries
 	queryMutex          sync.Mutex
 	queryIteratorMap    map[string]commonledger.ResultsIterator
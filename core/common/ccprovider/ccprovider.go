@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package ccprovider
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -92,7 +93,7 @@ func isPrintable(name string) bool {
 
 // GetChaincodePackage returns the chaincode package from the file system
 func GetChaincodePackageFromPath(ccNameVersion string, ccInstallPath string) ([]byte, error) {
-	path := fmt.Sprintf("%s/%s", ccInstallPath, strings.ReplaceAll(ccNameVersion, ":", "."))
+	path := fmt.Sprintf("%s/%s", ccInstallPath, strings.ReplaceAll(ccNameVersion, ChaincodeIDSeparator, "."))
 	var ccbytes []byte
 	var err error
 	if ccbytes, err = os.ReadFile(path); err != nil {
@@ -219,7 +220,7 @@ func (cifs *CCInfoFSImpl) ListInstalledChaincodes(dir string, ls DirEnumerator,
 		ccName := f.Name()[:i]      // Everything before the separator
 		ccVersion := f.Name()[i+1:] // Everything after the separator
 
-		ccPackage, err := ccFromPath(ccName+":"+ccVersion, dir, cifs.GetHasher)
+		ccPackage, err := ccFromPath(ccName+ChaincodeIDSeparator+ccVersion, dir, cifs.GetHasher)
 		if err != nil {
 			ccproviderLogger.Warning("Failed obtaining chaincode information about", ccName, ccVersion, ":", err)
 			return nil, errors.Wrapf(err, "failed obtaining information about %s, version %s", ccName, ccVersion)
@@ -318,7 +319,7 @@ func GetInstalledChaincodes() (*pb.ChaincodeQueryResponse, error) {
 		if len(fileNameArray) == 2 {
 			ccname := fileNameArray[0]
 			ccversion := fileNameArray[1]
-			ccpack, err := GetChaincodeFromFS(ccname + ":" + ccversion)
+			ccpack, err := GetChaincodeFromFS(ccname + ChaincodeIDSeparator + ccversion)
 			if err != nil {
 				// either chaincode on filesystem has been tampered with or
 				// _lifecycle chaincode files exist in the chaincodes directory.
@@ -387,7 +388,22 @@ type ChaincodeData struct {
 
 // ChaincodeID is the name by which the chaincode will register itself.
 func (cd *ChaincodeData) ChaincodeID() string {
-	return cd.Name + ":" + cd.Version
+	return cd.Name + ChaincodeIDSeparator + cd.Version
+}
+
+// ChaincodeIDSeparator separates the name from the version in the ID of a
+// chaincode deployed with the legacy lifecycle.
+const ChaincodeIDSeparator = ":"
+
+// LegacyChaincodeID returns the ID of the named chaincode version deployed
+// with the legacy lifecycle. As the version may contain the separator, a name
+// containing it is rejected; the ID could otherwise be shared by a different
+// name and version, such as "a:b" at version "c" and "a" at version "b:c".
+func LegacyChaincodeID(name, version string) (string, error) {
+	if strings.Contains(name, ChaincodeIDSeparator) {
+		return "", errors.Errorf("chaincode name '%s' must not contain '%s'", name, ChaincodeIDSeparator)
+	}
+	return name + ChaincodeIDSeparator + version, nil
 }
 
 // implement functions needed from proto.Message for proto's mar/unmarshal functions
@@ -414,6 +430,30 @@ type TransactionParams struct {
 	CollectionStore      privdata.CollectionStore
 	IsInitTransaction    bool
 
+	// IsIdempotent marks an invocation which has no side effects, such as a
+	// query, and may therefore be safely retried. Chaincode-to-chaincode
+	// invocations on another channel, whose results are discarded, are
+	// marked idempotent.
+	IsIdempotent bool
+
 	// this is additional data passed to the chaincode
 	ProposalDecorations map[string][]byte
+
+	// CorrelationID, when set, identifies the invocation across systems. It
+	// is passed to the chaincode as a decoration and included in the peer's
+	// log lines for the invocation.
+	CorrelationID string
+
+	// RequiredVersion, when set, is the version of the chaincode definition
+	// the invocation must be served by. Invocations resolved to another
+	// version fail rather than execute. When configured to enforce proposal
+	// versions, the endorser sets it from the chaincode version named in the
+	// proposal header.
+	RequiredVersion string
+
+	// Context, when set, is the context of the request which caused the
+	// invocation. It carries request-scoped values, such as a tenant or
+	// request ID, which the peer may make available to the invocation, and
+	// when cancelled stops the invocation waiting on a chaincode launch.
+	Context context.Context
 }
@@ -179,3 +179,16 @@ func setupDirectoryStructure(t *testing.T) (string, map[string][]byte) {
 
 	return tmp, hashes
 }
+
+func TestLegacyChaincodeID(t *testing.T) {
+	ccid, err := ccprovider.LegacyChaincodeID("a", "b:c")
+	require.NoError(t, err)
+	require.Equal(t, "a:b:c", ccid)
+
+	// "a:b" at version "c" would otherwise share the ID of "a" at version "b:c"
+	_, err = ccprovider.LegacyChaincodeID("a:b", "c")
+	require.EqualError(t, err, "chaincode name 'a:b' must not contain ':'")
+
+	cd := &ccprovider.ChaincodeData{Name: "a", Version: "b:c"}
+	require.Equal(t, ccid, cd.ChaincodeID())
+}
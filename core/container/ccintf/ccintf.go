@@ -24,6 +24,15 @@ type ChaincodeStream interface {
 type PeerConnection struct {
 	Address   string
 	TLSConfig *TLSConfig
+
+	// NodeAffinity is a hint naming the runtime node the chaincode should
+	// be placed on. It is empty when the chaincode may run on any node.
+	NodeAffinity string
+
+	// Network names the runtime network the chaincode should attach to,
+	// for example to reach sidecars. It is empty when the runtime's default
+	// network should be used.
+	Network string
 }
 
 // TLSConfig is used to pass the TLS context into the chaincode launch
@@ -46,6 +46,25 @@ type Instance interface {
 	Wait() (int, error)
 }
 
+// An ImageDigester is an Instance which runs from an image and can report
+// the digest of that image.
+type ImageDigester interface {
+	ImageDigest() (string, error)
+}
+
+// A MemoryReporter is an Instance which can report the memory used by the
+// running chaincode. The bool is false when the memory usage is unavailable,
+// for example because the runtime does not collect it.
+type MemoryReporter interface {
+	MemoryUsage() (uint64, bool, error)
+}
+
+// A Prober is an Instance which can run a command inside the running
+// chaincode to check that it is ready.
+type Prober interface {
+	Probe(command []string, timeout time.Duration) error
+}
+
 type UninitializedInstance struct{}
 
 func (UninitializedInstance) Start(peerConnection *ccintf.PeerConnection) error {
@@ -154,6 +173,36 @@ func (r *Router) Wait(ccid string) (int, error) {
 	return r.getInstance(ccid).Wait()
 }
 
+// ImageDigest returns the digest of the image the chaincode instance runs
+// from. The digest is empty for instances which do not run from an image.
+func (r *Router) ImageDigest(ccid string) (string, error) {
+	if digester, ok := r.getInstance(ccid).(ImageDigester); ok {
+		return digester.ImageDigest()
+	}
+	return "", nil
+}
+
+// MemoryUsage returns the memory in bytes used by the chaincode instance. The
+// bool is false for instances which cannot report their memory usage.
+func (r *Router) MemoryUsage(ccid string) (uint64, bool, error) {
+	if reporter, ok := r.getInstance(ccid).(MemoryReporter); ok {
+		return reporter.MemoryUsage()
+	}
+	return 0, false, nil
+}
+
+// Probe runs the command in the chaincode instance and returns an error unless
+// it succeeds within the timeout. Instances which cannot run commands, such as
+// external chaincode servers, are not probed.
+func (r *Router) Probe(ccid string, command []string, timeout time.Duration) error {
+	prober, ok := r.getInstance(ccid).(Prober)
+	if !ok {
+		vmLogger.Debugw("chaincode instance cannot be probed", "ccid", ccid)
+		return nil
+	}
+	return prober.Probe(command, timeout)
+}
+
 func (r *Router) Shutdown(timeout time.Duration) {
 	var wg sync.WaitGroup
 	for ccid := range r.containers {
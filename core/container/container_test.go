@@ -9,6 +9,7 @@ package container_test
 import (
 	"bytes"
 	"io"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -20,6 +21,37 @@ import (
 	"github.com/pkg/errors"
 )
 
+type digestingInstance struct {
+	*mock.Instance
+	digest string
+}
+
+func (d *digestingInstance) ImageDigest() (string, error) {
+	return d.digest, nil
+}
+
+type reportingInstance struct {
+	*mock.Instance
+	usage     uint64
+	available bool
+}
+
+func (r *reportingInstance) MemoryUsage() (uint64, bool, error) {
+	return r.usage, r.available, nil
+}
+
+type probingInstance struct {
+	*mock.Instance
+	command []string
+	timeout time.Duration
+	err     error
+}
+
+func (p *probingInstance) Probe(command []string, timeout time.Duration) error {
+	p.command, p.timeout = command, timeout
+	return p.err
+}
+
 var _ = Describe("Router", func() {
 	var (
 		fakeDockerBuilder   *mock.DockerBuilder
@@ -235,6 +267,90 @@ var _ = Describe("Router", func() {
 			})
 		})
 
+		Describe("ImageDigest", func() {
+			It("returns an empty digest for instances which do not run from an image", func() {
+				digest, err := router.ImageDigest("fake-id")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(digest).To(BeEmpty())
+			})
+
+			Context("when the instance runs from an image", func() {
+				BeforeEach(func() {
+					fakeExternalBuilder.BuildReturns(&digestingInstance{Instance: fakeInstance, digest: "sha256:digest"}, nil)
+					err := router.Build("digest-id")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns the digest of the image", func() {
+					digest, err := router.ImageDigest("digest-id")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(digest).To(Equal("sha256:digest"))
+				})
+			})
+		})
+
+		Describe("MemoryUsage", func() {
+			It("reports instances which cannot report their memory usage", func() {
+				_, ok, err := router.MemoryUsage("fake-id")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+
+			Context("when the instance reports its memory usage", func() {
+				BeforeEach(func() {
+					fakeExternalBuilder.BuildReturns(&reportingInstance{Instance: fakeInstance, usage: 4096, available: true}, nil)
+					err := router.Build("reporting-id")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns the memory usage of the instance", func() {
+					usage, ok, err := router.MemoryUsage("reporting-id")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ok).To(BeTrue())
+					Expect(usage).To(Equal(uint64(4096)))
+				})
+			})
+
+			Context("when the memory usage of the instance is unavailable", func() {
+				BeforeEach(func() {
+					fakeExternalBuilder.BuildReturns(&reportingInstance{Instance: fakeInstance}, nil)
+					err := router.Build("reporting-id")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("reports that the memory usage cannot be determined", func() {
+					_, ok, err := router.MemoryUsage("reporting-id")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ok).To(BeFalse())
+				})
+			})
+		})
+
+		Describe("Probe", func() {
+			It("does not probe instances which cannot be probed", func() {
+				err := router.Probe("fake-id", []string{"ready"}, time.Second)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			Context("when the instance can be probed", func() {
+				var prober *probingInstance
+
+				BeforeEach(func() {
+					prober = &probingInstance{Instance: fakeInstance, err: errors.New("not-ready")}
+					fakeExternalBuilder.BuildReturns(prober, nil)
+					err := router.Build("probing-id")
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("runs the probe on the instance", func() {
+					err := router.Probe("probing-id", []string{"ready"}, time.Second)
+					Expect(err).To(MatchError("not-ready"))
+					Expect(prober.command).To(Equal([]string{"ready"}))
+					Expect(prober.timeout).To(Equal(time.Second))
+				})
+			})
+		})
+
 		Describe("Wait", func() {
 			BeforeEach(func() {
 				fakeInstance.WaitReturns(7, errors.New("fake-wait-error"))
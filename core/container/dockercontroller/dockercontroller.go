@@ -70,9 +70,21 @@ type dockerClient interface {
 	WaitContainer(containerID string) (int, error)
 	// InspectImage returns an image by its name or ID.
 	InspectImage(imageName string) (*docker.Image, error)
+	// Stats sends container statistics to the channel in the options and
+	// closes it when done.
+	Stats(opts docker.StatsOptions) error
+	// CreateExec sets up a command to be run in a running container.
+	CreateExec(opts docker.CreateExecOptions) (*docker.Exec, error)
+	// StartExec runs a command set up by CreateExec, returning once it
+	// completes.
+	StartExec(id string, opts docker.StartExecOptions) error
+	// InspectExec returns the state of a command set up by CreateExec,
+	// including its exit code.
+	InspectExec(id string) (*docker.ExecInspect, error)
 }
 
 type PlatformBuilder interface {
+	ValidatePlatform(ccType string) error
 	GenerateDockerBuild(ccType, path string, codePackage io.Reader) (io.Reader, error)
 }
 
@@ -98,6 +110,18 @@ func (ci *ContainerInstance) Wait() (int, error) {
 	return ci.DockerVM.Wait(ci.CCID)
 }
 
+func (ci *ContainerInstance) ImageDigest() (string, error) {
+	return ci.DockerVM.ImageDigest(ci.CCID)
+}
+
+func (ci *ContainerInstance) MemoryUsage() (uint64, bool, error) {
+	return ci.DockerVM.MemoryUsage(ci.CCID)
+}
+
+func (ci *ContainerInstance) Probe(command []string, timeout time.Duration) error {
+	return ci.DockerVM.Probe(ci.CCID, command, timeout)
+}
+
 // DockerVM is a vm. It is identified by an image id
 type DockerVM struct {
 	PeerID          string
@@ -122,9 +146,20 @@ func (vm *DockerVM) HealthCheck(ctx context.Context) error {
 	return nil
 }
 
-func (vm *DockerVM) createContainer(imageID, containerID string, args, env []string) error {
+func (vm *DockerVM) createContainer(imageID, containerID string, args, env []string, network string) error {
 	logger := dockerLogger.With("imageID", imageID, "containerID", containerID)
 	logger.Debugw("create container")
+
+	hostConfig := vm.HostConfig
+	if network != "" {
+		hc := docker.HostConfig{}
+		if vm.HostConfig != nil {
+			hc = *vm.HostConfig
+		}
+		hc.NetworkMode = network
+		hostConfig = &hc
+	}
+
 	_, err := vm.Client.CreateContainer(docker.CreateContainerOptions{
 		Name: containerID,
 		Config: &docker.Config{
@@ -134,7 +169,7 @@ func (vm *DockerVM) createContainer(imageID, containerID string, args, env []str
 			AttachStdout: vm.AttachStdOut,
 			AttachStderr: vm.AttachStdOut,
 		},
-		HostConfig: vm.HostConfig,
+		HostConfig: hostConfig,
 	})
 	if err != nil {
 		return err
@@ -188,6 +223,12 @@ func (vm *DockerVM) Build(ccid string, metadata *persistence.ChaincodePackageMet
 	// lifecycle tools seem to allow type to be set lower case.
 	ccType := strings.ToUpper(metadata.Type)
 
+	// Build runs both when the chaincode is installed and when it is
+	// launched, so the platform is checked even if the image already exists.
+	if err := vm.PlatformBuilder.ValidatePlatform(ccType); err != nil {
+		return nil, errors.Wrap(err, "platform validation failed")
+	}
+
 	_, err = vm.Client.InspectImage(imageName)
 	switch err {
 	case docker.ErrNoSuchImage:
@@ -275,6 +316,21 @@ func (vm *DockerVM) GetEnv(ccid string, tlsConfig *ccintf.TLSConfig) []string {
 	return envs
 }
 
+// ImageDigest returns the ID of the docker image for the chaincode, which is
+// the digest of the image configuration.
+func (vm *DockerVM) ImageDigest(ccid string) (string, error) {
+	imageName, err := vm.GetVMNameForDocker(ccid)
+	if err != nil {
+		return "", err
+	}
+
+	image, err := vm.Client.InspectImage(imageName)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to inspect image %s", imageName)
+	}
+	return image.ID, nil
+}
+
 // Start starts a container using a previously created docker image
 func (vm *DockerVM) Start(ccid string, ccType string, peerConnection *ccintf.PeerConnection) error {
 	imageName, err := vm.GetVMNameForDocker(ccid)
@@ -296,7 +352,7 @@ func (vm *DockerVM) Start(ccid string, ccType string, peerConnection *ccintf.Pee
 	env := vm.GetEnv(ccid, peerConnection.TLSConfig)
 	dockerLogger.Debugf("start container with env:\n\t%s", strings.Join(env, "\n\t"))
 
-	err = vm.createContainer(imageName, containerName, args, env)
+	err = vm.createContainer(imageName, containerName, args, env, peerConnection.Network)
 	if err != nil {
 		logger.Errorf("create container failed: %s", err)
 		return err
@@ -444,6 +500,79 @@ func (vm *DockerVM) Stop(ccid string) error {
 	return vm.stopInternal(id)
 }
 
+// MemoryUsage returns the working set in bytes of the chaincode container:
+// its memory usage less the inactive file cache, as reported under both
+// cgroup v1 and v2. The bool is false when docker reports no memory
+// statistics for the container. Docker samples the statistics before
+// replying, so the call takes on the order of a second.
+func (vm *DockerVM) MemoryUsage(ccid string) (uint64, bool, error) {
+	id := vm.ccidToContainerID(ccid)
+	statsCh := make(chan *docker.Stats, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- vm.Client.Stats(docker.StatsOptions{ID: id, Stats: statsCh})
+	}()
+
+	var last *docker.Stats
+	for stats := range statsCh {
+		last = stats
+	}
+	if err := <-errCh; err != nil {
+		return 0, false, errors.Wrapf(err, "failed to get stats of container %s", id)
+	}
+	if last == nil || last.MemoryStats.Usage == 0 {
+		return 0, false, nil
+	}
+
+	// cgroup v1 reports total_inactive_file, cgroup v2 inactive_file
+	inactive := last.MemoryStats.Stats.TotalInactiveFile
+	if inactive == 0 {
+		inactive = last.MemoryStats.Stats.InactiveFile
+	}
+	if inactive > last.MemoryStats.Usage {
+		return 0, true, nil
+	}
+	return last.MemoryStats.Usage - inactive, true, nil
+}
+
+// Probe runs the command in the chaincode container and returns an error
+// unless the command exits with status 0 within the timeout.
+func (vm *DockerVM) Probe(ccid string, command []string, timeout time.Duration) error {
+	id := vm.ccidToContainerID(ccid)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	exec, err := vm.Client.CreateExec(docker.CreateExecOptions{
+		Container:    id,
+		Cmd:          command,
+		AttachStdout: true,
+		AttachStderr: true,
+		Context:      ctx,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to create probe in container %s", id)
+	}
+
+	output := &bytes.Buffer{}
+	err = vm.Client.StartExec(exec.ID, docker.StartExecOptions{
+		OutputStream: output,
+		ErrorStream:  output,
+		Context:      ctx,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to run probe in container %s", id)
+	}
+
+	inspect, err := vm.Client.InspectExec(exec.ID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to inspect probe in container %s", id)
+	}
+	if inspect.ExitCode != 0 {
+		return errors.Errorf("probe in container %s exited with %d: %s", id, inspect.ExitCode, strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
 // Wait blocks until the container stops and returns the exit code of the container.
 func (vm *DockerVM) Wait(ccid string) (int, error) {
 	id := vm.ccidToContainerID(ccid)
@@ -201,6 +201,30 @@ func Test_Start(t *testing.T) {
 	gt.Expect(err).NotTo(HaveOccurred())
 }
 
+func TestStartWithNetwork(t *testing.T) {
+	gt := NewGomegaWithT(t)
+	dockerClient := &mock.DockerClient{}
+	dockerClient.CreateContainerReturns(&docker.Container{}, nil)
+	dvm := DockerVM{
+		BuildMetrics: NewBuildMetrics(&disabled.Provider{}),
+		Client:       dockerClient,
+		HostConfig:   &docker.HostConfig{NetworkMode: "host", Memory: 1024},
+	}
+
+	err := dvm.Start("simple:1.0", "GOLANG", &ccintf.PeerConnection{Address: "peer-address", Network: "sidecar-net"})
+	gt.Expect(err).NotTo(HaveOccurred())
+	gt.Expect(dockerClient.CreateContainerCallCount()).To(Equal(1))
+	opts := dockerClient.CreateContainerArgsForCall(0)
+	gt.Expect(opts.HostConfig.NetworkMode).To(Equal("sidecar-net"))
+	gt.Expect(opts.HostConfig.Memory).To(Equal(int64(1024)))
+	gt.Expect(dvm.HostConfig.NetworkMode).To(Equal("host"))
+
+	err = dvm.Start("simple:1.0", "GOLANG", &ccintf.PeerConnection{Address: "peer-address"})
+	gt.Expect(err).NotTo(HaveOccurred())
+	opts = dockerClient.CreateContainerArgsForCall(1)
+	gt.Expect(opts.HostConfig).To(BeIdenticalTo(dvm.HostConfig))
+}
+
 func Test_streamOutput(t *testing.T) {
 	gt := NewGomegaWithT(t)
 
@@ -300,6 +324,121 @@ func Test_Wait(t *testing.T) {
 	require.EqualError(t, err, "no-wait-for-you")
 }
 
+func TestImageDigest(t *testing.T) {
+	dvm := DockerVM{}
+
+	// happy path
+	client := &mock.DockerClient{}
+	dvm.Client = client
+
+	client.InspectImageReturns(&docker.Image{ID: "sha256:0123456789abcdef"}, nil)
+	digest, err := dvm.ImageDigest("the-name:the-version")
+	require.NoError(t, err)
+	require.Equal(t, "sha256:0123456789abcdef", digest)
+	imageName, err := dvm.GetVMNameForDocker("the-name:the-version")
+	require.NoError(t, err)
+	require.Equal(t, imageName, client.InspectImageArgsForCall(0))
+
+	// inspect fails
+	client.InspectImageReturns(nil, errors.New("no-image-for-you"))
+	_, err = dvm.ImageDigest("the-name:the-version")
+	require.EqualError(t, err, "failed to inspect image "+imageName+": no-image-for-you")
+}
+
+func TestMemoryUsage(t *testing.T) {
+	client := &mock.DockerClient{}
+	dvm := DockerVM{Client: client}
+
+	// cgroup v1
+	client.StatsStub = func(opts docker.StatsOptions) error {
+		stats := &docker.Stats{}
+		stats.MemoryStats.Usage = 12288
+		stats.MemoryStats.Stats.TotalInactiveFile = 4096
+		stats.MemoryStats.Stats.Rss = 8192
+		opts.Stats <- stats
+		close(opts.Stats)
+		return nil
+	}
+	usage, ok, err := dvm.MemoryUsage("the-name:the-version")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(8192), usage)
+	require.Equal(t, dvm.ccidToContainerID("the-name:the-version"), client.StatsArgsForCall(0).ID)
+	require.False(t, client.StatsArgsForCall(0).Stream)
+
+	// cgroup v2 reports no rss
+	client.StatsStub = func(opts docker.StatsOptions) error {
+		stats := &docker.Stats{}
+		stats.MemoryStats.Usage = 12288
+		stats.MemoryStats.Stats.InactiveFile = 2048
+		opts.Stats <- stats
+		close(opts.Stats)
+		return nil
+	}
+	usage, ok, err = dvm.MemoryUsage("the-name:the-version")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(10240), usage)
+
+	// no memory statistics
+	client.StatsStub = func(opts docker.StatsOptions) error {
+		opts.Stats <- &docker.Stats{}
+		close(opts.Stats)
+		return nil
+	}
+	_, ok, err = dvm.MemoryUsage("the-name:the-version")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// stats fail
+	client.StatsStub = func(opts docker.StatsOptions) error {
+		close(opts.Stats)
+		return errors.New("no-stats-for-you")
+	}
+	_, _, err = dvm.MemoryUsage("the-name:the-version")
+	require.EqualError(t, err, "failed to get stats of container "+dvm.ccidToContainerID("the-name:the-version")+": no-stats-for-you")
+}
+
+func TestProbe(t *testing.T) {
+	client := &mock.DockerClient{}
+	dvm := DockerVM{Client: client}
+	containerID := dvm.ccidToContainerID("the-name:the-version")
+
+	// happy path
+	client.CreateExecReturns(&docker.Exec{ID: "exec-id"}, nil)
+	client.InspectExecReturns(&docker.ExecInspect{ExitCode: 0}, nil)
+	err := dvm.Probe("the-name:the-version", []string{"/bin/ready"}, time.Second)
+	require.NoError(t, err)
+	createOpts := client.CreateExecArgsForCall(0)
+	require.Equal(t, containerID, createOpts.Container)
+	require.Equal(t, []string{"/bin/ready"}, createOpts.Cmd)
+	_, deadlineSet := createOpts.Context.Deadline()
+	require.True(t, deadlineSet)
+	execID, _ := client.StartExecArgsForCall(0)
+	require.Equal(t, "exec-id", execID)
+	require.Equal(t, "exec-id", client.InspectExecArgsForCall(0))
+
+	// probe exits with a failure
+	client.StartExecStub = func(id string, opts docker.StartExecOptions) error {
+		opts.ErrorStream.Write([]byte("not ready\n"))
+		return nil
+	}
+	client.InspectExecReturns(&docker.ExecInspect{ExitCode: 1}, nil)
+	err = dvm.Probe("the-name:the-version", []string{"/bin/ready"}, time.Second)
+	require.EqualError(t, err, "probe in container "+containerID+" exited with 1: not ready")
+
+	// probe cannot be run
+	client.StartExecStub = nil
+	client.StartExecReturns(errors.New("context deadline exceeded"))
+	err = dvm.Probe("the-name:the-version", []string{"/bin/ready"}, time.Second)
+	require.EqualError(t, err, "failed to run probe in container "+containerID+": context deadline exceeded")
+
+	// probe cannot be created
+	client.CreateExecReturns(nil, errors.New("no-such-container"))
+	err = dvm.Probe("the-name:the-version", []string{"/bin/ready"}, time.Second)
+	require.EqualError(t, err, "failed to create probe in container "+containerID+": no-such-container")
+}
+
 func TestHealthCheck(t *testing.T) {
 	client := &mock.DockerClient{}
 	vm := &DockerVM{Client: client}
@@ -449,7 +588,7 @@ func TestBuild(t *testing.T) {
 		client := &mock.DockerClient{}
 		client.InspectImageReturns(nil, errors.New("inspecting-image-fails"))
 
-		dvm := &DockerVM{Client: client, BuildMetrics: buildMetrics}
+		dvm := &DockerVM{Client: client, BuildMetrics: buildMetrics, PlatformBuilder: &mock.PlatformBuilder{}}
 		_, err := dvm.Build("chaincode-name:chaincode-version", md, bytes.NewBuffer([]byte("code-package")))
 		require.EqualError(t, err, "docker image inspection failed: inspecting-image-fails")
 
@@ -459,13 +598,29 @@ func TestBuild(t *testing.T) {
 	t.Run("when the image exists", func(t *testing.T) {
 		client := &mock.DockerClient{}
 
-		dvm := &DockerVM{Client: client, BuildMetrics: buildMetrics}
+		dvm := &DockerVM{Client: client, BuildMetrics: buildMetrics, PlatformBuilder: &mock.PlatformBuilder{}}
 		_, err := dvm.Build("chaincode-name:chaincode-version", md, bytes.NewBuffer([]byte("code-package")))
 		require.NoError(t, err)
 
 		require.Equal(t, 0, client.BuildImageCallCount())
 	})
 
+	t.Run("when the platform is not supported", func(t *testing.T) {
+		client := &mock.DockerClient{}
+
+		fakePlatformBuilder := &mock.PlatformBuilder{}
+		fakePlatformBuilder.ValidatePlatformReturns(errors.New("Unknown chaincodeType: TYPE"))
+
+		dvm := &DockerVM{Client: client, BuildMetrics: buildMetrics, PlatformBuilder: fakePlatformBuilder}
+		_, err := dvm.Build("chaincode-name:chaincode-version", md, bytes.NewBuffer([]byte("code-package")))
+		require.EqualError(t, err, "platform validation failed: Unknown chaincodeType: TYPE")
+
+		require.Equal(t, 1, fakePlatformBuilder.ValidatePlatformCallCount())
+		require.Equal(t, "TYPE", fakePlatformBuilder.ValidatePlatformArgsForCall(0))
+		require.Equal(t, 0, client.InspectImageCallCount())
+		require.Equal(t, 0, fakePlatformBuilder.GenerateDockerBuildCallCount())
+	})
+
 	t.Run("when the platform builder fails", func(t *testing.T) {
 		client := &mock.DockerClient{}
 		client.InspectImageReturns(nil, docker.ErrNoSuchImage)
@@ -44,6 +44,32 @@ type DockerClient struct {
 		result1 *docker.Container
 		result2 error
 	}
+	CreateExecStub        func(docker.CreateExecOptions) (*docker.Exec, error)
+	createExecMutex       sync.RWMutex
+	createExecArgsForCall []struct {
+		arg1 docker.CreateExecOptions
+	}
+	createExecReturns struct {
+		result1 *docker.Exec
+		result2 error
+	}
+	createExecReturnsOnCall map[int]struct {
+		result1 *docker.Exec
+		result2 error
+	}
+	InspectExecStub        func(string) (*docker.ExecInspect, error)
+	inspectExecMutex       sync.RWMutex
+	inspectExecArgsForCall []struct {
+		arg1 string
+	}
+	inspectExecReturns struct {
+		result1 *docker.ExecInspect
+		result2 error
+	}
+	inspectExecReturnsOnCall map[int]struct {
+		result1 *docker.ExecInspect
+		result2 error
+	}
 	InspectImageStub        func(string) (*docker.Image, error)
 	inspectImageMutex       sync.RWMutex
 	inspectImageArgsForCall []struct {
@@ -102,6 +128,29 @@ type DockerClient struct {
 	startContainerReturnsOnCall map[int]struct {
 		result1 error
 	}
+	StartExecStub        func(string, docker.StartExecOptions) error
+	startExecMutex       sync.RWMutex
+	startExecArgsForCall []struct {
+		arg1 string
+		arg2 docker.StartExecOptions
+	}
+	startExecReturns struct {
+		result1 error
+	}
+	startExecReturnsOnCall map[int]struct {
+		result1 error
+	}
+	StatsStub        func(docker.StatsOptions) error
+	statsMutex       sync.RWMutex
+	statsArgsForCall []struct {
+		arg1 docker.StatsOptions
+	}
+	statsReturns struct {
+		result1 error
+	}
+	statsReturnsOnCall map[int]struct {
+		result1 error
+	}
 	StopContainerStub        func(string, uint) error
 	stopContainerMutex       sync.RWMutex
 	stopContainerArgsForCall []struct {
@@ -149,15 +198,16 @@ func (fake *DockerClient) AttachToContainer(arg1 docker.AttachToContainerOptions
 	fake.attachToContainerArgsForCall = append(fake.attachToContainerArgsForCall, struct {
 		arg1 docker.AttachToContainerOptions
 	}{arg1})
+	stub := fake.AttachToContainerStub
+	fakeReturns := fake.attachToContainerReturns
 	fake.recordInvocation("AttachToContainer", []interface{}{arg1})
 	fake.attachToContainerMutex.Unlock()
-	if fake.AttachToContainerStub != nil {
-		return fake.AttachToContainerStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.attachToContainerReturns
 	return fakeReturns.result1
 }
 
@@ -209,15 +259,16 @@ func (fake *DockerClient) BuildImage(arg1 docker.BuildImageOptions) error {
 	fake.buildImageArgsForCall = append(fake.buildImageArgsForCall, struct {
 		arg1 docker.BuildImageOptions
 	}{arg1})
+	stub := fake.BuildImageStub
+	fakeReturns := fake.buildImageReturns
 	fake.recordInvocation("BuildImage", []interface{}{arg1})
 	fake.buildImageMutex.Unlock()
-	if fake.BuildImageStub != nil {
-		return fake.BuildImageStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.buildImageReturns
 	return fakeReturns.result1
 }
 
@@ -269,15 +320,16 @@ func (fake *DockerClient) CreateContainer(arg1 docker.CreateContainerOptions) (*
 	fake.createContainerArgsForCall = append(fake.createContainerArgsForCall, struct {
 		arg1 docker.CreateContainerOptions
 	}{arg1})
+	stub := fake.CreateContainerStub
+	fakeReturns := fake.createContainerReturns
 	fake.recordInvocation("CreateContainer", []interface{}{arg1})
 	fake.createContainerMutex.Unlock()
-	if fake.CreateContainerStub != nil {
-		return fake.CreateContainerStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.createContainerReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -326,21 +378,150 @@ func (fake *DockerClient) CreateContainerReturnsOnCall(i int, result1 *docker.Co
 	}{result1, result2}
 }
 
+func (fake *DockerClient) CreateExec(arg1 docker.CreateExecOptions) (*docker.Exec, error) {
+	fake.createExecMutex.Lock()
+	ret, specificReturn := fake.createExecReturnsOnCall[len(fake.createExecArgsForCall)]
+	fake.createExecArgsForCall = append(fake.createExecArgsForCall, struct {
+		arg1 docker.CreateExecOptions
+	}{arg1})
+	stub := fake.CreateExecStub
+	fakeReturns := fake.createExecReturns
+	fake.recordInvocation("CreateExec", []interface{}{arg1})
+	fake.createExecMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *DockerClient) CreateExecCallCount() int {
+	fake.createExecMutex.RLock()
+	defer fake.createExecMutex.RUnlock()
+	return len(fake.createExecArgsForCall)
+}
+
+func (fake *DockerClient) CreateExecCalls(stub func(docker.CreateExecOptions) (*docker.Exec, error)) {
+	fake.createExecMutex.Lock()
+	defer fake.createExecMutex.Unlock()
+	fake.CreateExecStub = stub
+}
+
+func (fake *DockerClient) CreateExecArgsForCall(i int) docker.CreateExecOptions {
+	fake.createExecMutex.RLock()
+	defer fake.createExecMutex.RUnlock()
+	argsForCall := fake.createExecArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *DockerClient) CreateExecReturns(result1 *docker.Exec, result2 error) {
+	fake.createExecMutex.Lock()
+	defer fake.createExecMutex.Unlock()
+	fake.CreateExecStub = nil
+	fake.createExecReturns = struct {
+		result1 *docker.Exec
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DockerClient) CreateExecReturnsOnCall(i int, result1 *docker.Exec, result2 error) {
+	fake.createExecMutex.Lock()
+	defer fake.createExecMutex.Unlock()
+	fake.CreateExecStub = nil
+	if fake.createExecReturnsOnCall == nil {
+		fake.createExecReturnsOnCall = make(map[int]struct {
+			result1 *docker.Exec
+			result2 error
+		})
+	}
+	fake.createExecReturnsOnCall[i] = struct {
+		result1 *docker.Exec
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DockerClient) InspectExec(arg1 string) (*docker.ExecInspect, error) {
+	fake.inspectExecMutex.Lock()
+	ret, specificReturn := fake.inspectExecReturnsOnCall[len(fake.inspectExecArgsForCall)]
+	fake.inspectExecArgsForCall = append(fake.inspectExecArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.InspectExecStub
+	fakeReturns := fake.inspectExecReturns
+	fake.recordInvocation("InspectExec", []interface{}{arg1})
+	fake.inspectExecMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *DockerClient) InspectExecCallCount() int {
+	fake.inspectExecMutex.RLock()
+	defer fake.inspectExecMutex.RUnlock()
+	return len(fake.inspectExecArgsForCall)
+}
+
+func (fake *DockerClient) InspectExecCalls(stub func(string) (*docker.ExecInspect, error)) {
+	fake.inspectExecMutex.Lock()
+	defer fake.inspectExecMutex.Unlock()
+	fake.InspectExecStub = stub
+}
+
+func (fake *DockerClient) InspectExecArgsForCall(i int) string {
+	fake.inspectExecMutex.RLock()
+	defer fake.inspectExecMutex.RUnlock()
+	argsForCall := fake.inspectExecArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *DockerClient) InspectExecReturns(result1 *docker.ExecInspect, result2 error) {
+	fake.inspectExecMutex.Lock()
+	defer fake.inspectExecMutex.Unlock()
+	fake.InspectExecStub = nil
+	fake.inspectExecReturns = struct {
+		result1 *docker.ExecInspect
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *DockerClient) InspectExecReturnsOnCall(i int, result1 *docker.ExecInspect, result2 error) {
+	fake.inspectExecMutex.Lock()
+	defer fake.inspectExecMutex.Unlock()
+	fake.InspectExecStub = nil
+	if fake.inspectExecReturnsOnCall == nil {
+		fake.inspectExecReturnsOnCall = make(map[int]struct {
+			result1 *docker.ExecInspect
+			result2 error
+		})
+	}
+	fake.inspectExecReturnsOnCall[i] = struct {
+		result1 *docker.ExecInspect
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *DockerClient) InspectImage(arg1 string) (*docker.Image, error) {
 	fake.inspectImageMutex.Lock()
 	ret, specificReturn := fake.inspectImageReturnsOnCall[len(fake.inspectImageArgsForCall)]
 	fake.inspectImageArgsForCall = append(fake.inspectImageArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.InspectImageStub
+	fakeReturns := fake.inspectImageReturns
 	fake.recordInvocation("InspectImage", []interface{}{arg1})
 	fake.inspectImageMutex.Unlock()
-	if fake.InspectImageStub != nil {
-		return fake.InspectImageStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.inspectImageReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -395,15 +576,16 @@ func (fake *DockerClient) KillContainer(arg1 docker.KillContainerOptions) error
 	fake.killContainerArgsForCall = append(fake.killContainerArgsForCall, struct {
 		arg1 docker.KillContainerOptions
 	}{arg1})
+	stub := fake.KillContainerStub
+	fakeReturns := fake.killContainerReturns
 	fake.recordInvocation("KillContainer", []interface{}{arg1})
 	fake.killContainerMutex.Unlock()
-	if fake.KillContainerStub != nil {
-		return fake.KillContainerStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.killContainerReturns
 	return fakeReturns.result1
 }
 
@@ -455,15 +637,16 @@ func (fake *DockerClient) PingWithContext(arg1 context.Context) error {
 	fake.pingWithContextArgsForCall = append(fake.pingWithContextArgsForCall, struct {
 		arg1 context.Context
 	}{arg1})
+	stub := fake.PingWithContextStub
+	fakeReturns := fake.pingWithContextReturns
 	fake.recordInvocation("PingWithContext", []interface{}{arg1})
 	fake.pingWithContextMutex.Unlock()
-	if fake.PingWithContextStub != nil {
-		return fake.PingWithContextStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.pingWithContextReturns
 	return fakeReturns.result1
 }
 
@@ -515,15 +698,16 @@ func (fake *DockerClient) RemoveContainer(arg1 docker.RemoveContainerOptions) er
 	fake.removeContainerArgsForCall = append(fake.removeContainerArgsForCall, struct {
 		arg1 docker.RemoveContainerOptions
 	}{arg1})
+	stub := fake.RemoveContainerStub
+	fakeReturns := fake.removeContainerReturns
 	fake.recordInvocation("RemoveContainer", []interface{}{arg1})
 	fake.removeContainerMutex.Unlock()
-	if fake.RemoveContainerStub != nil {
-		return fake.RemoveContainerStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.removeContainerReturns
 	return fakeReturns.result1
 }
 
@@ -576,15 +760,16 @@ func (fake *DockerClient) StartContainer(arg1 string, arg2 *docker.HostConfig) e
 		arg1 string
 		arg2 *docker.HostConfig
 	}{arg1, arg2})
+	stub := fake.StartContainerStub
+	fakeReturns := fake.startContainerReturns
 	fake.recordInvocation("StartContainer", []interface{}{arg1, arg2})
 	fake.startContainerMutex.Unlock()
-	if fake.StartContainerStub != nil {
-		return fake.StartContainerStub(arg1, arg2)
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.startContainerReturns
 	return fakeReturns.result1
 }
 
@@ -630,6 +815,129 @@ func (fake *DockerClient) StartContainerReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *DockerClient) StartExec(arg1 string, arg2 docker.StartExecOptions) error {
+	fake.startExecMutex.Lock()
+	ret, specificReturn := fake.startExecReturnsOnCall[len(fake.startExecArgsForCall)]
+	fake.startExecArgsForCall = append(fake.startExecArgsForCall, struct {
+		arg1 string
+		arg2 docker.StartExecOptions
+	}{arg1, arg2})
+	stub := fake.StartExecStub
+	fakeReturns := fake.startExecReturns
+	fake.recordInvocation("StartExec", []interface{}{arg1, arg2})
+	fake.startExecMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *DockerClient) StartExecCallCount() int {
+	fake.startExecMutex.RLock()
+	defer fake.startExecMutex.RUnlock()
+	return len(fake.startExecArgsForCall)
+}
+
+func (fake *DockerClient) StartExecCalls(stub func(string, docker.StartExecOptions) error) {
+	fake.startExecMutex.Lock()
+	defer fake.startExecMutex.Unlock()
+	fake.StartExecStub = stub
+}
+
+func (fake *DockerClient) StartExecArgsForCall(i int) (string, docker.StartExecOptions) {
+	fake.startExecMutex.RLock()
+	defer fake.startExecMutex.RUnlock()
+	argsForCall := fake.startExecArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *DockerClient) StartExecReturns(result1 error) {
+	fake.startExecMutex.Lock()
+	defer fake.startExecMutex.Unlock()
+	fake.StartExecStub = nil
+	fake.startExecReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DockerClient) StartExecReturnsOnCall(i int, result1 error) {
+	fake.startExecMutex.Lock()
+	defer fake.startExecMutex.Unlock()
+	fake.StartExecStub = nil
+	if fake.startExecReturnsOnCall == nil {
+		fake.startExecReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.startExecReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DockerClient) Stats(arg1 docker.StatsOptions) error {
+	fake.statsMutex.Lock()
+	ret, specificReturn := fake.statsReturnsOnCall[len(fake.statsArgsForCall)]
+	fake.statsArgsForCall = append(fake.statsArgsForCall, struct {
+		arg1 docker.StatsOptions
+	}{arg1})
+	stub := fake.StatsStub
+	fakeReturns := fake.statsReturns
+	fake.recordInvocation("Stats", []interface{}{arg1})
+	fake.statsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *DockerClient) StatsCallCount() int {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	return len(fake.statsArgsForCall)
+}
+
+func (fake *DockerClient) StatsCalls(stub func(docker.StatsOptions) error) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = stub
+}
+
+func (fake *DockerClient) StatsArgsForCall(i int) docker.StatsOptions {
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
+	argsForCall := fake.statsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *DockerClient) StatsReturns(result1 error) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = nil
+	fake.statsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *DockerClient) StatsReturnsOnCall(i int, result1 error) {
+	fake.statsMutex.Lock()
+	defer fake.statsMutex.Unlock()
+	fake.StatsStub = nil
+	if fake.statsReturnsOnCall == nil {
+		fake.statsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.statsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *DockerClient) StopContainer(arg1 string, arg2 uint) error {
 	fake.stopContainerMutex.Lock()
 	ret, specificReturn := fake.stopContainerReturnsOnCall[len(fake.stopContainerArgsForCall)]
@@ -637,15 +945,16 @@ func (fake *DockerClient) StopContainer(arg1 string, arg2 uint) error {
 		arg1 string
 		arg2 uint
 	}{arg1, arg2})
+	stub := fake.StopContainerStub
+	fakeReturns := fake.stopContainerReturns
 	fake.recordInvocation("StopContainer", []interface{}{arg1, arg2})
 	fake.stopContainerMutex.Unlock()
-	if fake.StopContainerStub != nil {
-		return fake.StopContainerStub(arg1, arg2)
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.stopContainerReturns
 	return fakeReturns.result1
 }
 
@@ -698,15 +1007,16 @@ func (fake *DockerClient) UploadToContainer(arg1 string, arg2 docker.UploadToCon
 		arg1 string
 		arg2 docker.UploadToContainerOptions
 	}{arg1, arg2})
+	stub := fake.UploadToContainerStub
+	fakeReturns := fake.uploadToContainerReturns
 	fake.recordInvocation("UploadToContainer", []interface{}{arg1, arg2})
 	fake.uploadToContainerMutex.Unlock()
-	if fake.UploadToContainerStub != nil {
-		return fake.UploadToContainerStub(arg1, arg2)
+	if stub != nil {
+		return stub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	fakeReturns := fake.uploadToContainerReturns
 	return fakeReturns.result1
 }
 
@@ -758,15 +1068,16 @@ func (fake *DockerClient) WaitContainer(arg1 string) (int, error) {
 	fake.waitContainerArgsForCall = append(fake.waitContainerArgsForCall, struct {
 		arg1 string
 	}{arg1})
+	stub := fake.WaitContainerStub
+	fakeReturns := fake.waitContainerReturns
 	fake.recordInvocation("WaitContainer", []interface{}{arg1})
 	fake.waitContainerMutex.Unlock()
-	if fake.WaitContainerStub != nil {
-		return fake.WaitContainerStub(arg1)
+	if stub != nil {
+		return stub(arg1)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.waitContainerReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -824,6 +1135,10 @@ func (fake *DockerClient) Invocations() map[string][][]interface{} {
 	defer fake.buildImageMutex.RUnlock()
 	fake.createContainerMutex.RLock()
 	defer fake.createContainerMutex.RUnlock()
+	fake.createExecMutex.RLock()
+	defer fake.createExecMutex.RUnlock()
+	fake.inspectExecMutex.RLock()
+	defer fake.inspectExecMutex.RUnlock()
 	fake.inspectImageMutex.RLock()
 	defer fake.inspectImageMutex.RUnlock()
 	fake.killContainerMutex.RLock()
@@ -834,6 +1149,10 @@ func (fake *DockerClient) Invocations() map[string][][]interface{} {
 	defer fake.removeContainerMutex.RUnlock()
 	fake.startContainerMutex.RLock()
 	defer fake.startContainerMutex.RUnlock()
+	fake.startExecMutex.RLock()
+	defer fake.startExecMutex.RUnlock()
+	fake.statsMutex.RLock()
+	defer fake.statsMutex.RUnlock()
 	fake.stopContainerMutex.RLock()
 	defer fake.stopContainerMutex.RUnlock()
 	fake.uploadToContainerMutex.RLock()
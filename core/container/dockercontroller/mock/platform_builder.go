@@ -22,6 +22,17 @@ type PlatformBuilder struct {
 		result1 io.Reader
 		result2 error
 	}
+	ValidatePlatformStub        func(string) error
+	validatePlatformMutex       sync.RWMutex
+	validatePlatformArgsForCall []struct {
+		arg1 string
+	}
+	validatePlatformReturns struct {
+		result1 error
+	}
+	validatePlatformReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -34,15 +45,16 @@ func (fake *PlatformBuilder) GenerateDockerBuild(arg1 string, arg2 string, arg3
 		arg2 string
 		arg3 io.Reader
 	}{arg1, arg2, arg3})
+	stub := fake.GenerateDockerBuildStub
+	fakeReturns := fake.generateDockerBuildReturns
 	fake.recordInvocation("GenerateDockerBuild", []interface{}{arg1, arg2, arg3})
 	fake.generateDockerBuildMutex.Unlock()
-	if fake.GenerateDockerBuildStub != nil {
-		return fake.GenerateDockerBuildStub(arg1, arg2, arg3)
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
 	}
-	fakeReturns := fake.generateDockerBuildReturns
 	return fakeReturns.result1, fakeReturns.result2
 }
 
@@ -91,11 +103,74 @@ func (fake *PlatformBuilder) GenerateDockerBuildReturnsOnCall(i int, result1 io.
 	}{result1, result2}
 }
 
+func (fake *PlatformBuilder) ValidatePlatform(arg1 string) error {
+	fake.validatePlatformMutex.Lock()
+	ret, specificReturn := fake.validatePlatformReturnsOnCall[len(fake.validatePlatformArgsForCall)]
+	fake.validatePlatformArgsForCall = append(fake.validatePlatformArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ValidatePlatformStub
+	fakeReturns := fake.validatePlatformReturns
+	fake.recordInvocation("ValidatePlatform", []interface{}{arg1})
+	fake.validatePlatformMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *PlatformBuilder) ValidatePlatformCallCount() int {
+	fake.validatePlatformMutex.RLock()
+	defer fake.validatePlatformMutex.RUnlock()
+	return len(fake.validatePlatformArgsForCall)
+}
+
+func (fake *PlatformBuilder) ValidatePlatformCalls(stub func(string) error) {
+	fake.validatePlatformMutex.Lock()
+	defer fake.validatePlatformMutex.Unlock()
+	fake.ValidatePlatformStub = stub
+}
+
+func (fake *PlatformBuilder) ValidatePlatformArgsForCall(i int) string {
+	fake.validatePlatformMutex.RLock()
+	defer fake.validatePlatformMutex.RUnlock()
+	argsForCall := fake.validatePlatformArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *PlatformBuilder) ValidatePlatformReturns(result1 error) {
+	fake.validatePlatformMutex.Lock()
+	defer fake.validatePlatformMutex.Unlock()
+	fake.ValidatePlatformStub = nil
+	fake.validatePlatformReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *PlatformBuilder) ValidatePlatformReturnsOnCall(i int, result1 error) {
+	fake.validatePlatformMutex.Lock()
+	defer fake.validatePlatformMutex.Unlock()
+	fake.ValidatePlatformStub = nil
+	if fake.validatePlatformReturnsOnCall == nil {
+		fake.validatePlatformReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.validatePlatformReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *PlatformBuilder) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.generateDockerBuildMutex.RLock()
 	defer fake.generateDockerBuildMutex.RUnlock()
+	fake.validatePlatformMutex.RLock()
+	defer fake.validatePlatformMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
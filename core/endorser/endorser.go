@@ -101,6 +101,11 @@ type Endorser struct {
 	Support                Support
 	PvtRWSetAssembler      PvtRWSetAssembler
 	Metrics                *Metrics
+
+	// EnforceProposalVersion requires a proposal which names a chaincode
+	// version to be served by the chaincode definition of that version.
+	// Proposals which name no version are not checked.
+	EnforceProposalVersion bool
 }
 
 // call specified chaincode (system or user)
@@ -347,7 +352,7 @@ func (e *Endorser) ProcessProposal(ctx context.Context, signedProp *pb.SignedPro
 		e.Metrics.ProposalDuration.With(meterLabels...).Observe(time.Since(startTime).Seconds())
 	}()
 
-	pResp, err := e.ProcessProposalSuccessfullyOrError(up)
+	pResp, err := e.ProcessProposalSuccessfullyOrError(ctx, up)
 	if err != nil {
 		endorserLogger.Warnw("Failed to invoke chaincode", "channel", up.ChannelHeader.ChannelId, "chaincode", up.ChaincodeName, "error", err.Error())
 		// Return a nil error since clients are expected to look at the ProposalResponse response status code (500) and message.
@@ -366,12 +371,16 @@ func (e *Endorser) ProcessProposal(ctx context.Context, signedProp *pb.SignedPro
 	return pResp, nil
 }
 
-func (e *Endorser) ProcessProposalSuccessfullyOrError(up *UnpackedProposal) (*pb.ProposalResponse, error) {
+func (e *Endorser) ProcessProposalSuccessfullyOrError(ctx context.Context, up *UnpackedProposal) (*pb.ProposalResponse, error) {
 	txParams := &ccprovider.TransactionParams{
 		ChannelID:  up.ChannelHeader.ChannelId,
 		TxID:       up.ChannelHeader.TxId,
 		SignedProp: up.SignedProposal,
 		Proposal:   up.Proposal,
+		Context:    ctx,
+	}
+	if e.EnforceProposalVersion {
+		txParams.RequiredVersion = up.ChaincodeVersion
 	}
 
 	logger := decorateLogger(endorserLogger, txParams)
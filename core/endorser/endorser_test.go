@@ -83,9 +83,10 @@ var _ = Describe("Endorser", func() {
 		fakeTxSimulator          *fake.TxSimulator
 		fakeHistoryQueryExecutor *fake.HistoryQueryExecutor
 
-		signedProposal *pb.SignedProposal
-		channelID      string
-		chaincodeName  string
+		signedProposal   *pb.SignedProposal
+		channelID        string
+		chaincodeName    string
+		chaincodeVersion string
 
 		chaincodeResponse *pb.Response
 		chaincodeEvent    *pb.ChaincodeEvent
@@ -134,6 +135,7 @@ var _ = Describe("Endorser", func() {
 
 		channelID = "channel-id"
 		chaincodeName = "chaincode-name"
+		chaincodeVersion = ""
 		chaincodeInput = &pb.ChaincodeInput{
 			Args: [][]byte{[]byte("arg1"), []byte("arg2"), []byte("arg3")},
 		}
@@ -216,7 +218,8 @@ var _ = Describe("Endorser", func() {
 						ChannelId: channelID,
 						Extension: protoutil.MarshalOrPanic(&pb.ChaincodeHeaderExtension{
 							ChaincodeId: &pb.ChaincodeID{
-								Name: chaincodeName,
+								Name:    chaincodeName,
+								Version: chaincodeVersion,
 							},
 						}),
 						TxId: "6f142589e4ef6a1e62c9c816e2074f70baa9f7cf67c2f0c287d4ef907d6d2015",
@@ -499,6 +502,35 @@ var _ = Describe("Endorser", func() {
 		Expect(proto.Equal(input, &pb.ChaincodeInput{
 			Args: [][]byte{[]byte("arg1"), []byte("arg2"), []byte("arg3")},
 		})).To(BeTrue())
+		Expect(txParams.RequiredVersion).To(BeEmpty())
+	})
+
+	Context("when the proposal names a chaincode version", func() {
+		BeforeEach(func() {
+			chaincodeVersion = "chaincode-version"
+		})
+
+		It("does not require the chaincode to be served at that version", func() {
+			_, err := e.ProcessProposal(context.Background(), signedProposal)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeSupport.ExecuteCallCount()).To(Equal(1))
+			txParams, _, _ := fakeSupport.ExecuteArgsForCall(0)
+			Expect(txParams.RequiredVersion).To(BeEmpty())
+		})
+
+		Context("when proposal versions are enforced", func() {
+			BeforeEach(func() {
+				e.EnforceProposalVersion = true
+			})
+
+			It("requires the chaincode to be served at that version", func() {
+				_, err := e.ProcessProposal(context.Background(), signedProposal)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeSupport.ExecuteCallCount()).To(Equal(1))
+				txParams, _, _ := fakeSupport.ExecuteArgsForCall(0)
+				Expect(txParams.RequiredVersion).To(Equal("chaincode-version"))
+			})
+		})
 	})
 
 	Context("when calling the chaincode returns an error", func() {
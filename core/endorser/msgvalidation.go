@@ -27,6 +27,10 @@ type UnpackedProposal struct {
 	SignatureHeader *common.SignatureHeader
 	SignedProposal  *peer.SignedProposal
 	ProposalHash    []byte
+
+	// ChaincodeVersion is the chaincode version named in the proposal
+	// header, if any. Unlike the other fields it may be empty.
+	ChaincodeVersion string
 }
 
 func (up *UnpackedProposal) ChannelID() string {
@@ -117,6 +121,8 @@ func UnpackProposal(signedProp *peer.SignedProposal) (*UnpackedProposal, error)
 		ChaincodeName:   chaincodeHdrExt.ChaincodeId.Name,
 		Input:           cis.ChaincodeSpec.Input,
 		ProposalHash:    propHash.Sum(nil)[:],
+
+		ChaincodeVersion: chaincodeHdrExt.ChaincodeId.Version,
 	}, nil
 }
 
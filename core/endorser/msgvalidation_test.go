@@ -77,7 +77,8 @@ var _ = Describe("UnpackProposal", func() {
 			}
 		*/
 		chaincodeID = &pb.ChaincodeID{
-			Name: "chaincode-name",
+			Name:    "chaincode-name",
+			Version: "chaincode-version",
 		}
 
 		chaincodeHeaderExtension = &pb.ChaincodeHeaderExtension{
@@ -156,6 +157,7 @@ var _ = Describe("UnpackProposal", func() {
 		up, err := endorser.UnpackProposal(signedProposal)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(up.ChaincodeName).To(Equal("chaincode-name"))
+		Expect(up.ChaincodeVersion).To(Equal("chaincode-version"))
 		Expect(up.SignedProposal).To(Equal(signedProposal))
 		Expect(proto.Equal(up.Proposal, proposal)).To(BeTrue())
 		Expect(proto.Equal(up.Input, chaincodeInput)).To(BeTrue())
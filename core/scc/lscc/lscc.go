@@ -299,7 +299,7 @@ func (lscc *SCC) ChaincodeEndorsementInfo(channelID, chaincodeName string, qe le
 	return &lifecycle.ChaincodeEndorsementInfo{
 		Version:           chaincodeData.Version,
 		EndorsementPlugin: chaincodeData.Escc,
-		ChaincodeID:       chaincodeData.Name + ":" + chaincodeData.Version,
+		ChaincodeID:       chaincodeData.ChaincodeID(),
 	}, nil
 }
 
@@ -709,7 +709,7 @@ func (lscc *SCC) executeInstall(stub shim.ChaincodeStubInterface, ccbytes []byte
 		return err
 	}
 
-	ccid := ccpack.GetChaincodeData().Name + ":" + ccpack.GetChaincodeData().Version
+	ccid := ccpack.GetChaincodeData().ChaincodeID()
 	buildStatus, building := lscc.BuildRegistry.BuildStatus(ccid)
 	if !building {
 		err := lscc.ChaincodeBuilder.Build(ccid)
@@ -780,7 +780,7 @@ func (lscc *SCC) executeDeployOrUpgrade(
 		return nil, err
 	}
 
-	chaincodeNameVersion := chaincodeName + ":" + chaincodeVersion
+	chaincodeNameVersion := chaincodeName + ccprovider.ChaincodeIDSeparator + chaincodeVersion
 
 	ccpack, err := lscc.Support.GetChaincodeFromLocalStorage(chaincodeNameVersion)
 	if err != nil {
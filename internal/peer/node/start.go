@@ -520,7 +520,9 @@ func serve(args []string) error {
 	if err != nil {
 		logger.Panic("Failed creating authentication layer:", err)
 	}
-	ccSrv, ccEndpoint, err := createChaincodeServer(coreConfig, ca, peerHost)
+	chaincodeConfig := chaincode.GlobalConfig()
+
+	ccSrv, ccEndpoint, err := createChaincodeServer(coreConfig, ca, peerHost, chaincodeConfig.TLSClientAuth)
 	if err != nil {
 		logger.Panicf("Failed to create chaincode server: %s", err)
 	}
@@ -533,6 +535,7 @@ func serve(args []string) error {
 	authenticator := accesscontrol.NewAuthenticator(ca)
 
 	chaincodeHandlerRegistry := chaincode.NewHandlerRegistry(userRunsCC)
+	chaincodeHandlerRegistry.ReplaceStaleHandlers = chaincodeConfig.ReplaceStaleHandlers
 	lifecycleTxQueryExecutorGetter := &chaincode.TxQueryExecutorGetter{
 		CCID:            scc.ChaincodeID(lifecycle.LifecycleNamespace),
 		HandlerRegistry: chaincodeHandlerRegistry,
@@ -542,8 +545,6 @@ func serve(args []string) error {
 		logger.Panic("VMEndpoint not set and no ExternalBuilders defined")
 	}
 
-	chaincodeConfig := chaincode.GlobalConfig()
-
 	var dockerBuilder container.DockerBuilder
 	if coreConfig.VMEndpoint != "" {
 		client, err := createDockerClient(coreConfig)
@@ -640,6 +641,13 @@ func serve(args []string) error {
 		BuildRegistry:   buildRegistry,
 		ContainerRouter: containerRouter,
 	}
+	if chaincodeConfig.StartRetries > 0 {
+		containerRuntime.StartBackoff = &chaincode.ExponentialStartBackoff{
+			Retries: chaincodeConfig.StartRetries,
+			Initial: chaincodeConfig.StartRetryBackoff,
+			Max:     chaincodeConfig.StartupTimeout,
+		}
+	}
 
 	lifecycleFunctions := &lifecycle.ExternalFunctions{
 		Resources:                 lifecycleResources,
@@ -661,15 +669,43 @@ func serve(args []string) error {
 		ACLProvider:            aclProvider,
 	}
 
+	var metricLabeler chaincode.MetricLabeler
+	if chaincodeConfig.ChannelMetricLabel {
+		metricLabeler = chaincode.ChannelMetricLabeler{}
+	}
+
 	chaincodeLauncher := &chaincode.RuntimeLauncher{
-		Metrics:           chaincode.NewLaunchMetrics(opsSystem.Provider),
-		Registry:          chaincodeHandlerRegistry,
-		Runtime:           containerRuntime,
-		StartupTimeout:    chaincodeConfig.StartupTimeout,
-		CertGenerator:     authenticator,
-		CACert:            ca.CertBytes(),
-		PeerAddress:       ccEndpoint,
-		ConnectionHandler: &extcc.ExternalChaincodeRuntime{},
+		Metrics:             chaincode.NewLabeledLaunchMetrics(opsSystem.Provider, metricLabeler),
+		Registry:            chaincodeHandlerRegistry,
+		Runtime:             containerRuntime,
+		StartupTimeout:      chaincodeConfig.StartupTimeout,
+		StallTimeout:        chaincodeConfig.LaunchStallTimeout,
+		RegistrationTimeout: chaincodeConfig.RegistrationTimeout,
+		Jitter:              chaincodeConfig.LaunchJitter,
+		ContainerNetwork:    chaincodeConfig.ContainerNetwork,
+		StartupProbe:        chaincodeConfig.StartupProbe,
+		PrioritizeInit:      chaincodeConfig.PrioritizeInitLaunches,
+		NotRunningPolicy:    chaincodeConfig.NotRunningPolicy,
+		CertGenerator:       authenticator,
+		CACert:              ca.CertBytes(),
+		PeerAddress:         ccEndpoint,
+		ConnectionHandler:   &extcc.ExternalChaincodeRuntime{},
+	}
+
+	if chaincodeConfig.MaxConcurrentLaunches > 0 {
+		chaincodeLauncher.Limiter = chaincode.NewLaunchLimiter(chaincodeConfig.MaxConcurrentLaunches)
+	}
+
+	if chaincodeConfig.TotalChaincodeMemoryLimitMB > 0 {
+		if containerMemory := getDockerHostConfig().Memory; containerMemory > 0 {
+			chaincodeLauncher.MemoryBudget = chaincode.NewMemoryBudget(uint64(chaincodeConfig.TotalChaincodeMemoryLimitMB)<<20, uint64(containerMemory))
+		} else {
+			logger.Warning("Ignoring chaincode.totalMemoryLimitMB as vm.docker.hostConfig.Memory does not limit chaincode containers")
+		}
+	}
+
+	if chaincodeConfig.LaunchFailureWebhook != "" {
+		chaincodeLauncher.FailureNotifier = &chaincode.WebhookNotifier{URL: chaincodeConfig.LaunchFailureWebhook}
 	}
 
 	// Keep TestQueries working
@@ -677,24 +713,84 @@ func serve(args []string) error {
 		chaincodeLauncher.CertGenerator = nil
 	}
 
+	chaincodeHandlerMetrics := chaincode.NewLabeledHandlerMetrics(opsSystem.Provider, metricLabeler)
+
 	chaincodeSupport := &chaincode.ChaincodeSupport{
 		ACLProvider:            aclProvider,
+		AllowedResponseTypes:   chaincodeConfig.AllowedResponseTypes,
 		AppConfig:              peerInstance,
+		AutoRestart:            chaincodeConfig.AutoRestart,
+		AutoRestartReset:       chaincodeConfig.AutoRestartReset,
+		ContextKeys:            chaincodeConfig.ContextKeys,
+		DefaultChannel:         chaincodeConfig.DefaultChannel,
+		DevModeWait:            chaincodeConfig.DevModeWait,
+		DiscardUnknownFields:   chaincodeConfig.DiscardUnknownFields,
 		DeployedCCInfoProvider: lifecycleValidatorCommitter,
+		ErrorFormat:            chaincodeConfig.ErrorFormat,
 		ExecuteTimeout:         chaincodeConfig.ExecuteTimeout,
+		GracefulStopTimeout:    chaincodeConfig.GracefulStopTimeout,
 		InstallTimeout:         chaincodeConfig.InstallTimeout,
 		HandlerRegistry:        chaincodeHandlerRegistry,
-		HandlerMetrics:         chaincode.NewHandlerMetrics(opsSystem.Provider),
+		HandlerMetrics:         chaincodeHandlerMetrics,
 		Keepalive:              chaincodeConfig.Keepalive,
+		KeepaliveDisabled:      chaincodeConfig.KeepaliveDisabled,
+		KeepaliveIdleOnly:      chaincodeConfig.KeepaliveIdleOnly,
+		KeepaliveMissThreshold: chaincodeConfig.KeepaliveMissThreshold,
+		KeepaliveRetries:       chaincodeConfig.KeepaliveRetries,
+		KeepaliveRetryBackoff:  chaincodeConfig.KeepaliveRetryBackoff,
 		Launcher:               chaincodeLauncher,
+		LaunchFailureMaxTTL:    chaincodeConfig.LaunchFailureMaxTTL,
+		LaunchFailureTTL:       chaincodeConfig.LaunchFailureTTL,
+		LaunchPolicy:           chaincodeConfig.LaunchPolicy,
+		LedgerGetter:           peerInstance,
 		Lifecycle:              chaincodeEndorsementInfo,
+		LifecycleLookupTimeout: chaincodeConfig.LifecycleLookupTimeout,
+		MaxInitInputBytes:      chaincodeConfig.MaxInitInputBytes,
+		MaxAutoRestarts:        chaincodeConfig.MaxAutoRestarts,
+		MaxInvokeInputBytes:    chaincodeConfig.MaxInvokeInputBytes,
+		MemoryAccounting:       chaincodeConfig.MemoryAccounting,
+		MessageTypes:           chaincodeConfig.MessageTypes,
+		MinContainerLifetime:   chaincodeConfig.MinContainerLifetime,
+		PayloadChecksums:       chaincodeConfig.PayloadChecksums,
+		ResponseChecksums:      chaincodeConfig.ResponseChecksums,
 		Peer:                   peerInstance,
+		ReceiveBufferSize:      chaincodeConfig.ReceiveBufferSize,
+		RetryOnTimeout:         chaincodeConfig.RetryOnTimeout,
 		Runtime:                containerRuntime,
+		SendBufferSize:         chaincodeConfig.SendBufferSize,
+		SendTimeout:            chaincodeConfig.SendTimeout,
+		SerialExecution:        chaincodeConfig.SerialExecution,
+		SoftConcurrencyLimit:   chaincodeConfig.SoftConcurrencyLimit,
+		StopGracePeriod:        chaincodeConfig.StopGracePeriod,
+		StopOnPanic:            chaincodeConfig.StopOnPanic,
+		StructuredErrors:       chaincodeConfig.StructuredErrors,
 		BuiltinSCCs:            builtinSCCs,
 		TotalQueryLimit:        chaincodeConfig.TotalQueryLimit,
 		UserRunsCC:             userRunsCC,
 	}
 
+	if len(chaincodeConfig.ChannelLimits) > 0 {
+		chaincodeSupport.ChannelLimiter = chaincode.NewChannelLimiter(
+			chaincodeConfig.ChannelLimits,
+			chaincodeConfig.ChannelLimitPolicy,
+			chaincodeHandlerMetrics.ChannelExecutionsInFlight,
+			chaincodeHandlerMetrics.ChannelExecuteRejections,
+		)
+	}
+	chaincodeSupport.RateLimiter = chaincode.NewRateLimiter(chaincodeConfig.RateLimits, chaincodeConfig.RateLimitPolicy)
+
+	if chaincodeConfig.MaxConcurrentExecutions > 0 {
+		executeLimiter := chaincode.NewExecuteLimiter(chaincodeConfig.MaxConcurrentExecutions, chaincodeHandlerMetrics.ExecuteQueueDepth)
+		executeLimiter.AlertThreshold = chaincodeConfig.ExecuteQueueAlertThreshold
+		executeLimiter.Alert = func(depth int) {
+			logger.Warningf("%d chaincode executions are waiting for the execution concurrency limit of %d", depth, chaincodeConfig.MaxConcurrentExecutions)
+		}
+		chaincodeSupport.ExecuteLimiter = executeLimiter
+	}
+	if chaincodeConfig.SystemExecutionReserve > 0 {
+		chaincodeSupport.SystemExecuteLimiter = chaincode.NewExecuteLimiter(chaincodeConfig.SystemExecutionReserve, chaincodeHandlerMetrics.SystemExecuteQueueDepth)
+	}
+
 	custodianLauncher := custodianLauncherAdapter{
 		launcher:      chaincodeLauncher,
 		streamHandler: chaincodeSupport,
@@ -702,7 +798,7 @@ func serve(args []string) error {
 	go chaincodeCustodian.Work(buildRegistry, containerRouter, custodianLauncher)
 
 	ccSupSrv := pb.ChaincodeSupportServer(chaincodeSupport)
-	if tlsEnabled {
+	if tlsEnabled && chaincodeConfig.TLSClientAuth.RequireClientCert() {
 		ccSupSrv = authenticator.Wrap(ccSupSrv)
 	}
 
@@ -716,11 +812,29 @@ func serve(args []string) error {
 	)
 	qsccInst := scc.SelfDescribingSysCC(qscc.New(aclProvider, peerInstance))
 
+	if chaincodeConfig.ResumeLaunches {
+		chaincodeSupport.LaunchIntents = &chaincode.FileLaunchIntentStore{
+			Path: filepath.Join(coreconfig.GetPath("peer.fileSystemPath"), "launchintents"),
+		}
+	}
+
 	pb.RegisterChaincodeSupportServer(ccSrv.Server(), ccSupSrv)
 
 	// start the chaincode specific gRPC listening service
 	go ccSrv.Start()
 
+	if chaincodeConfig.MemoryAccounting {
+		go chaincodeSupport.SampleMemoryEvery(chaincodeConfig.MemorySampleInterval, nil)
+	}
+
+	if chaincodeSupport.LaunchIntents != nil {
+		go func() {
+			if err := chaincodeSupport.ResumeLaunches(); err != nil {
+				logger.Warningf("Failed to resume interrupted chaincode launches: %s", err)
+			}
+		}()
+	}
+
 	logger.Debugf("Running peer")
 
 	libConf, err := library.LoadConfig()
@@ -759,6 +873,7 @@ func serve(args []string) error {
 		LocalMSP:               localMSP,
 		Support:                endorserSupport,
 		Metrics:                endorser.NewMetrics(metricsProvider),
+		EnforceProposalVersion: chaincodeConfig.EnforceProposalVersion,
 	}
 
 	// deploy system chaincodes
@@ -876,8 +991,8 @@ func serve(args []string) error {
 	}
 
 	handleSignals(addPlatformSignals(map[os.Signal]func(){
-		syscall.SIGINT:  func() { containerRouter.Shutdown(5 * time.Second); serve <- nil },
-		syscall.SIGTERM: func() { containerRouter.Shutdown(5 * time.Second); serve <- nil },
+		syscall.SIGINT:  func() { shutdownChaincodes(chaincodeSupport, containerRouter); serve <- nil },
+		syscall.SIGTERM: func() { shutdownChaincodes(chaincodeSupport, containerRouter); serve <- nil },
 	}))
 
 	logger.Infof("Started peer with ID=[%s], network ID=[%s], address=[%s]", coreConfig.PeerID, coreConfig.NetworkID, coreConfig.PeerAddress)
@@ -929,6 +1044,13 @@ func serve(args []string) error {
 	return <-serve
 }
 
+// shutdownChaincodes stops the registered chaincodes in dependency order
+// before stopping any containers which remain.
+func shutdownChaincodes(chaincodeSupport *chaincode.ChaincodeSupport, containerRouter *container.Router) {
+	chaincodeSupport.Shutdown(5 * time.Second)
+	containerRouter.Shutdown(5 * time.Second)
+}
+
 func handleSignals(handlers map[os.Signal]func()) {
 	var signals []os.Signal
 	for sig := range handlers {
@@ -1013,7 +1135,7 @@ func createDiscoveryService(
 }
 
 // create a CC listener using peer.chaincodeListenAddress (and if that's not set use peer.peerAddress)
-func createChaincodeServer(coreConfig *peer.Config, ca tlsgen.CA, peerHostname string) (srv *comm.GRPCServer, ccEndpoint string, err error) {
+func createChaincodeServer(coreConfig *peer.Config, ca tlsgen.CA, peerHostname string, clientAuth chaincode.TLSClientAuth) (srv *comm.GRPCServer, ccEndpoint string, err error) {
 	// before potentially setting chaincodeListenAddress, compute chaincode endpoint at first
 	ccEndpoint, err = computeChaincodeEndpoint(coreConfig.ChaincodeAddress, coreConfig.ChaincodeListenAddress, peerHostname)
 	if err != nil {
@@ -1051,24 +1173,7 @@ func createChaincodeServer(coreConfig *peer.Config, ca tlsgen.CA, peerHostname s
 
 	// Override TLS configuration if TLS is applicable
 	if config.SecOpts.UseTLS {
-		// Create a self-signed TLS certificate with a SAN that matches the computed chaincode endpoint
-		certKeyPair, err := ca.NewServerCertKeyPair(host)
-		if err != nil {
-			logger.Panicf("Failed generating TLS certificate for chaincode service: +%v", err)
-		}
-		config.SecOpts = comm.SecureOptions{
-			UseTLS: true,
-			// Require chaincode shim to authenticate itself
-			RequireClientCert: true,
-			// Trust only client certificates signed by ourselves
-			ClientRootCAs: [][]byte{ca.CertBytes()},
-			// Use our own self-signed TLS certificate and key
-			Certificate: certKeyPair.Cert,
-			Key:         certKeyPair.Key,
-			// No point in specifying server root CAs since this TLS config is only used for
-			// a gRPC server and not a client
-			ServerRootCAs: nil,
-		}
+		config.SecOpts = chaincodeServerSecOpts(ca, host, clientAuth)
 	}
 
 	// Chaincode keepalive options - static for now
@@ -1089,6 +1194,28 @@ func createChaincodeServer(coreConfig *peer.Config, ca tlsgen.CA, peerHostname s
 	return srv, ccEndpoint, nil
 }
 
+// chaincodeServerSecOpts creates the TLS configuration of the chaincode server.
+func chaincodeServerSecOpts(ca tlsgen.CA, host string, clientAuth chaincode.TLSClientAuth) comm.SecureOptions {
+	// Create a self-signed TLS certificate with a SAN that matches the computed chaincode endpoint
+	certKeyPair, err := ca.NewServerCertKeyPair(host)
+	if err != nil {
+		logger.Panicf("Failed generating TLS certificate for chaincode service: +%v", err)
+	}
+	return comm.SecureOptions{
+		UseTLS: true,
+		// Require chaincode shim to authenticate itself unless configured otherwise
+		RequireClientCert: clientAuth.RequireClientCert(),
+		// Trust only client certificates signed by ourselves
+		ClientRootCAs: [][]byte{ca.CertBytes()},
+		// Use our own self-signed TLS certificate and key
+		Certificate: certKeyPair.Cert,
+		Key:         certKeyPair.Key,
+		// No point in specifying server root CAs since this TLS config is only used for
+		// a gRPC server and not a client
+		ServerRootCAs: nil,
+	}
+}
+
 // computeChaincodeEndpoint will utilize chaincode address, chaincode listen
 // address (these two are from viper) and peer address to compute chaincode endpoint.
 // There could be following cases of computing chaincode endpoint:
@@ -8,14 +8,19 @@ package node
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric/common/crypto/tlsgen"
+	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/handlers/library"
 	"github.com/hyperledger/fabric/core/testutil"
 	"github.com/hyperledger/fabric/internal/peer/node/mock"
+	"github.com/hyperledger/fabric/internal/pkg/comm"
 	msptesttools "github.com/hyperledger/fabric/msp/mgmt/testtools"
 	"github.com/mitchellh/mapstructure"
 	. "github.com/onsi/gomega"
@@ -213,3 +218,38 @@ func TestResetLoop(t *testing.T) {
 	require.False(t, resetFilter.reject)
 	require.Equal(t, 4, peerLedger.GetBlockchainInfoCallCount())
 }
+
+func TestChaincodeServerClientAuth(t *testing.T) {
+	ca, err := tlsgen.NewCA()
+	require.NoError(t, err)
+
+	t.Run("RequireAndVerifyClientCert", func(t *testing.T) {
+		secOpts := chaincodeServerSecOpts(ca, "127.0.0.1", chaincode.RequireAndVerifyClientCert)
+		require.True(t, secOpts.RequireClientCert)
+
+		srv, err := comm.NewGRPCServer("127.0.0.1:0", comm.ServerConfig{SecOpts: secOpts})
+		require.NoError(t, err)
+		go srv.Start()
+		defer srv.Stop()
+
+		rootCAs := x509.NewCertPool()
+		require.True(t, rootCAs.AppendCertsFromPEM(ca.CertBytes()))
+		conn, err := tls.Dial("tcp", srv.Address(), &tls.Config{
+			RootCAs:    rootCAs,
+			ServerName: "127.0.0.1",
+		})
+		if err == nil {
+			// with TLS 1.3 the server reports the missing client certificate
+			// after the client side of the handshake has completed
+			defer conn.Close()
+			_, err = conn.Read(make([]byte, 1))
+		}
+		require.ErrorContains(t, err, "certificate required")
+	})
+
+	t.Run("NoClientCert", func(t *testing.T) {
+		secOpts := chaincodeServerSecOpts(ca, "127.0.0.1", chaincode.NoClientCert)
+		require.False(t, secOpts.RequireClientCert)
+		require.True(t, secOpts.UseTLS)
+	})
+}